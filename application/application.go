@@ -4,11 +4,14 @@ package application
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/MonikaPalova/currency-master/auth"
 	"github.com/MonikaPalova/currency-master/config"
 	. "github.com/MonikaPalova/currency-master/db"
 	"github.com/MonikaPalova/currency-master/handlers"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/stream"
 	"github.com/MonikaPalova/currency-master/svc"
 	"github.com/gorilla/mux"
 	"github.com/robfig/cron"
@@ -20,6 +23,12 @@ type Application struct {
 	svc    *svc.Service
 	config *config.App
 	auth   *mux.Router
+	admin  *mux.Router
+	// authentication of the auth routes, which registers the api key scopes they require
+	sessionAuth *auth.SessionAuth
+	// access rules of the auth routes by role and ownership
+	policy *auth.Policy
+	hub    *stream.Hub
 }
 
 // Application construtor
@@ -27,9 +36,11 @@ func New() Application {
 	var a Application
 	a.initDB()
 	a.config = config.NewApp()
-	a.svc = svc.NewSvc(a.db)
+	a.initSvc()
+	a.hub = stream.NewHub()
+	a.svc.ASvc.OnRefresh(a.hub.Publish)
 	a.setupHTTP()
-	a.triggerSessionsCleaner()
+	a.triggerAssetsRefresher()
 
 	return a
 }
@@ -44,8 +55,20 @@ func (a *Application) initDB() {
 	log.Println("Successful database connection!")
 }
 
+// Initializes application's services
+func (a *Application) initSvc() {
+	var err error
+	a.svc, err = svc.NewSvc(a.db)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
 // Starts application
 func (a Application) Start() error {
+	stopCleanup := a.svc.SSvc.StartCleanup()
+	defer stopCleanup()
+
 	log.Println("Starting server")
 	return http.ListenAndServe(a.config.Host+":"+a.config.Port, a.router)
 }
@@ -54,22 +77,56 @@ func (a *Application) setupHTTP() {
 	a.router = mux.NewRouter()
 
 	a.auth = a.router.NewRoute().Subrouter()
-	sessionAuth := auth.SessionAuth{Svc: a.svc.SSvc, Config: config.NewSession()}
-	a.auth.Use(sessionAuth.Middleware)
+	// signed requests need the key which encrypts the signing secrets of the api keys
+	var signingConfig *config.Signing
+	if c := config.NewSigning(); c.SecretKey != "" {
+		signingConfig = c
+	}
+	a.sessionAuth = &auth.SessionAuth{Svc: a.svc.SSvc, Keys: a.svc.KSvc, Config: config.NewSession(),
+		Signing: signingConfig, Nonces: auth.NewMemoryNonces(), Clock: a.svc.Clock}
+	a.policy = &auth.Policy{Roles: a.svc.USvc, Emails: a.svc.USvc, TwoFactor: a.svc.TfSvc}
+	a.auth.Use(a.sessionAuth.Middleware, a.policy.Middleware)
+
+	a.admin = a.router.PathPrefix(a.config.AdminApiV1).Subrouter()
+	adminAuth := auth.AdminAuth{Config: config.NewAdmin()}
+	a.admin.Use(adminAuth.Middleware)
 
 	a.setupAuthHandler()
+	a.setupOIDCHandler()
 	a.setupAssetsHandler()
 	a.setupUsersHandler()
+	a.setupAccountHandler()
+	a.setupTwoFactorHandler()
 	a.setupUserAssetsHandler()
 	a.setupAcquisitionsHandler()
+	a.setupRatesHandler()
+	a.setupStreamHandler()
+	a.setupAlertsHandler()
+	a.setupWatchlistsHandler()
+	a.setupMoversHandler()
+	a.setupAPIKeysHandler()
+	a.setupReplayHandler()
+	a.setupSettlementsHandler()
+	a.setupLockoutsHandler()
 }
 
 func (a *Application) setupAuthHandler() {
-	authHandler := handlers.AuthHandler{USvc: a.svc.USvc, SSvc: a.svc.SSvc}
+	authHandler := handlers.AuthHandler{USvc: a.svc.USvc, SSvc: a.svc.SSvc, TfSvc: a.svc.TfSvc, LSvc: a.svc.LSvc}
 	a.router.Path("/login").Methods(http.MethodPost).HandlerFunc(authHandler.Login)
+	a.router.Path("/login/2fa").Methods(http.MethodPost).HandlerFunc(authHandler.LoginSecondFactor)
 	a.auth.Path("/logout").Methods(http.MethodPost).HandlerFunc(authHandler.Logout)
 }
 
+// login with an OpenID Connect provider is available only when one is configured
+func (a *Application) setupOIDCHandler() {
+	if a.svc.OIDC == nil {
+		return
+	}
+	oidcHandler := handlers.OIDCHandler{Client: a.svc.OIDC, Svc: a.svc.IdSvc, Sessions: a.svc.SSvc, TwoFactor: a.svc.TfSvc}
+	a.router.Path("/login/oidc").Methods(http.MethodGet).HandlerFunc(oidcHandler.Login)
+	a.router.Path("/login/oidc/callback").Methods(http.MethodGet).HandlerFunc(oidcHandler.Callback)
+}
+
 func (a *Application) setupAssetsHandler() {
 	assetsHandler := handlers.AssetsHandler{Svc: a.svc.ASvc}
 	a.router.Path(a.config.AssetsApiV1).Methods(http.MethodGet).HandlerFunc(assetsHandler.GetAll)
@@ -77,27 +134,132 @@ func (a *Application) setupAssetsHandler() {
 }
 
 func (a *Application) setupUsersHandler() {
-	usersHandler := handlers.UsersHandler{Svc: a.svc.USvc}
-	a.router.Path(a.config.UsersApiV1).Methods(http.MethodGet).HandlerFunc(usersHandler.GetAll)
-	a.router.Path(a.config.UsersApiV1 + "/{username}").Methods(http.MethodGet).HandlerFunc(usersHandler.GetByUsername)
+	usersHandler := handlers.UsersHandler{Svc: a.svc.USvc, Sessions: a.svc.SSvc, Keys: a.svc.KSvc, Accounts: a.svc.AcSvc, SessionCookieName: a.sessionAuth.Config.SessionCookieName}
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1).Methods(http.MethodGet).HandlerFunc(usersHandler.GetAll), auth.Admin)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}").Methods(http.MethodGet).HandlerFunc(usersHandler.GetByUsername), auth.OwnerOrStaff)
 	a.router.Path(a.config.UsersApiV1).Methods(http.MethodPost).HandlerFunc(usersHandler.Post)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}").Methods(http.MethodPatch).HandlerFunc(usersHandler.Patch), auth.SensitiveOwner)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}").Methods(http.MethodDelete).HandlerFunc(usersHandler.Delete), auth.SensitiveOwnerOrAdmin)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}/password").Methods(http.MethodPost).HandlerFunc(usersHandler.ChangePassword), auth.SensitiveOwner)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}/verification").Methods(http.MethodPost).HandlerFunc(usersHandler.ResendVerification), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}/role").Methods(http.MethodPut).HandlerFunc(usersHandler.PutRole), auth.Admin)
+}
+
+func (a *Application) setupAccountHandler() {
+	accountHandler := handlers.AccountHandler{Svc: a.svc.AcSvc, Sessions: a.svc.SSvc, Keys: a.svc.KSvc}
+	a.router.Path(a.config.AccountApiV1 + "/verify").Methods(http.MethodGet).HandlerFunc(accountHandler.Verify)
+	a.router.Path(a.config.AccountApiV1 + "/password-reset").Methods(http.MethodPost).HandlerFunc(accountHandler.RequestPasswordReset)
+	a.router.Path(a.config.AccountApiV1 + "/password-reset/confirm").Methods(http.MethodPost).HandlerFunc(accountHandler.ResetPassword)
+}
+
+func (a *Application) setupTwoFactorHandler() {
+	twoFactorHandler := handlers.TwoFactorHandler{Svc: a.svc.TfSvc}
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}/2fa").Methods(http.MethodPost).HandlerFunc(twoFactorHandler.Enroll), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}/2fa").Methods(http.MethodDelete).HandlerFunc(twoFactorHandler.Disable), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}/2fa/confirm").Methods(http.MethodPost).HandlerFunc(twoFactorHandler.Confirm), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.UsersApiV1+"/{username}/2fa/recovery-codes").Methods(http.MethodPost).HandlerFunc(twoFactorHandler.RegenerateRecoveryCodes), auth.SensitiveOwner)
 }
 
 func (a *Application) setupUserAssetsHandler() {
-	userAssetsHandler := handlers.UserAssetsHandler{ASvc: a.svc.ASvc, USvc: a.svc.USvc, UaSvc: a.svc.UaSvc, ADB: a.db.AcquisitionsDBHandler}
+	userAssetsHandler := handlers.UserAssetsHandler{ASvc: a.svc.ASvc, USvc: a.svc.USvc, UaSvc: a.svc.UaSvc, ADB: a.db.AcquisitionsDBHandler,
+		Exec: a.svc.Exec, Clock: a.svc.Clock}
 	a.router.Path(a.config.UserAssetsApiV1).Methods(http.MethodGet).HandlerFunc(userAssetsHandler.GetAll)
 	a.router.Path(a.config.UserAssetsApiV1 + "/{id}").Methods(http.MethodGet).HandlerFunc(userAssetsHandler.GetByID)
-	a.auth.Path(a.config.UserAssetsApiV1 + "/{id}/buy").Methods(http.MethodPost).HandlerFunc(userAssetsHandler.Buy)
-	a.auth.Path(a.config.UserAssetsApiV1 + "/{id}/sell").Methods(http.MethodPost).HandlerFunc(userAssetsHandler.Sell)
+	a.policy.Require(a.sessionAuth.RequireScope(a.auth.Path(a.config.UserAssetsApiV1+"/{id}/buy").Methods(http.MethodPost).HandlerFunc(userAssetsHandler.Buy), model.ScopeTrade), auth.VerifiedOwner)
+	a.policy.Require(a.sessionAuth.RequireScope(a.auth.Path(a.config.UserAssetsApiV1+"/{id}/sell").Methods(http.MethodPost).HandlerFunc(userAssetsHandler.Sell), model.ScopeTrade), auth.VerifiedOwner)
 }
 
 func (a *Application) setupAcquisitionsHandler() {
 	acquisitionsHandler := handlers.AcquisitionsHandler{DB: a.db.AcquisitionsDBHandler}
-	a.router.Path(a.config.AcquisitionsApiV1).Methods(http.MethodGet).HandlerFunc(acquisitionsHandler.GetAll)
+	a.policy.Require(a.auth.Path(a.config.AcquisitionsApiV1).Methods(http.MethodGet).HandlerFunc(acquisitionsHandler.GetAll), auth.Admin)
+}
+
+func (a *Application) setupRatesHandler() {
+	ratesHandler := handlers.RatesHandler{Svc: a.svc.ASvc}
+	a.router.Path(a.config.ConvertApiV1).Methods(http.MethodGet).HandlerFunc(ratesHandler.Convert)
+	a.router.Path(a.config.RatesApiV1 + "/{base}").Methods(http.MethodGet).HandlerFunc(ratesHandler.GetByBase)
+}
+
+func (a *Application) setupStreamHandler() {
+	streamHandler := handlers.StreamHandler{Hub: a.hub, ASvc: a.svc.ASvc}
+	a.router.Path(a.config.StreamApiV1 + "/sse").Methods(http.MethodGet).HandlerFunc(streamHandler.SSE)
+	a.router.Path(a.config.StreamApiV1 + "/ws").Methods(http.MethodGet).HandlerFunc(streamHandler.WebSocket)
 }
 
-func (a Application) triggerSessionsCleaner() {
+func (a *Application) setupAlertsHandler() {
+	alertsHandler := handlers.AlertsHandler{Svc: a.svc.AlSvc}
+	a.policy.Require(a.auth.Path(a.config.AlertsApiV1).Methods(http.MethodGet).HandlerFunc(alertsHandler.GetAll), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.AlertsApiV1).Methods(http.MethodPost).HandlerFunc(alertsHandler.Post), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.AlertsApiV1+"/{id}").Methods(http.MethodGet).HandlerFunc(alertsHandler.GetByID), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.AlertsApiV1+"/{id}").Methods(http.MethodDelete).HandlerFunc(alertsHandler.Delete), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.NotificationsApiV1).Methods(http.MethodGet).HandlerFunc(alertsHandler.GetNotifications), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.NotificationsApiV1+"/{id}/read").Methods(http.MethodPost).HandlerFunc(alertsHandler.MarkNotificationRead), auth.Owner)
+}
+
+func (a *Application) setupWatchlistsHandler() {
+	watchlistsHandler := handlers.WatchlistsHandler{Svc: a.svc.WSvc}
+	// other users read the public watchlists, the handler hides the private ones
+	a.auth.Path(a.config.WatchlistsApiV1).Methods(http.MethodGet).HandlerFunc(watchlistsHandler.GetAll)
+	a.policy.Require(a.auth.Path(a.config.WatchlistsApiV1).Methods(http.MethodPost).HandlerFunc(watchlistsHandler.Post), auth.Owner)
+	a.auth.Path(a.config.WatchlistsApiV1 + "/{id}").Methods(http.MethodGet).HandlerFunc(watchlistsHandler.GetByID)
+	a.policy.Require(a.auth.Path(a.config.WatchlistsApiV1+"/{id}").Methods(http.MethodPut).HandlerFunc(watchlistsHandler.Put), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.WatchlistsApiV1+"/{id}").Methods(http.MethodDelete).HandlerFunc(watchlistsHandler.Delete), auth.Owner)
+}
+
+func (a *Application) setupMoversHandler() {
+	moversHandler := handlers.MoversHandler{Svc: a.svc.MSvc}
+	a.router.Path(a.config.MarketsApiV1 + "/movers").Methods(http.MethodGet).HandlerFunc(moversHandler.Get)
+}
+
+// api keys are managed with a session only, so a leaked key can't create new ones
+func (a *Application) setupAPIKeysHandler() {
+	apiKeysHandler := handlers.APIKeysHandler{Svc: a.svc.KSvc}
+	a.policy.Require(a.auth.Path(a.config.APIKeysApiV1).Methods(http.MethodGet).HandlerFunc(apiKeysHandler.GetAll), auth.Owner)
+	a.policy.Require(a.auth.Path(a.config.APIKeysApiV1).Methods(http.MethodPost).HandlerFunc(apiKeysHandler.Post), auth.SensitiveOwner)
+	a.policy.Require(a.auth.Path(a.config.APIKeysApiV1+"/{id}").Methods(http.MethodDelete).HandlerFunc(apiKeysHandler.Delete), auth.Owner)
+}
+
+// replay control is available only when prices are replayed
+func (a *Application) setupReplayHandler() {
+	if a.svc.Replay == nil {
+		return
+	}
+	replayHandler := handlers.ReplayHandler{Replay: a.svc.Replay, ASvc: a.svc.ASvc}
+	a.admin.Path("/replay").Methods(http.MethodGet).HandlerFunc(replayHandler.GetStatus)
+	a.admin.Path("/replay").Methods(http.MethodPost).HandlerFunc(replayHandler.Control)
+}
+
+func (a *Application) setupSettlementsHandler() {
+	settlementsHandler := handlers.SettlementsHandler{Svc: a.svc.StSvc}
+	a.admin.Path("/delisted").Methods(http.MethodGet).HandlerFunc(settlementsHandler.GetDelisted)
+	a.admin.Path("/delisted/{id}/settle").Methods(http.MethodPost).HandlerFunc(settlementsHandler.Settle)
+}
+
+// lockouts are managed with the admin token, so locked out admins can be unlocked
+func (a *Application) setupLockoutsHandler() {
+	lockoutsHandler := handlers.LockoutsHandler{Svc: a.svc.LSvc, Events: a.svc.EvSvc}
+	a.admin.Path("/lockouts").Methods(http.MethodGet).HandlerFunc(lockoutsHandler.GetAll)
+	a.admin.Path("/lockouts/{kind}/{subject}").Methods(http.MethodDelete).HandlerFunc(lockoutsHandler.Delete)
+	a.admin.Path("/security-events").Methods(http.MethodGet).HandlerFunc(lockoutsHandler.GetEvents)
+}
+
+// refreshes expired prices even when nobody requests them, so streams get updates.
+// Checks every minute or more often if prices are cached for less
+func (a Application) triggerAssetsRefresher() {
+	interval := time.Minute
+	if ttl := config.NewPrices().CacheTTL; ttl < interval {
+		interval = ttl
+	}
+
+	refresh := func() {
+		if err := a.svc.ASvc.Refresh(); err != nil {
+			log.Printf("Could not refresh assets, %v", err)
+		}
+	}
+	// prices restored from a snapshot are refreshed right away
+	go refresh()
+
 	c := cron.New()
-	c.AddFunc("@hourly", func() { a.svc.SSvc.ClearExpired() })
+	c.AddFunc("@every "+interval.String(), refresh)
 	c.Start()
 }
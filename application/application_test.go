@@ -0,0 +1,326 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/auth"
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/db"
+	"github.com/MonikaPalova/currency-master/handlers"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/oidc"
+	"github.com/MonikaPalova/currency-master/stream"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+)
+
+type stubRoles map[string]string
+
+func (s stubRoles) GetRole(username string) (string, error) {
+	return s[username], nil
+}
+
+type stubEmails map[string]bool
+
+func (s stubEmails) IsEmailVerified(username string) (bool, error) {
+	return s[username], nil
+}
+
+// current codes of the users with two-factor authentication
+type stubTwoFactor map[string]string
+
+func (s stubTwoFactor) VerifyFresh(username, code string) (bool, error) {
+	want, enabled := s[username]
+	return !enabled || code == want, nil
+}
+
+// who can access a route
+type access int
+
+const (
+	public access = iota
+	// any user with a session
+	authenticated
+	owner
+	ownerOrStaff
+	ownerOrAdmin
+	admin
+	// callers with the admin token
+	adminToken
+)
+
+// callers of the routes, the paths are of the resources of owner
+const (
+	anonymous     = ""
+	ownerCaller   = "u1"
+	otherCaller   = "u2"
+	supportCaller = "s1"
+	adminCaller   = "a1"
+)
+
+// status codes of the callers anonymous, owner, other, support and admin
+var wantStatusCodes = map[access][]int{
+	public:        {200, 200, 200, 200, 200},
+	authenticated: {401, 200, 200, 200, 200},
+	owner:         {401, 200, 403, 403, 403},
+	ownerOrStaff:  {401, 200, 403, 200, 200},
+	ownerOrAdmin:  {401, 200, 403, 403, 200},
+	admin:         {401, 403, 403, 403, 200},
+	adminToken:    {401, 401, 401, 401, 401},
+}
+
+// sets up the routes of the application with handlers which only respond with OK
+func newTestApplication(t *testing.T) Application {
+	t.Setenv("ADMIN_TOKEN", "token")
+	cfg := config.NewSession()
+	a := Application{db: &db.Database{}, config: config.NewApp(), hub: stream.NewHub(), svc: &svc.Service{
+		SSvc:  &svc.Sessions{Store: svc.NewMemorySessionStore(), Config: cfg, Clock: clock.NewFake(time.Now())},
+		OIDC:  oidc.NewClient(&config.OIDC{Issuer: "http://issuer.local"}, clock.Real{}),
+		Clock: clock.NewFake(time.Now()),
+	}}
+	a.setupHTTP()
+	a.policy.Roles = stubRoles{ownerCaller: model.RoleUser, otherCaller: model.RoleUser, supportCaller: model.RoleSupport, adminCaller: model.RoleAdmin}
+	a.policy.Emails = stubEmails{ownerCaller: true, otherCaller: true, supportCaller: true, adminCaller: true}
+	a.policy.TwoFactor = stubTwoFactor{}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	a.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() != nil {
+			route.Handler(ok)
+		}
+		return nil
+	})
+	return a
+}
+
+func TestApplication_AccessRules(t *testing.T) {
+	a := newTestApplication(t)
+	c := a.config
+	routes := map[string]access{
+		"POST /login":     public,
+		"POST /login/2fa": public,
+		"POST /logout":    authenticated,
+
+		"GET /login/oidc":          public,
+		"GET /login/oidc/callback": public,
+
+		"GET " + c.AssetsApiV1:                                    public,
+		"GET " + c.AssetsApiV1 + "/{id}":                          public,
+		"GET " + c.ConvertApiV1:                                   public,
+		"GET " + c.RatesApiV1 + "/{base}":                         public,
+		"GET " + c.StreamApiV1 + "/sse":                           public,
+		"GET " + c.StreamApiV1 + "/ws":                            public,
+		"GET " + c.MarketsApiV1 + "/movers":                       public,
+		"GET " + c.UserAssetsApiV1:                                public,
+		"GET " + c.UserAssetsApiV1 + "/{id}":                      public,
+		"POST " + c.UserAssetsApiV1 + "/{id}/buy":                 owner,
+		"POST " + c.UserAssetsApiV1 + "/{id}/sell":                owner,
+		"POST " + c.UsersApiV1:                                    public,
+		"GET " + c.UsersApiV1:                                     admin,
+		"GET " + c.UsersApiV1 + "/{username}":                     ownerOrStaff,
+		"PATCH " + c.UsersApiV1 + "/{username}":                   owner,
+		"DELETE " + c.UsersApiV1 + "/{username}":                  ownerOrAdmin,
+		"POST " + c.UsersApiV1 + "/{username}/password":           owner,
+		"POST " + c.UsersApiV1 + "/{username}/verification":       owner,
+		"POST " + c.UsersApiV1 + "/{username}/2fa":                owner,
+		"DELETE " + c.UsersApiV1 + "/{username}/2fa":              owner,
+		"POST " + c.UsersApiV1 + "/{username}/2fa/confirm":        owner,
+		"POST " + c.UsersApiV1 + "/{username}/2fa/recovery-codes": owner,
+		"PUT " + c.UsersApiV1 + "/{username}/role":                admin,
+		"GET " + c.AccountApiV1 + "/verify":                       public,
+		"POST " + c.AccountApiV1 + "/password-reset":              public,
+		"POST " + c.AccountApiV1 + "/password-reset/confirm":      public,
+		"GET " + c.AcquisitionsApiV1:                              admin,
+		"GET " + c.AlertsApiV1:                                    owner,
+		"POST " + c.AlertsApiV1:                                   owner,
+		"GET " + c.AlertsApiV1 + "/{id}":                          owner,
+		"DELETE " + c.AlertsApiV1 + "/{id}":                       owner,
+		"GET " + c.NotificationsApiV1:                             owner,
+		"POST " + c.NotificationsApiV1 + "/{id}/read":             owner,
+		"GET " + c.WatchlistsApiV1:                                authenticated,
+		"POST " + c.WatchlistsApiV1:                               owner,
+		"GET " + c.WatchlistsApiV1 + "/{id}":                      authenticated,
+		"PUT " + c.WatchlistsApiV1 + "/{id}":                      owner,
+		"DELETE " + c.WatchlistsApiV1 + "/{id}":                   owner,
+		"GET " + c.APIKeysApiV1:                                   owner,
+		"POST " + c.APIKeysApiV1:                                  owner,
+		"DELETE " + c.APIKeysApiV1 + "/{id}":                      owner,
+
+		"GET " + c.AdminApiV1 + "/delisted":                     adminToken,
+		"POST " + c.AdminApiV1 + "/delisted/{id}/settle":        adminToken,
+		"GET " + c.AdminApiV1 + "/lockouts":                     adminToken,
+		"DELETE " + c.AdminApiV1 + "/lockouts/{kind}/{subject}": adminToken,
+		"GET " + c.AdminApiV1 + "/security-events":              adminToken,
+	}
+
+	// every registered route should have an expected access
+	a.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if _, ok := routes[method+" "+path]; !ok {
+				t.Errorf("route %s %s has no expected access", method, path)
+			}
+		}
+		return nil
+	})
+
+	callers := []string{anonymous, ownerCaller, otherCaller, supportCaller, adminCaller}
+	for route, access := range routes {
+		for i, caller := range callers {
+			t.Run(route+" as "+caller, func(t *testing.T) {
+				methodAndPath := strings.SplitN(route, " ", 2)
+				path := strings.NewReplacer("{username}", ownerCaller, "{id}", "1", "{base}", "USD", "{kind}", "ip", "{subject}", "10.0.0.1").Replace(methodAndPath[1])
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(methodAndPath[0], path, nil)
+				if caller != anonymous {
+					cookie, err := a.svc.SSvc.CreateCookie(caller)
+					if err != nil {
+						t.Fatalf("could not create session, %v", err)
+					}
+					r.AddCookie(cookie)
+				}
+
+				a.router.ServeHTTP(w, r)
+
+				if want := wantStatusCodes[access][i]; w.Code != want {
+					t.Errorf("unexpected status code: got %v want %v, %s", w.Code, want, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+// watchlists of the owner, 1 is public and 2 is private
+type stubWatchlists struct{}
+
+func (s stubWatchlists) GetByUsername(username string, publicOnly bool) ([]model.Watchlist, error) {
+	if publicOnly {
+		return []model.Watchlist{{ID: "1", Username: username, Public: true}}, nil
+	}
+	return []model.Watchlist{{ID: "1", Username: username, Public: true}, {ID: "2", Username: username}}, nil
+}
+func (s stubWatchlists) GetByID(username, id string) (*model.Watchlist, error) {
+	switch id {
+	case "1":
+		return &model.Watchlist{ID: id, Username: username, Public: true}, nil
+	case "2":
+		return &model.Watchlist{ID: id, Username: username}, nil
+	}
+	return nil, nil
+}
+func (s stubWatchlists) Create(watchlist model.Watchlist) (*model.Watchlist, error) {
+	return &watchlist, nil
+}
+func (s stubWatchlists) Update(watchlist model.Watchlist) (*model.Watchlist, error) {
+	return &watchlist, nil
+}
+func (s stubWatchlists) Delete(username, id string) (bool, error) { return true, nil }
+
+func TestApplication_OtherUsersReadPublicWatchlists(t *testing.T) {
+	a := newTestApplication(t)
+	watchlistsHandler := handlers.WatchlistsHandler{Svc: stubWatchlists{}}
+	watchlists := a.config.WatchlistsApiV1
+	a.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		if len(methods) == 1 && methods[0] == http.MethodGet && path == watchlists {
+			route.HandlerFunc(watchlistsHandler.GetAll)
+		}
+		if len(methods) == 1 && methods[0] == http.MethodGet && path == watchlists+"/{id}" {
+			route.HandlerFunc(watchlistsHandler.GetByID)
+		}
+		return nil
+	})
+	cookie, err := a.svc.SSvc.CreateCookie(otherCaller)
+	if err != nil {
+		t.Fatalf("could not create session, %v", err)
+	}
+
+	path := strings.Replace(watchlists, "{username}", ownerCaller, 1)
+	for route, want := range map[string]int{path: http.StatusOK, path + "/1": http.StatusOK, path + "/2": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", route, nil)
+		r.AddCookie(cookie)
+
+		a.router.ServeHTTP(w, r)
+
+		if w.Code != want {
+			t.Errorf("unexpected status code of %s: got %v want %v", route, w.Code, want)
+		}
+		if route == path && strings.Contains(w.Body.String(), `"id":"2"`) {
+			t.Errorf("other user got the private watchlist: %s", w.Body.String())
+		}
+	}
+}
+
+func TestApplication_AdminToken(t *testing.T) {
+	a := newTestApplication(t)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", a.config.AdminApiV1+"/delisted", nil)
+	r.Header.Set("X-Admin-Token", "token")
+
+	a.router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status code: got %v want %v", w.Code, http.StatusOK)
+	}
+}
+
+func TestApplication_UnverifiedOwnerCantTrade(t *testing.T) {
+	a := newTestApplication(t)
+	a.policy.Emails = stubEmails{}
+	cookie, err := a.svc.SSvc.CreateCookie(ownerCaller)
+	if err != nil {
+		t.Fatalf("could not create session, %v", err)
+	}
+
+	for _, action := range []string{"buy", "sell"} {
+		w := httptest.NewRecorder()
+		path := strings.Replace(a.config.UserAssetsApiV1, "{username}", ownerCaller, 1) + "/1/" + action
+		r := httptest.NewRequest("POST", path, nil)
+		r.AddCookie(cookie)
+
+		a.router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("unexpected status code of %s: got %v want %v", action, w.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestApplication_SensitiveActionsRequireTOTP(t *testing.T) {
+	a := newTestApplication(t)
+	a.policy.TwoFactor = stubTwoFactor{ownerCaller: "123456"}
+	cookie, err := a.svc.SSvc.CreateCookie(ownerCaller)
+	if err != nil {
+		t.Fatalf("could not create session, %v", err)
+	}
+
+	user := a.config.UsersApiV1 + "/" + ownerCaller
+	routes := []string{"PATCH " + user, "DELETE " + user, "POST " + user + "/password", "POST " + user + "/2fa/recovery-codes",
+		"POST " + strings.Replace(a.config.APIKeysApiV1, "{username}", ownerCaller, 1)}
+	for _, route := range routes {
+		for code, want := range map[string]int{"": http.StatusForbidden, "654321": http.StatusForbidden, "123456": http.StatusOK} {
+			methodAndPath := strings.SplitN(route, " ", 2)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(methodAndPath[0], methodAndPath[1], nil)
+			r.AddCookie(cookie)
+			r.Header.Set(auth.TOTPHeader, code)
+
+			a.router.ServeHTTP(w, r)
+
+			if w.Code != want {
+				t.Errorf("unexpected status code of %s with code %q: got %v want %v", route, code, w.Code, want)
+			}
+		}
+	}
+}
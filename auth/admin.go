@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/httputils"
+)
+
+type AdminAuth struct {
+	Config *config.Admin
+}
+
+// Provides Middleware function which allows only requests with the admin token
+func (a AdminAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Config.Token == "" {
+			httputils.RespondWithError(w, http.StatusForbidden, nil, "The admin API is disabled")
+			return
+		}
+		token := r.Header.Get(a.Config.TokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.Config.Token)) != 1 {
+			httputils.RespondWithError(w, http.StatusUnauthorized, nil, "This action requires a valid admin token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -3,26 +3,78 @@ package auth
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/MonikaPalova/currency-master/clock"
 	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/signing"
 	"github.com/MonikaPalova/currency-master/svc"
 	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/gorilla/mux"
 )
 
 type SessionUserCtxKey string
 
 const CallerCtxKey SessionUserCtxKey = "caller"
 
+// Authenticates requests with a session cookie or an api key in the Authorization header
 type SessionAuth struct {
 	Config *config.Session
 	Svc    *svc.Sessions
+	Keys   apiKeyAuthenticator
+
+	// verification of requests signed with api key secrets, signed requests are rejected if not set
+	Signing *config.Signing
+	Nonces  nonceStore
+	Clock   clock.Clock
+
+	// scopes api keys need for routes which change something, other such routes need a session
+	scopes map[*mux.Route]string
+}
+
+type apiKeyAuthenticator interface {
+	// get the api key of the plain key, nil if there is no such key or it is expired
+	Authenticate(plain string) (*model.APIKey, error)
+	// get the api key with id, nil if there is no such key or it is expired
+	AuthenticateID(id string) (*model.APIKey, error)
+}
+
+// bodies of signed requests are read whole to verify them
+const maxSignedBodySize = 1 << 20
+
+type nonceStore interface {
+	// record nonce until expires, false if it was already used
+	Use(nonce string, expires, now time.Time) bool
+}
+
+// Lets api keys with scope call route, which changes something.
+// Requests with methods which only read need the read scope and are allowed on every route
+func (s *SessionAuth) RequireScope(route *mux.Route, scope string) *mux.Route {
+	if s.scopes == nil {
+		s.scopes = map[*mux.Route]string{}
+	}
+	s.scopes[route] = scope
+	return route
 }
 
 // Prodvides Middleware function for authentication
-func (s SessionAuth) Middleware(next http.Handler) http.Handler {
+func (s *SessionAuth) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(signing.SignatureHeader) != "" {
+			s.serveSigned(w, r, next)
+			return
+		}
+		if header := r.Header.Get("Authorization"); header != "" {
+			s.serveAPIKey(w, r, header, next)
+			return
+		}
+
 		sessionCookie, err := r.Cookie(s.Config.SessionCookieName)
 		if err != nil {
 			httputils.RespondWithError(w, http.StatusUnauthorized, err, "This action requires an active session cookie")
@@ -40,6 +92,102 @@ func (s SessionAuth) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// authenticates the bearer api key and checks its scopes
+func (s *SessionAuth) serveAPIKey(w http.ResponseWriter, r *http.Request, header string, next http.Handler) {
+	plain := strings.TrimPrefix(header, "Bearer ")
+	if plain == header || s.Keys == nil {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "Authorization header should be Bearer <api key>")
+		return
+	}
+	key, err := s.Keys.Authenticate(strings.TrimSpace(plain))
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not check api key")
+		return
+	}
+	if key == nil {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "Invalid or expired api key")
+		return
+	}
+
+	s.serveWithScope(w, r, key, next)
+}
+
+// authenticates the request signed with the secret of an api key and checks its scopes.
+// The timestamp should be within the max skew from now and the nonce should not be used before
+func (s *SessionAuth) serveSigned(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if s.Signing == nil || s.Keys == nil {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "Signed requests are not supported")
+		return
+	}
+	keyID, nonce := r.Header.Get(signing.KeyHeader), r.Header.Get(signing.NonceHeader)
+	timestamp, err := strconv.ParseInt(r.Header.Get(signing.TimestampHeader), 10, 64)
+	if keyID == "" || nonce == "" || err != nil {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, fmt.Sprintf("Signed requests need the %s, %s and %s headers", signing.KeyHeader, signing.TimestampHeader, signing.NonceHeader))
+		return
+	}
+	now := s.Clock.Now()
+	signed := time.Unix(timestamp, 0)
+	if skew := now.Sub(signed); skew > s.Signing.MaxSkew || skew < -s.Signing.MaxSkew {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, fmt.Sprintf("Request was signed at %v, more than %v from the server time", signed.UTC(), s.Signing.MaxSkew))
+		return
+	}
+
+	key, err := s.Keys.AuthenticateID(keyID)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not check api key")
+		return
+	}
+	if key == nil || key.Secret == "" {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "Invalid or expired api key")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSignedBodySize)
+	canonical, err := signing.CanonicalRequest(r)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "Could not read request body")
+		return
+	}
+	if !signing.Verify(key.Secret, canonical, r.Header.Get(signing.SignatureHeader)) {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "Invalid request signature")
+		return
+	}
+	// the nonce is used only after the signature is verified, so others can't burn it
+	if !s.Nonces.Use(key.ID+":"+nonce, signed.Add(s.Signing.MaxSkew), now) {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "Nonce was already used")
+		return
+	}
+
+	s.serveWithScope(w, r, key, next)
+}
+
+// checks that key has the scope the request requires and serves it as its user
+func (s *SessionAuth) serveWithScope(w http.ResponseWriter, r *http.Request, key *model.APIKey, next http.Handler) {
+	scope := s.requiredScope(r)
+	if scope == "" {
+		httputils.RespondWithError(w, http.StatusForbidden, nil, "This action requires a session and can't be done with an api key")
+		return
+	}
+	if !key.HasScope(scope) {
+		httputils.RespondWithError(w, http.StatusForbidden, nil, fmt.Sprintf("This action requires an api key with scope %s", scope))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), CallerCtxKey, key.Username)
+	log.Printf("User %s successfully authenticated with api key %s", key.Username, key.ID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// methods which only read need the read scope, others the scope registered for the route, if any
+func (s *SessionAuth) requiredScope(r *http.Request) string {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return model.ScopeRead
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		return s.scopes[route]
+	}
+	return ""
+}
+
 // Retrieves user from request context
 func GetUser(r *http.Request) string {
 	return r.Context().Value(CallerCtxKey).(string)
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/signing"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+)
+
+type stubKeys map[string]model.APIKey
+
+func (s stubKeys) Authenticate(plain string) (*model.APIKey, error) {
+	key, ok := s[plain]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (s stubKeys) AuthenticateID(id string) (*model.APIKey, error) {
+	for _, key := range s {
+		if key.ID == id {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestSessionAuth_Middleware(t *testing.T) {
+	cfg := &config.Session{SessionDuration: time.Hour, SessionCookieName: "session"}
+	sessions := &svc.Sessions{Store: svc.NewMemorySessionStore(), Config: cfg, Clock: clock.NewFake(time.Now())}
+	cookie, _ := sessions.CreateCookie("u1")
+	keys := stubKeys{
+		"reader": {ID: "1", Username: "u2", Scopes: []string{model.ScopeRead}},
+		"trader": {ID: "2", Username: "u2", Scopes: []string{model.ScopeTrade}},
+	}
+
+	sessionAuth := &SessionAuth{Config: cfg, Svc: sessions, Keys: keys}
+	router := mux.NewRouter()
+	router.Use(sessionAuth.Middleware)
+	whoami := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(GetUser(r))) }
+	router.Path("/assets").Methods(http.MethodGet).HandlerFunc(whoami)
+	sessionAuth.RequireScope(router.Path("/buy").Methods(http.MethodPost).HandlerFunc(whoami), model.ScopeTrade)
+	router.Path("/apikeys").Methods(http.MethodPost).HandlerFunc(whoami)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		cookie         *http.Cookie
+		authorization  string
+		wantStatusCode int
+		wantCaller     string
+	}{
+		{"session", "GET", "/assets", cookie, "", http.StatusOK, "u1"},
+		{"session on session only route", "POST", "/apikeys", cookie, "", http.StatusOK, "u1"},
+		{"no credentials", "GET", "/assets", nil, "", http.StatusUnauthorized, ""},
+		{"read key reads", "GET", "/assets", nil, "Bearer reader", http.StatusOK, "u2"},
+		{"read key can't trade", "POST", "/buy", nil, "Bearer reader", http.StatusForbidden, ""},
+		{"trade key trades", "POST", "/buy", nil, "Bearer trader", http.StatusOK, "u2"},
+		{"trade key can't read", "GET", "/assets", nil, "Bearer trader", http.StatusForbidden, ""},
+		{"key on session only route", "POST", "/apikeys", nil, "Bearer trader", http.StatusForbidden, ""},
+		{"unknown key", "GET", "/assets", nil, "Bearer unknown", http.StatusUnauthorized, ""},
+		{"not bearer", "GET", "/assets", nil, "Basic dTE6cA==", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v, %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if tt.wantCaller != "" && w.Body.String() != tt.wantCaller {
+				t.Errorf("unexpected caller: got %v want %v", w.Body.String(), tt.wantCaller)
+			}
+		})
+	}
+}
+
+func TestSessionAuth_Middleware_Signed(t *testing.T) {
+	now := time.Date(2022, 2, 17, 10, 0, 0, 0, time.UTC)
+	keys := stubKeys{
+		"reader": {ID: "1", Username: "u2", Scopes: []string{model.ScopeRead}, Secret: "s1"},
+		"trader": {ID: "2", Username: "u2", Scopes: []string{model.ScopeTrade}, Secret: "s2"},
+	}
+	sessionAuth := &SessionAuth{Config: &config.Session{SessionCookieName: "session"}, Keys: keys,
+		Signing: &config.Signing{MaxSkew: 30 * time.Second}, Nonces: NewMemoryNonces(), Clock: clock.NewFake(now)}
+	router := mux.NewRouter()
+	router.Use(sessionAuth.Middleware)
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(GetUser(r) + " " + string(body)))
+	}
+	sessionAuth.RequireScope(router.Path("/buy").Methods(http.MethodPost).HandlerFunc(echo), model.ScopeTrade)
+
+	signed := func(keyID, secret string, at time.Time) *http.Request {
+		r := httptest.NewRequest("POST", "/buy?quantity=2", strings.NewReader(`{"id":"BTC"}`))
+		if err := signing.SignRequest(r, keyID, secret, at); err != nil {
+			t.Fatalf("signing.SignRequest() error = %v", err)
+		}
+		return r
+	}
+	tests := []struct {
+		name           string
+		request        func() *http.Request
+		wantStatusCode int
+		wantBody       string
+	}{
+		{"signed trade", func() *http.Request { return signed("2", "s2", now) }, http.StatusOK, `u2 {"id":"BTC"}`},
+		{"signed within skew", func() *http.Request { return signed("2", "s2", now.Add(-20*time.Second)) }, http.StatusOK, `u2 {"id":"BTC"}`},
+		{"read key can't trade", func() *http.Request { return signed("1", "s1", now) }, http.StatusForbidden, ""},
+		{"wrong secret", func() *http.Request { return signed("2", "s1", now) }, http.StatusUnauthorized, ""},
+		{"unknown key", func() *http.Request { return signed("3", "s2", now) }, http.StatusUnauthorized, ""},
+		{"stale timestamp", func() *http.Request { return signed("2", "s2", now.Add(-time.Minute)) }, http.StatusUnauthorized, ""},
+		{"future timestamp", func() *http.Request { return signed("2", "s2", now.Add(time.Minute)) }, http.StatusUnauthorized, ""},
+		{"tampered body", func() *http.Request {
+			r := signed("2", "s2", now)
+			r.Body = ioutil.NopCloser(strings.NewReader(`{"id":"ETH"}`))
+			return r
+		}, http.StatusUnauthorized, ""},
+		{"tampered query", func() *http.Request {
+			r := signed("2", "s2", now)
+			r.URL.RawQuery = "quantity=200"
+			return r
+		}, http.StatusUnauthorized, ""},
+		{"missing nonce", func() *http.Request {
+			r := signed("2", "s2", now)
+			r.Header.Del(signing.NonceHeader)
+			return r
+		}, http.StatusUnauthorized, ""},
+		{"replayed", func() *http.Request {
+			r := signed("2", "s2", now)
+			router.ServeHTTP(httptest.NewRecorder(), r.Clone(r.Context()))
+			r.Body = ioutil.NopCloser(strings.NewReader(`{"id":"BTC"}`))
+			return r
+		}, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, tt.request())
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v, %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("unexpected body: got %v want %v", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Remembers the nonces of signed requests until their timestamps are too old to be accepted, safe for concurrent use
+type MemoryNonces struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// time of the next deletion of expired nonces
+	nextPurge time.Time
+}
+
+// Constructor
+func NewMemoryNonces() *MemoryNonces {
+	return &MemoryNonces{seen: map[string]time.Time{}}
+}
+
+// Records nonce until expires, false if it was already used and didn't expire at now
+func (n *MemoryNonces) Use(nonce string, expires, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if now.After(n.nextPurge) {
+		for seen, seenExpires := range n.seen {
+			if seenExpires.Before(now) {
+				delete(n.seen, seen)
+			}
+		}
+		n.nextPurge = now.Add(time.Minute)
+	}
+
+	if seenExpires, ok := n.seen[nonce]; ok && !seenExpires.Before(now) {
+		return false
+	}
+	n.seen[nonce] = expires
+	return true
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/gorilla/mux"
+)
+
+// Who can access a route. The caller is allowed if they have one of the roles
+// or, for owner rules, if they are the user in the {username} path variable.
+// Verified rules additionally require the caller to have verified their email
+// and FreshTOTP rules a current code in the TOTPHeader if the caller enabled two-factor authentication
+type Rule struct {
+	Roles     []string
+	Owner     bool
+	Verified  bool
+	FreshTOTP bool
+}
+
+// header with the code of the authenticator app required by sensitive actions
+const TOTPHeader = "X-TOTP-Code"
+
+var (
+	// only the user whose resources are accessed
+	Owner = Rule{Owner: true}
+	// only the user whose resources are accessed, with a verified email
+	VerifiedOwner = Rule{Owner: true, Verified: true}
+	// only the user whose resources are accessed, with a current two-factor code if they enabled it
+	SensitiveOwner = Rule{Owner: true, FreshTOTP: true}
+	// the user whose resources are accessed and the staff helping them
+	OwnerOrStaff = Rule{Owner: true, Roles: []string{model.RoleSupport, model.RoleAdmin}}
+	// the user whose resources are accessed and admins
+	OwnerOrAdmin = Rule{Owner: true, Roles: []string{model.RoleAdmin}}
+	// the user whose resources are accessed, with a current two-factor code if they enabled it, and admins with theirs
+	SensitiveOwnerOrAdmin = Rule{Owner: true, Roles: []string{model.RoleAdmin}, FreshTOTP: true}
+	// admins only
+	Admin = Rule{Roles: []string{model.RoleAdmin}}
+)
+
+// Checks the rules of routes against the authenticated caller, so it should run after authentication.
+// Routes without a rule are allowed to every caller
+type Policy struct {
+	Roles     roleGetter
+	Emails    emailVerifier
+	TwoFactor freshCodeVerifier
+	rules     map[*mux.Route]Rule
+}
+
+type roleGetter interface {
+	// get the role of user, empty if the user doesn't exist
+	GetRole(username string) (string, error)
+}
+
+type emailVerifier interface {
+	// check whether the user has verified their email
+	IsEmailVerified(username string) (bool, error)
+}
+
+type freshCodeVerifier interface {
+	// check a code of a sensitive action, true if the user didn't enable two-factor authentication
+	VerifyFresh(username, code string) (bool, error)
+}
+
+// Registers the rule of route and returns the route
+func (p *Policy) Require(route *mux.Route, rule Rule) *mux.Route {
+	if p.rules == nil {
+		p.rules = map[*mux.Route]Rule{}
+	}
+	p.rules[route] = rule
+	return route
+}
+
+// Provides Middleware function which allows only callers satisfying the rule of the route
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := p.rules[mux.CurrentRoute(r)]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		caller, _ := r.Context().Value(CallerCtxKey).(string)
+		if caller == "" {
+			httputils.RespondWithError(w, http.StatusUnauthorized, nil, "This action requires authentication")
+			return
+		}
+		if rule.Verified {
+			verified, err := p.Emails.IsEmailVerified(caller)
+			if err != nil {
+				httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not check the email of the caller")
+				return
+			}
+			if !verified {
+				httputils.RespondWithError(w, http.StatusForbidden, nil, "Verify your email before trading")
+				return
+			}
+		}
+		allowed, err := p.allows(rule, caller, r)
+		if err != nil {
+			httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not check the role of the caller")
+			return
+		}
+		if !allowed {
+			httputils.RespondWithError(w, http.StatusForbidden, nil, "You are not allowed to do this action")
+			return
+		}
+		if rule.FreshTOTP {
+			fresh, err := p.TwoFactor.VerifyFresh(caller, r.Header.Get(TOTPHeader))
+			if err != nil {
+				httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not check the two-factor code of the caller")
+				return
+			}
+			if !fresh {
+				httputils.RespondWithError(w, http.StatusForbidden, nil, "This action requires a current code of your authenticator app in the "+TOTPHeader+" header")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// whether caller is the owner of the resources or has one of the roles of rule
+func (p *Policy) allows(rule Rule, caller string, r *http.Request) (bool, error) {
+	if rule.Owner && mux.Vars(r)["username"] == caller {
+		return true, nil
+	}
+	if len(rule.Roles) == 0 {
+		return false, nil
+	}
+	role, err := p.Roles.GetRole(caller)
+	if err != nil {
+		return false, err
+	}
+	for _, allowed := range rule.Roles {
+		if role == allowed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
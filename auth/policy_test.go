@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/gorilla/mux"
+)
+
+type stubRoles struct {
+	roles map[string]string
+	err   error
+}
+
+func (s stubRoles) GetRole(username string) (string, error) {
+	return s.roles[username], s.err
+}
+
+type stubEmails struct {
+	verified map[string]bool
+	err      error
+}
+
+func (s stubEmails) IsEmailVerified(username string) (bool, error) {
+	return s.verified[username], s.err
+}
+
+func TestPolicy_Middleware(t *testing.T) {
+	roles := map[string]string{"u1": model.RoleUser, "s1": model.RoleSupport, "a1": model.RoleAdmin}
+	tests := []struct {
+		name           string
+		rule           *Rule
+		roles          stubRoles
+		emails         stubEmails
+		caller         string
+		wantStatusCode int
+	}{
+		{"no rule", nil, stubRoles{roles: roles}, stubEmails{}, "u2", http.StatusOK},
+		{"owner", &Owner, stubRoles{roles: roles}, stubEmails{}, "u1", http.StatusOK},
+		{"owner rule other user", &Owner, stubRoles{roles: roles}, stubEmails{}, "u2", http.StatusForbidden},
+		{"owner rule admin", &Owner, stubRoles{roles: roles}, stubEmails{}, "a1", http.StatusForbidden},
+		{"staff", &OwnerOrStaff, stubRoles{roles: roles}, stubEmails{}, "s1", http.StatusOK},
+		{"admin", &Admin, stubRoles{roles: roles}, stubEmails{}, "a1", http.StatusOK},
+		{"admin rule owner", &Admin, stubRoles{roles: roles}, stubEmails{}, "u1", http.StatusForbidden},
+		{"admin rule support", &Admin, stubRoles{roles: roles}, stubEmails{}, "s1", http.StatusForbidden},
+		{"deleted user", &Admin, stubRoles{roles: roles}, stubEmails{}, "gone", http.StatusForbidden},
+		{"roles error", &Admin, stubRoles{err: fmt.Errorf("db")}, stubEmails{}, "a1", http.StatusInternalServerError},
+		{"unauthenticated", &Admin, stubRoles{roles: roles}, stubEmails{}, "", http.StatusUnauthorized},
+		{"verified owner", &VerifiedOwner, stubRoles{roles: roles}, stubEmails{verified: map[string]bool{"u1": true}}, "u1", http.StatusOK},
+		{"unverified owner", &VerifiedOwner, stubRoles{roles: roles}, stubEmails{}, "u1", http.StatusForbidden},
+		{"verified other user", &VerifiedOwner, stubRoles{roles: roles}, stubEmails{verified: map[string]bool{"u2": true}}, "u2", http.StatusForbidden},
+		{"emails error", &VerifiedOwner, stubRoles{roles: roles}, stubEmails{err: fmt.Errorf("db")}, "u1", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &Policy{Roles: tt.roles, Emails: tt.emails}
+			router := mux.NewRouter()
+			router.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tt.caller != "" {
+						r = r.WithContext(context.WithValue(r.Context(), CallerCtxKey, tt.caller))
+					}
+					next.ServeHTTP(w, r)
+				})
+			}, policy.Middleware)
+			route := router.Path("/users/{username}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			if tt.rule != nil {
+				policy.Require(route, *tt.rule)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/users/u1", nil))
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+type stubTwoFactor struct {
+	codes map[string]string
+	err   error
+}
+
+func (s stubTwoFactor) VerifyFresh(username, code string) (bool, error) {
+	want, enabled := s.codes[username]
+	return !enabled || code == want, s.err
+}
+
+func TestPolicy_Middleware_FreshTOTP(t *testing.T) {
+	codes := map[string]string{"u1": "123456"}
+	tests := []struct {
+		name           string
+		twoFactor      stubTwoFactor
+		caller         string
+		code           string
+		wantStatusCode int
+	}{
+		{"current code", stubTwoFactor{codes: codes}, "u1", "123456", http.StatusOK},
+		{"wrong code", stubTwoFactor{codes: codes}, "u1", "654321", http.StatusForbidden},
+		{"no code", stubTwoFactor{codes: codes}, "u1", "", http.StatusForbidden},
+		{"two-factor not enabled", stubTwoFactor{}, "u1", "", http.StatusOK},
+		{"other user with code", stubTwoFactor{codes: codes}, "u2", "123456", http.StatusForbidden},
+		{"two-factor error", stubTwoFactor{err: fmt.Errorf("db")}, "u1", "123456", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &Policy{Roles: stubRoles{}, TwoFactor: tt.twoFactor}
+			router := mux.NewRouter()
+			router.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), CallerCtxKey, tt.caller)))
+				})
+			}, policy.Middleware)
+			policy.Require(router.Path("/users/{username}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), SensitiveOwner)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/users/u1", nil)
+			r.Header.Set(TOTPHeader, tt.code)
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
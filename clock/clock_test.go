@@ -0,0 +1,32 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2022, 2, 17, 10, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	if got := f.Now(); !got.Equal(start) {
+		t.Fatalf("Now() = %v, want %v", got, start)
+	}
+
+	f.Advance(90 * time.Minute)
+	if want := start.Add(90 * time.Minute); !f.Now().Equal(want) {
+		t.Errorf("Now() after Advance = %v, want %v", f.Now(), want)
+	}
+
+	f.Set(start)
+	if !f.Now().Equal(start) {
+		t.Errorf("Now() after Set = %v, want %v", f.Now(), start)
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	got := Real{}.Now()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("Real.Now() = %v is not the wall time", got)
+	}
+}
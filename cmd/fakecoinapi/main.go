@@ -0,0 +1,50 @@
+// Command fakecoinapi runs a fake of the external Coin API for offline development.
+//
+// Start it and point the application to it:
+//
+//	go run ./cmd/fakecoinapi -fixture coinapi/testdata/assets.json
+//	COINAPI_ASSETS_URL=http://localhost:8081/v1/assets go run ./cmd
+//
+// Prices, latency and errors are controlled with the admin endpoints:
+//
+//	curl -X POST localhost:8081/admin/prices -d '{"prices":{"BTC":50000},"changes":{"ETH":-10}}'
+//	curl -X POST localhost:8081/admin/latency -d '{"latencyMs":500}'
+//	curl -X POST localhost:8081/admin/errors -d '{"count":3,"status":503}'
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/coinapi/coinapitest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8081", "address to listen on")
+	fixture := flag.String("fixture", "", "JSON file with assets in the format of the external api, generated assets are served if not set")
+	count := flag.Int("generate", 200, "number of assets to generate")
+	seed := flag.Int64("seed", 1, "seed of the generated assets")
+	apiKey := flag.String("key", "", "required API key, all requests are accepted if not set")
+	flag.Parse()
+
+	var assets []coinapitest.Asset
+	if *fixture != "" {
+		var err error
+		if assets, err = coinapitest.LoadFixture(*fixture); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Printf("Loaded %d assets from %s", len(assets), *fixture)
+	} else {
+		assets = coinapitest.Generate(*count, *seed)
+		log.Printf("Generated %d assets with seed %d", len(assets), *seed)
+	}
+
+	fake := coinapitest.NewFake(assets)
+	fake.RequireAPIKey(*apiKey)
+
+	log.Printf("Serving fake coin API on http://%s%s", *addr, coinapitest.AssetsPath)
+	if err := http.ListenAndServe(*addr, fake); err != nil {
+		log.Fatalf("An error occured when starting the fake coin API: %s", err.Error())
+	}
+}
@@ -3,14 +3,51 @@ package coinapi
 
 import (
 	"encoding/json"
+	"log"
+	"time"
 )
 
+// format of the data start and end dates in the external api
+const dataDateLayout = "2006-01-02"
+
 // Asset object received from external api
 type Asset struct {
 	ID       string  `json:"id"`
 	Name     string  `json:"name"`
 	IsCrypto bool    `json:"isCrypto"`
 	PriceUSD float64 `json:"priceUSD"`
+
+	// prices at which the asset is sold and bought, set by the spread model
+	BidUSD float64 `json:"bidUSD"`
+	AskUSD float64 `json:"askUSD"`
+
+	// traded volume in USD over the last hour, day and month
+	Volume1hUSD  float64 `json:"volume1hUSD"`
+	Volume24hUSD float64 `json:"volume24hUSD"`
+	Volume30dUSD float64 `json:"volume30dUSD"`
+
+	// dates of the first and last data the external api has for the asset
+	DataStart *time.Time `json:"dataStart,omitempty"`
+	DataEnd   *time.Time `json:"dataEnd,omitempty"`
+
+	// id of the asset icon in the external api
+	IconID string `json:"iconId,omitempty"`
+}
+
+// Gets the price at which the asset is sold, the mid price if there is no spread
+func (a Asset) Bid() float64 {
+	if a.BidUSD > 0 {
+		return a.BidUSD
+	}
+	return a.PriceUSD
+}
+
+// Gets the price at which the asset is bought, the mid price if there is no spread
+func (a Asset) Ask() float64 {
+	if a.AskUSD > 0 {
+		return a.AskUSD
+	}
+	return a.PriceUSD
 }
 
 // Page with assets
@@ -24,10 +61,16 @@ type AssetPage struct {
 // formats asset object received from external api to Asset object
 func (a *Asset) UnmarshalJSON(bytes []byte) (err error) {
 	var asset struct {
-		ID       string  `json:"asset_id"`
-		Name     string  `json:"name"`
-		IsCrypto float64 `json:"type_is_crypto"`
-		PriceUSD float64 `json:"price_usd"`
+		ID           string  `json:"asset_id"`
+		Name         string  `json:"name"`
+		IsCrypto     float64 `json:"type_is_crypto"`
+		PriceUSD     float64 `json:"price_usd"`
+		Volume1hUSD  float64 `json:"volume_1hrs_usd"`
+		Volume24hUSD float64 `json:"volume_1day_usd"`
+		Volume30dUSD float64 `json:"volume_1mth_usd"`
+		DataStart    string  `json:"data_start"`
+		DataEnd      string  `json:"data_end"`
+		IconID       string  `json:"id_icon"`
 	}
 	if err = json.Unmarshal(bytes, &asset); err != nil {
 		return err
@@ -37,6 +80,26 @@ func (a *Asset) UnmarshalJSON(bytes []byte) (err error) {
 	a.Name = asset.Name
 	a.IsCrypto = asset.IsCrypto != 0
 	a.PriceUSD = asset.PriceUSD
+	a.Volume1hUSD = asset.Volume1hUSD
+	a.Volume24hUSD = asset.Volume24hUSD
+	a.Volume30dUSD = asset.Volume30dUSD
+	a.IconID = asset.IconID
+	// the dates are informational, so an asset with a bad one is kept without it
+	a.DataStart = parseDataDate(asset.ID, "start", asset.DataStart)
+	a.DataEnd = parseDataDate(asset.ID, "end", asset.DataEnd)
 
-	return err
+	return nil
+}
+
+// parses the data start or end date of asset, nil if it is missing or invalid
+func parseDataDate(assetID, name, date string) *time.Time {
+	if date == "" {
+		return nil
+	}
+	parsed, err := time.Parse(dataDateLayout, date)
+	if err != nil {
+		log.Printf("Ignoring invalid data %s %q of asset %s, %v", name, date, assetID, err)
+		return nil
+	}
+	return &parsed
 }
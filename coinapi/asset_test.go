@@ -0,0 +1,39 @@
+package coinapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAsset_UnmarshalJSON(t *testing.T) {
+	start := time.Date(2010, time.July, 17, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, time.February, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		json    string
+		want    Asset
+		wantErr bool
+	}{
+		{"all fields", `{"asset_id":"BTC","name":"Bitcoin","type_is_crypto":1,"price_usd":43868.37,"volume_1hrs_usd":10.5,"volume_1day_usd":250.25,"volume_1mth_usd":7000,"data_start":"2010-07-17","data_end":"2022-02-17","id_icon":"4caf2b16"}`,
+			Asset{ID: "BTC", Name: "Bitcoin", IsCrypto: true, PriceUSD: 43868.37, Volume1hUSD: 10.5, Volume24hUSD: 250.25, Volume30dUSD: 7000, DataStart: &start, DataEnd: &end, IconID: "4caf2b16"}, false},
+		{"only required fields", `{"asset_id":"USD","name":"US Dollar","type_is_crypto":0,"price_usd":1}`, Asset{ID: "USD", Name: "US Dollar", PriceUSD: 1}, false},
+		{"invalid data start", `{"asset_id":"BTC","price_usd":1,"data_start":"17.07.2010","data_end":"2022-02-17"}`, Asset{ID: "BTC", PriceUSD: 1, DataEnd: &end}, false},
+		{"invalid data end", `{"asset_id":"BTC","price_usd":1,"data_start":"2010-07-17","data_end":"yesterday"}`, Asset{ID: "BTC", PriceUSD: 1, DataStart: &start}, false},
+		{"not an object", `[]`, Asset{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Asset
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Asset.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Asset.UnmarshalJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,73 +1,177 @@
 package coinapi
 
 import (
+	"sync"
 	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
 )
 
 const minutesToKeepCache = 30
 
 // Cache object which keeps information about assets received from external api.
+// Safe for concurrent use
 type Cache struct {
+	mu      sync.RWMutex
 	assets  []Asset
 	ids     map[string]int
+	index   assetIndex
+	updated time.Time
 	expires time.Time
+	ttl     time.Duration
+	clock   clock.Clock
+	// assets come from a snapshot and should be refreshed even if they are not expired
+	restored bool
 }
 
 // Cache constructor.
 func NewCache() *Cache {
-	return &Cache{assets: []Asset{}, ids: map[string]int{}, expires: time.Now().Add(-time.Hour)}
+	return NewCacheWithTTL(time.Minute*minutesToKeepCache, clock.Real{})
+}
+
+// Cache constructor with the time for which filled assets are kept and the clock which tells when they expire.
+func NewCacheWithTTL(ttl time.Duration, clk clock.Clock) *Cache {
+	return &Cache{assets: []Asset{}, ids: map[string]int{}, expires: clk.Now().Add(-time.Hour), ttl: ttl, clock: clk}
 }
 
 // Clears cache and adds assets.
 func (c *Cache) Fill(assets []Asset) {
+	index := newAssetIndex(assets)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(assets, index)
+	c.updated = c.clock.Now()
+	ttl := c.ttl
+	if ttl <= 0 {
+		ttl = time.Minute * minutesToKeepCache
+	}
+	c.expires = c.updated.Add(ttl)
+	c.restored = false
+}
+
+// Fills cache with the assets of snapshot, keeping the time they were fetched.
+// They are served until they are older than maxAge, but the cache needs a refresh right away.
+// Returns false and leaves the cache as it is if the snapshot is already older than maxAge
+func (c *Cache) Restore(snapshot Snapshot, maxAge time.Duration) bool {
+	expires := snapshot.Updated.Add(maxAge)
+	if expires.Before(c.clock.Now()) {
+		return false
+	}
+	index := newAssetIndex(snapshot.Assets)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(snapshot.Assets, index)
+	c.updated = snapshot.Updated
+	c.expires = expires
+	c.restored = true
+	return true
+}
+
+// replaces the assets, should be called with lock
+func (c *Cache) set(assets []Asset, index assetIndex) {
 	c.assets = assets
+	c.ids = make(map[string]int, len(assets))
 	for idx, asset := range assets {
 		c.ids[asset.ID] = idx
 	}
-	c.expires = time.Now().Add(time.Minute * minutesToKeepCache)
+	c.index = index
+}
+
+// Gets the cached assets with the time they were fetched.
+func (c *Cache) Snapshot() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Snapshot{Updated: c.updated, Assets: c.assets}
 }
 
 // Gets specific page from cache.
 // If page is negative or after the last page, returns an empty page
-func (c Cache) GetPage(page, size int) AssetPage {
-	if c.IsExpired() {
-		c.cleanCache()
+func (c *Cache) GetPage(page, size int) AssetPage {
+	return c.Find(AssetQuery{}, page, size)
+}
+
+// Gets specific page of the assets matching the query.
+// Total in the returned page is the number of matching assets.
+// If page is negative or after the last page, returns an empty page
+func (c *Cache) Find(query AssetQuery, page, size int) AssetPage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.isExpired() {
 		return AssetPage{[]Asset{}, page, size, 0}
 	}
+	index := c.index
+	if len(index.keys) != len(c.assets) {
+		index = newAssetIndex(c.assets)
+	}
 
+	matches := index.find(c.assets, query)
 	from := (page - 1) * size
 	to := from + size
-	total := len(c.assets)
+	total := len(matches)
 	if from > total || from < 0 {
 		return AssetPage{[]Asset{}, page, size, total}
 	}
 	if to > total {
 		to = total
 	}
-	return AssetPage{c.assets[from:to], page, size, total}
+
+	assets := make([]Asset, 0, to-from)
+	for _, pos := range matches[from:to] {
+		assets = append(assets, c.assets[pos])
+	}
+	return AssetPage{assets, page, size, total}
+}
+
+// Gets all cached assets.
+// If cache is expired, returns an empty array
+func (c *Cache) All() []Asset {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.isExpired() {
+		return []Asset{}
+	}
+	return c.assets
 }
 
 // Gets asset by id.
 // If cache is expired or asset is not in cache, returns nil
-func (c Cache) GetAsset(id string) *Asset {
-	if c.IsExpired() {
-		c.cleanCache()
+func (c *Cache) GetAsset(id string) *Asset {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.isExpired() {
 		return nil
 	}
 	pos, ok := c.ids[id]
 	if !ok {
 		return nil
 	}
-	return &c.assets[pos]
+	asset := c.assets[pos]
+	return &asset
+}
+
+// Returns when the cached assets were fetched from the external api.
+func (c *Cache) Updated() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.updated
 }
 
 // Returns if cache is expired.
-func (c Cache) IsExpired() bool {
-	return c.expires.Before(time.Now())
+func (c *Cache) IsExpired() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.isExpired()
+}
+
+// Returns if cache is expired or still serves assets restored from a snapshot.
+func (c *Cache) NeedsRefresh() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.restored || c.isExpired()
 }
 
-func (c *Cache) cleanCache() {
-	c.assets = []Asset{}
-	c.ids = map[string]int{}
-	c.expires = time.Now()
+func (c *Cache) isExpired() bool {
+	return c.expires.Before(c.clock.Now())
 }
@@ -4,6 +4,14 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+)
+
+// clock of the cache tests, stopped at testNow
+var (
+	testNow   = time.Date(2022, 2, 17, 10, 0, 0, 0, time.UTC)
+	testClock = clock.NewFake(testNow)
 )
 
 func TestCache_Fill(t *testing.T) {
@@ -24,7 +32,7 @@ func TestCache_Fill(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := NewCache()
+			c := NewCacheWithTTL(time.Hour, testClock)
 
 			c.Fill(tt.args.assets)
 
@@ -41,6 +49,23 @@ func TestCache_Fill(t *testing.T) {
 	}
 }
 
+func TestCache_FillWithTTL(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	c := NewCacheWithTTL(time.Minute, clk)
+	c.Fill([]Asset{{ID: "id1"}})
+	if c.IsExpired() {
+		t.Fatal("Cache should not be expired immediately after being refilled")
+	}
+	if !c.Updated().Equal(testNow) {
+		t.Errorf("Cache should be updated at %v, got %v", testNow, c.Updated())
+	}
+
+	clk.Advance(time.Minute + time.Second)
+	if !c.IsExpired() {
+		t.Error("Cache should be expired after its ttl")
+	}
+}
+
 func TestCache_GetPage(t *testing.T) {
 	type fields struct {
 		assets  []Asset
@@ -59,13 +84,13 @@ func TestCache_GetPage(t *testing.T) {
 		args   args
 		want   AssetPage
 	}{
-		{"page 0", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, time.Now().Add(time.Hour * 1)}, args{0, 1}, AssetPage{[]Asset{}, 0, 1, 2}},
-		{"page after last", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, time.Now().Add(time.Hour * 1)}, args{15, 1}, AssetPage{[]Asset{}, 15, 1, 2}},
-		{"page not full", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, time.Now().Add(time.Hour * 1)}, args{1, 3}, AssetPage{[]Asset{a1, a2}, 1, 3, 2}},
-		{"size 0", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, time.Now().Add(time.Hour * 1)}, args{1, 0}, AssetPage{[]Asset{}, 1, 0, 2}},
-		{"page 1 size 1", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, time.Now().Add(time.Hour * 1)}, args{1, 1}, AssetPage{[]Asset{a1}, 1, 1, 2}},
-		{"page 2 size 1", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, time.Now().Add(time.Hour * 1)}, args{2, 1}, AssetPage{[]Asset{a2}, 2, 1, 2}},
-		{"expired", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, time.Now().Add(-time.Hour * 1)}, args{1, 1}, AssetPage{[]Asset{}, 1, 1, 0}},
+		{"page 0", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, testNow.Add(time.Hour * 1)}, args{0, 1}, AssetPage{[]Asset{}, 0, 1, 2}},
+		{"page after last", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, testNow.Add(time.Hour * 1)}, args{15, 1}, AssetPage{[]Asset{}, 15, 1, 2}},
+		{"page not full", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, testNow.Add(time.Hour * 1)}, args{1, 3}, AssetPage{[]Asset{a1, a2}, 1, 3, 2}},
+		{"size 0", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, testNow.Add(time.Hour * 1)}, args{1, 0}, AssetPage{[]Asset{}, 1, 0, 2}},
+		{"page 1 size 1", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, testNow.Add(time.Hour * 1)}, args{1, 1}, AssetPage{[]Asset{a1}, 1, 1, 2}},
+		{"page 2 size 1", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, testNow.Add(time.Hour * 1)}, args{2, 1}, AssetPage{[]Asset{a2}, 2, 1, 2}},
+		{"expired", fields{[]Asset{a1, a2}, map[string]int{a1.ID: 0, a2.ID: 1}, testNow.Add(-time.Hour * 1)}, args{1, 1}, AssetPage{[]Asset{}, 1, 1, 0}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -73,6 +98,7 @@ func TestCache_GetPage(t *testing.T) {
 				assets:  tt.fields.assets,
 				ids:     tt.fields.ids,
 				expires: tt.fields.expires,
+				clock:   testClock,
 			}
 			if got := c.GetPage(tt.args.page, tt.args.size); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Cache.GetPage() = %v, want %v", got, tt.want)
@@ -96,10 +122,10 @@ func TestCache_GetAsset(t *testing.T) {
 		args   args
 		want   *Asset
 	}{
-		{"get existing asset", fields{[]Asset{{ID: "id1"}}, map[string]int{"id1": 0}, time.Now().Add(time.Hour * 1)}, args{"id1"}, &Asset{ID: "id1"}},
-		{"get not existing asset", fields{[]Asset{{ID: "id1"}}, map[string]int{"id1": 0}, time.Now().Add(time.Hour * 1)}, args{"id2"}, nil},
-		{"get not existing asset empty cache", fields{[]Asset{}, map[string]int{}, time.Now().Add(time.Hour * 1)}, args{"id1"}, nil},
-		{"expired cache", fields{[]Asset{{ID: "id1"}}, map[string]int{"id1": 0}, time.Now().Add(-time.Hour * 1)}, args{"id1"}, nil},
+		{"get existing asset", fields{[]Asset{{ID: "id1"}}, map[string]int{"id1": 0}, testNow.Add(time.Hour * 1)}, args{"id1"}, &Asset{ID: "id1"}},
+		{"get not existing asset", fields{[]Asset{{ID: "id1"}}, map[string]int{"id1": 0}, testNow.Add(time.Hour * 1)}, args{"id2"}, nil},
+		{"get not existing asset empty cache", fields{[]Asset{}, map[string]int{}, testNow.Add(time.Hour * 1)}, args{"id1"}, nil},
+		{"expired cache", fields{[]Asset{{ID: "id1"}}, map[string]int{"id1": 0}, testNow.Add(-time.Hour * 1)}, args{"id1"}, nil},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -107,6 +133,7 @@ func TestCache_GetAsset(t *testing.T) {
 				assets:  tt.fields.assets,
 				ids:     tt.fields.ids,
 				expires: tt.fields.expires,
+				clock:   testClock,
 			}
 			if got := c.GetAsset(tt.args.id); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Cache.GetAsset() = %v, want %v", got, tt.want)
@@ -124,13 +151,14 @@ func TestCache_IsExpired(t *testing.T) {
 		fields fields
 		want   bool
 	}{
-		{"true", fields{time.Now().Add(-1 * time.Hour)}, true},
-		{"false", fields{time.Now().Add(1 * time.Hour)}, false},
+		{"true", fields{testNow.Add(-1 * time.Hour)}, true},
+		{"false", fields{testNow.Add(1 * time.Hour)}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := Cache{
 				expires: tt.fields.expires,
+				clock:   testClock,
 			}
 			if got := c.IsExpired(); got != tt.want {
 				t.Errorf("Cache.IsExpired() = %v, want %v", got, tt.want)
@@ -138,3 +166,73 @@ func TestCache_IsExpired(t *testing.T) {
 		})
 	}
 }
+
+func TestCache_Find(t *testing.T) {
+	type args struct {
+		query AssetQuery
+		page  int
+		size  int
+	}
+	btcStart := time.Date(2010, time.July, 17, 0, 0, 0, 0, time.UTC)
+	ethStart := time.Date(2015, time.August, 7, 0, 0, 0, 0, time.UTC)
+	btc := Asset{ID: "BTC", Name: "Bitcoin", IsCrypto: true, PriceUSD: 40000, Volume1hUSD: 20, Volume24hUSD: 500, Volume30dUSD: 9000, DataStart: &btcStart}
+	eth := Asset{ID: "ETH", Name: "Ethereum", IsCrypto: true, PriceUSD: 3000, Volume1hUSD: 30, Volume24hUSD: 400, Volume30dUSD: 8000, DataStart: &ethStart}
+	usd := Asset{ID: "USD", Name: "US Dollar", IsCrypto: false, PriceUSD: 1, Volume1hUSD: 50, Volume24hUSD: 1000, Volume30dUSD: 30000}
+	wbtc := Asset{ID: "WBTC", Name: "Wrapped Bitcoin", IsCrypto: true, PriceUSD: 39900, Volume24hUSD: 1}
+	crypto := true
+	fiat := false
+	tests := []struct {
+		name string
+		args args
+		want AssetPage
+	}{
+		{"no criteria keeps external order", args{AssetQuery{}, 1, 10}, AssetPage{[]Asset{btc, eth, usd, wbtc}, 1, 10, 4}},
+		{"search by id", args{AssetQuery{Search: "eth"}, 1, 10}, AssetPage{[]Asset{eth}, 1, 10, 1}},
+		{"search by name", args{AssetQuery{Search: "BITCOIN"}, 1, 10}, AssetPage{[]Asset{btc, wbtc}, 1, 10, 2}},
+		{"only crypto", args{AssetQuery{IsCrypto: &crypto}, 1, 10}, AssetPage{[]Asset{btc, eth, wbtc}, 1, 10, 3}},
+		{"only fiat", args{AssetQuery{IsCrypto: &fiat}, 1, 10}, AssetPage{[]Asset{usd}, 1, 10, 1}},
+		{"price range", args{AssetQuery{MinPrice: 2, MaxPrice: 39900}, 1, 10}, AssetPage{[]Asset{eth, wbtc}, 1, 10, 2}},
+		{"sort by name", args{AssetQuery{SortBy: SortByName}, 1, 10}, AssetPage{[]Asset{btc, eth, usd, wbtc}, 1, 10, 4}},
+		{"sort by price desc", args{AssetQuery{SortBy: SortByPrice, Desc: true}, 1, 10}, AssetPage{[]Asset{btc, wbtc, eth, usd}, 1, 10, 4}},
+		{"sort by price with range", args{AssetQuery{SortBy: SortByPrice, MinPrice: 1, MaxPrice: 3000}, 1, 10}, AssetPage{[]Asset{usd, eth}, 1, 10, 2}},
+		{"sort by id desc", args{AssetQuery{SortBy: SortByID, Desc: true}, 1, 10}, AssetPage{[]Asset{wbtc, usd, eth, btc}, 1, 10, 4}},
+		{"sort by volume 1h desc", args{AssetQuery{SortBy: SortByVolume1h, Desc: true}, 1, 10}, AssetPage{[]Asset{usd, eth, btc, wbtc}, 1, 10, 4}},
+		{"sort by volume 30d", args{AssetQuery{SortBy: SortByVolume30d}, 1, 10}, AssetPage{[]Asset{wbtc, eth, btc, usd}, 1, 10, 4}},
+		{"min volume 24h", args{AssetQuery{MinVolume24h: 450, SortBy: SortByVolume24h}, 1, 10}, AssetPage{[]Asset{btc, usd}, 1, 10, 2}},
+		{"sort by data start, unknown last", args{AssetQuery{SortBy: SortByDataStart}, 1, 10}, AssetPage{[]Asset{btc, eth, usd, wbtc}, 1, 10, 4}},
+		{"total is filtered count", args{AssetQuery{IsCrypto: &crypto, SortBy: SortByPrice}, 2, 2}, AssetPage{[]Asset{btc}, 2, 2, 3}},
+		{"no matches", args{AssetQuery{Search: "doge"}, 1, 10}, AssetPage{[]Asset{}, 1, 10, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCacheWithTTL(time.Hour, testClock)
+			c.Fill([]Asset{btc, eth, usd, wbtc})
+
+			if got := c.Find(tt.args.query, tt.args.page, tt.args.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cache.Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssetQuery_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   AssetQuery
+		wantErr bool
+	}{
+		{"empty", AssetQuery{}, false},
+		{"valid", AssetQuery{Search: "b", MinPrice: 1, MaxPrice: 2, SortBy: SortByPrice, Desc: true}, false},
+		{"negative price", AssetQuery{MinPrice: -1}, true},
+		{"min above max", AssetQuery{MinPrice: 3, MaxPrice: 2}, true},
+		{"negative volume", AssetQuery{MinVolume24h: -1}, true},
+		{"unknown sort field", AssetQuery{SortBy: "color"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.query.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("AssetQuery.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -17,7 +17,12 @@ type Client struct {
 
 // Client constructor.
 func NewClient() *Client {
-	return &Client{&http.Client{}, config.NewCoinAPI()}
+	return NewClientWithConfig(config.NewCoinAPI())
+}
+
+// Client constructor for an external api with the given configuration.
+func NewClientWithConfig(config *config.CoinAPI) *Client {
+	return &Client{&http.Client{}, config}
 }
 
 // Gets all assets from external api.
@@ -0,0 +1,116 @@
+package coinapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+const (
+	assetsFixture = "testdata/assets.json"
+	// an asset with invalid data dates among valid ones
+	badDatesFixture = "testdata/assets_bad_dates.json"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &Client{server.Client(), &config.CoinAPI{AssetsUrl: server.URL + "/v1/assets", ApiKeyHeader: "X-CoinAPI-Key", ApiKey: "test-key"}}
+}
+
+func TestClient_GetAssets(t *testing.T) {
+	fixture, err := os.ReadFile(assetsFixture)
+	if err != nil {
+		t.Fatalf("could not read fixture %s, %v", assetsFixture, err)
+	}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CoinAPI-Key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Invalid API key"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	})
+
+	assets, err := c.GetAssets()
+	if err != nil {
+		t.Fatalf("Client.GetAssets() error = %v", err)
+	}
+
+	if len(assets) != 4 {
+		t.Fatalf("Client.GetAssets() returned %d assets, want 4 priced assets", len(assets))
+	}
+	btc := assets[0]
+	if btc.ID != "BTC" || btc.Name != "Bitcoin" || !btc.IsCrypto || btc.PriceUSD != 43868.37436531734 {
+		t.Errorf("Client.GetAssets() parsed BTC basic fields incorrectly: %+v", btc)
+	}
+	if btc.Volume1hUSD != 2071963851427.66 || btc.Volume24hUSD != 49917563296537.52 || btc.Volume30dUSD != 1871003716838457.97 {
+		t.Errorf("Client.GetAssets() parsed BTC volumes incorrectly: %+v", btc)
+	}
+	if btc.DataStart == nil || btc.DataStart.Format(dataDateLayout) != "2010-07-17" || btc.DataEnd == nil || btc.DataEnd.Format(dataDateLayout) != "2022-02-17" {
+		t.Errorf("Client.GetAssets() parsed BTC data dates incorrectly: %v - %v", btc.DataStart, btc.DataEnd)
+	}
+	if btc.IconID != "4caf2b16-a017-4e26-a348-2cea69c34cba" {
+		t.Errorf("Client.GetAssets() parsed BTC icon incorrectly: %s", btc.IconID)
+	}
+	if usd := assets[2]; usd.ID != "USD" || usd.IsCrypto {
+		t.Errorf("Client.GetAssets() parsed USD incorrectly: %+v", usd)
+	}
+}
+
+func TestClient_GetAssets_BadDates(t *testing.T) {
+	fixture, err := os.ReadFile(badDatesFixture)
+	if err != nil {
+		t.Fatalf("could not read fixture %s, %v", badDatesFixture, err)
+	}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	})
+
+	assets, err := c.GetAssets()
+	if err != nil {
+		t.Fatalf("Client.GetAssets() error = %v, want the assets without the bad dates", err)
+	}
+	if len(assets) != 2 {
+		t.Fatalf("Client.GetAssets() returned %d assets, want 2", len(assets))
+	}
+	if btc := assets[0]; btc.DataStart == nil || btc.DataEnd == nil {
+		t.Errorf("Client.GetAssets() parsed BTC data dates incorrectly: %v - %v", btc.DataStart, btc.DataEnd)
+	}
+	if xyz := assets[1]; xyz.ID != "XYZ" || xyz.PriceUSD != 0.5 || xyz.DataStart != nil || xyz.DataEnd != nil {
+		t.Errorf("Client.GetAssets() = %+v, want XYZ without data dates", xyz)
+	}
+}
+
+func TestClient_GetAssets_Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"error response", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"You have exceeded your API key rate limits"}`))
+		}},
+		{"error response without json", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}},
+		{"malformed json", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"asset_id":`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, tt.handler)
+
+			if _, err := c.GetAssets(); err == nil {
+				t.Errorf("Client.GetAssets() should return error")
+			}
+		})
+	}
+}
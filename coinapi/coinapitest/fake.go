@@ -0,0 +1,258 @@
+// Package coinapitest provides a fake of the external Coin API for development and tests.
+// The fake serves /v1/assets in the format of the external api and has admin endpoints under /admin
+// to move prices, add latency and inject errors while it is running.
+package coinapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// path of the assets endpoint of the external api
+	AssetsPath = "/v1/assets"
+	// prefix of the admin endpoints of the fake
+	AdminPath = "/admin"
+)
+
+// Asset in the format of the external api
+type Asset struct {
+	ID           string  `json:"asset_id"`
+	Name         string  `json:"name"`
+	IsCrypto     int     `json:"type_is_crypto"`
+	PriceUSD     float64 `json:"price_usd,omitempty"`
+	Volume1hUSD  float64 `json:"volume_1hrs_usd,omitempty"`
+	Volume24hUSD float64 `json:"volume_1day_usd,omitempty"`
+	Volume30dUSD float64 `json:"volume_1mth_usd,omitempty"`
+	DataStart    string  `json:"data_start,omitempty"`
+	DataEnd      string  `json:"data_end,omitempty"`
+	IconID       string  `json:"id_icon,omitempty"`
+}
+
+// Fake external api. It is safe for concurrent use and can be controlled from the test
+// through its methods or over http through the admin endpoints.
+type Fake struct {
+	mu     sync.Mutex
+	assets []Asset
+	apiKey string
+
+	latency time.Duration
+	// requests which will fail, negative fails all until cleared
+	failures   int
+	failStatus int
+
+	requests int
+}
+
+// Creates fake which serves the given assets.
+func NewFake(assets []Asset) *Fake {
+	f := &Fake{}
+	f.SetAssets(assets)
+	return f
+}
+
+// Rejects assets requests without the given key in the X-CoinAPI-Key header, empty key accepts all.
+func (f *Fake) RequireAPIKey(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.apiKey = key
+}
+
+// Gets copy of the served assets.
+func (f *Fake) Assets() []Asset {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Asset{}, f.assets...)
+}
+
+// Replaces the served assets.
+func (f *Fake) SetAssets(assets []Asset) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.assets = append([]Asset{}, assets...)
+}
+
+// Sets the price of asset with id, false if the asset is not served.
+func (f *Fake) SetPrice(id string, price float64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.assets {
+		if f.assets[i].ID == id {
+			f.assets[i].PriceUSD = price
+			return true
+		}
+	}
+	return false
+}
+
+// Moves the price of asset with id by percent, false if the asset is not served.
+func (f *Fake) MovePrice(id string, percent float64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.assets {
+		if f.assets[i].ID == id {
+			f.assets[i].PriceUSD *= 1 + percent/100
+			return true
+		}
+	}
+	return false
+}
+
+// Delays every assets response by latency.
+func (f *Fake) SetLatency(latency time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = latency
+}
+
+// Fails the next count assets requests with status, negative count fails all until FailNext(0, 0) is called.
+func (f *Fake) FailNext(count, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = count
+	f.failStatus = status
+}
+
+// Gets the number of assets requests received so far.
+func (f *Fake) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == AssetsPath && r.Method == http.MethodGet:
+		f.serveAssets(w, r)
+	case r.URL.Path == AdminPath+"/assets" && r.Method == http.MethodGet:
+		respond(w, http.StatusOK, f.Assets())
+	case r.URL.Path == AdminPath+"/prices" && r.Method == http.MethodPost:
+		f.servePrices(w, r)
+	case r.URL.Path == AdminPath+"/latency" && r.Method == http.MethodPost:
+		f.serveLatency(w, r)
+	case r.URL.Path == AdminPath+"/errors" && r.Method == http.MethodPost:
+		f.serveErrors(w, r)
+	default:
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("%s %s is not supported", r.Method, r.URL.Path))
+	}
+}
+
+func (f *Fake) serveAssets(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	latency, apiKey := f.latency, f.apiKey
+	failStatus := 0
+	if f.failures != 0 {
+		failStatus = f.failStatus
+		if f.failures > 0 {
+			f.failures--
+		}
+	}
+	assets := append([]Asset{}, f.assets...)
+	f.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if apiKey != "" && r.Header.Get("X-CoinAPI-Key") != apiKey {
+		respondWithError(w, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+	if failStatus != 0 {
+		respondWithError(w, failStatus, "Injected error")
+		return
+	}
+	respond(w, http.StatusOK, assets)
+}
+
+// body of the prices admin request, prices are absolute in USD and changes in percent
+type pricesRequest struct {
+	Prices  map[string]float64 `json:"prices"`
+	Changes map[string]float64 `json:"changes"`
+}
+
+func (f *Fake) servePrices(w http.ResponseWriter, r *http.Request) {
+	var req pricesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("could not parse prices request, %v", err))
+		return
+	}
+
+	var missing []string
+	for id, price := range req.Prices {
+		if !f.SetPrice(strings.ToUpper(id), price) {
+			missing = append(missing, id)
+		}
+	}
+	for id, percent := range req.Changes {
+		if !f.MovePrice(strings.ToUpper(id), percent) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("assets %s are not served", strings.Join(missing, ",")))
+		return
+	}
+	log.Printf("Moved prices of %d assets", len(req.Prices)+len(req.Changes))
+	respond(w, http.StatusOK, f.Assets())
+}
+
+// body of the latency admin request
+type latencyRequest struct {
+	LatencyMs int `json:"latencyMs"`
+}
+
+func (f *Fake) serveLatency(w http.ResponseWriter, r *http.Request) {
+	var req latencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LatencyMs < 0 {
+		respondWithError(w, http.StatusBadRequest, "latencyMs should be a non negative number")
+		return
+	}
+	f.SetLatency(time.Duration(req.LatencyMs) * time.Millisecond)
+	log.Printf("Set latency to %dms", req.LatencyMs)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// body of the errors admin request, negative count fails all requests until count 0 is set
+type errorsRequest struct {
+	Count  int `json:"count"`
+	Status int `json:"status"`
+}
+
+func (f *Fake) serveErrors(w http.ResponseWriter, r *http.Request) {
+	var req errorsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("could not parse errors request, %v", err))
+		return
+	}
+	if req.Count != 0 && (req.Status < 400 || req.Status > 599) {
+		respondWithError(w, http.StatusBadRequest, "status should be an error code between 400 and 599")
+		return
+	}
+	f.FailNext(req.Count, req.Status)
+	log.Printf("Failing next %d requests with code %d", req.Count, req.Status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// responds with error in the format of the external api
+func respondWithError(w http.ResponseWriter, status int, msg string) {
+	respond(w, status, struct {
+		Error string `json:"error"`
+	}{msg})
+}
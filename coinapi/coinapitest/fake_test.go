@@ -0,0 +1,143 @@
+package coinapitest_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/coinapi/coinapitest"
+)
+
+func newTestServer(t *testing.T) (*coinapitest.Server, *coinapi.Client) {
+	assets, err := coinapitest.LoadFixture("../testdata/assets.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := coinapitest.NewServer(assets)
+	t.Cleanup(server.Close)
+	return server, coinapi.NewClientWithConfig(server.CoinAPIConfig())
+}
+
+func priceOf(t *testing.T, client *coinapi.Client, id string) float64 {
+	assets, err := client.GetAssets()
+	if err != nil {
+		t.Fatalf("GetAssets() error = %v", err)
+	}
+	for _, asset := range assets {
+		if asset.ID == id {
+			return asset.PriceUSD
+		}
+	}
+	t.Fatalf("GetAssets() didn't return %s", id)
+	return 0
+}
+
+func TestFake_Assets(t *testing.T) {
+	_, client := newTestServer(t)
+	assets, err := client.GetAssets()
+	if err != nil {
+		t.Fatalf("GetAssets() error = %v", err)
+	}
+	var ids []string
+	for _, asset := range assets {
+		ids = append(ids, asset.ID)
+	}
+	if want := []string{"BTC", "ETH", "USD", "DOGE"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("GetAssets() ids = %v, want %v", ids, want)
+	}
+}
+
+func TestFake_Prices(t *testing.T) {
+	server, client := newTestServer(t)
+
+	server.SetPrice("BTC", 50000)
+	if got := priceOf(t, client, "BTC"); got != 50000 {
+		t.Errorf("price after SetPrice = %v, want 50000", got)
+	}
+
+	response, err := http.Post(server.URL+"/admin/prices", "application/json", strings.NewReader(`{"prices":{"btc":60000},"changes":{"BTC":-50}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("admin prices returned code %d", response.StatusCode)
+	}
+	if got := priceOf(t, client, "BTC"); got != 30000 {
+		t.Errorf("price after admin request = %v, want 30000", got)
+	}
+
+	response, err = http.Post(server.URL+"/admin/prices", "application/json", strings.NewReader(`{"prices":{"XYZ":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("admin prices for missing asset returned code %d", response.StatusCode)
+	}
+}
+
+func TestFake_Errors(t *testing.T) {
+	server, client := newTestServer(t)
+
+	response, err := http.Post(server.URL+"/admin/errors", "application/json", strings.NewReader(`{"count":2,"status":503}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetAssets(); err == nil || !strings.Contains(err.Error(), "503") {
+			t.Fatalf("GetAssets() error = %v, want injected 503", err)
+		}
+	}
+	if _, err := client.GetAssets(); err != nil {
+		t.Errorf("GetAssets() after injected errors error = %v", err)
+	}
+	if got := server.Requests(); got != 3 {
+		t.Errorf("Requests() = %d, want 3", got)
+	}
+}
+
+func TestFake_Latency(t *testing.T) {
+	server, client := newTestServer(t)
+	server.SetLatency(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := client.GetAssets(); err != nil {
+		t.Fatalf("GetAssets() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("GetAssets() took %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestFake_APIKey(t *testing.T) {
+	server, client := newTestServer(t)
+	server.RequireAPIKey("other-key")
+
+	if _, err := client.GetAssets(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("GetAssets() error = %v, want 401", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	assets := coinapitest.Generate(50, 7)
+	if len(assets) != 50 {
+		t.Fatalf("Generate() returned %d assets, want 50", len(assets))
+	}
+	if !reflect.DeepEqual(assets, coinapitest.Generate(50, 7)) {
+		t.Errorf("Generate() with the same seed returned different assets")
+	}
+
+	ids := map[string]bool{}
+	for _, asset := range assets {
+		if ids[asset.ID] || asset.PriceUSD <= 0 {
+			t.Errorf("Generate() returned duplicate or unpriced asset %+v", asset)
+		}
+		ids[asset.ID] = true
+	}
+}
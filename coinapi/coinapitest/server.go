@@ -0,0 +1,26 @@
+package coinapitest
+
+import (
+	"net/http/httptest"
+
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+// Fake external api running on a local test server.
+type Server struct {
+	*httptest.Server
+	*Fake
+}
+
+// Starts a test server with a fake which serves the given assets. It should be closed after use.
+func NewServer(assets []Asset) *Server {
+	fake := NewFake(assets)
+	return &Server{Server: httptest.NewServer(fake), Fake: fake}
+}
+
+// Gets the Coin API configuration which points to the server.
+func (s *Server) CoinAPIConfig() *config.CoinAPI {
+	coinAPI := config.NewCoinAPI()
+	coinAPI.AssetsUrl = s.URL + AssetsPath
+	return coinAPI
+}
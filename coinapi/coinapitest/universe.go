@@ -0,0 +1,76 @@
+package coinapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Loads assets from a JSON file in the format of the external api, such as a saved response.
+func LoadFixture(path string) ([]Asset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open fixture %s, %v", path, err)
+	}
+	defer file.Close()
+
+	var assets []Asset
+	if err := json.NewDecoder(file).Decode(&assets); err != nil {
+		return nil, fmt.Errorf("could not parse fixture %s, %v", path, err)
+	}
+	return assets, nil
+}
+
+// Generates a universe of count assets. The same seed always generates the same assets.
+// The first asset is USD, the rest are mostly crypto with prices between 0.0001 and 100000 USD.
+func Generate(count int, seed int64) []Asset {
+	rnd := rand.New(rand.NewSource(seed))
+	assets := make([]Asset, 0, count)
+	if count <= 0 {
+		return assets
+	}
+
+	assets = append(assets, Asset{ID: "USD", Name: "US Dollar", PriceUSD: 1, DataStart: "2010-07-17", DataEnd: "2022-02-17"})
+	ids := map[string]bool{"USD": true}
+	start := time.Date(2010, 7, 17, 0, 0, 0, 0, time.UTC)
+	for len(assets) < count {
+		id := randomID(rnd)
+		if ids[id] {
+			continue
+		}
+		ids[id] = true
+
+		price := math.Pow(10, rnd.Float64()*9-4)
+		volume24h := price * math.Pow(10, rnd.Float64()*8+2)
+		isCrypto := 0
+		if rnd.Float64() < 0.9 {
+			isCrypto = 1
+		}
+		assets = append(assets, Asset{
+			ID:           id,
+			Name:         fmt.Sprintf("Generated %s", id),
+			IsCrypto:     isCrypto,
+			PriceUSD:     price,
+			Volume1hUSD:  volume24h / 24,
+			Volume24hUSD: volume24h,
+			Volume30dUSD: volume24h * 30,
+			DataStart:    start.AddDate(0, 0, rnd.Intn(4000)).Format("2006-01-02"),
+			DataEnd:      "2022-02-17",
+		})
+	}
+	return assets
+}
+
+const idLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// random id with 3 to 5 letters
+func randomID(rnd *rand.Rand) string {
+	id := make([]byte, 3+rnd.Intn(3))
+	for i := range id {
+		id[i] = idLetters[rnd.Intn(len(idLetters))]
+	}
+	return string(id)
+}
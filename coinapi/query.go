@@ -0,0 +1,191 @@
+package coinapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fields by which assets can be sorted
+const (
+	SortByID        = "id"
+	SortByName      = "name"
+	SortByPrice     = "price"
+	SortByVolume1h  = "volume1h"
+	SortByVolume24h = "volume24h"
+	SortByVolume30d = "volume30d"
+	SortByDataStart = "dataStart"
+)
+
+// compares assets by the field they are sorted by
+var sortFields = map[string]func(a, b Asset) bool{
+	SortByID: func(a, b Asset) bool { return a.ID < b.ID },
+	SortByName: func(a, b Asset) bool {
+		an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+		if an == bn {
+			return a.ID < b.ID
+		}
+		return an < bn
+	},
+	SortByPrice:     byNumber(func(a Asset) float64 { return a.PriceUSD }),
+	SortByVolume1h:  byNumber(func(a Asset) float64 { return a.Volume1hUSD }),
+	SortByVolume24h: byNumber(func(a Asset) float64 { return a.Volume24hUSD }),
+	SortByVolume30d: byNumber(func(a Asset) float64 { return a.Volume30dUSD }),
+	SortByDataStart: func(a, b Asset) bool {
+		as, bs := a.DataStart, b.DataStart
+		if as == nil || bs == nil || as.Equal(*bs) {
+			// assets without data start date go last
+			if (as == nil) != (bs == nil) {
+				return bs == nil
+			}
+			return a.ID < b.ID
+		}
+		return as.Before(*bs)
+	},
+}
+
+func byNumber(field func(a Asset) float64) func(a, b Asset) bool {
+	return func(a, b Asset) bool {
+		if field(a) == field(b) {
+			return a.ID < b.ID
+		}
+		return field(a) < field(b)
+	}
+}
+
+// Criteria used to search, filter and sort assets.
+// Zero values mean that the criterion is not applied
+type AssetQuery struct {
+	// case insensitive text matched against asset id and name
+	Search string
+
+	// if set, only crypto or only non-crypto assets are returned
+	IsCrypto *bool
+
+	// inclusive price range in USD, zero means no bound
+	MinPrice float64
+	MaxPrice float64
+
+	// minimum traded volume in USD over the last 24 hours, zero means no bound
+	MinVolume24h float64
+
+	// field to sort by, if empty the order of the external api is kept
+	SortBy string
+
+	// sort in descending order
+	Desc bool
+}
+
+// Checks if the query criteria are consistent.
+func (q AssetQuery) Validate() error {
+	if q.MinPrice < 0 || q.MaxPrice < 0 {
+		return fmt.Errorf("price bounds should not be negative")
+	}
+	if q.MaxPrice > 0 && q.MinPrice > q.MaxPrice {
+		return fmt.Errorf("min price %f should not be greater than max price %f", q.MinPrice, q.MaxPrice)
+	}
+	if q.MinVolume24h < 0 {
+		return fmt.Errorf("min volume should not be negative")
+	}
+	if _, ok := sortFields[q.SortBy]; q.SortBy != "" && !ok {
+		return fmt.Errorf("assets cannot be sorted by %s", q.SortBy)
+	}
+	return nil
+}
+
+// indexes over the cached assets, rebuilt on every fill
+type assetIndex struct {
+	// lowercased id and name of each asset, used for search
+	keys []string
+
+	// positions of the assets sorted by each sort field
+	sorted map[string][]int
+}
+
+func newAssetIndex(assets []Asset) assetIndex {
+	idx := assetIndex{keys: make([]string, len(assets)), sorted: map[string][]int{}}
+	for i, asset := range assets {
+		idx.keys[i] = strings.ToLower(asset.ID) + "\x00" + strings.ToLower(asset.Name)
+	}
+
+	for field, less := range sortFields {
+		idx.sorted[field] = sortedPositions(assets, less)
+	}
+	return idx
+}
+
+func sortedPositions(assets []Asset, less func(a, b Asset) bool) []int {
+	positions := make([]int, len(assets))
+	for i := range positions {
+		positions[i] = i
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		return less(assets[positions[i]], assets[positions[j]])
+	})
+	return positions
+}
+
+// returns the positions of assets matching the query in the requested order
+func (idx assetIndex) find(assets []Asset, q AssetQuery) []int {
+	order := idx.order(assets, q)
+	search := strings.ToLower(strings.TrimSpace(q.Search))
+
+	matches := make([]int, 0, len(order))
+	for _, pos := range order {
+		if idx.matches(assets[pos], pos, search, q) {
+			matches = append(matches, pos)
+		}
+	}
+	if q.Desc {
+		for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+			matches[i], matches[j] = matches[j], matches[i]
+		}
+	}
+	return matches
+}
+
+// picks the candidate positions in sort order.
+// When sorting by price, the price range is applied with a binary search
+func (idx assetIndex) order(assets []Asset, q AssetQuery) []int {
+	if q.SortBy == "" {
+		positions := make([]int, len(assets))
+		for i := range positions {
+			positions[i] = i
+		}
+		return positions
+	}
+
+	sorted := idx.sorted[q.SortBy]
+	if q.SortBy != SortByPrice {
+		return sorted
+	}
+
+	from := sort.Search(len(sorted), func(i int) bool { return assets[sorted[i]].PriceUSD >= q.MinPrice })
+	to := len(sorted)
+	if q.MaxPrice > 0 {
+		to = sort.Search(len(sorted), func(i int) bool { return assets[sorted[i]].PriceUSD > q.MaxPrice })
+	}
+	if from > to {
+		return []int{}
+	}
+	return sorted[from:to]
+}
+
+func (idx assetIndex) matches(asset Asset, pos int, search string, q AssetQuery) bool {
+	if search != "" && !strings.Contains(idx.keys[pos], search) {
+		return false
+	}
+	if q.IsCrypto != nil && asset.IsCrypto != *q.IsCrypto {
+		return false
+	}
+	if asset.PriceUSD < q.MinPrice {
+		return false
+	}
+	if q.MaxPrice > 0 && asset.PriceUSD > q.MaxPrice {
+		return false
+	}
+	if asset.Volume24hUSD < q.MinVolume24h {
+		return false
+	}
+	return true
+}
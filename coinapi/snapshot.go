@@ -0,0 +1,77 @@
+package coinapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cached assets with the time they were fetched, saved to disk so a restart doesn't start with an empty cache
+type Snapshot struct {
+	Updated time.Time
+	Assets  []Asset
+}
+
+// Asset without the external api format, so it is saved and loaded with the field names of Asset
+type snapshotAsset Asset
+
+type snapshotFile struct {
+	Updated time.Time       `json:"updated"`
+	Assets  []snapshotAsset `json:"assets"`
+}
+
+// Saves snapshot to path. The file is replaced at once, so a crash never leaves a partial snapshot.
+// Returns error if the file can't be written
+func SaveSnapshot(path string, snapshot Snapshot) error {
+	file := snapshotFile{Updated: snapshot.Updated, Assets: make([]snapshotAsset, 0, len(snapshot.Assets))}
+	for _, asset := range snapshot.Assets {
+		file.Assets = append(file.Assets, snapshotAsset(asset))
+	}
+	bytes, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("could not convert cache snapshot to JSON, %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create cache snapshot file, %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write cache snapshot, %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write cache snapshot, %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not replace cache snapshot %s, %v", path, err)
+	}
+	return nil
+}
+
+// Loads snapshot saved with SaveSnapshot from path.
+// Returns nil if there is no snapshot and error if it can't be read
+func LoadSnapshot(path string) (*Snapshot, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read cache snapshot %s, %v", path, err)
+	}
+
+	var file snapshotFile
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return nil, fmt.Errorf("could not parse cache snapshot %s, %v", path, err)
+	}
+	snapshot := Snapshot{Updated: file.Updated, Assets: make([]Asset, 0, len(file.Assets))}
+	for _, asset := range file.Assets {
+		snapshot.Assets = append(snapshot.Assets, Asset(asset))
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,80 @@
+package coinapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+)
+
+func TestSnapshot_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	start := time.Date(2010, 7, 17, 0, 0, 0, 0, time.UTC)
+	snapshot := Snapshot{Updated: testNow, Assets: []Asset{
+		{ID: "BTC", Name: "Bitcoin", IsCrypto: true, PriceUSD: 44000, Volume1hUSD: 1, Volume24hUSD: 24, Volume30dUSD: 720, DataStart: &start, IconID: "icon"},
+		{ID: "USD", Name: "US Dollar", PriceUSD: 1},
+	}}
+
+	if err := SaveSnapshot(path, snapshot); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+	got, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	if !reflect.DeepEqual(*got, snapshot) {
+		t.Errorf("LoadSnapshot() = %+v, want %+v", *got, snapshot)
+	}
+	if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("SaveSnapshot() left %d files, want only the snapshot", len(files))
+	}
+}
+
+func TestSnapshot_LoadMissing(t *testing.T) {
+	got, err := LoadSnapshot(filepath.Join(t.TempDir(), "snapshot.json"))
+	if got != nil || err != nil {
+		t.Errorf("LoadSnapshot() = %v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestSnapshot_LoadCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := os.WriteFile(path, []byte(`{"assets":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(path); err == nil {
+		t.Error("LoadSnapshot() expected error for a corrupted snapshot")
+	}
+}
+
+func TestCache_Restore(t *testing.T) {
+	snapshot := Snapshot{Updated: testNow.Add(-2 * time.Hour), Assets: []Asset{{ID: "BTC", PriceUSD: 44000}}}
+
+	clk := clock.NewFake(testNow)
+	c := NewCacheWithTTL(time.Minute, clk)
+	if c.Restore(snapshot, time.Hour) {
+		t.Fatal("Restore() should not load a snapshot older than max age")
+	}
+	if !c.Restore(snapshot, 3*time.Hour) {
+		t.Fatal("Restore() should load a snapshot younger than max age")
+	}
+	if c.IsExpired() || !c.NeedsRefresh() {
+		t.Fatalf("restored cache should be served and need a refresh, expired %v, needs refresh %v", c.IsExpired(), c.NeedsRefresh())
+	}
+	if got := c.GetAsset("BTC"); got == nil || got.PriceUSD != 44000 || !c.Updated().Equal(snapshot.Updated) {
+		t.Errorf("restored cache asset = %v, updated %v", got, c.Updated())
+	}
+
+	clk.Advance(time.Hour + time.Second)
+	if !c.IsExpired() {
+		t.Error("restored cache should expire when the snapshot gets older than max age")
+	}
+
+	c.Fill([]Asset{{ID: "BTC", PriceUSD: 45000}})
+	if c.NeedsRefresh() {
+		t.Error("cache should not need a refresh after it is filled")
+	}
+}
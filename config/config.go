@@ -1,7 +1,13 @@
 // package config keeps types of configs used in the application
 package config
 
-import "time"
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const (
 	user     = "root"
@@ -31,18 +37,277 @@ type CoinAPI struct {
 	ApiKey       string
 }
 
+// The url and key can be overridden with COINAPI_ASSETS_URL and COINAPI_KEY, e.g. to use a fake server for development
 func NewCoinAPI() *CoinAPI {
-	return &CoinAPI{assetsUrl, apiKeyHeader, apiKey}
+	return &CoinAPI{getEnv("COINAPI_ASSETS_URL", assetsUrl), apiKeyHeader, getEnv("COINAPI_KEY", apiKey)}
+}
+
+// gets environment variable or the default value if it is not set
+func getEnv(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// gets positive integer environment variable or the default value if it is not set or invalid
+func getEnvPositiveInt(key string, defaultValue int) int {
+	number := getEnvInt64(key, int64(defaultValue))
+	if number <= 0 {
+		log.Printf("Invalid number %d in %s, using %d", number, key, defaultValue)
+		return defaultValue
+	}
+	return int(number)
+}
+
+// gets duration environment variable, such as 10s, or the default value if it is not set or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid duration %q in %s, using %v", value, key, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+// gets integer environment variable or the default value if it is not set or invalid
+func getEnvInt64(key string, defaultValue int64) int64 {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid number %q in %s, using %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+// gets positive decimal environment variable or the default value if it is not set or invalid
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 {
+		log.Printf("Invalid number %q in %s, using %f", value, key, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+// sources of asset prices
+const (
+	// the external Coin API
+	PriceSourceCoinAPI = "coinapi"
+	// the synthetic market simulator
+	PriceSourceSimulator = "simulator"
+	// the replay of historical prices from a file
+	PriceSourceReplay = "replay"
+)
+
+const (
+	pricesCacheTTL = 30 * time.Minute
+	simulatorSeed  = 1
+	simulatorStep  = time.Minute
+	replaySpeed    = 60
+
+	cacheSnapshotFile   = "./cache_snapshot.json"
+	cacheSnapshotMaxAge = 24 * time.Hour
+)
+
+// Prices configuration - where prices come from and for how long they are cached
+type Prices struct {
+	// coinapi, simulator or replay
+	Source   string
+	CacheTTL time.Duration
+
+	// JSON file with the simulated assets and shocks, the default market is simulated if not set
+	SimulatorScenario string
+	SimulatorSeed     int64
+	// simulated time which passes on each refresh
+	SimulatorStep time.Duration
+
+	// CSV or JSONL file with historical prices
+	ReplayFile string
+	// how many times the replayed market is faster than the wall time
+	ReplaySpeed float64
+
+	// file where the cache of coinapi prices is saved on every refresh and loaded from at startup
+	SnapshotFile string
+	// snapshot older than this is not served
+	SnapshotMaxAge time.Duration
+}
+
+// Read from PRICE_SOURCE, PRICE_CACHE_TTL, SIMULATOR_SCENARIO, SIMULATOR_SEED, SIMULATOR_STEP, REPLAY_FILE, REPLAY_SPEED,
+// CACHE_SNAPSHOT_FILE and CACHE_SNAPSHOT_MAX_AGE
+func NewPrices() *Prices {
+	return &Prices{
+		Source:            getEnv("PRICE_SOURCE", PriceSourceCoinAPI),
+		CacheTTL:          getEnvDuration("PRICE_CACHE_TTL", pricesCacheTTL),
+		SimulatorScenario: getEnv("SIMULATOR_SCENARIO", ""),
+		SimulatorSeed:     getEnvInt64("SIMULATOR_SEED", simulatorSeed),
+		SimulatorStep:     getEnvDuration("SIMULATOR_STEP", simulatorStep),
+		ReplayFile:        getEnv("REPLAY_FILE", ""),
+		ReplaySpeed:       getEnvFloat("REPLAY_SPEED", replaySpeed),
+		SnapshotFile:      getEnv("CACHE_SNAPSHOT_FILE", cacheSnapshotFile),
+		SnapshotMaxAge:    getEnvDuration("CACHE_SNAPSHOT_MAX_AGE", cacheSnapshotMaxAge),
+	}
+}
+
+// models of the spread between bid and ask prices
+const (
+	// every asset has the same spread
+	SpreadFixed = "fixed"
+	// the spread narrows as the traded volume of the asset grows
+	SpreadVolume = "volume"
+)
+
+const spreadBps = 10
+
+// Spread configuration - how far the bid and ask prices are from the mid price, in basis points
+type Spread struct {
+	// fixed or volume
+	Model string
+	// spread of every asset for the fixed model, spread of an asset with 1 billion USD daily volume for the volume model
+	Bps float64
+	// spreads of specific assets which override the model
+	AssetBps map[string]float64
+}
+
+// Read from SPREAD_MODEL, SPREAD_BPS and SPREAD_ASSET_BPS, a comma separated list such as BTC=5,ETH=8
+func NewSpread() *Spread {
+	return &Spread{
+		Model:    getEnv("SPREAD_MODEL", SpreadVolume),
+		Bps:      getEnvFloat("SPREAD_BPS", spreadBps),
+		AssetBps: parseAssetBps(getEnv("SPREAD_ASSET_BPS", "")),
+	}
+}
+
+// parses id=bps pairs, invalid pairs are skipped
+func parseAssetBps(value string) map[string]float64 {
+	assetBps := map[string]float64{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			log.Printf("Invalid asset spread %q in SPREAD_ASSET_BPS, skipping it", pair)
+			continue
+		}
+		bps, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || bps < 0 {
+			log.Printf("Invalid asset spread %q in SPREAD_ASSET_BPS, skipping it", pair)
+			continue
+		}
+		assetBps[strings.ToUpper(strings.TrimSpace(parts[0]))] = bps
+	}
+	return assetBps
+}
+
+const (
+	executionDepthFraction = 0.01
+	executionLevels        = 10
+	executionLevelStepBps  = 10
+	executionRecovery      = 5 * time.Minute
+)
+
+// Execution configuration - the virtual order book against which buy and sell orders are executed
+type Execution struct {
+	// part of the daily volume of an asset available in the book
+	DepthFraction float64
+	// number of book levels, each with an equal part of the depth
+	Levels int
+	// distance between levels in basis points of the quoted price
+	LevelStepBps float64
+	// time in which depth taken by orders recovers, every order sees the full book if zero
+	Recovery time.Duration
+}
+
+// Read from EXECUTION_DEPTH, EXECUTION_LEVELS, EXECUTION_LEVEL_BPS and EXECUTION_RECOVERY
+func NewExecution() *Execution {
+	return &Execution{
+		DepthFraction: getEnvFloat("EXECUTION_DEPTH", executionDepthFraction),
+		Levels:        int(getEnvInt64("EXECUTION_LEVELS", executionLevels)),
+		LevelStepBps:  getEnvFloat("EXECUTION_LEVEL_BPS", executionLevelStepBps),
+		Recovery:      getEnvDuration("EXECUTION_RECOVERY", executionRecovery),
+	}
+}
+
+const (
+	historyInterval  = 5 * time.Minute
+	historyRetention = 8 * 24 * time.Hour
+	historyMaxGap    = time.Hour
+	moversLimit      = 10
+)
+
+// Price history configuration - the downsampled snapshots of prices from which price changes are computed
+type History struct {
+	// minimal time between two stored snapshots, refreshes in between are not stored
+	Interval time.Duration
+	// snapshots older than this are deleted
+	Retention time.Duration
+	// a snapshot is the price at a time only if it was taken at most this long before it
+	MaxGap time.Duration
+}
+
+// Read from PRICE_HISTORY_INTERVAL, PRICE_HISTORY_RETENTION and PRICE_HISTORY_MAX_GAP
+func NewHistory() *History {
+	return &History{
+		Interval:  getEnvDuration("PRICE_HISTORY_INTERVAL", historyInterval),
+		Retention: getEnvDuration("PRICE_HISTORY_RETENTION", historyRetention),
+		MaxGap:    getEnvDuration("PRICE_HISTORY_MAX_GAP", historyMaxGap),
+	}
+}
+
+// Market movers configuration
+type Movers struct {
+	// number of assets in every list of movers
+	Limit int
+}
+
+// Read from MOVERS_LIMIT
+func NewMovers() *Movers {
+	return &Movers{Limit: getEnvPositiveInt("MOVERS_LIMIT", moversLimit)}
+}
+
+// Admin API configuration. The admin API is disabled if the token is not set in ADMIN_TOKEN
+type Admin struct {
+	Token       string
+	TokenHeader string
+}
+
+func NewAdmin() *Admin {
+	return &Admin{Token: getEnv("ADMIN_TOKEN", ""), TokenHeader: adminTokenHeader}
 }
 
 const (
 	host = "localhost"
 	port = "7777"
 
-	usersApiV1        = "/api/v1/users"
-	assetsApiV1       = "/api/v1/assets"
-	userAssetsApiV1   = "/api/v1/users/{username}/assets"
-	acquisitionsApiV1 = "/api/v1/acquisitions"
+	usersApiV1         = "/api/v1/users"
+	assetsApiV1        = "/api/v1/assets"
+	userAssetsApiV1    = "/api/v1/users/{username}/assets"
+	acquisitionsApiV1  = "/api/v1/acquisitions"
+	convertApiV1       = "/api/v1/convert"
+	ratesApiV1         = "/api/v1/rates"
+	streamApiV1        = "/api/v1/stream"
+	alertsApiV1        = "/api/v1/users/{username}/alerts"
+	notificationsApiV1 = "/api/v1/users/{username}/notifications"
+	watchlistsApiV1    = "/api/v1/users/{username}/watchlists"
+	marketsApiV1       = "/api/v1/markets"
+	apiKeysApiV1       = "/api/v1/users/{username}/apikeys"
+	adminApiV1         = "/api/v1/admin"
+	accountApiV1       = "/api/v1/account"
+
+	adminTokenHeader = "X-Admin-Token"
 )
 
 // Application configuration
@@ -50,27 +315,225 @@ type App struct {
 	Host string
 	Port string
 
-	UsersApiV1        string
-	AssetsApiV1       string
-	UserAssetsApiV1   string
-	AcquisitionsApiV1 string
+	UsersApiV1         string
+	AssetsApiV1        string
+	UserAssetsApiV1    string
+	AcquisitionsApiV1  string
+	ConvertApiV1       string
+	RatesApiV1         string
+	StreamApiV1        string
+	AlertsApiV1        string
+	NotificationsApiV1 string
+	WatchlistsApiV1    string
+	MarketsApiV1       string
+	APIKeysApiV1       string
+	AdminApiV1         string
+	AccountApiV1       string
 }
 
 func NewApp() *App {
-	return &App{Host: host, Port: port, UserAssetsApiV1: userAssetsApiV1, UsersApiV1: usersApiV1, AssetsApiV1: assetsApiV1, AcquisitionsApiV1: acquisitionsApiV1}
+	return &App{Host: host, Port: port, UserAssetsApiV1: userAssetsApiV1, UsersApiV1: usersApiV1, AssetsApiV1: assetsApiV1, AcquisitionsApiV1: acquisitionsApiV1,
+		ConvertApiV1: convertApiV1, RatesApiV1: ratesApiV1, StreamApiV1: streamApiV1,
+		AlertsApiV1: alertsApiV1, NotificationsApiV1: notificationsApiV1, WatchlistsApiV1: watchlistsApiV1, MarketsApiV1: marketsApiV1,
+		APIKeysApiV1: apiKeysApiV1, AdminApiV1: adminApiV1, AccountApiV1: accountApiV1}
 }
 
+// stores of sessions
+const (
+	// sessions are kept in memory and lost on restart
+	SessionStoreMemory = "memory"
+	// sessions are kept in the database and shared by all instances
+	SessionStoreMysql = "mysql"
+)
+
 const (
-	sessionDuration   = time.Hour
-	sessionCookieName = "CURRENCY-MASTER-SESSION-ID"
+	sessionDuration        = time.Hour
+	sessionCookieName      = "CURRENCY-MASTER-SESSION-ID"
+	sessionCleanupInterval = time.Hour
 )
 
 // Session configuration
 type Session struct {
 	SessionDuration   time.Duration
 	SessionCookieName string
+	// memory or mysql
+	Store string
+	// how often expired sessions are deleted from the store
+	CleanupInterval time.Duration
 }
 
+// The store is read from SESSION_STORE and the cleanup interval from SESSION_CLEANUP_INTERVAL
 func NewSession() *Session {
-	return &Session{SessionDuration: sessionDuration, SessionCookieName: sessionCookieName}
+	return &Session{SessionDuration: sessionDuration, SessionCookieName: sessionCookieName,
+		Store: getEnv("SESSION_STORE", SessionStoreMemory), CleanupInterval: getEnvDuration("SESSION_CLEANUP_INTERVAL", sessionCleanupInterval)}
+}
+
+const signingMaxSkew = 30 * time.Second
+
+// Request signing configuration. Signed requests are disabled if the secret key is not set
+type Signing struct {
+	// signed requests whose timestamp differs from the server time by more than this are rejected
+	MaxSkew time.Duration
+	// base64 encoded 32 bytes key which encrypts the signing secrets of the api keys in the database
+	SecretKey string
+}
+
+// Read from SIGNING_MAX_SKEW and SIGNING_SECRET_KEY
+func NewSigning() *Signing {
+	return &Signing{MaxSkew: getEnvDuration("SIGNING_MAX_SKEW", signingMaxSkew), SecretKey: getEnv("SIGNING_SECRET_KEY", "")}
+}
+
+const passwordHashCost = 10
+
+// Password configuration
+type Password struct {
+	// bcrypt cost of the password hashes, stored hashes of a lower cost are rehashed when their user logs in
+	HashCost int
+}
+
+// Read from PASSWORD_HASH_COST
+func NewPassword() *Password {
+	return &Password{HashCost: int(getEnvInt64("PASSWORD_HASH_COST", passwordHashCost))}
+}
+
+const webhookTimeout = 5 * time.Second
+
+// Notifications delivery configuration, emails are sent by the configured mailer
+type Notify struct {
+	WebhookTimeout time.Duration
+	// networks in CIDR notation webhooks may be posted to although they are private, such as an internal receiver
+	WebhookAllowedNetworks []string
+}
+
+// Read from WEBHOOK_ALLOWED_NETWORKS, separated by commas
+func NewNotify() *Notify {
+	return &Notify{WebhookTimeout: webhookTimeout, WebhookAllowedNetworks: strings.FieldsFunc(getEnv("WEBHOOK_ALLOWED_NETWORKS", ""), func(r rune) bool { return r == ',' || r == ' ' })}
+}
+
+// mailers sending emails
+const (
+	// sends through an SMTP server, such as a local development stand-in
+	MailerSMTP = "smtp"
+	// appends the emails to a file
+	MailerFile = "file"
+	// writes the emails to the log
+	MailerLog = "log"
+)
+
+const (
+	smtpHost = "localhost"
+	smtpPort = "1025"
+	smtpFrom = "no-reply@currency-master.local"
+	mailFile = "./mail.log"
+)
+
+// Email configuration
+type Mail struct {
+	// one of smtp, file and log
+	Mailer   string
+	SMTPHost string
+	SMTPPort string
+	From     string
+	// file the file mailer appends to
+	File string
+}
+
+// Read from MAILER, SMTP_HOST, SMTP_PORT, MAIL_FROM and MAIL_FILE
+func NewMail() *Mail {
+	return &Mail{Mailer: getEnv("MAILER", MailerSMTP), SMTPHost: getEnv("SMTP_HOST", smtpHost), SMTPPort: getEnv("SMTP_PORT", smtpPort),
+		From: getEnv("MAIL_FROM", smtpFrom), File: getEnv("MAIL_FILE", mailFile)}
+}
+
+const (
+	publicURL       = "http://localhost:7777"
+	verificationTTL = 48 * time.Hour
+	resetTTL        = time.Hour
+)
+
+// Email verification and password reset configuration
+type Account struct {
+	// url of the application in the links sent by email
+	PublicURL string
+	// how long email verification tokens are valid
+	VerificationTTL time.Duration
+	// how long password reset tokens are valid
+	ResetTTL time.Duration
+}
+
+// Read from PUBLIC_URL, EMAIL_VERIFICATION_TTL and PASSWORD_RESET_TTL
+func NewAccount() *Account {
+	return &Account{PublicURL: getEnv("PUBLIC_URL", publicURL), VerificationTTL: getEnvDuration("EMAIL_VERIFICATION_TTL", verificationTTL),
+		ResetTTL: getEnvDuration("PASSWORD_RESET_TTL", resetTTL)}
+}
+
+const (
+	totpIssuer   = "Currency Master"
+	totpLoginTTL = 5 * time.Minute
+)
+
+// Two-factor authentication configuration
+type TwoFactor struct {
+	// name of the application shown by authenticator apps
+	Issuer string
+	// how long a login waits for the second factor
+	LoginTTL time.Duration
+}
+
+// Read from TOTP_ISSUER and TOTP_LOGIN_TTL
+func NewTwoFactor() *TwoFactor {
+	return &TwoFactor{Issuer: getEnv("TOTP_ISSUER", totpIssuer), LoginTTL: getEnvDuration("TOTP_LOGIN_TTL", totpLoginTTL)}
+}
+
+const (
+	oidcCallbackPath = "/login/oidc/callback"
+	oidcScopes       = "openid email profile"
+)
+
+// OpenID Connect login configuration, login with the identity provider is disabled without an issuer
+type OIDC struct {
+	// url of the identity provider, its configuration is discovered from it
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// url of the callback the provider redirects to after login
+	RedirectURL string
+	Scopes      []string
+}
+
+// Read from OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_REDIRECT_URL and OIDC_SCOPES.
+// The redirect url defaults to the callback under PUBLIC_URL
+func NewOIDC() *OIDC {
+	return &OIDC{Issuer: getEnv("OIDC_ISSUER", ""), ClientID: getEnv("OIDC_CLIENT_ID", ""), ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL: getEnv("OIDC_REDIRECT_URL", getEnv("PUBLIC_URL", publicURL)+oidcCallbackPath), Scopes: strings.Fields(getEnv("OIDC_SCOPES", oidcScopes))}
+}
+
+const (
+	loginMaxFailures   = 5
+	loginIPMaxFailures = 50
+	loginFailureWindow = 15 * time.Minute
+	loginLockout       = 15 * time.Minute
+	loginDelay         = time.Second
+	loginMaxDelay      = 30 * time.Second
+)
+
+// Brute-force protection of the password login
+type Lockout struct {
+	// failed logins of a username within the window which lock it out
+	MaxFailures int
+	// failed logins from an ip address within the window which lock it out
+	IPMaxFailures int
+	// failures older than the window are forgotten
+	FailureWindow time.Duration
+	// how long a username or ip address stays locked out
+	Duration time.Duration
+	// wait before the next login after the first failure, doubled by each further failure
+	Delay time.Duration
+	// longest wait between logins
+	MaxDelay time.Duration
+}
+
+// Read from LOGIN_MAX_FAILURES, LOGIN_IP_MAX_FAILURES, LOGIN_FAILURE_WINDOW, LOGIN_LOCKOUT_DURATION, LOGIN_DELAY and LOGIN_MAX_DELAY
+func NewLockout() *Lockout {
+	return &Lockout{MaxFailures: getEnvPositiveInt("LOGIN_MAX_FAILURES", loginMaxFailures), IPMaxFailures: getEnvPositiveInt("LOGIN_IP_MAX_FAILURES", loginIPMaxFailures),
+		FailureWindow: getEnvDuration("LOGIN_FAILURE_WINDOW", loginFailureWindow), Duration: getEnvDuration("LOGIN_LOCKOUT_DURATION", loginLockout), Delay: getEnvDuration("LOGIN_DELAY", loginDelay), MaxDelay: getEnvDuration("LOGIN_MAX_DELAY", loginMaxDelay)}
 }
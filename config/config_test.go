@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestNewMovers(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     string
+		wantLimit int
+	}{
+		{"default", "", moversLimit},
+		{"set", "3", 3},
+		{"zero", "0", moversLimit},
+		{"negative", "-5", moversLimit},
+		{"not a number", "ten", moversLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MOVERS_LIMIT", tt.limit)
+			if got := NewMovers().Limit; got != tt.wantLimit {
+				t.Errorf("NewMovers().Limit = %d, want %d", got, tt.wantLimit)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/MonikaPalova/currency-master/model"
 )
@@ -11,6 +12,7 @@ import (
 const (
 	selectAcquisitions           = "SELECT username, asset_id, quantity, price_usd, quantity*price_usd AS total_usd, created FROM ACQUISITIONS;"
 	selectAcquisitionsByUsername = "SELECT username, asset_id, quantity, price_usd, quantity*price_usd AS total_usd, created FROM ACQUISITIONS WHERE username=?;"
+	selectTradedAssetsSince      = "SELECT asset_id, COUNT(*), SUM(quantity*price_usd) FROM ACQUISITIONS WHERE created>=? GROUP BY asset_id;"
 	insertAcquisition            = "INSERT INTO ACQUISITIONS (username, asset_id, price_usd, quantity, created) VALUES (?, ?, ?, ?, ?);"
 )
 
@@ -41,6 +43,26 @@ func (a AcquisitionsDBHandler) GetByUsername(username string) ([]model.Acquisiti
 	return deserializeAcquisitions(rows)
 }
 
+// Gets the number and usd volume of acquisitions of every asset acquired since the given time.
+// Returns error on database query error
+func (a AcquisitionsDBHandler) GetTradedSince(since time.Time) ([]model.TradedAsset, error) {
+	rows, err := a.conn.Query(selectTradedAssetsSince, since)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve traded assets from database, %v", err)
+	}
+	defer rows.Close()
+
+	traded := []model.TradedAsset{}
+	for rows.Next() {
+		var asset model.TradedAsset
+		if err := rows.Scan(&asset.AssetId, &asset.Trades, &asset.VolumeUSD); err != nil {
+			return nil, fmt.Errorf("could not read traded asset row, %v", err)
+		}
+		traded = append(traded, asset)
+	}
+	return traded, nil
+}
+
 func deserializeAcquisitions(rows *sql.Rows) ([]model.Acquisition, error) {
 	acqs := []model.Acquisition{}
 	for rows.Next() {
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	selectAPIKeysByUsername = "SELECT id, username, name, prefix, key_hash, secret, scopes, expires, created, last_used FROM API_KEYS WHERE username=? ORDER BY created;"
+	selectAPIKeyByHash      = "SELECT id, username, name, prefix, key_hash, secret, scopes, expires, created, last_used FROM API_KEYS WHERE key_hash=?;"
+	selectAPIKeyByID        = "SELECT id, username, name, prefix, key_hash, secret, scopes, expires, created, last_used FROM API_KEYS WHERE id=?;"
+	insertAPIKey            = "INSERT INTO API_KEYS (id, username, name, prefix, key_hash, secret, scopes, expires, created) VALUES (?,?,?,?,?,?,?,?,?);"
+	deleteAPIKey            = "DELETE FROM API_KEYS WHERE username=? AND id=?;"
+	deleteAPIKeysByUsername = "DELETE FROM API_KEYS WHERE username=?;"
+	updateAPIKeyLastUsed    = "UPDATE API_KEYS SET last_used=? WHERE id=?;"
+)
+
+// Handles sql operations to API_KEYS table.
+type APIKeysDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets all api keys of user.
+// Returns error on database query error
+func (h APIKeysDBHandler) GetByUsername(username string) ([]model.APIKey, error) {
+	rows, err := h.conn.Query(selectAPIKeysByUsername, username)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve api keys from database, %v", err)
+	}
+	return deserializeAPIKeys(rows)
+}
+
+// Gets api key by the hash of the key.
+// Returns nil if there is no such key
+// Returns error on database query error
+func (h APIKeysDBHandler) GetByHash(hash string) (*model.APIKey, error) {
+	rows, err := h.conn.Query(selectAPIKeyByHash, hash)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve api key from database, %v", err)
+	}
+
+	keys, err := deserializeAPIKeys(rows)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return &keys[0], nil
+}
+
+// Gets api key by id.
+// Returns nil if there is no such key
+// Returns error on database query error
+func (h APIKeysDBHandler) GetByID(id string) (*model.APIKey, error) {
+	rows, err := h.conn.Query(selectAPIKeyByID, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve api key from database, %v", err)
+	}
+
+	keys, err := deserializeAPIKeys(rows)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return &keys[0], nil
+}
+
+func deserializeAPIKeys(rows *sql.Rows) ([]model.APIKey, error) {
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		var key model.APIKey
+		var scopes string
+		var expires, lastUsed sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Username, &key.Name, &key.Prefix, &key.Hash, &key.Secret, &scopes, &expires, &key.Created, &lastUsed); err != nil {
+			return nil, fmt.Errorf("could not read api key row, %v", err)
+		}
+		key.Scopes = strings.Split(scopes, ",")
+		if expires.Valid {
+			key.Expires = &expires.Time
+		}
+		if lastUsed.Valid {
+			key.LastUsed = &lastUsed.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Saves a new api key in the database.
+// Returns error if the user does not exist or on database query error
+func (h APIKeysDBHandler) Create(key model.APIKey) (*model.APIKey, error) {
+	var expires *time.Time
+	if key.Expires != nil {
+		utc := key.Expires.UTC()
+		expires = &utc
+	}
+	if _, err := h.conn.Exec(insertAPIKey, key.ID, key.Username, key.Name, key.Prefix, key.Hash, key.Secret, strings.Join(key.Scopes, ","), expires, key.Created); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 {
+			return nil, fmt.Errorf("user with username %s doesn't exist, %v", key.Username, err)
+		}
+		return nil, fmt.Errorf("error when inserting api key in database, %v", err)
+	}
+	return &key, nil
+}
+
+// Deletes api key of user.
+// Returns false if the key does not exist
+// Returns error on database query error
+func (h APIKeysDBHandler) Delete(username, id string) (bool, error) {
+	res, err := h.conn.Exec(deleteAPIKey, username, id)
+	if err != nil {
+		return false, fmt.Errorf("error when deleting api key from database, %v", err)
+	}
+	deleted, _ := res.RowsAffected()
+	return deleted > 0, nil
+}
+
+// Deletes all api keys of user and returns how many there were.
+// Returns error on database query error
+func (h APIKeysDBHandler) DeleteByUsername(username string) (int, error) {
+	res, err := h.conn.Exec(deleteAPIKeysByUsername, username)
+	if err != nil {
+		return 0, fmt.Errorf("error when deleting api keys of user %s from database, %v", username, err)
+	}
+	deleted, _ := res.RowsAffected()
+	return int(deleted), nil
+}
+
+// Sets the time the api key was last used.
+// Returns error on database query error
+func (h APIKeysDBHandler) UpdateLastUsed(id string, used time.Time) error {
+	if _, err := h.conn.Exec(updateAPIKeyLastUsed, used.UTC(), id); err != nil {
+		return fmt.Errorf("error when updating last use of api key in database, %v", err)
+	}
+	return nil
+}
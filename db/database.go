@@ -17,9 +17,21 @@ const (
 type Database struct {
 	conn *sql.DB
 
-	UsersDBHandler        *UsersDBHandler
-	UserAssetsDBHandler   *UserAssetsDBHandler
-	AcquisitionsDBHandler *AcquisitionsDBHandler
+	UsersDBHandler          *UsersDBHandler
+	UserAssetsDBHandler     *UserAssetsDBHandler
+	AcquisitionsDBHandler   *AcquisitionsDBHandler
+	PriceAlertsDBHandler    *PriceAlertsDBHandler
+	NotificationsDBHandler  *NotificationsDBHandler
+	LastPricesDBHandler     *LastPricesDBHandler
+	WatchlistsDBHandler     *WatchlistsDBHandler
+	PriceHistoryDBHandler   *PriceHistoryDBHandler
+	SessionsDBHandler       *SessionsDBHandler
+	APIKeysDBHandler        *APIKeysDBHandler
+	TokensDBHandler         *TokensDBHandler
+	TwoFactorDBHandler      *TwoFactorDBHandler
+	IdentitiesDBHandler     *IdentitiesDBHandler
+	LoginFailuresDBHandler  *LoginFailuresDBHandler
+	SecurityEventsDBHandler *SecurityEventsDBHandler
 }
 
 // Creates new database connection and db handlers.
@@ -40,5 +52,10 @@ func NewDB() (*Database, error) {
 		return nil, fmt.Errorf("request to create tables in db failed, %v", err)
 	}
 
-	return &Database{conn: conn, UsersDBHandler: &UsersDBHandler{conn: conn}, UserAssetsDBHandler: &UserAssetsDBHandler{conn}, AcquisitionsDBHandler: &AcquisitionsDBHandler{conn}}, nil
+	return &Database{conn: conn, UsersDBHandler: &UsersDBHandler{conn: conn}, UserAssetsDBHandler: &UserAssetsDBHandler{conn}, AcquisitionsDBHandler: &AcquisitionsDBHandler{conn},
+		PriceAlertsDBHandler: &PriceAlertsDBHandler{conn}, NotificationsDBHandler: &NotificationsDBHandler{conn}, LastPricesDBHandler: &LastPricesDBHandler{conn},
+		WatchlistsDBHandler: &WatchlistsDBHandler{conn}, PriceHistoryDBHandler: &PriceHistoryDBHandler{conn},
+		SessionsDBHandler: &SessionsDBHandler{conn}, APIKeysDBHandler: &APIKeysDBHandler{conn}, TokensDBHandler: &TokensDBHandler{conn},
+		TwoFactorDBHandler: &TwoFactorDBHandler{conn}, IdentitiesDBHandler: &IdentitiesDBHandler{conn},
+		LoginFailuresDBHandler: &LoginFailuresDBHandler{conn}, SecurityEventsDBHandler: &SecurityEventsDBHandler{conn}}, nil
 }
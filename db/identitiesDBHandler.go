@@ -0,0 +1,48 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	selectIdentityUsername = "SELECT username FROM USER_IDENTITIES WHERE issuer=? AND subject=?;"
+	insertIdentity         = "INSERT INTO USER_IDENTITIES (issuer, subject, username, created) VALUES (?,?,?,?);"
+)
+
+// Handles sql operations to USER_IDENTITIES table.
+type IdentitiesDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets the username of the user linked to the account subject at issuer.
+// Returns empty username if the account is not linked
+// Returns error on database query error
+func (h IdentitiesDBHandler) GetUsername(issuer, subject string) (string, error) {
+	var username string
+	if err := h.conn.QueryRow(selectIdentityUsername, issuer, subject).Scan(&username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not read identity row, %v", err)
+	}
+	return username, nil
+}
+
+// Links a user to an account at an identity provider.
+// Returns false if the account is already linked
+// Returns error on database query error
+func (h IdentitiesDBHandler) Create(identity model.Identity) (bool, error) {
+	if _, err := h.conn.Exec(insertIdentity, identity.Issuer, identity.Subject, identity.Username, identity.Created.UTC()); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			return false, nil
+		}
+		return false, fmt.Errorf("error when inserting identity in database, %v", err)
+	}
+	return true, nil
+}
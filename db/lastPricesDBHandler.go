@@ -0,0 +1,53 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	selectLastPriceById = "SELECT asset_id, name, price_usd, bid_usd, updated FROM LAST_PRICES WHERE asset_id=?;"
+	upsertLastPrice     = "INSERT INTO LAST_PRICES (asset_id, name, price_usd, bid_usd, updated) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE name=VALUES(name), price_usd=VALUES(price_usd), bid_usd=VALUES(bid_usd), updated=VALUES(updated);"
+)
+
+// Handles sql operations to LAST_PRICES table, the last known price of every asset.
+type LastPricesDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets the last known price of asset.
+// Returns nil if the asset was never priced
+// Returns error on database query error
+func (l LastPricesDBHandler) GetByID(id string) (*model.LastPrice, error) {
+	row := l.conn.QueryRow(selectLastPriceById, id)
+
+	var price model.LastPrice
+	if err := row.Scan(&price.AssetId, &price.Name, &price.PriceUSD, &price.BidUSD, &price.Updated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read last price row, %v", err)
+	}
+
+	return &price, nil
+}
+
+// Saves prices as the last known ones, replacing the previous prices of the same assets.
+// Returns error on database query error
+func (l LastPricesDBHandler) Save(prices []model.LastPrice) error {
+	upsertStmt, err := l.conn.Prepare(upsertLastPrice)
+	if err != nil {
+		return fmt.Errorf("error when preparing upsert statement for last prices in database, %v", err)
+	}
+	defer upsertStmt.Close()
+
+	for _, price := range prices {
+		if _, err := upsertStmt.Exec(price.AssetId, price.Name, price.PriceUSD, price.BidUSD, price.Updated); err != nil {
+			return fmt.Errorf("error when saving last price of asset %s in database, %v", price.AssetId, err)
+		}
+	}
+	return nil
+}
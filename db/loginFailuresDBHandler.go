@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	selectLoginFailures = "SELECT kind, subject, failures, first_failure, last_failure, locked_until FROM LOGIN_FAILURES WHERE kind=? AND subject=?;"
+	selectLockedLogins  = "SELECT kind, subject, failures, first_failure, last_failure, locked_until FROM LOGIN_FAILURES WHERE locked_until>? ORDER BY locked_until;"
+	// an empty row to lock while the failures are counted, kept only if some are
+	insertNoLoginFailures = "INSERT IGNORE INTO LOGIN_FAILURES (kind, subject, failures, first_failure, last_failure, locked_until) VALUES (?,?,0,UTC_TIMESTAMP(),UTC_TIMESTAMP(),NULL);"
+	lockLoginFailures     = "SELECT kind, subject, failures, first_failure, last_failure, locked_until FROM LOGIN_FAILURES WHERE kind=? AND subject=? FOR UPDATE;"
+	updateLoginFailures   = "UPDATE LOGIN_FAILURES SET failures=?, first_failure=?, last_failure=?, locked_until=? WHERE kind=? AND subject=?;"
+	deleteLoginFailures   = "DELETE FROM LOGIN_FAILURES WHERE kind=? AND subject=? AND failures>0;"
+)
+
+// Handles sql operations to LOGIN_FAILURES table.
+type LoginFailuresDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets the failed logins of subject of kind.
+// Returns nil if there are none
+// Returns error on database query error
+func (h LoginFailuresDBHandler) Get(kind, subject string) (*model.LoginFailures, error) {
+	failures, err := scanLoginFailures(h.conn.QueryRow(selectLoginFailures, kind, subject))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read login failures row, %v", err)
+	}
+	return failures, nil
+}
+
+// Gets the subjects which are locked out at now, the earliest unlocked first.
+// Returns error on database query error
+func (h LoginFailuresDBHandler) GetLocked(now time.Time) ([]model.LoginFailures, error) {
+	rows, err := h.conn.Query(selectLockedLogins, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve locked logins from database, %v", err)
+	}
+	defer rows.Close()
+
+	locked := []model.LoginFailures{}
+	for rows.Next() {
+		failures, err := scanLoginFailures(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not read login failures row, %v", err)
+		}
+		locked = append(locked, *failures)
+	}
+	return locked, nil
+}
+
+// Updates the failed logins of subject of kind with count while other logins of it wait, so concurrent logins are counted one after another.
+// count gets the failures so far, nil if there are none, and returns them updated or nil to keep them; no failures are deleted
+// Returns error on database query error
+func (h LoginFailuresDBHandler) Count(kind, subject string, count func(*model.LoginFailures) *model.LoginFailures) error {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction to count login failures, %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(insertNoLoginFailures, kind, subject); err != nil {
+		return fmt.Errorf("error when saving login failures in database, %v", err)
+	}
+	failures, err := scanLoginFailures(tx.QueryRow(lockLoginFailures, kind, subject))
+	if err != nil {
+		return fmt.Errorf("could not read login failures row, %v", err)
+	}
+	// the empty row inserted above
+	if failures.Failures == 0 {
+		failures = nil
+	}
+
+	updated := count(failures)
+	if updated == nil {
+		return nil
+	}
+	if updated.Failures <= 0 {
+		if _, err := tx.Exec(deleteLoginFailures, kind, subject); err != nil {
+			return fmt.Errorf("error when deleting login failures from database, %v", err)
+		}
+	} else {
+		var lockedUntil sql.NullTime
+		if updated.LockedUntil != nil {
+			lockedUntil = sql.NullTime{Time: updated.LockedUntil.UTC(), Valid: true}
+		}
+		if _, err := tx.Exec(updateLoginFailures, updated.Failures, updated.FirstFailure.UTC(), updated.LastFailure.UTC(), lockedUntil, kind, subject); err != nil {
+			return fmt.Errorf("error when saving login failures in database, %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit login failures, %v", err)
+	}
+	return nil
+}
+
+// Forgets the failed logins of subject of kind, which unlocks it.
+// Returns false if there were none
+// Returns error on database query error
+func (h LoginFailuresDBHandler) Delete(kind, subject string) (bool, error) {
+	res, err := h.conn.Exec(deleteLoginFailures, kind, subject)
+	if err != nil {
+		return false, fmt.Errorf("error when deleting login failures from database, %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not check deleted login failures in database, %v", err)
+	}
+	return n > 0, nil
+}
+
+// a single row or the current one of rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLoginFailures(row rowScanner) (*model.LoginFailures, error) {
+	var failures model.LoginFailures
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&failures.Kind, &failures.Subject, &failures.Failures, &failures.FirstFailure, &failures.LastFailure, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		failures.LockedUntil = &lockedUntil.Time
+	}
+	return &failures, nil
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	selectNotificationsByUsername = "SELECT id, username, alert_id, message, created, is_read FROM NOTIFICATIONS WHERE username=? ORDER BY created DESC;"
+	insertNotification            = "INSERT INTO NOTIFICATIONS (id, username, alert_id, message, created, is_read) VALUES (?,?,?,?,?,?);"
+	updateNotificationRead        = "UPDATE NOTIFICATIONS SET is_read=TRUE WHERE username=? AND id=?;"
+	countNotification             = "SELECT COUNT(*) FROM NOTIFICATIONS WHERE username=? AND id=?;"
+)
+
+// Handles sql operations to NOTIFICATIONS table, the in-app inbox.
+type NotificationsDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets all notifications of user, newest first.
+// Returns error on database query error
+func (n NotificationsDBHandler) GetByUsername(username string) ([]model.Notification, error) {
+	rows, err := n.conn.Query(selectNotificationsByUsername, username)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve notifications from database, %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []model.Notification{}
+	for rows.Next() {
+		var notification model.Notification
+		if err := rows.Scan(&notification.ID, &notification.Username, &notification.AlertID, &notification.Message, &notification.Created, &notification.Read); err != nil {
+			return nil, fmt.Errorf("could not read notification row, %v", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// Saves a new notification in the database.
+// Returns error on database query error
+func (n NotificationsDBHandler) Create(notification model.Notification) (*model.Notification, error) {
+	insertStmt, err := n.conn.Prepare(insertNotification)
+	if err != nil {
+		return nil, fmt.Errorf("error when preparing insert statement for notification in database, %v", err)
+	}
+	defer insertStmt.Close()
+
+	if _, err = insertStmt.Exec(notification.ID, notification.Username, notification.AlertID, notification.Message, notification.Created, notification.Read); err != nil {
+		return nil, fmt.Errorf("error when inserting notification in database, %v", err)
+	}
+	return &notification, nil
+}
+
+// Marks a notification of user as read.
+// Returns false if the notification does not exist
+// Returns error on database query error
+func (n NotificationsDBHandler) MarkRead(username, id string) (bool, error) {
+	updateStmt, err := n.conn.Prepare(updateNotificationRead)
+	if err != nil {
+		return false, fmt.Errorf("error when preparing update statement for notification in database, %v", err)
+	}
+	defer updateStmt.Close()
+
+	res, err := updateStmt.Exec(username, id)
+	if err != nil {
+		return false, fmt.Errorf("error when updating notification in database, %v", err)
+	}
+	if cnt, _ := res.RowsAffected(); cnt > 0 {
+		return true, nil
+	}
+
+	// mysql reports no affected rows when the notification is already read
+	var cnt int
+	if err := n.conn.QueryRow(countNotification, username, id).Scan(&cnt); err != nil {
+		return false, fmt.Errorf("could not check notification in database, %v", err)
+	}
+	return cnt > 0, nil
+}
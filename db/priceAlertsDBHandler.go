@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	alertColumns           = "id, username, asset_id, alert_condition, threshold, mode, cooldown_minutes, channel, target, reference_price, reference_time, last_triggered, active, created"
+	selectAlertsByUsername = "SELECT " + alertColumns + " FROM PRICE_ALERTS WHERE username=?;"
+	selectAlertByID        = "SELECT " + alertColumns + " FROM PRICE_ALERTS WHERE username=? AND id=?;"
+	selectActiveAlerts     = "SELECT " + alertColumns + " FROM PRICE_ALERTS WHERE active=TRUE;"
+	insertAlert            = "INSERT INTO PRICE_ALERTS (" + alertColumns + ") VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?);"
+	updateAlertState       = "UPDATE PRICE_ALERTS SET reference_price=?, reference_time=?, last_triggered=?, active=? WHERE id=?;"
+	deleteAlert            = "DELETE FROM PRICE_ALERTS WHERE username=? AND id=?;"
+)
+
+// Handles sql operations to PRICE_ALERTS table.
+type PriceAlertsDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets all price alerts of user.
+// Returns error on database query error
+func (p PriceAlertsDBHandler) GetByUsername(username string) ([]model.PriceAlert, error) {
+	rows, err := p.conn.Query(selectAlertsByUsername, username)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve price alerts from database, %v", err)
+	}
+
+	return deserializeAlerts(rows)
+}
+
+// Gets all active price alerts of all users.
+// Returns error on database query error
+func (p PriceAlertsDBHandler) GetActive() ([]model.PriceAlert, error) {
+	rows, err := p.conn.Query(selectActiveAlerts)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve active price alerts from database, %v", err)
+	}
+
+	return deserializeAlerts(rows)
+}
+
+// Gets price alert of user by id.
+// Returns nil if the alert does not exist
+// Returns error on database query error
+func (p PriceAlertsDBHandler) GetByID(username, id string) (*model.PriceAlert, error) {
+	rows, err := p.conn.Query(selectAlertByID, username, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve price alert from database, %v", err)
+	}
+
+	alerts, err := deserializeAlerts(rows)
+	if err != nil || len(alerts) == 0 {
+		return nil, err
+	}
+	return &alerts[0], nil
+}
+
+func deserializeAlerts(rows *sql.Rows) ([]model.PriceAlert, error) {
+	defer rows.Close()
+	alerts := []model.PriceAlert{}
+	for rows.Next() {
+		var alert model.PriceAlert
+		var lastTriggered sql.NullTime
+		if err := rows.Scan(&alert.ID, &alert.Username, &alert.AssetId, &alert.Condition, &alert.Threshold, &alert.Mode, &alert.CooldownMinutes,
+			&alert.Channel, &alert.Target, &alert.ReferencePrice, &alert.ReferenceTime, &lastTriggered, &alert.Active, &alert.Created); err != nil {
+			return nil, fmt.Errorf("could not read price alert row, %v", err)
+		}
+		if lastTriggered.Valid {
+			alert.LastTriggered = &lastTriggered.Time
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+// Saves a new price alert in the database.
+// Returns error if the user does not exist or on database query error
+func (p PriceAlertsDBHandler) Create(alert model.PriceAlert) (*model.PriceAlert, error) {
+	insertStmt, err := p.conn.Prepare(insertAlert)
+	if err != nil {
+		return nil, fmt.Errorf("error when preparing insert statement for price alert in database, %v", err)
+	}
+	defer insertStmt.Close()
+
+	if _, err = insertStmt.Exec(alert.ID, alert.Username, alert.AssetId, alert.Condition, alert.Threshold, alert.Mode, alert.CooldownMinutes,
+		alert.Channel, alert.Target, alert.ReferencePrice, alert.ReferenceTime, alert.LastTriggered, alert.Active, alert.Created); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 {
+			return nil, fmt.Errorf("user with username %s doesn't exist, %v", alert.Username, err)
+		}
+		return nil, fmt.Errorf("error when inserting price alert in database, %v", err)
+	}
+	return &alert, nil
+}
+
+// Updates the evaluation state of a price alert - reference price and time, last trigger and whether it is active.
+// Returns error on database query error
+func (p PriceAlertsDBHandler) UpdateState(alert model.PriceAlert) error {
+	updateStmt, err := p.conn.Prepare(updateAlertState)
+	if err != nil {
+		return fmt.Errorf("error when preparing update statement for price alert in database, %v", err)
+	}
+	defer updateStmt.Close()
+
+	if _, err := updateStmt.Exec(alert.ReferencePrice, alert.ReferenceTime, alert.LastTriggered, alert.Active, alert.ID); err != nil {
+		return fmt.Errorf("error when updating price alert in database, %v", err)
+	}
+	return nil
+}
+
+// Deletes a price alert of user.
+// Returns false if the alert does not exist
+// Returns error on database query error
+func (p PriceAlertsDBHandler) Delete(username, id string) (bool, error) {
+	deleteStmt, err := p.conn.Prepare(deleteAlert)
+	if err != nil {
+		return false, fmt.Errorf("error when preparing delete statement for price alert in database, %v", err)
+	}
+	defer deleteStmt.Close()
+
+	res, err := deleteStmt.Exec(username, id)
+	if err != nil {
+		return false, fmt.Errorf("error when deleting price alert in database, %v", err)
+	}
+	cnt, _ := res.RowsAffected()
+	return cnt > 0, nil
+}
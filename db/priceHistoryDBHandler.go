@@ -0,0 +1,109 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	selectLatestRecorded = "SELECT MAX(recorded) FROM PRICE_HISTORY;"
+	selectPricesAt       = "SELECT asset_id, price_usd, recorded FROM PRICE_HISTORY WHERE recorded=(SELECT MAX(recorded) FROM PRICE_HISTORY WHERE recorded BETWEEN ? AND ?);"
+	selectPriceAt        = "SELECT asset_id, price_usd, recorded FROM PRICE_HISTORY WHERE asset_id=? AND recorded BETWEEN ? AND ? ORDER BY recorded DESC LIMIT 1;"
+	insertPricePoint     = "INSERT INTO PRICE_HISTORY (asset_id, price_usd, recorded) VALUES (?,?,?) ON DUPLICATE KEY UPDATE price_usd=VALUES(price_usd);"
+	deletePricesBefore   = "DELETE FROM PRICE_HISTORY WHERE recorded<?;"
+)
+
+// Handles sql operations to PRICE_HISTORY table, the stored snapshots of asset prices.
+type PriceHistoryDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets the time of the latest snapshot.
+// Returns nil if there are no snapshots
+// Returns error on database query error
+func (h PriceHistoryDBHandler) GetLatestRecorded() (*time.Time, error) {
+	var recorded sql.NullTime
+	if err := h.conn.QueryRow(selectLatestRecorded).Scan(&recorded); err != nil {
+		return nil, fmt.Errorf("could not retrieve latest price snapshot time from database, %v", err)
+	}
+	if !recorded.Valid {
+		return nil, nil
+	}
+	return &recorded.Time, nil
+}
+
+// Gets the prices of all assets in the latest snapshot taken between from and to.
+// Returns empty slice if there is no such snapshot
+// Returns error on database query error
+func (h PriceHistoryDBHandler) GetAllAt(from, to time.Time) ([]model.PricePoint, error) {
+	rows, err := h.conn.Query(selectPricesAt, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve price snapshot from database, %v", err)
+	}
+	defer rows.Close()
+
+	points := []model.PricePoint{}
+	for rows.Next() {
+		var point model.PricePoint
+		if err := rows.Scan(&point.AssetId, &point.PriceUSD, &point.Recorded); err != nil {
+			return nil, fmt.Errorf("could not read price history row, %v", err)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// Gets the price of asset in the latest snapshot taken between from and to.
+// Returns nil if the asset is in no such snapshot
+// Returns error on database query error
+func (h PriceHistoryDBHandler) GetAt(id string, from, to time.Time) (*model.PricePoint, error) {
+	row := h.conn.QueryRow(selectPriceAt, id, from, to)
+
+	var point model.PricePoint
+	if err := row.Scan(&point.AssetId, &point.PriceUSD, &point.Recorded); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read price history row, %v", err)
+	}
+	return &point, nil
+}
+
+// Saves a snapshot of prices.
+// Returns error on database query error, in which case nothing is saved
+func (h PriceHistoryDBHandler) Save(points []model.PricePoint) error {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction for price snapshot, %v", err)
+	}
+	defer tx.Rollback()
+
+	insertStmt, err := tx.Prepare(insertPricePoint)
+	if err != nil {
+		return fmt.Errorf("error when preparing insert statement for price history in database, %v", err)
+	}
+	defer insertStmt.Close()
+
+	for _, point := range points {
+		if _, err := insertStmt.Exec(point.AssetId, point.PriceUSD, point.Recorded); err != nil {
+			return fmt.Errorf("error when saving price of asset %s in price history, %v", point.AssetId, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit price snapshot, %v", err)
+	}
+	return nil
+}
+
+// Deletes the snapshots taken before t.
+// Returns error on database query error
+func (h PriceHistoryDBHandler) DeleteBefore(t time.Time) error {
+	if _, err := h.conn.Exec(deletePricesBefore, t); err != nil {
+		return fmt.Errorf("error when deleting old prices from price history, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	insertSecurityEvent  = "INSERT INTO SECURITY_EVENTS (id, type, kind, subject, message, created) VALUES (?,?,?,?,?,?);"
+	selectSecurityEvents = "SELECT id, type, kind, subject, message, created FROM SECURITY_EVENTS ORDER BY created DESC LIMIT ?;"
+)
+
+// Handles sql operations to SECURITY_EVENTS table.
+type SecurityEventsDBHandler struct {
+	conn *sql.DB
+}
+
+// Saves a new security event.
+// Returns error on database query error
+func (h SecurityEventsDBHandler) Create(event model.SecurityEvent) error {
+	if _, err := h.conn.Exec(insertSecurityEvent, event.ID, event.Type, event.Kind, event.Subject, event.Message, event.Created.UTC()); err != nil {
+		return fmt.Errorf("error when inserting security event in database, %v", err)
+	}
+	return nil
+}
+
+// Gets the latest security events, newest first.
+// Returns error on database query error
+func (h SecurityEventsDBHandler) GetLatest(limit int) ([]model.SecurityEvent, error) {
+	rows, err := h.conn.Query(selectSecurityEvents, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve security events from database, %v", err)
+	}
+	defer rows.Close()
+
+	events := []model.SecurityEvent{}
+	for rows.Next() {
+		var event model.SecurityEvent
+		if err := rows.Scan(&event.ID, &event.Type, &event.Kind, &event.Subject, &event.Message, &event.Created); err != nil {
+			return nil, fmt.Errorf("could not read security event row, %v", err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	selectSessionById     = "SELECT id, username, expiration FROM SESSIONS WHERE id=?;"
+	upsertSession         = "INSERT INTO SESSIONS (id, username, expiration) VALUES (?,?,?) ON DUPLICATE KEY UPDATE username=VALUES(username), expiration=VALUES(expiration);"
+	deleteSession         = "DELETE FROM SESSIONS WHERE id=?;"
+	deleteExpiredSessions = "DELETE FROM SESSIONS WHERE expiration<?;"
+	deleteUserSessions    = "DELETE FROM SESSIONS WHERE username=? AND id<>?;"
+)
+
+// Handles sql operations to SESSIONS table. It is a session store shared by all application instances.
+type SessionsDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets session by id.
+// Returns nil if the session does not exist
+// Returns error on database query error
+func (s SessionsDBHandler) Get(id string) (*model.Session, error) {
+	row := s.conn.QueryRow(selectSessionById, id)
+
+	var session model.Session
+	if err := row.Scan(&session.ID, &session.Username, &session.Expiration); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read session row, %v", err)
+	}
+	return &session, nil
+}
+
+// Saves session, replacing the session with the same id.
+// Returns error on database query error
+func (s SessionsDBHandler) Save(session model.Session) error {
+	if _, err := s.conn.Exec(upsertSession, session.ID, session.Username, session.Expiration.UTC()); err != nil {
+		return fmt.Errorf("error when saving session in database, %v", err)
+	}
+	return nil
+}
+
+// Deletes session by id.
+// Returns error on database query error
+func (s SessionsDBHandler) Delete(id string) error {
+	if _, err := s.conn.Exec(deleteSession, id); err != nil {
+		return fmt.Errorf("error when deleting session from database, %v", err)
+	}
+	return nil
+}
+
+// Deletes the sessions expired at now and returns their number.
+// Returns error on database query error
+func (s SessionsDBHandler) DeleteExpired(now time.Time) (int, error) {
+	res, err := s.conn.Exec(deleteExpiredSessions, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error when deleting expired sessions from database, %v", err)
+	}
+	deleted, _ := res.RowsAffected()
+	return int(deleted), nil
+}
+
+// Deletes the sessions of username except the one with id except and returns their number.
+// Returns error on database query error
+func (s SessionsDBHandler) DeleteByUsername(username, except string) (int, error) {
+	res, err := s.conn.Exec(deleteUserSessions, username, except)
+	if err != nil {
+		return 0, fmt.Errorf("error when deleting sessions of user from database, %v", err)
+	}
+	deleted, _ := res.RowsAffected()
+	return int(deleted), nil
+}
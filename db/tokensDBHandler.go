@@ -0,0 +1,65 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	insertToken            = "INSERT INTO USER_TOKENS (token_hash, username, purpose, expires) VALUES (?,?,?,?);"
+	selectTokenForUpdate   = "SELECT token_hash, username, purpose, expires FROM USER_TOKENS WHERE token_hash=? AND purpose=? FOR UPDATE;"
+	deleteToken            = "DELETE FROM USER_TOKENS WHERE token_hash=?;"
+	deleteTokensByUsername = "DELETE FROM USER_TOKENS WHERE username=? AND purpose=?;"
+)
+
+// Handles sql operations to USER_TOKENS table.
+type TokensDBHandler struct {
+	conn *sql.DB
+}
+
+// Saves a new token.
+// Returns error on database query error
+func (h TokensDBHandler) Create(token model.Token) error {
+	if _, err := h.conn.Exec(insertToken, token.Hash, token.Username, token.Purpose, token.Expires.UTC()); err != nil {
+		return fmt.Errorf("error when inserting token in database, %v", err)
+	}
+	return nil
+}
+
+// Deletes the token with hash and purpose and returns it, so it can be used only once.
+// Returns nil if there is no such token
+// Returns error on database query error
+func (h TokensDBHandler) Use(hash, purpose string) (*model.Token, error) {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not start transaction for token in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	var token model.Token
+	if err := tx.QueryRow(selectTokenForUpdate, hash, purpose).Scan(&token.Hash, &token.Username, &token.Purpose, &token.Expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read token row, %v", err)
+	}
+	if _, err := tx.Exec(deleteToken, hash); err != nil {
+		return nil, fmt.Errorf("error when deleting token from database, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit token use in database, %v", err)
+	}
+	return &token, nil
+}
+
+// Deletes the tokens of user with purpose.
+// Returns error on database query error
+func (h TokensDBHandler) DeleteByUsername(username, purpose string) error {
+	if _, err := h.conn.Exec(deleteTokensByUsername, username, purpose); err != nil {
+		return fmt.Errorf("error when deleting tokens of user from database, %v", err)
+	}
+	return nil
+}
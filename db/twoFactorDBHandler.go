@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+const (
+	selectTwoFactor     = "SELECT username, secret, enabled, last_step FROM USER_TOTP WHERE username=?;"
+	upsertTwoFactor     = "INSERT INTO USER_TOTP (username, secret, enabled, last_step) VALUES (?,?,FALSE,0) ON DUPLICATE KEY UPDATE secret=VALUES(secret), enabled=FALSE, last_step=0;"
+	enableTwoFactor     = "UPDATE USER_TOTP SET enabled=TRUE, last_step=? WHERE username=? AND enabled=FALSE;"
+	updateTwoFactorStep = "UPDATE USER_TOTP SET last_step=? WHERE username=? AND last_step<?;"
+	deleteTwoFactor     = "DELETE FROM USER_TOTP WHERE username=?;"
+	insertRecoveryCode  = "INSERT INTO USER_RECOVERY_CODES (username, code_hash) VALUES (?,?);"
+	deleteRecoveryCode  = "DELETE FROM USER_RECOVERY_CODES WHERE username=? AND code_hash=?;"
+	deleteRecoveryCodes = "DELETE FROM USER_RECOVERY_CODES WHERE username=?;"
+)
+
+// Handles sql operations to USER_TOTP and USER_RECOVERY_CODES tables.
+type TwoFactorDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets the two-factor authentication of user.
+// Returns nil if the user didn't enroll
+// Returns error on database query error
+func (h TwoFactorDBHandler) Get(username string) (*model.TwoFactor, error) {
+	var tf model.TwoFactor
+	if err := h.conn.QueryRow(selectTwoFactor, username).Scan(&tf.Username, &tf.Secret, &tf.Enabled, &tf.LastStep); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read two-factor authentication row, %v", err)
+	}
+	return &tf, nil
+}
+
+// Saves a not yet enabled enrollment of user with secret, replacing a previous one.
+// Returns error on database query error
+func (h TwoFactorDBHandler) Enroll(username, secret string) error {
+	if _, err := h.conn.Exec(upsertTwoFactor, username, secret); err != nil {
+		return fmt.Errorf("error when saving two-factor enrollment in database, %v", err)
+	}
+	return nil
+}
+
+// Enables the enrollment of user confirmed with the code of step and saves the hashes of their recovery codes.
+// Returns false if the user has no enrollment waiting for confirmation
+// Returns error on database query error
+func (h TwoFactorDBHandler) Enable(username string, step int64, codeHashes []string) (bool, error) {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("could not start transaction for two-factor authentication in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(enableTwoFactor, step, username)
+	if err != nil {
+		return false, fmt.Errorf("error when enabling two-factor authentication in database, %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if err := replaceRecoveryCodes(tx, username, codeHashes); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("could not commit two-factor authentication in database, %v", err)
+	}
+	return true, nil
+}
+
+// Records that the code of step was used, so only codes of later steps are accepted.
+// Returns false if a code of this or a later step was already used
+// Returns error on database query error
+func (h TwoFactorDBHandler) UseStep(username string, step int64) (bool, error) {
+	res, err := h.conn.Exec(updateTwoFactorStep, step, username, step)
+	if err != nil {
+		return false, fmt.Errorf("error when updating two-factor step in database, %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not check updated two-factor step, %v", err)
+	}
+	return n == 1, nil
+}
+
+// Deletes the recovery code of user with hash, so it can be used only once.
+// Returns false if the user has no such code
+// Returns error on database query error
+func (h TwoFactorDBHandler) UseRecoveryCode(username, hash string) (bool, error) {
+	res, err := h.conn.Exec(deleteRecoveryCode, username, hash)
+	if err != nil {
+		return false, fmt.Errorf("error when deleting recovery code from database, %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not check deleted recovery code, %v", err)
+	}
+	return n == 1, nil
+}
+
+// Replaces the recovery codes of user with the ones with codeHashes.
+// Returns error on database query error
+func (h TwoFactorDBHandler) ReplaceRecoveryCodes(username string, codeHashes []string) error {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction for recovery codes in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, username, codeHashes); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit recovery codes in database, %v", err)
+	}
+	return nil
+}
+
+// Deletes the two-factor authentication and the recovery codes of user.
+// Returns error on database query error
+func (h TwoFactorDBHandler) Delete(username string) error {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction for two-factor authentication in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteRecoveryCodes, username); err != nil {
+		return fmt.Errorf("error when deleting recovery codes from database, %v", err)
+	}
+	if _, err := tx.Exec(deleteTwoFactor, username); err != nil {
+		return fmt.Errorf("error when deleting two-factor authentication from database, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit two-factor authentication deletion in database, %v", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, username string, codeHashes []string) error {
+	if _, err := tx.Exec(deleteRecoveryCodes, username); err != nil {
+		return fmt.Errorf("error when deleting recovery codes from database, %v", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(insertRecoveryCode, username, hash); err != nil {
+			return fmt.Errorf("error when inserting recovery code in database, %v", err)
+		}
+	}
+	return nil
+}
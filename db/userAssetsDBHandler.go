@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/MonikaPalova/currency-master/model"
 	"github.com/go-sql-driver/mysql"
@@ -15,6 +16,11 @@ const (
 	insertAsset                 = "INSERT INTO USER_ASSETS (username, asset_id, name, quantity) VALUES (?,?,?,?);"
 	updateAsset                 = "UPDATE USER_ASSETS SET quantity=? WHERE username=? AND asset_id=?;"
 	deleteAsset                 = "DELETE FROM USER_ASSETS WHERE username=? AND asset_id=?;"
+	selectAssetsById            = "SELECT username, asset_id, name, quantity FROM USER_ASSETS WHERE asset_id=?;"
+	selectHeldAssetIds          = "SELECT DISTINCT asset_id FROM USER_ASSETS;"
+	selectAssetForUpdate        = "SELECT quantity FROM USER_ASSETS WHERE username=? AND asset_id=? FOR UPDATE;"
+	creditUserUSD               = "UPDATE USERS SET usd = usd + ? WHERE username=?;"
+	insertSettlement            = "INSERT INTO SETTLEMENTS (username, asset_id, quantity, price_usd, usd, settled) VALUES (?,?,?,?,?,?);"
 )
 
 // Handles sql operations to USER_ASSETS table.
@@ -33,6 +39,38 @@ func (u UserAssetsDBHandler) GetByUsername(username string) ([]model.UserAsset,
 	return deserializeUserAssets(rows)
 }
 
+// Gets the user assets of all users holding asset with specific id.
+// Returns error on database query error
+func (u UserAssetsDBHandler) GetByAssetId(id string) ([]model.UserAsset, error) {
+	rows, err := u.conn.Query(selectAssetsById, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve user assets from database, %v", err)
+	}
+
+	return deserializeUserAssets(rows)
+}
+
+// Gets the ids of all assets held by at least one user.
+// Returns error on database query error
+func (u UserAssetsDBHandler) GetHeldAssetIds() ([]string, error) {
+	rows, err := u.conn.Query(selectHeldAssetIds)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve held asset ids from database, %v", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("could not read asset id row, %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
 func deserializeUserAssets(rows *sql.Rows) ([]model.UserAsset, error) {
 	assets := []model.UserAsset{}
 	for rows.Next() {
@@ -127,3 +165,43 @@ func (u UserAssetsDBHandler) Delete(asset model.UserAsset) error {
 
 	return nil
 }
+
+// Settles the holding of user of asset with specific id into usd at priceUSD: removes the holding, credits the user
+// and records the settlement at settled, all or nothing.
+// Returns nil if the user doesn't hold the asset
+// Returns error on database query error
+func (u UserAssetsDBHandler) Settle(username, id string, priceUSD float64, settled time.Time) (*model.SettledHolding, error) {
+	tx, err := u.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not start transaction for settlement in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	holding := model.SettledHolding{Username: username}
+	if err := tx.QueryRow(selectAssetForUpdate, username, id).Scan(&holding.Quantity); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read user asset row, %v", err)
+	}
+	holding.USD = holding.Quantity * priceUSD
+
+	if _, err := tx.Exec(deleteAsset, username, id); err != nil {
+		return nil, fmt.Errorf("error when deleting settled user asset from database, %v", err)
+	}
+	res, err := tx.Exec(creditUserUSD, holding.USD, username)
+	if err != nil {
+		return nil, fmt.Errorf("error when crediting settlement to user in database, %v", err)
+	}
+	if cnt, _ := res.RowsAffected(); cnt == 0 && holding.USD != 0 {
+		return nil, fmt.Errorf("could not credit settlement, user with username %s doesn't exist", username)
+	}
+	if _, err := tx.Exec(insertSettlement, username, id, holding.Quantity, priceUSD, holding.USD, settled.UTC()); err != nil {
+		return nil, fmt.Errorf("error when inserting settlement in database, %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit settlement in database, %v", err)
+	}
+	return &holding, nil
+}
@@ -4,20 +4,43 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/MonikaPalova/currency-master/model"
 	"github.com/go-sql-driver/mysql"
 )
 
+// users without a row in USER_ROLES have the user role and the ones without a row in UNVERIFIED_EMAILS a verified email
 const (
-	selectUserAndAssets           = "SELECT USERS.username, USERS.email, USERS.usd, USER_ASSETS.asset_id, USER_ASSETS.name, USER_ASSETS.quantity FROM USERS LEFT JOIN USER_ASSETS ON USERS.username=USER_ASSETS.username;"
-	selectUserAndAssetsByUsername = "SELECT USERS.username, USERS.email, USERS.usd, USER_ASSETS.asset_id, USER_ASSETS.name, USER_ASSETS.quantity FROM USERS LEFT JOIN USER_ASSETS ON USERS.username=USER_ASSETS.username where USERS.username=?;"
+	userColumns = "USERS.username, USERS.email, USERS.usd, COALESCE(USER_ROLES.role, 'user'), UNVERIFIED_EMAILS.username IS NULL"
+	userJoins   = " LEFT JOIN USER_ROLES ON USERS.username=USER_ROLES.username LEFT JOIN UNVERIFIED_EMAILS ON USERS.username=UNVERIFIED_EMAILS.username"
+)
+
+const (
+	selectUserAndAssets           = "SELECT " + userColumns + ", USER_ASSETS.asset_id, USER_ASSETS.name, USER_ASSETS.quantity FROM USERS" + userJoins + " LEFT JOIN USER_ASSETS ON USERS.username=USER_ASSETS.username;"
+	selectUserAndAssetsByUsername = "SELECT " + userColumns + ", USER_ASSETS.asset_id, USER_ASSETS.name, USER_ASSETS.quantity FROM USERS" + userJoins + " LEFT JOIN USER_ASSETS ON USERS.username=USER_ASSETS.username where USERS.username=?;"
 	insertUser                    = "INSERT INTO USERS (username, email, password,usd) VALUES (?,?,?,?);"
-	selectUser                    = "SELECT username, email, usd FROM USERS where username=?;"
+	selectUser                    = "SELECT " + userColumns + " FROM USERS" + userJoins + " where USERS.username=?;"
+	selectVerifiedUsersByEmail    = "SELECT " + userColumns + " FROM USERS" + userJoins + " where USERS.email=? AND UNVERIFIED_EMAILS.username IS NULL;"
 	updateUserUSD                 = "UPDATE USERS SET usd = ? WHERE username=?;"
-	existsUser                    = "SELECT COUNT(1) FROM USERS WHERE username=? AND password=?;"
+	selectUserPassword            = "SELECT password FROM USERS WHERE username=?;"
+	updateUserPassword            = "UPDATE USERS SET password = ? WHERE username=?;"
+	selectUserRole                = "SELECT COALESCE(USER_ROLES.role, 'user') FROM USERS LEFT JOIN USER_ROLES ON USERS.username=USER_ROLES.username WHERE USERS.username=?;"
+	upsertUserRole                = "INSERT INTO USER_ROLES (username, role) VALUES (?,?) ON DUPLICATE KEY UPDATE role=VALUES(role);"
+	updateUserEmail               = "UPDATE USERS SET email = ? WHERE username=?;"
+	upsertUnverifiedEmail         = "INSERT INTO UNVERIFIED_EMAILS (username, email) VALUES (?,?) ON DUPLICATE KEY UPDATE email=VALUES(email);"
+	deleteUnverifiedEmail         = "DELETE FROM UNVERIFIED_EMAILS WHERE username=?;"
+	upsertVerifiedEmail           = "INSERT INTO VERIFIED_EMAILS (username, email, verified) SELECT username, email, ? FROM USERS WHERE username=? ON DUPLICATE KEY UPDATE email=USERS.email, verified=?;"
+	selectEmailVerified           = "SELECT VERIFIED_EMAILS.verified FROM VERIFIED_EMAILS JOIN USERS ON VERIFIED_EMAILS.username=USERS.username AND VERIFIED_EMAILS.email=USERS.email WHERE USERS.username=?;"
+	archiveUser                   = "INSERT INTO DELETED_USERS (username, email, usd, deleted) SELECT username, email, usd, ? FROM USERS WHERE username=?;"
+	archiveUserAcquisitions       = "INSERT INTO ACQUISITIONS_ARCHIVE (username, asset_id, quantity, price_usd, created, deleted) SELECT username, asset_id, quantity, price_usd, created, ? FROM ACQUISITIONS WHERE username=?;"
+	deleteUser                    = "DELETE FROM USERS WHERE username=?;"
 )
 
+// tables whose rows of a user are deleted with the user, USER_ASSETS is not
+// among them so users holding assets can't be deleted
+var userTables = []string{"ACQUISITIONS", "NOTIFICATIONS", "PRICE_ALERTS", "WATCHLISTS", "SESSIONS", "API_KEYS", "USER_ROLES", "USER_TOKENS", "UNVERIFIED_EMAILS", "VERIFIED_EMAILS", "USER_TOTP", "USER_RECOVERY_CODES", "USER_IDENTITIES"}
+
 // Handles sql operations to USERS table.
 type UsersDBHandler struct {
 	conn *sql.DB
@@ -30,24 +53,32 @@ type userAsset struct {
 	quantity sql.NullFloat64
 }
 
-// Saves new user in database.
+// Saves new user in database with an unverified email.
 // Returns same user with blank password and empty assets array, if successful.
 // Returns nil user if user already exists.
 // Returns error on database query error
 func (u UsersDBHandler) Create(user model.User) (*model.User, error) {
-	insertStmt, err := u.conn.Prepare(insertUser)
+	tx, err := u.conn.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("error when preparing insert statement for user in database, %v", err)
+		return nil, fmt.Errorf("could not start transaction for user in database, %v", err)
 	}
-	defer insertStmt.Close()
+	defer tx.Rollback()
 
-	if _, err = insertStmt.Exec(user.Username, user.Email, user.Password, user.USD); err != nil {
-		if err.(*mysql.MySQLError).Number == 1062 {
+	if _, err = tx.Exec(insertUser, user.Username, user.Email, user.Password, user.USD); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("error when inserting user in database, %v", err)
 	}
+	if _, err = tx.Exec(upsertUnverifiedEmail, user.Username, user.Email); err != nil {
+		return nil, fmt.Errorf("error when inserting unverified email of user in database, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit user in database, %v", err)
+	}
 	user.Password = ""
+	user.EmailVerified = false
 	user.Assets = []model.UserAsset{}
 	return &user, nil
 }
@@ -86,7 +117,7 @@ func deserializeUsers(rows *sql.Rows) ([]model.User, error) {
 
 	for rows.Next() {
 		var asset userAsset
-		if err := rows.Scan(&asset.user.Username, &asset.user.Email, &asset.user.USD, &asset.assetId, &asset.name, &asset.quantity); err != nil {
+		if err := rows.Scan(&asset.user.Username, &asset.user.Email, &asset.user.USD, &asset.user.Role, &asset.user.EmailVerified, &asset.assetId, &asset.name, &asset.quantity); err != nil {
 			return nil, fmt.Errorf("could not read user row, %v", err)
 		}
 		if user, exists := usersByUsername[asset.user.Username]; exists {
@@ -117,7 +148,7 @@ func (u UsersDBHandler) GetByUsername(username string) (*model.User, error) {
 	row := u.conn.QueryRow(selectUser, username)
 
 	var user model.User
-	if err := row.Scan(&user.Username, &user.Email, &user.USD); err != nil {
+	if err := row.Scan(&user.Username, &user.Email, &user.USD, &user.Role, &user.EmailVerified); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -127,6 +158,26 @@ func (u UsersDBHandler) GetByUsername(username string) (*model.User, error) {
 	return &user, nil
 }
 
+// Gets the users who verified email, without assets.
+// Returns error on database query error
+func (u UsersDBHandler) GetVerifiedByEmail(email string) ([]model.User, error) {
+	rows, err := u.conn.Query(selectVerifiedUsersByEmail, email)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve users by email from database, %v", err)
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.Username, &user.Email, &user.USD, &user.Role, &user.EmailVerified); err != nil {
+			return nil, fmt.Errorf("could not read user row, %v", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
 // Updates usd value of a user.
 // Returns error on database query error
 func (u UsersDBHandler) UpdateUSD(username string, money float64) error {
@@ -147,18 +198,152 @@ func (u UsersDBHandler) UpdateUSD(username string, money float64) error {
 	return nil
 }
 
-// Checks if user with this username and password exists in the database
+// Gets the stored password of user, a hash or the plaintext of a user created before passwords were hashed.
+// Returns nil if user does not exist
+// Returns error on database query error
+func (u UsersDBHandler) GetPassword(username string) (*string, error) {
+	row := u.conn.QueryRow(selectUserPassword, username)
+
+	var password string
+	if err := row.Scan(&password); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read user password, %v", err)
+	}
+	return &password, nil
+}
+
+// Replaces the stored password of user.
+// Returns error on database query error
+func (u UsersDBHandler) UpdatePassword(username, password string) error {
+	res, err := u.conn.Exec(updateUserPassword, password, username)
+	if err != nil {
+		return fmt.Errorf("error when updating user password in database, %v", err)
+	}
+	if cnt, _ := res.RowsAffected(); cnt == 0 {
+		return fmt.Errorf("could not update the password of user username=%s", username)
+	}
+	return nil
+}
+
+// Gets the role of user.
+// Returns empty role if user does not exist
 // Returns error on database query error
-func (u UsersDBHandler) Exists(username, password string) (bool, error) {
-	row := u.conn.QueryRow(existsUser, username, password)
+func (u UsersDBHandler) GetRole(username string) (string, error) {
+	row := u.conn.QueryRow(selectUserRole, username)
+
+	var role string
+	if err := row.Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not read user role, %v", err)
+	}
+	return role, nil
+}
 
-	var exists int
-	if err := row.Scan(&exists); err != nil {
-		return false, fmt.Errorf("could not check user row existence, %v", err)
+// Sets the role of an existing user.
+// Returns error on database query error
+func (u UsersDBHandler) UpdateRole(username, role string) error {
+	if _, err := u.conn.Exec(upsertUserRole, username, role); err != nil {
+		return fmt.Errorf("error when updating user role in database, %v", err)
+	}
+	return nil
+}
+
+// Updates the email of user, which is unverified until the user verifies it.
+// Returns error on database query error
+func (u UsersDBHandler) UpdateEmail(username, email string) error {
+	tx, err := u.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction for user email in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(updateUserEmail, email, username); err != nil {
+		return fmt.Errorf("error when updating user email in database, %v", err)
+	}
+	if _, err := tx.Exec(upsertUnverifiedEmail, username, email); err != nil {
+		return fmt.Errorf("error when marking user email unverified in database, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit user email in database, %v", err)
+	}
+	return nil
+}
+
+// Marks the email of user verified at the time.
+// Returns error on database query error
+func (u UsersDBHandler) SetEmailVerified(username string, verified time.Time) error {
+	tx, err := u.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction for user email in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteUnverifiedEmail, username); err != nil {
+		return fmt.Errorf("error when marking user email verified in database, %v", err)
+	}
+	if _, err := tx.Exec(upsertVerifiedEmail, verified.UTC(), username, verified.UTC()); err != nil {
+		return fmt.Errorf("error when saving verified user email in database, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit user email in database, %v", err)
+	}
+	return nil
+}
+
+// Gets when user verified their current email.
+// Returns nil if there is no record of it, e.g. for users created before verification
+// Returns error on database query error
+func (u UsersDBHandler) GetEmailVerified(username string) (*time.Time, error) {
+	var verified time.Time
+	if err := u.conn.QueryRow(selectEmailVerified, username).Scan(&verified); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read verified email row, %v", err)
+	}
+	return &verified, nil
+}
+
+// Deletes user with their acquisitions, alerts, notifications, watchlists, sessions and api keys.
+// The user and their acquisitions are archived at deleted.
+// Returns false if the user does not exist
+// Returns error if the user still holds assets or on database query error
+func (u UsersDBHandler) Delete(username string, deleted time.Time) (bool, error) {
+	tx, err := u.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("could not start transaction for user deletion in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(archiveUser, deleted.UTC(), username)
+	if err != nil {
+		return false, fmt.Errorf("error when archiving user in database, %v", err)
+	}
+	if cnt, _ := res.RowsAffected(); cnt == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(archiveUserAcquisitions, deleted.UTC(), username); err != nil {
+		return false, fmt.Errorf("error when archiving acquisitions of user in database, %v", err)
+	}
+	for _, table := range userTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE username=?;", username); err != nil {
+			return false, fmt.Errorf("error when deleting user rows from %s in database, %v", table, err)
+		}
+	}
+	if _, err := tx.Exec(deleteUser, username); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
+			return false, fmt.Errorf("user %s still holds assets, %v", username, err)
+		}
+		return false, fmt.Errorf("error when deleting user from database, %v", err)
 	}
 
-	if exists == 1 {
-		return true, nil
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("could not commit user deletion in database, %v", err)
 	}
-	return false, nil
+	return true, nil
 }
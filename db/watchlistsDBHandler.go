@@ -0,0 +1,185 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	selectWatchlistsByUsername = "SELECT id, username, name, public, created FROM WATCHLISTS WHERE username=? ORDER BY created;"
+	selectWatchlistByID        = "SELECT id, username, name, public, created FROM WATCHLISTS WHERE username=? AND id=?;"
+	selectWatchlistAssets      = "SELECT asset_id FROM WATCHLIST_ASSETS WHERE watchlist_id=? ORDER BY position;"
+	insertWatchlist            = "INSERT INTO WATCHLISTS (id, username, name, public, created) VALUES (?,?,?,?,?);"
+	updateWatchlist            = "UPDATE WATCHLISTS SET name=?, public=? WHERE username=? AND id=?;"
+	countWatchlist             = "SELECT COUNT(*) FROM WATCHLISTS WHERE username=? AND id=?;"
+	deleteWatchlist            = "DELETE FROM WATCHLISTS WHERE username=? AND id=?;"
+	insertWatchlistAsset       = "INSERT INTO WATCHLIST_ASSETS (watchlist_id, asset_id, position) VALUES (?,?,?);"
+	deleteWatchlistAssets      = "DELETE FROM WATCHLIST_ASSETS WHERE watchlist_id=?;"
+)
+
+// Handles sql operations to WATCHLISTS and WATCHLIST_ASSETS tables.
+type WatchlistsDBHandler struct {
+	conn *sql.DB
+}
+
+// Gets all watchlists of user with their asset ids.
+// Returns error on database query error
+func (h WatchlistsDBHandler) GetByUsername(username string) ([]model.Watchlist, error) {
+	rows, err := h.conn.Query(selectWatchlistsByUsername, username)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve watchlists from database, %v", err)
+	}
+
+	watchlists, err := deserializeWatchlists(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range watchlists {
+		if watchlists[i].AssetIds, err = h.getAssetIds(watchlists[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return watchlists, nil
+}
+
+// Gets watchlist of user by id with its asset ids.
+// Returns nil if the watchlist does not exist
+// Returns error on database query error
+func (h WatchlistsDBHandler) GetByID(username, id string) (*model.Watchlist, error) {
+	rows, err := h.conn.Query(selectWatchlistByID, username, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve watchlist from database, %v", err)
+	}
+
+	watchlists, err := deserializeWatchlists(rows)
+	if err != nil || len(watchlists) == 0 {
+		return nil, err
+	}
+	watchlist := watchlists[0]
+	if watchlist.AssetIds, err = h.getAssetIds(watchlist.ID); err != nil {
+		return nil, err
+	}
+	return &watchlist, nil
+}
+
+func deserializeWatchlists(rows *sql.Rows) ([]model.Watchlist, error) {
+	defer rows.Close()
+	watchlists := []model.Watchlist{}
+	for rows.Next() {
+		var watchlist model.Watchlist
+		if err := rows.Scan(&watchlist.ID, &watchlist.Username, &watchlist.Name, &watchlist.Public, &watchlist.Created); err != nil {
+			return nil, fmt.Errorf("could not read watchlist row, %v", err)
+		}
+		watchlists = append(watchlists, watchlist)
+	}
+
+	return watchlists, nil
+}
+
+func (h WatchlistsDBHandler) getAssetIds(id string) ([]string, error) {
+	rows, err := h.conn.Query(selectWatchlistAssets, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve assets of watchlist %s from database, %v", id, err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var assetId string
+		if err := rows.Scan(&assetId); err != nil {
+			return nil, fmt.Errorf("could not read watchlist asset row, %v", err)
+		}
+		ids = append(ids, assetId)
+	}
+	return ids, nil
+}
+
+// Saves a new watchlist with its assets in the database.
+// Returns error if the user does not exist or on database query error
+func (h WatchlistsDBHandler) Create(watchlist model.Watchlist) (*model.Watchlist, error) {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not start transaction for watchlist in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(insertWatchlist, watchlist.ID, watchlist.Username, watchlist.Name, watchlist.Public, watchlist.Created); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1452 {
+			return nil, fmt.Errorf("user with username %s doesn't exist, %v", watchlist.Username, err)
+		}
+		return nil, fmt.Errorf("error when inserting watchlist in database, %v", err)
+	}
+	if err := insertWatchlistAssets(tx, watchlist); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit watchlist in database, %v", err)
+	}
+	return &watchlist, nil
+}
+
+// Updates the name, visibility and assets of a watchlist of user.
+// Returns false if the watchlist does not exist
+// Returns error on database query error
+func (h WatchlistsDBHandler) Update(watchlist model.Watchlist) (bool, error) {
+	tx, err := h.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("could not start transaction for watchlist in database, %v", err)
+	}
+	defer tx.Rollback()
+
+	// mysql reports no affected rows when nothing changes, so existence is checked separately
+	var cnt int
+	if err := tx.QueryRow(countWatchlist, watchlist.Username, watchlist.ID).Scan(&cnt); err != nil {
+		return false, fmt.Errorf("could not check watchlist in database, %v", err)
+	}
+	if cnt == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(updateWatchlist, watchlist.Name, watchlist.Public, watchlist.Username, watchlist.ID); err != nil {
+		return false, fmt.Errorf("error when updating watchlist in database, %v", err)
+	}
+	if _, err := tx.Exec(deleteWatchlistAssets, watchlist.ID); err != nil {
+		return false, fmt.Errorf("error when deleting assets of watchlist in database, %v", err)
+	}
+	if err := insertWatchlistAssets(tx, watchlist); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("could not commit watchlist in database, %v", err)
+	}
+	return true, nil
+}
+
+func insertWatchlistAssets(tx *sql.Tx, watchlist model.Watchlist) error {
+	for i, assetId := range watchlist.AssetIds {
+		if _, err := tx.Exec(insertWatchlistAsset, watchlist.ID, assetId, i); err != nil {
+			return fmt.Errorf("error when inserting asset %s of watchlist in database, %v", assetId, err)
+		}
+	}
+	return nil
+}
+
+// Deletes a watchlist of user with its assets.
+// Returns false if the watchlist does not exist
+// Returns error on database query error
+func (h WatchlistsDBHandler) Delete(username, id string) (bool, error) {
+	deleteStmt, err := h.conn.Prepare(deleteWatchlist)
+	if err != nil {
+		return false, fmt.Errorf("error when preparing delete statement for watchlist in database, %v", err)
+	}
+	defer deleteStmt.Close()
+
+	res, err := deleteStmt.Exec(username, id)
+	if err != nil {
+		return false, fmt.Errorf("error when deleting watchlist in database, %v", err)
+	}
+	cnt, _ := res.RowsAffected()
+	return cnt > 0, nil
+}
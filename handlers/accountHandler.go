@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// Account API for email verification and forgotten passwords, open to unauthenticated callers
+type AccountHandler struct {
+	Svc      accountSvc
+	Sessions userSessions
+	Keys     userKeys
+}
+
+type accountSvc interface {
+	// verify the email of the token's user, false if the token is invalid or expired
+	Verify(token string) (bool, error)
+	// mail a password reset token to user if they exist
+	RequestPasswordReset(username string) error
+	// set the password of the token's user and get their username, empty if the token is invalid or expired
+	ResetPassword(token, newPassword string) (string, error)
+}
+
+// verifies an email with the token from the verification link
+func (a AccountHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "token query parameter is required")
+		return
+	}
+
+	verified, err := a.Svc.Verify(token)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not verify email")
+		return
+	}
+	if !verified {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "verification token is invalid or expired")
+		return
+	}
+	httputils.RespondWithOK(w, []byte(`{"emailVerified":true}`))
+}
+
+// mails a password reset token. Responds the same whether the user exists or not
+func (a AccountHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var request model.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to password reset request")
+		return
+	}
+	if err := request.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "password reset request body is invalid")
+		return
+	}
+
+	if err := a.Svc.RequestPasswordReset(request.Username); err != nil {
+		log.Printf("Could not send password reset email to user %s, %v", request.Username, err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sets a new password with a password reset token, ends all sessions of the user and revokes their api keys
+func (a AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var reset model.PasswordReset
+	if err := json.NewDecoder(r.Body).Decode(&reset); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to password reset")
+		return
+	}
+	if err := reset.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "password reset body is invalid")
+		return
+	}
+
+	username, err := a.Svc.ResetPassword(reset.Token, reset.NewPassword)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not reset password")
+		return
+	}
+	if username == "" {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "password reset token is invalid or expired")
+		return
+	}
+
+	if err := a.Sessions.DeleteOthers(username, ""); err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "password was reset but the sessions could not be ended")
+		return
+	}
+	// the password may have been reset because the account was taken over, so its api keys can't be trusted either
+	revoked, err := a.Keys.RevokeAll(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "password was reset but the api keys could not be revoked")
+		return
+	}
+	log.Printf("Reset password of user %s, revoked %d api keys", username, revoked)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAccountSvc struct {
+	mock.Mock
+}
+
+func (m *mockAccountSvc) Verify(token string) (bool, error) {
+	args := m.Called(token)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockAccountSvc) RequestPasswordReset(username string) error {
+	args := m.Called(username)
+	return args.Error(0)
+}
+
+func (m *mockAccountSvc) ResetPassword(token, newPassword string) (string, error) {
+	args := m.Called(token, newPassword)
+	return args.String(0), args.Error(1)
+}
+
+func TestAccountHandler_Verify(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		verified       bool
+		err            error
+		callsSvc       bool
+		wantStatusCode int
+	}{
+		{"ok", "?token=t1", true, nil, true, http.StatusOK},
+		{"invalid token", "?token=t1", false, nil, true, http.StatusBadRequest},
+		{"no token", "", false, nil, false, http.StatusBadRequest},
+		{"account svc error", "?token=t1", false, fmt.Errorf(""), true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", testAppConfig.AccountApiV1+"/verify"+tt.query, nil)
+
+			mockAccountSvc := new(mockAccountSvc)
+			if tt.callsSvc {
+				mockAccountSvc.On("Verify", "t1").Return(tt.verified, tt.err)
+			}
+
+			h := AccountHandler{Svc: mockAccountSvc}
+			h.Verify(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			if w.Code == http.StatusOK && w.Body.String() != `{"emailVerified":true}` {
+				t.Errorf("unexpected body: %s", w.Body.String())
+			}
+			mockAccountSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAccountHandler_RequestPasswordReset(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		err            error
+		callsSvc       bool
+		wantStatusCode int
+	}{
+		{"ok", `{"username":"u1"}`, nil, true, http.StatusAccepted},
+		{"mailer error is hidden", `{"username":"u1"}`, fmt.Errorf("smtp"), true, http.StatusAccepted},
+		{"non-json body", ``, nil, false, http.StatusBadRequest},
+		{"blank username", `{"username":" "}`, nil, false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", testAppConfig.AccountApiV1+"/password-reset", strings.NewReader(tt.body))
+
+			mockAccountSvc := new(mockAccountSvc)
+			if tt.callsSvc {
+				mockAccountSvc.On("RequestPasswordReset", "u1").Return(tt.err)
+			}
+
+			h := AccountHandler{Svc: mockAccountSvc}
+			h.RequestPasswordReset(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAccountSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAccountHandler_ResetPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		username       string
+		err            error
+		callsSvc       bool
+		sessionsErr    error
+		keysErr        error
+		wantStatusCode int
+	}{
+		{"ok", `{"token":"t1","newPassword":"Passw0rd"}`, "u1", nil, true, nil, nil, http.StatusNoContent},
+		{"invalid token", `{"token":"t1","newPassword":"Passw0rd"}`, "", nil, true, nil, nil, http.StatusBadRequest},
+		{"non-json body", ``, "", nil, false, nil, nil, http.StatusBadRequest},
+		{"blank token", `{"token":"","newPassword":"Passw0rd"}`, "", nil, false, nil, nil, http.StatusBadRequest},
+		{"blank password", `{"token":"t1","newPassword":" "}`, "", nil, false, nil, nil, http.StatusBadRequest},
+		{"account svc error", `{"token":"t1","newPassword":"Passw0rd"}`, "", fmt.Errorf(""), true, nil, nil, http.StatusInternalServerError},
+		{"sessions error", `{"token":"t1","newPassword":"Passw0rd"}`, "u1", nil, true, fmt.Errorf(""), nil, http.StatusInternalServerError},
+		{"api keys error", `{"token":"t1","newPassword":"Passw0rd"}`, "u1", nil, true, nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", testAppConfig.AccountApiV1+"/password-reset/confirm", strings.NewReader(tt.body))
+
+			mockAccountSvc := new(mockAccountSvc)
+			if tt.callsSvc {
+				mockAccountSvc.On("ResetPassword", "t1", "Passw0rd").Return(tt.username, tt.err)
+			}
+			mockUserSessions := new(mockUserSessions)
+			mockUserKeys := new(mockUserKeys)
+			if tt.username != "" {
+				mockUserSessions.On("DeleteOthers", "u1", "").Return(tt.sessionsErr)
+			}
+			if tt.username != "" && tt.sessionsErr == nil {
+				mockUserKeys.On("RevokeAll", "u1").Return(1, tt.keysErr)
+			}
+
+			h := AccountHandler{Svc: mockAccountSvc, Sessions: mockUserSessions, Keys: mockUserKeys}
+			h.ResetPassword(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAccountSvc.AssertExpectations(t)
+			mockUserSessions.AssertExpectations(t)
+			mockUserKeys.AssertExpectations(t)
+		})
+	}
+}
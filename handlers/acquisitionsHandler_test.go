@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-	"time"
 
 	"github.com/MonikaPalova/currency-master/config"
 	"github.com/MonikaPalova/currency-master/model"
@@ -43,7 +42,7 @@ func TestAcquisitionsHandler_GetAll(t *testing.T) {
 		w     *httptest.ResponseRecorder
 		query string
 	}
-	acq := model.Acquisition{Username: "p1", AssetId: "id1", Quantity: 3, PriceUSD: 0.1, TotalUSD: 0.3, Created: time.Now().UTC()}
+	acq := model.Acquisition{Username: "p1", AssetId: "id1", Quantity: 3, PriceUSD: 0.1, TotalUSD: 0.3, Created: testNow}
 	tests := []struct {
 		name           string
 		fields         fields
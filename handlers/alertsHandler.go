@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/MonikaPalova/currency-master/auth"
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+)
+
+// Price alerts and notifications inbox API handler. Users can access only their own alerts and notifications.
+type AlertsHandler struct {
+	Svc alertsSvc
+}
+
+type alertsSvc interface {
+	// get price alerts of user
+	GetByUsername(username string) ([]model.PriceAlert, error)
+	// get price alert of user by id, nil if it doesn't exist
+	GetByID(username, id string) (*model.PriceAlert, error)
+	// create a price alert with the current price of the asset as reference
+	Create(alert model.PriceAlert) (*model.PriceAlert, error)
+	// delete price alert of user, false if it doesn't exist
+	Delete(username, id string) (bool, error)
+	// get the inbox of user
+	GetNotifications(username string) ([]model.Notification, error)
+	// mark notification of user as read, false if it doesn't exist
+	MarkNotificationRead(username, id string) (bool, error)
+}
+
+// gets all price alerts of user
+func (h AlertsHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "price alerts")
+	if !ok {
+		return
+	}
+
+	alerts, err := h.Svc.GetByUsername(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not retrieve price alerts of user %s from database", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(alerts)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert price alerts to JSON")
+		return
+	}
+	log.Printf("Retrieved all price alerts of user %s", username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// gets price alert of user by id
+func (h AlertsHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "price alerts")
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	alert, err := h.Svc.GetByID(username, id)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not retrieve price alert %s of user %s from database", id, username))
+		return
+	}
+	if alert == nil {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user %s doesn't have price alert with id %s", username, id))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(alert)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert price alert to JSON")
+		return
+	}
+	log.Printf("Retrieved price alert %s of user %s", id, username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// creates a price alert for user
+func (h AlertsHandler) Post(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "price alerts")
+	if !ok {
+		return
+	}
+
+	var alert model.PriceAlert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to price alert")
+		return
+	}
+	alert.Username = username
+	alert.AssetId = strings.ToUpper(alert.AssetId)
+
+	if err := alert.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "price alert body is invalid")
+		return
+	}
+
+	created, err := h.Svc.Create(alert)
+	if err != nil {
+		var target svc.AlertTargetError
+		if errors.As(err, &target) {
+			httputils.RespondWithError(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		respondWithRatesError(w, err, fmt.Sprintf("could not create price alert for asset %s", alert.AssetId))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(created)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert created price alert to JSON")
+		return
+	}
+	log.Printf("Created price alert %s for user %s on asset %s", created.ID, username, created.AssetId)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// deletes price alert of user
+func (h AlertsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "price alerts")
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	deleted, err := h.Svc.Delete(username, id)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not delete price alert %s of user %s", id, username))
+		return
+	}
+	if !deleted {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user %s doesn't have price alert with id %s", username, id))
+		return
+	}
+
+	log.Printf("Deleted price alert %s of user %s", id, username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// gets the notifications inbox of user, newest first
+func (h AlertsHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "notifications")
+	if !ok {
+		return
+	}
+
+	notifications, err := h.Svc.GetNotifications(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not retrieve notifications of user %s from database", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(notifications)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert notifications to JSON")
+		return
+	}
+	log.Printf("Retrieved notifications of user %s", username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// marks notification of user as read
+func (h AlertsHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "notifications")
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	marked, err := h.Svc.MarkNotificationRead(username, id)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not mark notification %s of user %s as read", id, username))
+		return
+	}
+	if !marked {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user %s doesn't have notification with id %s", username, id))
+		return
+	}
+
+	log.Printf("Marked notification %s of user %s as read", id, username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// gets the username from the path and responds with forbidden if it is not the caller
+func authorizeOwner(w http.ResponseWriter, r *http.Request, resource string) (string, bool) {
+	username := mux.Vars(r)["username"]
+	if caller := auth.GetUser(r); caller != username {
+		httputils.RespondWithError(w, http.StatusForbidden, nil, fmt.Sprintf("user can access only their own %s, current user: %s", resource, caller))
+		return "", false
+	}
+	return username, true
+}
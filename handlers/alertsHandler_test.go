@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAlertsSvc struct {
+	mock.Mock
+}
+
+func (m *mockAlertsSvc) GetByUsername(username string) ([]model.PriceAlert, error) {
+	args := m.Called(username)
+	return args.Get(0).([]model.PriceAlert), args.Error(1)
+}
+
+func (m *mockAlertsSvc) GetByID(username, id string) (*model.PriceAlert, error) {
+	args := m.Called(username, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.PriceAlert), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockAlertsSvc) Create(alert model.PriceAlert) (*model.PriceAlert, error) {
+	args := m.Called(alert)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.PriceAlert), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockAlertsSvc) Delete(username, id string) (bool, error) {
+	args := m.Called(username, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockAlertsSvc) GetNotifications(username string) ([]model.Notification, error) {
+	args := m.Called(username)
+	return args.Get(0).([]model.Notification), args.Error(1)
+}
+
+func (m *mockAlertsSvc) MarkNotificationRead(username, id string) (bool, error) {
+	args := m.Called(username, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestAlertsHandler_GetAll(t *testing.T) {
+	username := "user"
+	tests := []struct {
+		name           string
+		alerts         []model.PriceAlert
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", []model.PriceAlert{{ID: "1", Username: username}}, nil, http.StatusOK},
+		{"svc error", []model.PriceAlert{}, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/v1/users/user/alerts", nil)
+			r = r.WithContext(testCtx{username: username})
+
+			mockAlertsSvc := new(mockAlertsSvc)
+			mockAlertsSvc.On("GetByUsername", username).Return(tt.alerts, tt.err)
+
+			h := AlertsHandler{Svc: mockAlertsSvc}
+			h.GetAll(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAlertsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAlertsHandler_GetByID(t *testing.T) {
+	username, id := "user", "1"
+	tests := []struct {
+		name           string
+		alert          *model.PriceAlert
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", &model.PriceAlert{ID: id, Username: username}, nil, http.StatusOK},
+		{"not found", nil, nil, http.StatusNotFound},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/v1/users/user/alerts/1", nil)
+			r = r.WithContext(testCtx{username: username, id: id})
+
+			mockAlertsSvc := new(mockAlertsSvc)
+			mockAlertsSvc.On("GetByID", username, id).Return(tt.alert, tt.err)
+
+			h := AlertsHandler{Svc: mockAlertsSvc}
+			h.GetByID(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAlertsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAlertsHandler_Post(t *testing.T) {
+	username := "user"
+	alert := model.PriceAlert{Username: username, AssetId: "BTC", Condition: model.AlertAbove, Threshold: 50000, Mode: model.AlertOnce, Channel: model.ChannelInbox}
+	body := `{"assetId":"btc","condition":"above","threshold":50000,"mode":"once","channel":"inbox"}`
+	tests := []struct {
+		name           string
+		created        *model.PriceAlert
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", &alert, nil, http.StatusOK},
+		{"missing asset", nil, svc.AssetNotFoundError{ID: "BTC"}, http.StatusNotFound},
+		{"rejected target", nil, svc.AlertTargetError{Reason: "webhook target is not allowed"}, http.StatusBadRequest},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/alerts", strings.NewReader(body))
+			r = r.WithContext(testCtx{username: username})
+
+			mockAlertsSvc := new(mockAlertsSvc)
+			mockAlertsSvc.On("Create", alert).Return(tt.created, tt.err)
+
+			h := AlertsHandler{Svc: mockAlertsSvc}
+			h.Post(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAlertsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAlertsHandler_Post_BadRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed body", `{"assetId":`},
+		{"unknown condition", `{"assetId":"BTC","condition":"sideways","threshold":1,"mode":"once","channel":"inbox"}`},
+		{"negative threshold", `{"assetId":"BTC","condition":"above","threshold":-1,"mode":"once","channel":"inbox"}`},
+		{"webhook without url", `{"assetId":"BTC","condition":"above","threshold":1,"mode":"once","channel":"webhook","target":"ftp://host"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/alerts", strings.NewReader(tt.body))
+			r = r.WithContext(testCtx{username: "user"})
+
+			h := AlertsHandler{Svc: new(mockAlertsSvc)}
+			h.Post(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestAlertsHandler_Forbidden(t *testing.T) {
+	h := AlertsHandler{Svc: new(mockAlertsSvc)}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"get all", h.GetAll},
+		{"get by id", h.GetByID},
+		{"post", h.Post},
+		{"delete", h.Delete},
+		{"notifications", h.GetNotifications},
+		{"mark read", h.MarkNotificationRead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/v1/users/other/alerts", nil)
+			r = r.WithContext(forbiddenCtx{testCtx{username: "other", id: "1"}})
+
+			tt.handler(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+// context in which the caller differs from the username in the path
+type forbiddenCtx struct {
+	testCtx
+}
+
+func (m forbiddenCtx) Value(key interface{}) interface{} {
+	if vars, ok := m.testCtx.Value(key).(map[string]string); ok {
+		return vars
+	}
+	return "caller"
+}
+
+func TestAlertsHandler_Delete(t *testing.T) {
+	username, id := "user", "1"
+	tests := []struct {
+		name           string
+		deleted        bool
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", true, nil, http.StatusNoContent},
+		{"not found", false, nil, http.StatusNotFound},
+		{"svc error", false, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "/api/v1/users/user/alerts/1", nil)
+			r = r.WithContext(testCtx{username: username, id: id})
+
+			mockAlertsSvc := new(mockAlertsSvc)
+			mockAlertsSvc.On("Delete", username, id).Return(tt.deleted, tt.err)
+
+			h := AlertsHandler{Svc: mockAlertsSvc}
+			h.Delete(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAlertsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAlertsHandler_GetNotifications(t *testing.T) {
+	username := "user"
+	tests := []struct {
+		name           string
+		notifications  []model.Notification
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", []model.Notification{{ID: "1", Username: username}}, nil, http.StatusOK},
+		{"svc error", []model.Notification{}, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/v1/users/user/notifications", nil)
+			r = r.WithContext(testCtx{username: username})
+
+			mockAlertsSvc := new(mockAlertsSvc)
+			mockAlertsSvc.On("GetNotifications", username).Return(tt.notifications, tt.err)
+
+			h := AlertsHandler{Svc: mockAlertsSvc}
+			h.GetNotifications(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAlertsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAlertsHandler_MarkNotificationRead(t *testing.T) {
+	username, id := "user", "1"
+	tests := []struct {
+		name           string
+		marked         bool
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", true, nil, http.StatusNoContent},
+		{"not found", false, nil, http.StatusNotFound},
+		{"svc error", false, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/notifications/1/read", nil)
+			r = r.WithContext(testCtx{username: username, id: id})
+
+			mockAlertsSvc := new(mockAlertsSvc)
+			mockAlertsSvc.On("MarkNotificationRead", username, id).Return(tt.marked, tt.err)
+
+			h := AlertsHandler{Svc: mockAlertsSvc}
+			h.MarkNotificationRead(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAlertsSvc.AssertExpectations(t)
+		})
+	}
+}
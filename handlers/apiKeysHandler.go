@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+)
+
+// Api keys API handler. Users can create, list and revoke only their own api keys.
+type APIKeysHandler struct {
+	Svc apiKeysSvc
+}
+
+type apiKeysSvc interface {
+	// get api keys of user, without the keys themselves
+	GetByUsername(username string) ([]model.APIKey, error)
+	// create an api key, the key itself is returned only here
+	Create(key model.APIKey) (*model.CreatedAPIKey, error)
+	// revoke api key of user, false if it doesn't exist
+	Delete(username, id string) (bool, error)
+}
+
+// gets all api keys of user
+func (h APIKeysHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "api keys")
+	if !ok {
+		return
+	}
+
+	keys, err := h.Svc.GetByUsername(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not retrieve api keys of user %s from database", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(keys)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert api keys to JSON")
+		return
+	}
+	log.Printf("Retrieved all api keys of user %s", username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// creates an api key for user, the response is the only time the key is shown
+func (h APIKeysHandler) Post(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "api keys")
+	if !ok {
+		return
+	}
+
+	var key model.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to api key")
+		return
+	}
+	if err := key.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "api key body is invalid")
+		return
+	}
+	key.Username = username
+
+	created, err := h.Svc.Create(key)
+	if err != nil {
+		var expired svc.APIKeyExpiredError
+		if errors.As(err, &expired) {
+			httputils.RespondWithError(w, http.StatusBadRequest, err, "api key body is invalid")
+			return
+		}
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not create api key for user %s", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(created)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert created api key to JSON")
+		return
+	}
+	log.Printf("Created api key %s for user %s", created.ID, username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// revokes api key of user
+func (h APIKeysHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "api keys")
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	deleted, err := h.Svc.Delete(username, id)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not revoke api key %s of user %s", id, username))
+		return
+	}
+	if !deleted {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user %s doesn't have api key with id %s", username, id))
+		return
+	}
+
+	log.Printf("Revoked api key %s of user %s", id, username)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAPIKeysSvc struct {
+	mock.Mock
+}
+
+func (m *mockAPIKeysSvc) GetByUsername(username string) ([]model.APIKey, error) {
+	args := m.Called(username)
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+func (m *mockAPIKeysSvc) Create(key model.APIKey) (*model.CreatedAPIKey, error) {
+	args := m.Called(key)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.CreatedAPIKey), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockAPIKeysSvc) Delete(username, id string) (bool, error) {
+	args := m.Called(username, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestAPIKeysHandler_GetAll(t *testing.T) {
+	tests := []struct {
+		name           string
+		keys           []model.APIKey
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", []model.APIKey{{ID: "1", Username: "user", Name: "bot", Prefix: "cm_abcdefg", Scopes: []string{model.ScopeRead}}}, nil, http.StatusOK},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/v1/users/user/apikeys", nil)
+			r = r.WithContext(testCtx{username: "user"})
+
+			mockAPIKeysSvc := new(mockAPIKeysSvc)
+			mockAPIKeysSvc.On("GetByUsername", "user").Return(tt.keys, tt.err)
+
+			h := APIKeysHandler{Svc: mockAPIKeysSvc}
+			h.GetAll(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			if tt.err == nil && strings.Contains(w.Body.String(), "hash") {
+				t.Errorf("api keys response should not contain hashes: %s", w.Body.String())
+			}
+			mockAPIKeysSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAPIKeysHandler_Post(t *testing.T) {
+	key := model.APIKey{Username: "user", Name: "bot", Scopes: []string{model.ScopeRead, model.ScopeTrade}}
+	created := model.CreatedAPIKey{APIKey: key, Key: "cm_secret"}
+	tests := []struct {
+		name           string
+		created        *model.CreatedAPIKey
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", &created, nil, http.StatusOK},
+		{"expired", nil, svc.APIKeyExpiredError{Expires: testNow}, http.StatusBadRequest},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/apikeys", strings.NewReader(`{"name":" bot ","scopes":["read","TRADE","read"]}`))
+			r = r.WithContext(testCtx{username: "user"})
+
+			mockAPIKeysSvc := new(mockAPIKeysSvc)
+			mockAPIKeysSvc.On("Create", key).Return(tt.created, tt.err)
+
+			h := APIKeysHandler{Svc: mockAPIKeysSvc}
+			h.Post(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			if tt.created != nil && !strings.Contains(w.Body.String(), `"key":"cm_secret"`) {
+				t.Errorf("created api key response should contain the key: %s", w.Body.String())
+			}
+			mockAPIKeysSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAPIKeysHandler_Post_BadRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed body", `{"name":`},
+		{"blank name", `{"name":" ","scopes":["read"]}`},
+		{"no scopes", `{"name":"bot"}`},
+		{"unknown scope", `{"name":"bot","scopes":["admin"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/apikeys", strings.NewReader(tt.body))
+			r = r.WithContext(testCtx{username: "user"})
+
+			h := APIKeysHandler{Svc: new(mockAPIKeysSvc)}
+			h.Post(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestAPIKeysHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		deleted        bool
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", true, nil, http.StatusNoContent},
+		{"not found", false, nil, http.StatusNotFound},
+		{"svc error", false, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "/api/v1/users/user/apikeys/1", nil)
+			r = r.WithContext(testCtx{username: "user", id: "1"})
+
+			mockAPIKeysSvc := new(mockAPIKeysSvc)
+			mockAPIKeysSvc.On("Delete", "user", "1").Return(tt.deleted, tt.err)
+
+			h := APIKeysHandler{Svc: mockAPIKeysSvc}
+			h.Delete(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockAPIKeysSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAPIKeysHandler_Forbidden(t *testing.T) {
+	h := APIKeysHandler{Svc: new(mockAPIKeysSvc)}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"get", h.GetAll},
+		{"post", h.Post},
+		{"delete", h.Delete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/apikeys", strings.NewReader(`{"name":"bot","scopes":["read"]}`))
+			r = r.WithContext(forbiddenCtx{testCtx{username: "user", id: "1"}})
+
+			tt.handler(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
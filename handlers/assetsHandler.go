@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/gorilla/mux"
@@ -26,7 +27,7 @@ type AssetsHandler struct {
 }
 
 type assetsSvc interface {
-	GetAssetPage(page, size int) (*coinapi.AssetPage, error)
+	GetAssetPage(query coinapi.AssetQuery, page, size int) (*coinapi.AssetPage, error)
 	GetAssetById(id string) (*coinapi.Asset, error)
 }
 
@@ -47,7 +48,13 @@ func (a AssetsHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 		size = maxSize
 	}
 
-	assetsPage, err := a.Svc.GetAssetPage(page, size)
+	query, err := getAssetQuery(queryParams)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "search, filter or sort parameters are invalid")
+		return
+	}
+
+	assetsPage, err := a.Svc.GetAssetPage(*query, page, size)
 	if err != nil {
 		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not retrieve assets from external api")
 		return
@@ -71,6 +78,49 @@ func getQueryParam(actual string, defaultValue int) int {
 	}
 }
 
+func getAssetQuery(queryParams url.Values) (*coinapi.AssetQuery, error) {
+	query := coinapi.AssetQuery{Search: queryParams.Get("search"), SortBy: queryParams.Get("sort")}
+
+	if isCrypto := queryParams.Get("isCrypto"); isCrypto != "" {
+		value, err := strconv.ParseBool(isCrypto)
+		if err != nil {
+			return nil, fmt.Errorf("isCrypto must be true or false")
+		}
+		query.IsCrypto = &value
+	}
+
+	var err error
+	if query.MinPrice, err = getFloatQueryParam(queryParams.Get("minPrice")); err != nil {
+		return nil, fmt.Errorf("minPrice must be a number")
+	}
+	if query.MaxPrice, err = getFloatQueryParam(queryParams.Get("maxPrice")); err != nil {
+		return nil, fmt.Errorf("maxPrice must be a number")
+	}
+	if query.MinVolume24h, err = getFloatQueryParam(queryParams.Get("minVolume24h")); err != nil {
+		return nil, fmt.Errorf("minVolume24h must be a number")
+	}
+
+	switch queryParams.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	return &query, nil
+}
+
+func getFloatQueryParam(actual string) (float64, error) {
+	if actual == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(actual, 64)
+}
+
 // Gets asset by id.
 func (a AssetsHandler) GetById(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
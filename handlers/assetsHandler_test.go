@@ -15,8 +15,8 @@ type mockAssetsSvc struct {
 	mock.Mock
 }
 
-func (m *mockAssetsSvc) GetAssetPage(page, size int) (*coinapi.AssetPage, error) {
-	args := m.Called(page, size)
+func (m *mockAssetsSvc) GetAssetPage(query coinapi.AssetQuery, page, size int) (*coinapi.AssetPage, error) {
+	args := m.Called(query, page, size)
 	return args.Get(0).(*coinapi.AssetPage), args.Error(1)
 }
 
@@ -30,13 +30,15 @@ func (m *mockAssetsSvc) GetAssetById(id string) (*coinapi.Asset, error) {
 
 func TestAssetsHandler_GetAll(t *testing.T) {
 	type fields struct {
-		page int
-		size int
+		query coinapi.AssetQuery
+		page  int
+		size  int
 	}
 	type args struct {
 		w     *httptest.ResponseRecorder
 		query string
 	}
+	crypto := true
 	tests := []struct {
 		name           string
 		fields         fields
@@ -47,13 +49,17 @@ func TestAssetsHandler_GetAll(t *testing.T) {
 		{"custom page default size", fields{page: 2, size: defaultSize}, args{httptest.NewRecorder(), "?page=2"}, http.StatusOK},
 		{"default page custom size", fields{page: defaultPage, size: 2}, args{httptest.NewRecorder(), "?size=2"}, http.StatusOK},
 		{"custom page custom size", fields{page: 2, size: 3}, args{httptest.NewRecorder(), "?size=3&page=2"}, http.StatusOK},
+		{"search", fields{query: coinapi.AssetQuery{Search: "bit"}, page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?search=bit"}, http.StatusOK},
+		{"filter", fields{query: coinapi.AssetQuery{IsCrypto: &crypto, MinPrice: 1, MaxPrice: 2.5}, page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?isCrypto=true&minPrice=1&maxPrice=2.5"}, http.StatusOK},
+		{"sort", fields{query: coinapi.AssetQuery{SortBy: coinapi.SortByPrice, Desc: true}, page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?sort=price&order=desc"}, http.StatusOK},
+		{"volume", fields{query: coinapi.AssetQuery{MinVolume24h: 1000, SortBy: coinapi.SortByVolume24h, Desc: true}, page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?minVolume24h=1000&sort=volume24h&order=desc"}, http.StatusOK},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := httptest.NewRequest("GET", testAppConfig.AssetsApiV1+tt.args.query, nil)
 
 			mockAssetsSvc := new(mockAssetsSvc)
-			mockAssetsSvc.On("GetAssetPage", tt.fields.page, tt.fields.size).Return(&coinapi.AssetPage{Page: tt.fields.page, Size: tt.fields.size, Total: 1}, nil)
+			mockAssetsSvc.On("GetAssetPage", tt.fields.query, tt.fields.page, tt.fields.size).Return(&coinapi.AssetPage{Page: tt.fields.page, Size: tt.fields.size, Total: 1}, nil)
 
 			a := AssetsHandler{mockAssetsSvc}
 			a.GetAll(tt.args.w, r)
@@ -84,6 +90,12 @@ func TestAssetsHandler_GetAll_BadRequest(t *testing.T) {
 	}{
 		{"negative page", fields{page: -2, size: defaultSize}, args{httptest.NewRecorder(), "?page=-2"}, http.StatusBadRequest},
 		{"negative size", fields{page: defaultPage, size: -2}, args{httptest.NewRecorder(), "?size=-2"}, http.StatusBadRequest},
+		{"invalid isCrypto", fields{page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?isCrypto=maybe"}, http.StatusBadRequest},
+		{"invalid price", fields{page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?minPrice=cheap"}, http.StatusBadRequest},
+		{"min price above max price", fields{page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?minPrice=5&maxPrice=1"}, http.StatusBadRequest},
+		{"invalid volume", fields{page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?minVolume24h=high"}, http.StatusBadRequest},
+		{"unknown sort field", fields{page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?sort=color"}, http.StatusBadRequest},
+		{"invalid order", fields{page: defaultPage, size: defaultSize}, args{httptest.NewRecorder(), "?sort=name&order=up"}, http.StatusBadRequest},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
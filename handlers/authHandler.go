@@ -1,21 +1,30 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/MonikaPalova/currency-master/svc"
 	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
 )
 
 // Authentication handler.
 type AuthHandler struct {
-	USvc *svc.Users
-	SSvc *svc.Sessions
+	USvc  *svc.Users
+	SSvc  *svc.Sessions
+	TfSvc *svc.TwoFactor
+	LSvc  *svc.Lockouts
 }
 
 // Logs user in
 // Returns error if no Basic Header is provider or user is invalid
+// Returns too many requests with Retry-After if the username or the ip address failed to log in too often recently.
+// The login is counted as failed until the password is checked, so concurrent logins are slowed down and locked out as well
+// Users with two-factor authentication get a token to complete the login with LoginSecondFactor instead of a session
 func (a AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	username, pass, ok := r.BasicAuth()
 	if !ok {
@@ -23,17 +32,113 @@ func (a AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := httputils.ClientIP(r)
+	if err := a.LSvc.Attempt(username, ip); err != nil {
+		var blocked svc.LoginBlockedError
+		if errors.As(err, &blocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(blocked.Seconds()))
+			httputils.RespondWithError(w, http.StatusTooManyRequests, nil, blocked.Error())
+			return
+		}
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not check failed logins")
+		return
+	}
+
 	valid, err := a.USvc.ValidateUser(username, pass)
 	if err != nil {
+		if err := a.LSvc.Cancel(username, ip); err != nil {
+			log.Printf("Could not forget login of %s from %s, %v", username, ip, err)
+		}
 		httputils.RespondWithError(w, http.StatusInternalServerError, err, "error occured while validating user credentials in db")
 		return
 	}
 	if !valid {
+		if err := a.LSvc.Fail(username, ip); err != nil {
+			log.Printf("Could not count failed login of %s from %s, %v", username, ip, err)
+		}
 		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "provided credentials are not valid")
 		return
 	}
+	if err := a.LSvc.Succeed(username, ip); err != nil {
+		log.Printf("Could not forget failed logins of %s from %s, %v", username, ip, err)
+	}
+
+	finishLogin(w, username, a.SSvc, a.TfSvc)
+}
+
+// Completes the login of a user with two-factor authentication with a current or recovery code
+// Returns error if the token or the code is not valid, the token can't be used again in that case
+func (a AuthHandler) LoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var body model.LoginSecondFactor
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to second factor")
+		return
+	}
+	if err := body.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "second factor body is invalid")
+		return
+	}
+
+	username, err := a.TfSvc.CompleteLogin(body.Token, body.Code)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not check second factor")
+		return
+	}
+	if username == "" {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "login token or code is not valid, log in again")
+		return
+	}
+
+	startSession(w, username, a.SSvc)
+}
+
+type sessionCreator interface {
+	// create a session of user and get its cookie
+	CreateCookie(username string) (*http.Cookie, error)
+}
+
+type loginTwoFactor interface {
+	// whether user enabled two-factor authentication
+	IsEnabled(username string) (bool, error)
+	// start a login of user waiting for the second factor and get its token
+	StartLogin(username string) (string, error)
+}
+
+// finishes the login of a user who proved their identity, with a session or with a token to complete it
+// with the second factor if they enabled two-factor authentication
+func finishLogin(w http.ResponseWriter, username string, sessions sessionCreator, twoFactor loginTwoFactor) {
+	enabled, err := twoFactor.IsEnabled(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not check two-factor authentication of user")
+		return
+	}
+	if !enabled {
+		startSession(w, username, sessions)
+		return
+	}
 
-	cookie := a.SSvc.CreateCookie(username)
+	token, err := twoFactor.StartLogin(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not start login")
+		return
+	}
+	jsonResponse, err := json.Marshal(model.LoginChallenge{Token: token})
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert login challenge to JSON")
+		return
+	}
+	log.Printf("User %s proved their identity, waiting for second factor", username)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(jsonResponse)
+}
+
+func startSession(w http.ResponseWriter, username string, sessions sessionCreator) {
+	cookie, err := sessions.CreateCookie(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not create session")
+		return
+	}
 
 	http.SetCookie(w, cookie)
 	log.Printf("User %s successfully logged in", username)
@@ -42,7 +147,10 @@ func (a AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 func (a AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	sessionCookie, _ := r.Cookie(a.SSvc.Config.SessionCookieName)
-	a.SSvc.Delete(sessionCookie.Value)
+	if err := a.SSvc.Delete(sessionCookie.Value); err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not delete session")
+		return
+	}
 
 	w.Write([]byte("Logged out successfully"))
 }
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/gorilla/mux"
+)
+
+// Admin API handler which lists and clears login lockouts and lists security events.
+type LockoutsHandler struct {
+	Svc    lockoutsSvc
+	Events securityEventsSvc
+}
+
+type lockoutsSvc interface {
+	// get the usernames and ip addresses which are locked out now
+	GetLocked() ([]model.Lockout, error)
+	// forget the failed logins of subject of kind, false if it had none
+	Clear(kind, subject string) (bool, error)
+}
+
+type securityEventsSvc interface {
+	// get the latest security events, newest first
+	GetLatest() ([]model.SecurityEvent, error)
+}
+
+// gets the usernames and ip addresses which can't log in now
+func (h LockoutsHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.Svc.GetLocked()
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not retrieve lockouts")
+		return
+	}
+
+	jsonResponse, err := json.Marshal(lockouts)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert lockouts to JSON")
+		return
+	}
+	log.Printf("Retrieved %d lockouts", len(lockouts))
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// clears the failed logins of a username or an ip address, which unlocks it
+func (h LockoutsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	kind, subject := mux.Vars(r)["kind"], mux.Vars(r)["subject"]
+	if !model.ValidLockoutKind(kind) {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, fmt.Sprintf("lockout kind should be %s or %s", model.LockoutUsername, model.LockoutIP))
+		return
+	}
+
+	cleared, err := h.Svc.Clear(kind, subject)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not clear failed logins of %s %s", kind, subject))
+		return
+	}
+	if !cleared {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("%s %s has no failed logins", kind, subject))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// gets the latest security events
+func (h LockoutsHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := h.Events.GetLatest()
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not retrieve security events")
+		return
+	}
+
+	jsonResponse, err := json.Marshal(events)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert security events to JSON")
+		return
+	}
+	log.Printf("Retrieved %d security events", len(events))
+	httputils.RespondWithOK(w, jsonResponse)
+}
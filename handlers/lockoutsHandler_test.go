@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockLockoutsSvc struct {
+	mock.Mock
+}
+
+func (m *mockLockoutsSvc) GetLocked() ([]model.Lockout, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Lockout), args.Error(1)
+}
+
+func (m *mockLockoutsSvc) Clear(kind, subject string) (bool, error) {
+	args := m.Called(kind, subject)
+	return args.Bool(0), args.Error(1)
+}
+
+type mockSecurityEventsSvc struct {
+	mock.Mock
+}
+
+func (m *mockSecurityEventsSvc) GetLatest() ([]model.SecurityEvent, error) {
+	args := m.Called()
+	return args.Get(0).([]model.SecurityEvent), args.Error(1)
+}
+
+func TestLockoutsHandler_GetAll(t *testing.T) {
+	tests := []struct {
+		name           string
+		lockouts       []model.Lockout
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", []model.Lockout{{Kind: model.LockoutUsername, Subject: "user", Failures: 5, Until: time.Now()}}, nil, http.StatusOK},
+		{"svc error", []model.Lockout(nil), fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", testAppConfig.AdminApiV1+"/lockouts", nil)
+
+			mockLockoutsSvc := new(mockLockoutsSvc)
+			mockLockoutsSvc.On("GetLocked").Return(tt.lockouts, tt.err)
+
+			h := LockoutsHandler{Svc: mockLockoutsSvc}
+			h.GetAll(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockLockoutsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLockoutsHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		kind           string
+		clear          bool
+		cleared        bool
+		err            error
+		wantStatusCode int
+	}{
+		{"username", model.LockoutUsername, true, true, nil, http.StatusNoContent},
+		{"ip", model.LockoutIP, true, true, nil, http.StatusNoContent},
+		{"no failed logins", model.LockoutUsername, true, false, nil, http.StatusNotFound},
+		{"svc error", model.LockoutUsername, true, false, fmt.Errorf(""), http.StatusInternalServerError},
+		{"unknown kind", "email", false, false, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", testAppConfig.AdminApiV1+"/lockouts/"+tt.kind+"/subject", nil)
+			r = mux.SetURLVars(r, map[string]string{"kind": tt.kind, "subject": "subject"})
+
+			mockLockoutsSvc := new(mockLockoutsSvc)
+			if tt.clear {
+				mockLockoutsSvc.On("Clear", tt.kind, "subject").Return(tt.cleared, tt.err)
+			}
+
+			h := LockoutsHandler{Svc: mockLockoutsSvc}
+			h.Delete(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockLockoutsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLockoutsHandler_GetEvents(t *testing.T) {
+	tests := []struct {
+		name           string
+		events         []model.SecurityEvent
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", []model.SecurityEvent{{ID: "1", Type: model.EventLoginLockout, Kind: model.LockoutIP, Subject: "10.0.0.1", Created: time.Now()}}, nil, http.StatusOK},
+		{"svc error", []model.SecurityEvent(nil), fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", testAppConfig.AdminApiV1+"/security-events", nil)
+
+			mockSecurityEventsSvc := new(mockSecurityEventsSvc)
+			mockSecurityEventsSvc.On("GetLatest").Return(tt.events, tt.err)
+
+			h := LockoutsHandler{Events: mockSecurityEventsSvc}
+			h.GetEvents(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockSecurityEventsSvc.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+)
+
+// Markets API handler which serves the top gainers, losers and most traded assets.
+type MoversHandler struct {
+	Svc moversSvc
+}
+
+type moversSvc interface {
+	// get the movers computed on the latest refresh of the prices
+	Get() (*model.Movers, error)
+}
+
+// gets the top gainers, losers and most traded assets over 1h, 24h and 7d
+func (h MoversHandler) Get(w http.ResponseWriter, r *http.Request) {
+	movers, err := h.Svc.Get()
+	if err != nil {
+		var notReady svc.MoversNotReadyError
+		if errors.As(err, &notReady) {
+			httputils.RespondWithError(w, http.StatusServiceUnavailable, nil, err.Error())
+			return
+		}
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not retrieve market movers")
+		return
+	}
+
+	jsonResponse, err := json.Marshal(movers)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert market movers to JSON")
+		return
+	}
+	log.Printf("Retrieved market movers computed at %v", movers.Updated)
+	httputils.RespondWithOK(w, jsonResponse)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockMoversSvc struct {
+	mock.Mock
+}
+
+func (m *mockMoversSvc) Get() (*model.Movers, error) {
+	args := m.Called()
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.Movers), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestMoversHandler_Get(t *testing.T) {
+	movers := model.Movers{Updated: testNow, Periods: []model.MoversPeriod{{Period: "1h", Since: testNow.Add(-time.Hour), Gainers: []model.Mover{{AssetId: "BTC", ChangePercent: 1}}}}}
+	tests := []struct {
+		name           string
+		movers         *model.Movers
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", &movers, nil, http.StatusOK},
+		{"not computed yet", nil, svc.MoversNotReadyError{}, http.StatusServiceUnavailable},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", testAppConfig.MarketsApiV1+"/movers", nil)
+
+			mockMoversSvc := new(mockMoversSvc)
+			mockMoversSvc.On("Get").Return(tt.movers, tt.err)
+
+			h := MoversHandler{Svc: mockMoversSvc}
+			h.Get(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockMoversSvc.AssertExpectations(t)
+		})
+	}
+}
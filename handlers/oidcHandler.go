@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/oidc"
+	"github.com/MonikaPalova/currency-master/svc"
+)
+
+const (
+	// cookie keeping the state, nonce and PKCE verifier of a login between the redirects
+	oidcCookieName = "CURRENCY-MASTER-OIDC"
+	oidcCookiePath = "/login/oidc"
+	// seconds a user has to log in at the provider
+	oidcCookieMaxAge = 600
+)
+
+// Login with an OpenID Connect identity provider
+type OIDCHandler struct {
+	Client    oidcClient
+	Svc       identitiesSvc
+	Sessions  sessionCreator
+	TwoFactor loginTwoFactor
+}
+
+type oidcClient interface {
+	// url of the login page of the provider
+	AuthCodeURL(state, nonce, verifier string) (string, error)
+	// exchange the code of a login for the identity of the user
+	Exchange(code, verifier, nonce string) (*oidc.Claims, error)
+}
+
+type identitiesSvc interface {
+	// get the user linked to the identity, linking or creating one if there is none
+	Login(claims oidc.Claims) (string, error)
+}
+
+// redirects to the login page of the identity provider
+func (o OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var values [3]string
+	for i := range values {
+		random, err := oidc.NewRandom()
+		if err != nil {
+			httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not start login")
+			return
+		}
+		values[i] = random
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := o.Client.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not reach identity provider")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookieName, Value: strings.Join(values[:], "."), Path: oidcCookiePath, MaxAge: oidcCookieMaxAge,
+		HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// completes the login when the identity provider redirects back with a code
+func (o OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "login was not started or took too long, start it again")
+		return
+	}
+	// the login can be completed once
+	http.SetCookie(w, &http.Cookie{Name: oidcCookieName, Path: oidcCookiePath, MaxAge: -1})
+	values := strings.Split(cookie.Value, ".")
+	query := r.URL.Query()
+	if len(values) != 3 || subtle.ConstantTimeCompare([]byte(values[0]), []byte(query.Get("state"))) != 1 {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "login state doesn't match, start the login again")
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		httputils.RespondWithError(w, http.StatusUnauthorized, nil, "identity provider refused the login, "+providerErr)
+		return
+	}
+	if query.Get("code") == "" {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "code query parameter is required")
+		return
+	}
+
+	claims, err := o.Client.Exchange(query.Get("code"), values[2], values[1])
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusUnauthorized, err, "could not verify the login at the identity provider")
+		return
+	}
+	username, err := o.Svc.Login(*claims)
+	if err != nil {
+		var withoutEmail svc.IdentityWithoutEmailError
+		if errors.As(err, &withoutEmail) {
+			httputils.RespondWithError(w, http.StatusForbidden, nil, err.Error())
+			return
+		}
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not find user of the identity")
+		return
+	}
+
+	log.Printf("User %s logged in with identity provider %s", username, claims.Issuer)
+	finishLogin(w, username, o.Sessions, o.TwoFactor)
+}
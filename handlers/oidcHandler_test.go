@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/oidc"
+	"github.com/MonikaPalova/currency-master/oidc/oidctest"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/stretchr/testify/mock"
+)
+
+const testOIDCCallback = "http://cm.local/login/oidc/callback"
+
+type mockIdentitiesSvc struct {
+	mock.Mock
+}
+
+func (m *mockIdentitiesSvc) Login(claims oidc.Claims) (string, error) {
+	args := m.Called(claims)
+	return args.String(0), args.Error(1)
+}
+
+type mockSessionCreator struct {
+	mock.Mock
+}
+
+func (m *mockSessionCreator) CreateCookie(username string) (*http.Cookie, error) {
+	args := m.Called(username)
+	return args.Get(0).(*http.Cookie), args.Error(1)
+}
+
+type mockLoginTwoFactor struct {
+	mock.Mock
+}
+
+func (m *mockLoginTwoFactor) IsEnabled(username string) (bool, error) {
+	args := m.Called(username)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockLoginTwoFactor) StartLogin(username string) (string, error) {
+	args := m.Called(username)
+	return args.String(0), args.Error(1)
+}
+
+// starts a login, logs in at the provider and gets the callback request it redirects to
+func startOIDCLogin(t *testing.T, h OIDCHandler) *http.Request {
+	w := httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest("GET", "/login/oidc", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("unexpected status code of login: got %v want %v", w.Code, http.StatusFound)
+	}
+
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirects.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("login at provider failed, %v", err)
+	}
+	resp.Body.Close()
+
+	r := httptest.NewRequest("GET", resp.Header.Get("Location"), nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func TestOIDCHandler_Callback(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	claims := oidc.Claims{Issuer: server.URL, Subject: "sub-1", Email: "monika@example.com", EmailVerified: true, PreferredUsername: "monika"}
+	tests := []struct {
+		name           string
+		username       string
+		err            error
+		twoFactor      bool
+		wantStatusCode int
+	}{
+		{"ok", "u1", nil, false, http.StatusOK},
+		{"two-factor authentication", "u1", nil, true, http.StatusAccepted},
+		{"identity without email", "", svc.IdentityWithoutEmailError{Subject: "sub-1"}, false, http.StatusForbidden},
+		{"identities svc error", "", fmt.Errorf(""), false, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIdentitiesSvc := new(mockIdentitiesSvc)
+			mockIdentitiesSvc.On("Login", claims).Return(tt.username, tt.err)
+			mockSessionCreator := new(mockSessionCreator)
+			mockLoginTwoFactor := new(mockLoginTwoFactor)
+			if tt.err == nil {
+				mockLoginTwoFactor.On("IsEnabled", "u1").Return(tt.twoFactor, nil)
+				if tt.twoFactor {
+					mockLoginTwoFactor.On("StartLogin", "u1").Return("t1", nil)
+				} else {
+					mockSessionCreator.On("CreateCookie", "u1").Return(&http.Cookie{Name: "session", Value: "s1"}, nil)
+				}
+			}
+			h := OIDCHandler{Client: oidc.NewClient(server.Config(testOIDCCallback), clock.Real{}), Svc: mockIdentitiesSvc,
+				Sessions: mockSessionCreator, TwoFactor: mockLoginTwoFactor}
+
+			w := httptest.NewRecorder()
+			h.Callback(w, startOIDCLogin(t, h))
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v, %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if w.Code == http.StatusAccepted && !strings.Contains(w.Body.String(), `"mfaToken":"t1"`) {
+				t.Errorf("unexpected body: %s", w.Body.String())
+			}
+			mockIdentitiesSvc.AssertExpectations(t)
+			mockSessionCreator.AssertExpectations(t)
+			mockLoginTwoFactor.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOIDCHandler_Callback_Invalid(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	tests := []struct {
+		name           string
+		change         func(r *http.Request) *http.Request
+		wantStatusCode int
+	}{
+		{"no login cookie", func(r *http.Request) *http.Request {
+			return httptest.NewRequest("GET", r.URL.String(), nil)
+		}, http.StatusBadRequest},
+		{"other state", func(r *http.Request) *http.Request {
+			query := r.URL.Query()
+			query.Set("state", "other")
+			return withQuery(r, query)
+		}, http.StatusBadRequest},
+		{"login refused by provider", func(r *http.Request) *http.Request {
+			query := url.Values{"state": {r.URL.Query().Get("state")}, "error": {"access_denied"}}
+			return withQuery(r, query)
+		}, http.StatusUnauthorized},
+		{"no code", func(r *http.Request) *http.Request {
+			return withQuery(r, url.Values{"state": {r.URL.Query().Get("state")}})
+		}, http.StatusBadRequest},
+		{"wrong code", func(r *http.Request) *http.Request {
+			query := r.URL.Query()
+			query.Set("code", "forged")
+			return withQuery(r, query)
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := OIDCHandler{Client: oidc.NewClient(server.Config(testOIDCCallback), clock.Real{}), Svc: new(mockIdentitiesSvc)}
+
+			w := httptest.NewRecorder()
+			h.Callback(w, tt.change(startOIDCLogin(t, h)))
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v, %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOIDCHandler_Callback_CodeUsedOnce(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	mockIdentitiesSvc := new(mockIdentitiesSvc)
+	mockIdentitiesSvc.On("Login", mock.Anything).Return("u1", nil).Once()
+	mockSessionCreator := new(mockSessionCreator)
+	mockSessionCreator.On("CreateCookie", "u1").Return(&http.Cookie{Name: "session", Value: "s1"}, nil).Once()
+	mockLoginTwoFactor := new(mockLoginTwoFactor)
+	mockLoginTwoFactor.On("IsEnabled", "u1").Return(false, nil).Once()
+	h := OIDCHandler{Client: oidc.NewClient(server.Config(testOIDCCallback), clock.Real{}), Svc: mockIdentitiesSvc,
+		Sessions: mockSessionCreator, TwoFactor: mockLoginTwoFactor}
+	r := startOIDCLogin(t, h)
+
+	w := httptest.NewRecorder()
+	h.Callback(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	w = httptest.NewRecorder()
+	h.Callback(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code of replayed callback: got %v want %v", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCHandler_Login_ProviderDown(t *testing.T) {
+	server := oidctest.NewServer()
+	server.Close()
+	h := OIDCHandler{Client: oidc.NewClient(server.Config(testOIDCCallback), clock.Real{})}
+
+	w := httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest("GET", "/login/oidc", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: got %v want %v", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func withQuery(r *http.Request, query url.Values) *http.Request {
+	changed := httptest.NewRequest("GET", r.URL.Path+"?"+query.Encode(), nil)
+	for _, cookie := range r.Cookies() {
+		changed.AddCookie(cookie)
+	}
+	return changed
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+)
+
+// Conversion and cross rates API handler.
+type RatesHandler struct {
+	Svc ratesSvc
+}
+
+type ratesSvc interface {
+	// converts amount of asset from to asset to
+	Convert(from, to string, amount float64) (*model.Conversion, error)
+	// gets the cross rates of assets with ids against base, all assets if no ids
+	GetRates(base string, ids []string) (*model.Rates, error)
+}
+
+// Converts an amount between two assets.
+func (h RatesHandler) Convert(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	from := strings.ToUpper(queryParams.Get("from"))
+	to := strings.ToUpper(queryParams.Get("to"))
+	if from == "" || to == "" {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "from and to query parameters are required")
+		return
+	}
+
+	amount := 1.0
+	if amountStr := queryParams.Get("amount"); amountStr != "" {
+		var err error
+		amount, err = strconv.ParseFloat(amountStr, 64)
+		if err != nil || amount <= 0 {
+			httputils.RespondWithError(w, http.StatusBadRequest, nil, "amount query parameter must be a positive number")
+			return
+		}
+	}
+
+	conversion, err := h.Svc.Convert(from, to, amount)
+	if err != nil {
+		respondWithRatesError(w, err, fmt.Sprintf("Could not convert %s to %s", from, to))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(conversion)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not convert conversion to JSON")
+		return
+	}
+	log.Printf("Successfully converted %f %s to %s", amount, from, to)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// Gets the cross rates against a base asset, optionally only for comma separated ids in the symbols query parameter.
+func (h RatesHandler) GetByBase(w http.ResponseWriter, r *http.Request) {
+	base := strings.ToUpper(mux.Vars(r)["base"])
+
+	var ids []string
+	if symbols := r.URL.Query().Get("symbols"); symbols != "" {
+		for _, id := range strings.Split(symbols, ",") {
+			if id = strings.ToUpper(strings.TrimSpace(id)); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	rates, err := h.Svc.GetRates(base, ids)
+	if err != nil {
+		respondWithRatesError(w, err, fmt.Sprintf("Could not compute rates against %s", base))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(rates)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not convert rates to JSON")
+		return
+	}
+	log.Printf("Successfully computed %d rates against %s", len(rates.Rates), base)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+func respondWithRatesError(w http.ResponseWriter, err error, msg string) {
+	var notFound svc.AssetNotFoundError
+	if errors.As(err, &notFound) {
+		httputils.RespondWithError(w, http.StatusNotFound, err, msg)
+		return
+	}
+	httputils.RespondWithError(w, http.StatusInternalServerError, err, msg)
+}
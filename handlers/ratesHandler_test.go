@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRatesSvc struct {
+	mock.Mock
+}
+
+func (m *mockRatesSvc) Convert(from, to string, amount float64) (*model.Conversion, error) {
+	args := m.Called(from, to, amount)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.Conversion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRatesSvc) GetRates(base string, ids []string) (*model.Rates, error) {
+	args := m.Called(base, ids)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.Rates), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestRatesHandler_Convert(t *testing.T) {
+	type fields struct {
+		from       string
+		to         string
+		amount     float64
+		conversion *model.Conversion
+		err        error
+	}
+	type args struct {
+		w     *httptest.ResponseRecorder
+		query string
+	}
+	c := model.Conversion{From: "BTC", To: "ETH", Amount: 0.1, Rate: 20, Result: 2}
+	tests := []struct {
+		name           string
+		fields         fields
+		args           args
+		wantStatusCode int
+	}{
+		{"ok", fields{"BTC", "ETH", 0.1, &c, nil}, args{httptest.NewRecorder(), "?from=BTC&to=ETH&amount=0.1"}, http.StatusOK},
+		{"default amount lowercase ids", fields{"BTC", "ETH", 1, &c, nil}, args{httptest.NewRecorder(), "?from=btc&to=eth"}, http.StatusOK},
+		{"missing asset", fields{"BTC", "XYZ", 1, nil, svc.AssetNotFoundError{ID: "XYZ"}}, args{httptest.NewRecorder(), "?from=BTC&to=XYZ"}, http.StatusNotFound},
+		{"svc error", fields{"BTC", "ETH", 1, nil, fmt.Errorf("")}, args{httptest.NewRecorder(), "?from=BTC&to=ETH"}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", testAppConfig.ConvertApiV1+tt.args.query, nil)
+
+			mockRatesSvc := new(mockRatesSvc)
+			mockRatesSvc.On("Convert", tt.fields.from, tt.fields.to, tt.fields.amount).Return(tt.fields.conversion, tt.fields.err)
+
+			h := RatesHandler{Svc: mockRatesSvc}
+			h.Convert(tt.args.w, r)
+
+			if tt.args.w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", tt.args.w.Code, tt.wantStatusCode)
+			}
+			mockRatesSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRatesHandler_Convert_BadRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"no from", "?to=ETH"},
+		{"no to", "?from=BTC"},
+		{"invalid amount", "?from=BTC&to=ETH&amount=abc"},
+		{"negative amount", "?from=BTC&to=ETH&amount=-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", testAppConfig.ConvertApiV1+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			h := RatesHandler{}
+			h.Convert(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestRatesHandler_GetByBase(t *testing.T) {
+	type fields struct {
+		ids   []string
+		rates *model.Rates
+		err   error
+	}
+	type args struct {
+		w     *httptest.ResponseRecorder
+		base  string
+		query string
+	}
+	rates := model.Rates{Base: "BTC", Rates: map[string]float64{"ETH": 20}, Missing: []string{}}
+	tests := []struct {
+		name           string
+		fields         fields
+		args           args
+		wantStatusCode int
+	}{
+		{"all assets", fields{nil, &rates, nil}, args{httptest.NewRecorder(), "btc", ""}, http.StatusOK},
+		{"symbols", fields{[]string{"ETH", "USD"}, &rates, nil}, args{httptest.NewRecorder(), "BTC", "?symbols=eth,%20USD"}, http.StatusOK},
+		{"missing base", fields{nil, nil, svc.AssetNotFoundError{ID: "BTC"}}, args{httptest.NewRecorder(), "BTC", ""}, http.StatusNotFound},
+		{"svc error", fields{nil, nil, fmt.Errorf("")}, args{httptest.NewRecorder(), "BTC", ""}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", testAppConfig.RatesApiV1+"/"+tt.args.base+tt.args.query, nil)
+			r = mux.SetURLVars(r, map[string]string{
+				"base": tt.args.base,
+			})
+
+			mockRatesSvc := new(mockRatesSvc)
+			mockRatesSvc.On("GetRates", "BTC", tt.fields.ids).Return(tt.fields.rates, tt.fields.err)
+
+			h := RatesHandler{Svc: mockRatesSvc}
+			h.GetByBase(tt.args.w, r)
+
+			if tt.args.w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", tt.args.w.Code, tt.wantStatusCode)
+			}
+			mockRatesSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRatesHandler_GetByBase_PricelessAsset(t *testing.T) {
+	provider := &fakeProvider{prices: map[string]float64{"BTC": 40000, "ETH": 2000}}
+	for _, query := range []string{"", "?symbols=ETH,DOGE"} {
+		t.Run("query "+query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", testAppConfig.RatesApiV1+"/BTC"+query, nil)
+			r = mux.SetURLVars(r, map[string]string{"base": "BTC"})
+
+			h := RatesHandler{Svc: svc.NewAssets(provider)}
+			h.GetByBase(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("unexpected status code: got %v want %v, %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			var rates model.Rates
+			if err := json.Unmarshal(w.Body.Bytes(), &rates); err != nil {
+				t.Fatalf("could not parse rates, %v", err)
+			}
+			if _, ok := rates.Rates["DOGE"]; ok || rates.Rates["ETH"] != 20 || !reflect.DeepEqual(rates.Missing, []string{"DOGE"}) {
+				t.Errorf("unexpected rates %+v, want DOGE without price missing", rates)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/replay"
+)
+
+// replay control actions
+const (
+	replayPause  = "pause"
+	replayResume = "resume"
+	replaySeek   = "seek"
+	replaySpeed  = "speed"
+)
+
+// Admin API handler which controls the replay of historical prices.
+type ReplayHandler struct {
+	Replay replayControl
+	ASvc   assetsReloader
+}
+
+type replayControl interface {
+	Status() replay.Status
+	Pause()
+	Resume()
+	Seek(at time.Time) error
+	SetSpeed(speed float64) error
+}
+
+type assetsReloader interface {
+	// refreshes the cache even if it is not expired
+	Reload() error
+}
+
+// control message of the replay, time is used by seek and speed by speed
+type replayRequest struct {
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	Speed  float64   `json:"speed"`
+}
+
+// gets the state of the replay
+func (h ReplayHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	h.respondWithStatus(w)
+}
+
+// pauses, resumes, seeks or changes the speed of the replay and reloads the cached prices
+func (h ReplayHandler) Control(w http.ResponseWriter, r *http.Request) {
+	var req replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse replay control request")
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case replayPause:
+		h.Replay.Pause()
+	case replayResume:
+		h.Replay.Resume()
+	case replaySeek:
+		err = h.Replay.Seek(req.Time)
+	case replaySpeed:
+		err = h.Replay.SetSpeed(req.Speed)
+	default:
+		err = fmt.Errorf("action should be one of %s, %s, %s, %s", replayPause, replayResume, replaySeek, replaySpeed)
+	}
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "invalid replay control request")
+		return
+	}
+	log.Printf("Replay control %s applied", req.Action)
+
+	if err := h.ASvc.Reload(); err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not reload replayed prices")
+		return
+	}
+	h.respondWithStatus(w)
+}
+
+func (h ReplayHandler) respondWithStatus(w http.ResponseWriter) {
+	jsonResponse, err := json.Marshal(h.Replay.Status())
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert replay status to JSON")
+		return
+	}
+	httputils.RespondWithOK(w, jsonResponse)
+}
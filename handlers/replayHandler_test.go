@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/replay"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockReplay struct {
+	mock.Mock
+}
+
+func (m *mockReplay) Status() replay.Status {
+	return replay.Status{Speed: 1}
+}
+
+func (m *mockReplay) Pause() {
+	m.Called()
+}
+
+func (m *mockReplay) Resume() {
+	m.Called()
+}
+
+func (m *mockReplay) Seek(at time.Time) error {
+	return m.Called(at).Error(0)
+}
+
+func (m *mockReplay) SetSpeed(speed float64) error {
+	return m.Called(speed).Error(0)
+}
+
+type mockReloader struct {
+	mock.Mock
+}
+
+func (m *mockReloader) Reload() error {
+	return m.Called().Error(0)
+}
+
+func TestReplayHandler_Control(t *testing.T) {
+	at := time.Date(2021, 5, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		body           string
+		method         string
+		args           []interface{}
+		err            error
+		reloadErr      error
+		wantStatusCode int
+	}{
+		{"pause", `{"action":"pause"}`, "Pause", nil, nil, nil, http.StatusOK},
+		{"resume", `{"action":"resume"}`, "Resume", nil, nil, nil, http.StatusOK},
+		{"seek", `{"action":"seek","time":"2021-05-19T12:00:00Z"}`, "Seek", []interface{}{at}, nil, nil, http.StatusOK},
+		{"seek outside", `{"action":"seek","time":"2021-05-19T12:00:00Z"}`, "Seek", []interface{}{at}, fmt.Errorf(""), nil, http.StatusBadRequest},
+		{"speed", `{"action":"speed","speed":60}`, "SetSpeed", []interface{}{60.0}, nil, nil, http.StatusOK},
+		{"invalid speed", `{"action":"speed","speed":-1}`, "SetSpeed", []interface{}{-1.0}, fmt.Errorf(""), nil, http.StatusBadRequest},
+		{"reload error", `{"action":"pause"}`, "Pause", nil, nil, fmt.Errorf(""), http.StatusInternalServerError},
+		{"unknown action", `{"action":"rewind"}`, "", nil, nil, nil, http.StatusBadRequest},
+		{"malformed body", `{"action":`, "", nil, nil, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", testAppConfig.AdminApiV1+"/replay", strings.NewReader(tt.body))
+
+			mockReplay := new(mockReplay)
+			mockReloader := new(mockReloader)
+			if tt.method != "" {
+				call := mockReplay.On(tt.method, tt.args...)
+				if tt.method == "Seek" || tt.method == "SetSpeed" {
+					call.Return(tt.err)
+				}
+				if tt.err == nil {
+					mockReloader.On("Reload").Return(tt.reloadErr)
+				}
+			}
+
+			h := ReplayHandler{Replay: mockReplay, ASvc: mockReloader}
+			h.Control(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockReplay.AssertExpectations(t)
+			mockReloader.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReplayHandler_GetStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", testAppConfig.AdminApiV1+"/replay", nil)
+
+	h := ReplayHandler{Replay: new(mockReplay), ASvc: new(mockReloader)}
+	h.GetStatus(w, r)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"speed":1`) {
+		t.Fatalf("unexpected response: %v %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+)
+
+// Admin API handler which lists delisted assets still held by users and settles them into usd.
+type SettlementsHandler struct {
+	Svc settlementsSvc
+}
+
+type settlementsSvc interface {
+	// get the held assets which are no longer listed
+	GetDelisted() ([]model.DelistedAsset, error)
+	// settle all holdings of a delisted asset into usd at priceUSD
+	Settle(id string, priceUSD float64) (*model.Settlement, error)
+}
+
+// settlement request, the price is required so an asset is never written off by accident
+type settlementRequest struct {
+	PriceUSD *float64 `json:"priceUSD"`
+}
+
+// gets the delisted assets which are still held by users
+func (h SettlementsHandler) GetDelisted(w http.ResponseWriter, r *http.Request) {
+	delisted, err := h.Svc.GetDelisted()
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not retrieve delisted assets")
+		return
+	}
+
+	jsonResponse, err := json.Marshal(delisted)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert delisted assets to JSON")
+		return
+	}
+	log.Printf("Retrieved %d delisted assets", len(delisted))
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// settles all holdings of a delisted asset into usd at the chosen price
+func (h SettlementsHandler) Settle(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req settlementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse settlement request")
+		return
+	}
+	if req.PriceUSD == nil || *req.PriceUSD < 0 {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, "priceUSD is required and should not be negative")
+		return
+	}
+
+	settlement, err := h.Svc.Settle(id, *req.PriceUSD)
+	if err != nil {
+		var listed svc.AssetListedError
+		var notFound svc.AssetNotFoundError
+		switch {
+		case errors.As(err, &listed):
+			httputils.RespondWithError(w, http.StatusConflict, nil, err.Error())
+		case errors.As(err, &notFound):
+			httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("nobody holds asset with id %s", id))
+		default:
+			httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not settle asset with id %s", id))
+		}
+		return
+	}
+
+	jsonResponse, err := json.Marshal(settlement)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert settlement to JSON")
+		return
+	}
+	log.Printf("Settled delisted asset %s of %d users for %f usd", id, len(settlement.Holders), settlement.TotalUSD)
+	httputils.RespondWithOK(w, jsonResponse)
+}
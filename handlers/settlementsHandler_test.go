@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSettlementsSvc struct {
+	mock.Mock
+}
+
+func (m *mockSettlementsSvc) GetDelisted() ([]model.DelistedAsset, error) {
+	args := m.Called()
+	return args.Get(0).([]model.DelistedAsset), args.Error(1)
+}
+
+func (m *mockSettlementsSvc) Settle(id string, priceUSD float64) (*model.Settlement, error) {
+	args := m.Called(id, priceUSD)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.Settlement), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestSettlementsHandler_GetDelisted(t *testing.T) {
+	tests := []struct {
+		name           string
+		delisted       []model.DelistedAsset
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", []model.DelistedAsset{{AssetId: "LUNA", Holders: 2, Quantity: 5}}, nil, http.StatusOK},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", testAppConfig.AdminApiV1+"/delisted", nil)
+
+			mockSettlementsSvc := new(mockSettlementsSvc)
+			mockSettlementsSvc.On("GetDelisted").Return(tt.delisted, tt.err)
+
+			h := SettlementsHandler{Svc: mockSettlementsSvc}
+			h.GetDelisted(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockSettlementsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSettlementsHandler_Settle(t *testing.T) {
+	settlement := model.Settlement{AssetId: "LUNA", PriceUSD: 0.5, TotalUSD: 1, Holders: []model.SettledHolding{{Username: "user", Quantity: 2, USD: 1}}}
+	tests := []struct {
+		name           string
+		body           string
+		settle         bool
+		settlement     *model.Settlement
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", `{"priceUSD":0.5}`, true, &settlement, nil, http.StatusOK},
+		{"still listed", `{"priceUSD":0.5}`, true, nil, svc.AssetListedError{ID: "LUNA"}, http.StatusConflict},
+		{"not held", `{"priceUSD":0.5}`, true, nil, svc.AssetNotFoundError{ID: "LUNA"}, http.StatusNotFound},
+		{"svc error", `{"priceUSD":0.5}`, true, nil, fmt.Errorf(""), http.StatusInternalServerError},
+		{"missing price", `{}`, false, nil, nil, http.StatusBadRequest},
+		{"negative price", `{"priceUSD":-1}`, false, nil, nil, http.StatusBadRequest},
+		{"malformed body", `{"priceUSD":`, false, nil, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", testAppConfig.AdminApiV1+"/delisted/LUNA/settle", strings.NewReader(tt.body))
+			r = mux.SetURLVars(r, map[string]string{"id": "LUNA"})
+
+			mockSettlementsSvc := new(mockSettlementsSvc)
+			if tt.settle {
+				mockSettlementsSvc.On("Settle", "LUNA", 0.5).Return(tt.settlement, tt.err)
+			}
+
+			h := SettlementsHandler{Svc: mockSettlementsSvc}
+			h.Settle(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockSettlementsSvc.AssertExpectations(t)
+		})
+	}
+}
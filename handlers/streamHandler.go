@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/stream"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// interval of keep alive messages sent to idle clients
+	streamKeepAlive = 15 * time.Second
+
+	// time a client has to accept a message before it is disconnected
+	streamWriteTimeout = 10 * time.Second
+
+	// maximum number of assets a client can subscribe to
+	maxStreamIDs = 100
+
+	// maximum size in bytes of a websocket control message, enough for maxStreamIDs ids
+	maxStreamMessageSize = 4096
+)
+
+// Price streaming API over Server-Sent Events and WebSocket.
+type StreamHandler struct {
+	Hub  streamHub
+	ASvc streamAssetsSvc
+}
+
+type streamAssetsSvc interface {
+	// gets asset by id, nil if it doesn't exist
+	GetAssetById(id string) (*coinapi.Asset, error)
+	// gets when the cached prices were fetched
+	PricesUpdated() time.Time
+}
+
+type streamHub interface {
+	// subscribes to price updates of assets with ids
+	Subscribe(ids []string) *stream.Subscription
+	// removes subscription and closes its updates channel
+	Unsubscribe(s *stream.Subscription)
+}
+
+// message sent by websocket clients to change their subscription
+type streamControlMessage struct {
+	// subscribe, unsubscribe or set
+	Action string   `json:"action"`
+	IDs    []string `json:"ids"`
+}
+
+var upgrader = websocket.Upgrader{}
+
+// Streams price updates of the assets in the ids query parameter as Server-Sent Events.
+func (s StreamHandler) SSE(w http.ResponseWriter, r *http.Request) {
+	ids, err := getStreamIDs(r)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "stream parameters are invalid")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httputils.RespondWithError(w, http.StatusInternalServerError, nil, "streaming is not supported by the connection")
+		return
+	}
+
+	// the snapshot is taken before subscribing, so a refresh it triggers is not delivered twice
+	snapshot := s.snapshot(ids)
+	sub := s.Hub.Subscribe(ids)
+	defer s.Hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := writeEvent(w, snapshot); err != nil {
+		log.Printf("Could not send prices snapshot to stream client, %v", err)
+		return
+	}
+	flusher.Flush()
+	log.Printf("Started price stream over SSE for %d assets", len(ids))
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Println("Price stream client disconnected")
+			return
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, update); err != nil {
+				log.Printf("Could not send price update to stream client, %v", err)
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, update stream.Update) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: prices\ndata: %s\n\n", data)
+	return err
+}
+
+// Streams price updates of the assets in the ids query parameter over a WebSocket.
+// Clients can change their subscription by sending control messages.
+func (s StreamHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	ids, err := getStreamIDs(r)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "stream parameters are invalid")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Could not upgrade price stream connection to websocket, %v", err)
+		return
+	}
+	defer conn.Close()
+
+	snapshot := s.snapshot(ids)
+	sub := s.Hub.Subscribe(ids)
+	defer s.Hub.Unsubscribe(sub)
+	log.Printf("Started price stream over websocket for %d assets", len(ids))
+
+	go s.readControlMessages(conn, sub)
+
+	if err := writeMessage(conn, snapshot); err != nil {
+		log.Printf("Could not send prices snapshot to stream client, %v", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+			if err := writeMessage(conn, update); err != nil {
+				log.Printf("Could not send price update to stream client, %v", err)
+				return
+			}
+		}
+	}
+}
+
+func writeMessage(conn *websocket.Conn, update stream.Update) error {
+	conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return conn.WriteJSON(update)
+}
+
+// reads control messages until the connection is closed, then ends the subscription
+func (s StreamHandler) readControlMessages(conn *websocket.Conn, sub *stream.Subscription) {
+	defer s.Hub.Unsubscribe(sub)
+	conn.SetReadLimit(maxStreamMessageSize)
+	for {
+		var msg streamControlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Price stream websocket closed, %v", err)
+			}
+			return
+		}
+
+		ids := normalizeIDs(msg.IDs)
+		switch msg.Action {
+		case "subscribe":
+			sub.Add(ids)
+		case "unsubscribe":
+			sub.Remove(ids)
+		case "set":
+			sub.Set(ids)
+		default:
+			log.Printf("Unknown price stream action %s", msg.Action)
+			continue
+		}
+		if sub.Len() > maxStreamIDs {
+			log.Printf("Price stream subscription of %d assets is over the limit, closing websocket", sub.Len())
+			reason := fmt.Sprintf("at most %d assets can be streamed", maxStreamIDs)
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), time.Now().Add(streamWriteTimeout))
+			return
+		}
+		log.Printf("Price stream subscription changed, action %s, ids %v", msg.Action, ids)
+	}
+}
+
+// current prices of the assets, sent to clients right after they connect
+func (s StreamHandler) snapshot(ids []string) stream.Update {
+	update := stream.Update{Assets: []coinapi.Asset{}}
+	for _, id := range ids {
+		asset, err := s.ASvc.GetAssetById(id)
+		if err != nil {
+			log.Printf("Could not get asset %s for prices snapshot, %v", id, err)
+			return update
+		}
+		if asset != nil {
+			update.Assets = append(update.Assets, *asset)
+		}
+	}
+	update.Updated = s.ASvc.PricesUpdated()
+	return update
+}
+
+func getStreamIDs(r *http.Request) ([]string, error) {
+	ids := normalizeIDs(strings.Split(r.URL.Query().Get("ids"), ","))
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("ids query parameter with comma separated asset ids is required")
+	}
+	if len(ids) > maxStreamIDs {
+		return nil, fmt.Errorf("at most %d assets can be streamed, got %d", maxStreamIDs, len(ids))
+	}
+	return ids, nil
+}
+
+func normalizeIDs(ids []string) []string {
+	normalized := []string{}
+	for _, id := range ids {
+		if id = strings.ToUpper(strings.TrimSpace(id)); id != "" {
+			normalized = append(normalized, id)
+		}
+	}
+	return normalized
+}
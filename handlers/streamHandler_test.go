@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/stream"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/gorilla/websocket"
+)
+
+// in-process price provider whose prices are changed by the tests
+type fakeProvider struct {
+	mu     sync.Mutex
+	prices map[string]float64
+}
+
+func (f *fakeProvider) GetAssets() ([]coinapi.Asset, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assets := []coinapi.Asset{}
+	for _, id := range []string{"BTC", "ETH", "DOGE"} {
+		assets = append(assets, coinapi.Asset{ID: id, Name: id, IsCrypto: true, PriceUSD: f.prices[id]})
+	}
+	return assets, nil
+}
+
+func (f *fakeProvider) set(id string, price float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prices[id] = price
+}
+
+func newStreamServer(t *testing.T) (*httptest.Server, *fakeProvider, *svc.Assets) {
+	provider := &fakeProvider{prices: map[string]float64{"BTC": 40000, "ETH": 3000, "DOGE": 0.1}}
+	assets := svc.NewAssets(provider)
+	hub := stream.NewHub()
+	assets.OnRefresh(hub.Publish)
+
+	s := StreamHandler{Hub: hub, ASvc: assets}
+	mux := http.NewServeMux()
+	mux.HandleFunc(testAppConfig.StreamApiV1+"/sse", s.SSE)
+	mux.HandleFunc(testAppConfig.StreamApiV1+"/ws", s.WebSocket)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, provider, assets
+}
+
+// update as received by clients, assets are decoded from the API format rather than the external api one
+type streamedUpdate struct {
+	Updated time.Time `json:"updated"`
+	Assets  []struct {
+		ID       string  `json:"id"`
+		PriceUSD float64 `json:"priceUSD"`
+	} `json:"assets"`
+}
+
+func readEvent(t *testing.T, reader *bufio.Reader) streamedUpdate {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("could not read event, %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			var update streamedUpdate
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &update); err != nil {
+				t.Fatalf("could not parse event data %s, %v", line, err)
+			}
+			return update
+		}
+	}
+}
+
+func assertPrices(t *testing.T, update streamedUpdate, want map[string]float64) {
+	got := map[string]float64{}
+	for _, asset := range update.Assets {
+		got[asset.ID] = asset.PriceUSD
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected prices in update: got %v want %v", got, want)
+	}
+	for id, price := range want {
+		if got[id] != price {
+			t.Fatalf("unexpected prices in update: got %v want %v", got, want)
+		}
+	}
+}
+
+func TestStreamHandler_SSE(t *testing.T) {
+	server, provider, assets := newStreamServer(t)
+
+	response, err := http.Get(server.URL + testAppConfig.StreamApiV1 + "/sse?ids=btc,ETH")
+	if err != nil {
+		t.Fatalf("could not connect to stream, %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response: code %d, content type %s", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(response.Body)
+
+	assertPrices(t, readEvent(t, reader), map[string]float64{"BTC": 40000, "ETH": 3000})
+
+	provider.set("BTC", 41000)
+	if err := assets.Reload(); err != nil {
+		t.Fatalf("could not refresh assets, %v", err)
+	}
+	assertPrices(t, readEvent(t, reader), map[string]float64{"BTC": 41000, "ETH": 3000})
+}
+
+func TestStreamHandler_WebSocket(t *testing.T) {
+	server, provider, assets := newStreamServer(t)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + testAppConfig.StreamApiV1 + "/ws?ids=BTC"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not connect to stream, %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var update streamedUpdate
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("could not read snapshot, %v", err)
+	}
+	assertPrices(t, update, map[string]float64{"BTC": 40000})
+
+	if err := conn.WriteJSON(streamControlMessage{Action: "set", IDs: []string{"doge"}}); err != nil {
+		t.Fatalf("could not change subscription, %v", err)
+	}
+	// control messages are handled asynchronously, so refresh until the new subscription is applied
+	for price := 0.2; ; price += 0.1 {
+		provider.set("DOGE", price)
+		if err := assets.Reload(); err != nil {
+			t.Fatalf("could not refresh assets, %v", err)
+		}
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("could not read update, %v", err)
+		}
+		if update.Assets[0].ID == "DOGE" {
+			assertPrices(t, update, map[string]float64{"DOGE": price})
+			return
+		}
+	}
+}
+
+func TestStreamHandler_WebSocket_Limits(t *testing.T) {
+	ids := func(n int) []string {
+		ids := []string{}
+		for i := 0; i < n; i++ {
+			ids = append(ids, fmt.Sprintf("A%d", i))
+		}
+		return ids
+	}
+	tests := []struct {
+		name     string
+		message  interface{}
+		wantCode int
+	}{
+		{"subscribe over the limit", streamControlMessage{Action: "subscribe", IDs: ids(maxStreamIDs)}, websocket.ClosePolicyViolation},
+		{"set over the limit", streamControlMessage{Action: "set", IDs: ids(maxStreamIDs + 1)}, websocket.ClosePolicyViolation},
+		{"message too big", streamControlMessage{Action: "unsubscribe", IDs: []string{strings.Repeat("A", maxStreamMessageSize)}}, websocket.CloseMessageTooBig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _, _ := newStreamServer(t)
+
+			url := "ws" + strings.TrimPrefix(server.URL, "http") + testAppConfig.StreamApiV1 + "/ws?ids=BTC"
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatalf("could not connect to stream, %v", err)
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			var update streamedUpdate
+			if err := conn.ReadJSON(&update); err != nil {
+				t.Fatalf("could not read snapshot, %v", err)
+			}
+			if err := conn.WriteJSON(tt.message); err != nil {
+				t.Fatalf("could not send control message, %v", err)
+			}
+			for {
+				if err = conn.ReadJSON(&update); err != nil {
+					break
+				}
+			}
+			if !websocket.IsCloseError(err, tt.wantCode) {
+				t.Fatalf("unexpected websocket close: got %v want code %d", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestStreamHandler_BadRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"no ids", ""},
+		{"blank ids", "?ids=,%20,"},
+		{"too many ids", "?ids=" + strings.Repeat("A,", maxStreamIDs+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/sse", "/ws"} {
+				r := httptest.NewRequest("GET", testAppConfig.StreamApiV1+path+tt.query, nil)
+				w := httptest.NewRecorder()
+
+				s := StreamHandler{}
+				if path == "/sse" {
+					s.SSE(w, r)
+				} else {
+					s.WebSocket(w, r)
+				}
+
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("unexpected status code for %s: got %v want %v", path, w.Code, http.StatusBadRequest)
+				}
+			}
+		})
+	}
+}
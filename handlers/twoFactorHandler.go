@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/gorilla/mux"
+)
+
+// Two-factor authentication API
+type TwoFactorHandler struct {
+	Svc twoFactorSvc
+}
+
+type twoFactorSvc interface {
+	// start the enrollment of user, nil if two-factor authentication is already enabled
+	Enroll(username string) (*model.TwoFactorEnrollment, error)
+	// enable the enrollment of user with a current code and get recovery codes, nil if the code is not valid
+	Confirm(username, code string) ([]string, error)
+	// disable two-factor authentication with a current or recovery code, false if the code is not valid
+	Disable(username, code string) (bool, error)
+	// replace the recovery codes of user, nil if two-factor authentication isn't enabled
+	RegenerateRecoveryCodes(username string) ([]string, error)
+}
+
+// starts the enrollment of a user and responds with the secret to add to an authenticator app
+func (t TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	enrollment, err := t.Svc.Enroll(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not enroll user %s in two-factor authentication", username))
+		return
+	}
+	if enrollment == nil {
+		httputils.RespondWithError(w, http.StatusConflict, nil, "two-factor authentication is already enabled")
+		return
+	}
+
+	jsonResponse, err := json.Marshal(enrollment)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert enrollment to JSON")
+		return
+	}
+	log.Printf("User %s enrolled in two-factor authentication", username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// enables two-factor authentication with a code of the enrolled app and responds with the recovery codes
+func (t TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	code, ok := decodeTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := t.Svc.Confirm(username, code)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not enable two-factor authentication of user %s", username))
+		return
+	}
+	if codes == nil {
+		httputils.RespondWithError(w, http.StatusForbidden, nil, "code is not valid or there is no enrollment to confirm")
+		return
+	}
+	respondWithRecoveryCodes(w, codes)
+}
+
+// disables two-factor authentication with a current or recovery code
+func (t TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	code, ok := decodeTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	disabled, err := t.Svc.Disable(username, code)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not disable two-factor authentication of user %s", username))
+		return
+	}
+	if !disabled {
+		httputils.RespondWithError(w, http.StatusForbidden, nil, "code is not valid or two-factor authentication is not enabled")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replaces the recovery codes of a user
+func (t TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	codes, err := t.Svc.RegenerateRecoveryCodes(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not replace recovery codes of user %s", username))
+		return
+	}
+	if codes == nil {
+		httputils.RespondWithError(w, http.StatusConflict, nil, "two-factor authentication is not enabled")
+		return
+	}
+	log.Printf("Replaced recovery codes of user %s", username)
+	respondWithRecoveryCodes(w, codes)
+}
+
+func decodeTwoFactorCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body model.TwoFactorCode
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to code")
+		return "", false
+	}
+	if err := body.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "code body is invalid")
+		return "", false
+	}
+	return body.Code, true
+}
+
+func respondWithRecoveryCodes(w http.ResponseWriter, codes []string) {
+	jsonResponse, err := json.Marshal(model.RecoveryCodes{Codes: codes})
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert recovery codes to JSON")
+		return
+	}
+	httputils.RespondWithOK(w, jsonResponse)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockTwoFactorSvc struct {
+	mock.Mock
+}
+
+func (m *mockTwoFactorSvc) Enroll(username string) (*model.TwoFactorEnrollment, error) {
+	args := m.Called(username)
+	return args.Get(0).(*model.TwoFactorEnrollment), args.Error(1)
+}
+
+func (m *mockTwoFactorSvc) Confirm(username, code string) ([]string, error) {
+	args := m.Called(username, code)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockTwoFactorSvc) Disable(username, code string) (bool, error) {
+	args := m.Called(username, code)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockTwoFactorSvc) RegenerateRecoveryCodes(username string) ([]string, error) {
+	args := m.Called(username)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func newTwoFactorRequest(method, path, body string) *http.Request {
+	r := httptest.NewRequest(method, testAppConfig.UsersApiV1+"/u1/2fa"+path, strings.NewReader(body))
+	return mux.SetURLVars(r, map[string]string{"username": "u1"})
+}
+
+func TestTwoFactorHandler_Enroll(t *testing.T) {
+	tests := []struct {
+		name           string
+		enrollment     *model.TwoFactorEnrollment
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", &model.TwoFactorEnrollment{Secret: "S", URI: "otpauth://totp/CM:u1?secret=S"}, nil, http.StatusOK},
+		{"already enabled", nil, nil, http.StatusConflict},
+		{"two-factor svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mockTwoFactorSvc := new(mockTwoFactorSvc)
+			mockTwoFactorSvc.On("Enroll", "u1").Return(tt.enrollment, tt.err)
+
+			h := TwoFactorHandler{Svc: mockTwoFactorSvc}
+			h.Enroll(w, newTwoFactorRequest("POST", "", ""))
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockTwoFactorSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTwoFactorHandler_Confirm(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		codes          []string
+		err            error
+		callsSvc       bool
+		wantStatusCode int
+	}{
+		{"ok", `{"code":"123456"}`, []string{"abcde-fghij"}, nil, true, http.StatusOK},
+		{"wrong code", `{"code":"123456"}`, nil, nil, true, http.StatusForbidden},
+		{"non-json body", ``, nil, nil, false, http.StatusBadRequest},
+		{"blank code", `{"code":" "}`, nil, nil, false, http.StatusBadRequest},
+		{"two-factor svc error", `{"code":"123456"}`, nil, fmt.Errorf(""), true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mockTwoFactorSvc := new(mockTwoFactorSvc)
+			if tt.callsSvc {
+				mockTwoFactorSvc.On("Confirm", "u1", "123456").Return(tt.codes, tt.err)
+			}
+
+			h := TwoFactorHandler{Svc: mockTwoFactorSvc}
+			h.Confirm(w, newTwoFactorRequest("POST", "/confirm", tt.body))
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			if tt.wantStatusCode == http.StatusOK && !strings.Contains(w.Body.String(), `"recoveryCodes":["abcde-fghij"]`) {
+				t.Errorf("unexpected body: %s", w.Body.String())
+			}
+			mockTwoFactorSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTwoFactorHandler_Disable(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		disabled       bool
+		err            error
+		callsSvc       bool
+		wantStatusCode int
+	}{
+		{"ok", `{"code":"123456"}`, true, nil, true, http.StatusNoContent},
+		{"wrong code", `{"code":"123456"}`, false, nil, true, http.StatusForbidden},
+		{"non-json body", ``, false, nil, false, http.StatusBadRequest},
+		{"two-factor svc error", `{"code":"123456"}`, false, fmt.Errorf(""), true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mockTwoFactorSvc := new(mockTwoFactorSvc)
+			if tt.callsSvc {
+				mockTwoFactorSvc.On("Disable", "u1", "123456").Return(tt.disabled, tt.err)
+			}
+
+			h := TwoFactorHandler{Svc: mockTwoFactorSvc}
+			h.Disable(w, newTwoFactorRequest("DELETE", "", tt.body))
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockTwoFactorSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTwoFactorHandler_RegenerateRecoveryCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		codes          []string
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", []string{"abcde-fghij"}, nil, http.StatusOK},
+		{"not enabled", nil, nil, http.StatusConflict},
+		{"two-factor svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mockTwoFactorSvc := new(mockTwoFactorSvc)
+			mockTwoFactorSvc.On("RegenerateRecoveryCodes", "u1").Return(tt.codes, tt.err)
+
+			h := TwoFactorHandler{Svc: mockTwoFactorSvc}
+			h.RegenerateRecoveryCodes(w, newTwoFactorRequest("POST", "/recovery-codes", ""))
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockTwoFactorSvc.AssertExpectations(t)
+		})
+	}
+}
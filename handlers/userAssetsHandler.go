@@ -6,9 +6,11 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/MonikaPalova/currency-master/auth"
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/market"
 	"github.com/MonikaPalova/currency-master/model"
 	"github.com/MonikaPalova/currency-master/httputils"
 	"github.com/gorilla/mux"
@@ -20,6 +22,8 @@ type UserAssetsHandler struct {
 	ASvc  assetsSvc
 	UaSvc userAssetsSvc
 	USvc  usersSvc
+	Exec  executor
+	Clock clock.Clock
 }
 
 type userAssetOperation struct {
@@ -33,6 +37,26 @@ type userAssetOperationResponse struct {
 	AssetId  string  `json:"assetId"`
 	Balance  float64 `json:"balance"`
 	Quantity float64 `json:"quantity"`
+
+	// executed quantity, the part of the requested one which wasn't executed and its average price
+	Sold        float64 `json:"sold"`
+	Remaining   float64 `json:"remaining"`
+	AvgPriceUSD float64 `json:"avgPriceUSD"`
+}
+
+// acquisition with the part of the requested quantity which wasn't executed
+type buyResponse struct {
+	model.Acquisition
+	Remaining float64 `json:"remaining"`
+}
+
+type executor interface {
+	// buys quantity of asset with market impact, partially if there is not enough liquidity
+	Buy(asset coinapi.Asset, quantity float64) market.Fill
+	// sells quantity of asset with market impact, partially if there is not enough liquidity
+	Sell(asset coinapi.Asset, quantity float64) market.Fill
+	// takes the liquidity used by a fill once its trade is done
+	Take(fill market.Fill)
 }
 
 type userAssetsSvc interface {
@@ -84,7 +108,8 @@ func (u UserAssetsHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	httputils.RespondWithOK(w,jsonResponse)
 }
 
-// Buys asset for user with the given quantity
+// Buys asset for user with the given quantity from the ask price up, with market impact.
+// Only part of the quantity is bought if there is not enough liquidity
 func (u UserAssetsHandler) Buy(w http.ResponseWriter, r *http.Request) {
 	operation, err := getOperation(r)
 	if err != nil {
@@ -107,6 +132,10 @@ func (u UserAssetsHandler) Buy(w http.ResponseWriter, r *http.Request) {
 		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("Asset with id %s doesn't exist", operation.assetId))
 		return
 	}
+	if asset.PriceUSD <= 0 {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("Asset with id %s has no price", operation.assetId))
+		return
+	}
 
 	user, err := u.USvc.GetByUsername(operation.username, false)
 	if err != nil {
@@ -118,9 +147,13 @@ func (u UserAssetsHandler) Buy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	price := operation.quantity * asset.PriceUSD
-	if price > user.USD {
-		httputils.RespondWithError(w, http.StatusConflict, nil, fmt.Sprintf("user with username %s doesn't have enough money to buy asset %s, needed: %f", operation.username, operation.assetId, price-user.USD))
+	fill := u.Exec.Buy(*asset, operation.quantity)
+	if fill.Quantity <= 0 {
+		httputils.RespondWithError(w, http.StatusConflict, nil, fmt.Sprintf("there is no liquidity to buy asset %s", operation.assetId))
+		return
+	}
+	if fill.TotalUSD > user.USD {
+		httputils.RespondWithError(w, http.StatusConflict, nil, fmt.Sprintf("user with username %s doesn't have enough money to buy asset %s, needed: %f", operation.username, operation.assetId, fill.TotalUSD-user.USD))
 		return
 	}
 
@@ -130,7 +163,7 @@ func (u UserAssetsHandler) Buy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if userAsset == nil {
-		userAsset = &model.UserAsset{Username: operation.username, AssetId: operation.assetId, Name: asset.Name, Quantity: operation.quantity}
+		userAsset = &model.UserAsset{Username: operation.username, AssetId: operation.assetId, Name: asset.Name, Quantity: fill.Quantity}
 		_, err = u.UaSvc.Create(*userAsset)
 		if err != nil {
 			httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not create new user asset in database")
@@ -138,7 +171,7 @@ func (u UserAssetsHandler) Buy(w http.ResponseWriter, r *http.Request) {
 		}
 		log.Printf("Created new user asset, username %s, asset id %s, quantity %f", userAsset.Username, userAsset.AssetId, userAsset.Quantity)
 	} else {
-		userAsset.Quantity += operation.quantity
+		userAsset.Quantity += fill.Quantity
 		_, err = u.UaSvc.Update(*userAsset)
 		if err != nil {
 			httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not update asset in database")
@@ -147,7 +180,7 @@ func (u UserAssetsHandler) Buy(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Updated existing user asset's quantity, username %s, asset id %s, new quantity %f", userAsset.Username, userAsset.AssetId, userAsset.Quantity)
 	}
 
-	paid := operation.quantity * asset.PriceUSD
+	paid := fill.TotalUSD
 	_, err = u.USvc.DeductUSD(operation.username, paid)
 	if err != nil {
 		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not update user in database")
@@ -155,24 +188,26 @@ func (u UserAssetsHandler) Buy(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Deducted %f usd from user %s", paid, operation.username)
 
-	acq := model.Acquisition{Username: operation.username, AssetId: operation.assetId, Quantity: operation.quantity, PriceUSD: asset.PriceUSD, TotalUSD: paid, Created: time.Now().UTC()}
+	acq := model.Acquisition{Username: operation.username, AssetId: operation.assetId, Quantity: fill.Quantity, PriceUSD: fill.AvgPriceUSD, TotalUSD: paid, Created: u.Clock.Now().UTC()}
 	createdAcq, err := u.ADB.Create(acq)
 	if err != nil {
 		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not save acquisition in database")
 		return
 	}
 	log.Printf("created new acquisition, username %s, asset id %s, created %v, quantity %f", acq.Username, acq.AssetId, acq.Created, acq.Quantity)
+	u.Exec.Take(fill)
 
-	jsonResponse, err := json.Marshal(createdAcq)
+	jsonResponse, err := json.Marshal(buyResponse{Acquisition: *createdAcq, Remaining: fill.Remaining})
 	if err != nil {
 		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not convert acquisition response to JSON")
 		return
 	}
-	log.Printf("User %s successfully bought %f of asset with id %s", operation.username, operation.quantity, operation.assetId)
+	log.Printf("User %s successfully bought %f of asset with id %s, %f remaining", operation.username, fill.Quantity, operation.assetId, fill.Remaining)
 	httputils.RespondWithOK(w,jsonResponse)
 }
 
-// Sells the given quantity of an asset owned by user
+// Sells the given quantity of an asset owned by user from the bid price down, with market impact.
+// Only part of the quantity is sold if there is not enough liquidity
 func (u UserAssetsHandler) Sell(w http.ResponseWriter, r *http.Request) {
 	operation, err := getOperation(r)
 	if err != nil {
@@ -210,8 +245,19 @@ func (u UserAssetsHandler) Sell(w http.ResponseWriter, r *http.Request) {
 		httputils.RespondWithError(w, http.StatusGone, nil, fmt.Sprintf("Asset with id %s doesn't exist", operation.assetId))
 		return
 	}
+	// it can't be sold until it is priced again or settled
+	if asset.PriceUSD <= 0 {
+		httputils.RespondWithError(w, http.StatusConflict, nil, fmt.Sprintf("Asset with id %s has no price", operation.assetId))
+		return
+	}
+
+	fill := u.Exec.Sell(*asset, operation.quantity)
+	if fill.Quantity <= 0 {
+		httputils.RespondWithError(w, http.StatusConflict, nil, fmt.Sprintf("there is no liquidity to sell asset %s", operation.assetId))
+		return
+	}
 
-	userAsset.Quantity -= operation.quantity
+	userAsset.Quantity -= fill.Quantity
 	if userAsset.Quantity == 0 {
 		if err := u.UaSvc.Delete(*userAsset); err != nil {
 			httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not delete asset from database")
@@ -227,21 +273,23 @@ func (u UserAssetsHandler) Sell(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Updated existing user asset's quantity, username %s, asset id %s, new quantity %f", userAsset.Username, userAsset.AssetId, userAsset.Quantity)
 	}
 
-	earned := operation.quantity * asset.PriceUSD
+	earned := fill.TotalUSD
 	balance, err := u.USvc.AddUSD(operation.username, earned)
 	if err != nil {
 		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not update user in database")
 		return
 	}
 	log.Printf("Added %f usd from user %s, new balance %f", earned, operation.username, balance)
+	u.Exec.Take(fill)
 
-	operationResponse := userAssetOperationResponse{Username: operation.username, AssetId: operation.assetId, Quantity: userAsset.Quantity, Balance: balance}
+	operationResponse := userAssetOperationResponse{Username: operation.username, AssetId: operation.assetId, Quantity: userAsset.Quantity, Balance: balance,
+		Sold: fill.Quantity, Remaining: fill.Remaining, AvgPriceUSD: fill.AvgPriceUSD}
 	jsonResponse, err := json.Marshal(operationResponse)
 	if err != nil {
 		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not convert sell operation response to JSON")
 		return
 	}
-	log.Printf("User %s successfully sold %f of asset with id %s", operation.username, operation.quantity, operation.assetId)
+	log.Printf("User %s successfully sold %f of asset with id %s, %f remaining", operation.username, fill.Quantity, operation.assetId, fill.Remaining)
 	httputils.RespondWithOK(w,jsonResponse)
 }
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,7 +10,10 @@ import (
 	"time"
 
 	"github.com/MonikaPalova/currency-master/auth"
+	"github.com/MonikaPalova/currency-master/clock"
 	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/market"
 	"github.com/MonikaPalova/currency-master/model"
 	"github.com/stretchr/testify/mock"
 )
@@ -46,6 +50,9 @@ func (m *mockUserAssetsSvc) Delete(asset model.UserAsset) error {
 	return args.Error(0)
 }
 
+// time at which the fake clocks of the handlers tests are stopped
+var testNow = time.Date(2022, 2, 17, 10, 0, 0, 0, time.UTC)
+
 type testCtx struct {
 	username string
 	id       string
@@ -199,7 +206,8 @@ func TestUserAssetsHandler_Buy_NotFound(t *testing.T) {
 		wantStatusCode int
 	}{
 		{"no such asset in external api", fields{asset: nil, user: &model.User{Username: "u1"}}, args{httptest.NewRecorder(), "u1", "id1", "?quantity=1"}, http.StatusNotFound},
-		{"no such user", fields{asset: &coinapi.Asset{ID: "id1"}, user: nil}, args{httptest.NewRecorder(), "u1", "id1", "?quantity=1"}, http.StatusNotFound},
+		{"asset without a price", fields{asset: &coinapi.Asset{ID: "id1"}, user: &model.User{Username: "u1"}}, args{httptest.NewRecorder(), "u1", "id1", "?quantity=1"}, http.StatusNotFound},
+		{"no such user", fields{asset: &coinapi.Asset{ID: "id1", PriceUSD: 2}, user: nil}, args{httptest.NewRecorder(), "u1", "id1", "?quantity=1"}, http.StatusNotFound},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -232,7 +240,7 @@ func TestUserAssetsHandler_Buy_NoMoney(t *testing.T) {
 	mockAssetsSvc := new(mockAssetsSvc)
 	mockAssetsSvc.On("GetAssetById", id).Return(&coinapi.Asset{ID: id, PriceUSD: 100}, nil)
 
-	u := UserAssetsHandler{USvc: mockUsersSvc, ASvc: mockAssetsSvc}
+	u := UserAssetsHandler{USvc: mockUsersSvc, ASvc: mockAssetsSvc, Exec: market.Impact{}}
 	w := httptest.NewRecorder()
 	r = r.WithContext(testCtx{username: username, id: id})
 	u.Buy(w, r)
@@ -260,10 +268,11 @@ func TestUserAssetsHandler_Buy_NewAsset(t *testing.T) {
 	mockUserAssetsSvc := new(mockUserAssetsSvc)
 	mockUserAssetsSvc.On("GetByUsernameAndId", username, id).Return(nil, nil)
 	mockUserAssetsSvc.On("Create", ua).Return(&ua, nil)
+	acq := model.Acquisition{Username: username, AssetId: id, Quantity: q, PriceUSD: a.PriceUSD, TotalUSD: q * a.PriceUSD, Created: testNow}
 	mockAcqDB := new(mockAcqDB)
-	mockAcqDB.On("Create", mock.Anything).Return(&model.Acquisition{}, nil)
+	mockAcqDB.On("Create", acq).Return(&acq, nil)
 
-	u := UserAssetsHandler{USvc: mockUsersSvc, ASvc: mockAssetsSvc, UaSvc: mockUserAssetsSvc, ADB: mockAcqDB}
+	u := UserAssetsHandler{USvc: mockUsersSvc, ASvc: mockAssetsSvc, UaSvc: mockUserAssetsSvc, ADB: mockAcqDB, Exec: market.Impact{}, Clock: clock.NewFake(testNow)}
 	w := httptest.NewRecorder()
 	r = r.WithContext(testCtx{username: username, id: id})
 	u.Buy(w, r)
@@ -280,7 +289,7 @@ func TestUserAssetsHandler_Buy_NewAsset(t *testing.T) {
 func TestUserAssetsHandler_Buy_UpdateAsset(t *testing.T) {
 	username := "u1"
 	id := "id1"
-	a := coinapi.Asset{ID: id, PriceUSD: 2, Name: "n1"}
+	a := coinapi.Asset{ID: id, PriceUSD: 1.5, BidUSD: 1, AskUSD: 2, Name: "n1"}
 	q := 1.0
 	ua := model.UserAsset{Username: username, AssetId: id, Name: a.Name, Quantity: 2}
 	updatedUa := model.UserAsset{Username: username, AssetId: id, Name: a.Name, Quantity: 2 + q}
@@ -288,16 +297,17 @@ func TestUserAssetsHandler_Buy_UpdateAsset(t *testing.T) {
 
 	mockUsersSvc := new(mockUsersSvc)
 	mockUsersSvc.On("GetByUsername", username, false).Return(&model.User{Username: username, USD: 2}, nil)
-	mockUsersSvc.On("DeductUSD", username, q*a.PriceUSD).Return(2-q*a.PriceUSD, nil)
+	mockUsersSvc.On("DeductUSD", username, q*a.AskUSD).Return(2-q*a.AskUSD, nil)
 	mockAssetsSvc := new(mockAssetsSvc)
 	mockAssetsSvc.On("GetAssetById", id).Return(&a, nil)
 	mockUserAssetsSvc := new(mockUserAssetsSvc)
 	mockUserAssetsSvc.On("GetByUsernameAndId", username, id).Return(&ua, nil)
 	mockUserAssetsSvc.On("Update", updatedUa).Return(&updatedUa, nil)
+	acq := model.Acquisition{Username: username, AssetId: id, Quantity: q, PriceUSD: a.AskUSD, TotalUSD: q * a.AskUSD, Created: testNow}
 	mockAcqDB := new(mockAcqDB)
-	mockAcqDB.On("Create", mock.Anything).Return(&model.Acquisition{}, nil)
+	mockAcqDB.On("Create", acq).Return(&acq, nil)
 
-	u := UserAssetsHandler{USvc: mockUsersSvc, ASvc: mockAssetsSvc, UaSvc: mockUserAssetsSvc, ADB: mockAcqDB}
+	u := UserAssetsHandler{USvc: mockUsersSvc, ASvc: mockAssetsSvc, UaSvc: mockUserAssetsSvc, ADB: mockAcqDB, Exec: market.Impact{}, Clock: clock.NewFake(testNow)}
 	w := httptest.NewRecorder()
 	r = r.WithContext(testCtx{username: username, id: id})
 	u.Buy(w, r)
@@ -364,6 +374,29 @@ func TestUserAssetsHandler_Sell_Gone(t *testing.T) {
 	mockUserAssetsSvc.AssertExpectations(t)
 }
 
+func TestUserAssetsHandler_Sell_NoPrice(t *testing.T) {
+	username := "u1"
+	id := "id1"
+	ua := model.UserAsset{Username: username, AssetId: id, Name: "name", Quantity: 2}
+	r := httptest.NewRequest("POST", testAppConfig.UsersApiV1+"/"+username+"/assets/"+id+"/sell?quantity=1", nil)
+
+	mockAssetsSvc := new(mockAssetsSvc)
+	mockAssetsSvc.On("GetAssetById", id).Return(&coinapi.Asset{ID: id, Name: "name"}, nil)
+	mockUserAssetsSvc := new(mockUserAssetsSvc)
+	mockUserAssetsSvc.On("GetByUsernameAndId", username, id).Return(&ua, nil)
+
+	u := UserAssetsHandler{ASvc: mockAssetsSvc, UaSvc: mockUserAssetsSvc, Exec: market.Impact{}}
+	w := httptest.NewRecorder()
+	r = r.WithContext(testCtx{username: username, id: id})
+	u.Sell(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusConflict)
+	}
+	mockAssetsSvc.AssertExpectations(t)
+	mockUserAssetsSvc.AssertExpectations(t)
+}
+
 func TestUserAssetsHandler_Sell_NoQuantity(t *testing.T) {
 	username := "u1"
 	id := "id1"
@@ -406,7 +439,7 @@ func TestUserAssetsHandler_Sell_NoAsset(t *testing.T) {
 func TestUserAssetsHandler_Sell_Ok(t *testing.T) {
 	username := "u1"
 	id := "id1"
-	a := coinapi.Asset{ID: id, PriceUSD: 2, Name: "n1"}
+	a := coinapi.Asset{ID: id, PriceUSD: 2, BidUSD: 1.5, AskUSD: 2.5, Name: "n1"}
 	ua := model.UserAsset{Username: username, AssetId: id, Name: a.Name, Quantity: 5}
 	updatedUa := model.UserAsset{Username: username, AssetId: id, Name: a.Name, Quantity: 5 - 1}
 	r := httptest.NewRequest("POST", testAppConfig.UsersApiV1+"/"+username+"/assets/"+id+"/sell?quantity=1", nil)
@@ -417,9 +450,9 @@ func TestUserAssetsHandler_Sell_Ok(t *testing.T) {
 	mockAssetsSvc := new(mockAssetsSvc)
 	mockAssetsSvc.On("GetAssetById", id).Return(&a, nil)
 	mockUsersSvc := new(mockUsersSvc)
-	mockUsersSvc.On("AddUSD", username, 1*a.PriceUSD).Return(3.0, nil)
+	mockUsersSvc.On("AddUSD", username, 1*a.BidUSD).Return(3.0, nil)
 
-	u := UserAssetsHandler{UaSvc: mockUserAssetsSvc, ASvc: mockAssetsSvc, USvc: mockUsersSvc}
+	u := UserAssetsHandler{UaSvc: mockUserAssetsSvc, ASvc: mockAssetsSvc, USvc: mockUsersSvc, Exec: market.Impact{}}
 	w := httptest.NewRecorder()
 	r = r.WithContext(testCtx{username: username, id: id})
 	u.Sell(w, r)
@@ -431,3 +464,82 @@ func TestUserAssetsHandler_Sell_Ok(t *testing.T) {
 	mockAssetsSvc.AssertExpectations(t)
 	mockUsersSvc.AssertExpectations(t)
 }
+
+// book with two levels of 2 usd, the second one 1% away from the quoted price
+var thinBook = market.NewImpact(&config.Execution{DepthFraction: 0.01, Levels: 2, LevelStepBps: 100}, clock.NewFake(testNow))
+
+func TestUserAssetsHandler_Buy_PartialFill(t *testing.T) {
+	username := "u1"
+	id := "id1"
+	a := coinapi.Asset{ID: id, PriceUSD: 1, Name: "n1", Volume24hUSD: 400}
+	fill := thinBook.Buy(a, 5)
+	ua := model.UserAsset{Username: username, AssetId: id, Name: a.Name, Quantity: fill.Quantity}
+	r := httptest.NewRequest("POST", testAppConfig.UsersApiV1+"/"+username+"/assets/"+id+"/buy?quantity=5", nil)
+
+	mockUsersSvc := new(mockUsersSvc)
+	mockUsersSvc.On("GetByUsername", username, false).Return(&model.User{Username: username, USD: 10}, nil)
+	mockUsersSvc.On("DeductUSD", username, 4.0).Return(6.0, nil)
+	mockAssetsSvc := new(mockAssetsSvc)
+	mockAssetsSvc.On("GetAssetById", id).Return(&a, nil)
+	mockUserAssetsSvc := new(mockUserAssetsSvc)
+	mockUserAssetsSvc.On("GetByUsernameAndId", username, id).Return(nil, nil)
+	mockUserAssetsSvc.On("Create", ua).Return(&ua, nil)
+	acq := model.Acquisition{Username: username, AssetId: id, Quantity: fill.Quantity, PriceUSD: 4 / fill.Quantity, TotalUSD: 4, Created: testNow}
+	mockAcqDB := new(mockAcqDB)
+	mockAcqDB.On("Create", acq).Return(&acq, nil)
+
+	u := UserAssetsHandler{USvc: mockUsersSvc, ASvc: mockAssetsSvc, UaSvc: mockUserAssetsSvc, ADB: mockAcqDB, Exec: thinBook, Clock: clock.NewFake(testNow)}
+	w := httptest.NewRecorder()
+	r = r.WithContext(testCtx{username: username, id: id})
+	u.Buy(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var got buyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Quantity != fill.Quantity || got.Remaining != 5-fill.Quantity || got.PriceUSD <= a.PriceUSD {
+		t.Errorf("unexpected buy response %+v", got)
+	}
+	mockUsersSvc.AssertExpectations(t)
+	mockUserAssetsSvc.AssertExpectations(t)
+	mockAcqDB.AssertExpectations(t)
+}
+
+func TestUserAssetsHandler_Sell_PartialFill(t *testing.T) {
+	username := "u1"
+	id := "id1"
+	a := coinapi.Asset{ID: id, PriceUSD: 1, Name: "n1", Volume24hUSD: 400}
+	fill := thinBook.Sell(a, 5)
+	ua := model.UserAsset{Username: username, AssetId: id, Name: a.Name, Quantity: 10}
+	updatedUa := model.UserAsset{Username: username, AssetId: id, Name: a.Name, Quantity: 10 - fill.Quantity}
+	r := httptest.NewRequest("POST", testAppConfig.UsersApiV1+"/"+username+"/assets/"+id+"/sell?quantity=5", nil)
+
+	mockUserAssetsSvc := new(mockUserAssetsSvc)
+	mockUserAssetsSvc.On("GetByUsernameAndId", username, id).Return(&ua, nil)
+	mockUserAssetsSvc.On("Update", updatedUa).Return(&updatedUa, nil)
+	mockAssetsSvc := new(mockAssetsSvc)
+	mockAssetsSvc.On("GetAssetById", id).Return(&a, nil)
+	mockUsersSvc := new(mockUsersSvc)
+	mockUsersSvc.On("AddUSD", username, fill.TotalUSD).Return(10.0, nil)
+
+	u := UserAssetsHandler{UaSvc: mockUserAssetsSvc, ASvc: mockAssetsSvc, USvc: mockUsersSvc, Exec: thinBook}
+	w := httptest.NewRecorder()
+	r = r.WithContext(testCtx{username: username, id: id})
+	u.Sell(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusOK)
+	}
+	var got userAssetOperationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Sold != fill.Quantity || got.Remaining <= 0 || got.AvgPriceUSD >= a.PriceUSD {
+		t.Errorf("unexpected sell response %+v", got)
+	}
+	mockUserAssetsSvc.AssertExpectations(t)
+	mockUsersSvc.AssertExpectations(t)
+}
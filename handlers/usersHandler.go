@@ -2,18 +2,41 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
+	"github.com/MonikaPalova/currency-master/auth"
 	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
 	"github.com/MonikaPalova/currency-master/httputils"
 	"github.com/gorilla/mux"
 )
 
 // Users API
 type UsersHandler struct {
-	Svc usersSvc
+	Svc      usersSvc
+	Sessions userSessions
+	Keys     userKeys
+	Accounts userAccounts
+	// name of the cookie of the session kept when the password is changed
+	SessionCookieName string
+}
+
+type userSessions interface {
+	// delete the sessions of user except the one with id keep, all if keep is empty
+	DeleteOthers(username, keep string) error
+}
+
+type userKeys interface {
+	// revoke all api keys of user and get how many there were
+	RevokeAll(username string) (int, error)
+}
+
+type userAccounts interface {
+	// mail user a link verifying their email
+	SendVerification(user model.User) error
 }
 
 type usersSvc interface {
@@ -27,6 +50,19 @@ type usersSvc interface {
 	AddUSD(username string, usd float64) (float64, error)
 	// add usd to user balance and get new balance
 	DeductUSD(username string, usd float64) (float64, error)
+	// set the role of user, nil if the user doesn't exist
+	UpdateRole(username, role string) (*model.User, error)
+	// change the email of user, nil if the user doesn't exist
+	UpdateEmail(username, email string) (*model.User, error)
+	// replace the password of user if current is their password, false if it isn't
+	ChangePassword(username, current, newPassword string) (bool, error)
+	// delete user and archive their acquisitions, false if the user doesn't exist
+	Delete(username string) (bool, error)
+}
+
+// body of a role change request
+type roleRequest struct {
+	Role string `json:"role"`
 }
 
 // handles a create user request
@@ -58,6 +94,9 @@ func (u UsersHandler) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("Created new user %s", user.Username)
+	if err := u.Accounts.SendVerification(*createdUser); err != nil {
+		log.Printf("Could not send verification email to user %s, %v", user.Username, err)
+	}
 	httputils.RespondWithOK(w,jsonResponse)
 }
 
@@ -99,3 +138,166 @@ func (u UsersHandler) GetByUsername(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Retrieved user %s with valuation", username)
 	httputils.RespondWithOK(w,jsonResponse)
 }
+
+// changes the role of a user
+func (u UsersHandler) PutRole(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	var body roleRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to role")
+		return
+	}
+	if !model.ValidRole(body.Role) {
+		httputils.RespondWithError(w, http.StatusBadRequest, nil, fmt.Sprintf("role should be one of %s, %s and %s", model.RoleUser, model.RoleSupport, model.RoleAdmin))
+		return
+	}
+
+	user, err := u.Svc.UpdateRole(username, body.Role)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not change the role of user %s", username))
+		return
+	}
+	if user == nil {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user with username %s doesn't exist", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(user)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not convert user to JSON")
+		return
+	}
+	log.Printf("User %s changed the role of user %s to %s", auth.GetUser(r), username, body.Role)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// changes the profile of a user
+func (u UsersHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	var update model.ProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to profile")
+		return
+	}
+	if err := update.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "profile body is invalid")
+		return
+	}
+
+	user, err := u.Svc.UpdateEmail(username, update.Email)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not update the profile of user %s", username))
+		return
+	}
+	if user == nil {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user with username %s doesn't exist", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(user)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "Could not convert user to JSON")
+		return
+	}
+	log.Printf("Updated profile of user %s", username)
+	if err := u.Accounts.SendVerification(*user); err != nil {
+		log.Printf("Could not send verification email to user %s, %v", username, err)
+	}
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// mails a new verification link to a user whose email isn't verified yet
+func (u UsersHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	user, err := u.Svc.GetByUsername(username, false)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not retrieve user with username %s from database", username))
+		return
+	}
+	if user == nil {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user with username %s doesn't exist", username))
+		return
+	}
+	if user.EmailVerified {
+		httputils.RespondWithError(w, http.StatusConflict, nil, fmt.Sprintf("email of user %s is already verified", username))
+		return
+	}
+
+	if err := u.Accounts.SendVerification(*user); err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not send verification email")
+		return
+	}
+	log.Printf("Sent verification email to user %s", username)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// changes the password of a user, ends their sessions except the current one and revokes their api keys
+func (u UsersHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	var change model.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to password change")
+		return
+	}
+	if err := change.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "password change body is invalid")
+		return
+	}
+
+	changed, err := u.Svc.ChangePassword(username, change.CurrentPassword, change.NewPassword)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not change the password of user %s", username))
+		return
+	}
+	if !changed {
+		httputils.RespondWithError(w, http.StatusForbidden, nil, "current password is not valid")
+		return
+	}
+
+	keep := ""
+	if cookie, err := r.Cookie(u.SessionCookieName); err == nil {
+		keep = cookie.Value
+	}
+	if err := u.Sessions.DeleteOthers(username, keep); err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "password was changed but the other sessions could not be ended")
+		return
+	}
+	// api keys and their signing secrets may be known to whoever knew the old password
+	revoked, err := u.Keys.RevokeAll(username)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "password was changed but the api keys could not be revoked")
+		return
+	}
+
+	log.Printf("Changed password of user %s, revoked %d api keys", username, revoked)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deletes a user who doesn't hold assets and ends their sessions
+func (u UsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	deleted, err := u.Svc.Delete(username)
+	if err != nil {
+		var hasAssets svc.UserHasAssetsError
+		if errors.As(err, &hasAssets) {
+			httputils.RespondWithError(w, http.StatusConflict, nil, err.Error())
+			return
+		}
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not delete user %s", username))
+		return
+	}
+	if !deleted {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user with username %s doesn't exist", username))
+		return
+	}
+
+	// sessions in the database are deleted with the user, the ones in memory are deleted here
+	if err := u.Sessions.DeleteOthers(username, ""); err != nil {
+		log.Printf("Could not end the sessions of deleted user %s, %v", username, err)
+	}
+	if auth.GetUser(r) == username {
+		http.SetCookie(w, &http.Cookie{Name: u.SessionCookieName, MaxAge: -1})
+	}
+	log.Printf("User %s deleted user %s", auth.GetUser(r), username)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/MonikaPalova/currency-master/auth"
 	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
 )
@@ -41,6 +44,53 @@ func (m *mockUsersSvc) DeductUSD(username string, usd float64) (float64, error)
 	return args.Get(0).(float64), args.Error(1)
 }
 
+func (m *mockUsersSvc) UpdateRole(username, role string) (*model.User, error) {
+	args := m.Called(username, role)
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *mockUsersSvc) UpdateEmail(username, email string) (*model.User, error) {
+	args := m.Called(username, email)
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *mockUsersSvc) ChangePassword(username, current, newPassword string) (bool, error) {
+	args := m.Called(username, current, newPassword)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockUsersSvc) Delete(username string) (bool, error) {
+	args := m.Called(username)
+	return args.Bool(0), args.Error(1)
+}
+
+type mockUserSessions struct {
+	mock.Mock
+}
+
+func (m *mockUserSessions) DeleteOthers(username, keep string) error {
+	args := m.Called(username, keep)
+	return args.Error(0)
+}
+
+type mockUserKeys struct {
+	mock.Mock
+}
+
+func (m *mockUserKeys) RevokeAll(username string) (int, error) {
+	args := m.Called(username)
+	return args.Int(0), args.Error(1)
+}
+
+type mockUserAccounts struct {
+	mock.Mock
+}
+
+func (m *mockUserAccounts) SendVerification(user model.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
 func TestUsersHandler_Post_InvalidData(t *testing.T) {
 	type fields struct {
 		user *model.User
@@ -57,9 +107,11 @@ func TestUsersHandler_Post_InvalidData(t *testing.T) {
 		wantStatusCode int
 	}{
 		{"non-json body", fields{}, args{httptest.NewRecorder()}, ``, http.StatusBadRequest},
-		{"blank username", fields{}, args{httptest.NewRecorder()}, `{"username":"","password":"p1","email":"e1"}`, http.StatusBadRequest},
-		{"blank password", fields{}, args{httptest.NewRecorder()}, `{"username":"u1","password":"","email":"e1"}`, http.StatusBadRequest},
+		{"blank username", fields{}, args{httptest.NewRecorder()}, `{"username":"","password":"p1","email":"e1@example.com"}`, http.StatusBadRequest},
+		{"blank password", fields{}, args{httptest.NewRecorder()}, `{"username":"u1","password":"","email":"e1@example.com"}`, http.StatusBadRequest},
 		{"blank email", fields{}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":""}`, http.StatusBadRequest},
+		{"invalid email", fields{}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1"}`, http.StatusBadRequest},
+		{"email with a name", fields{}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"Monika <e1@example.com>"}`, http.StatusBadRequest},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -80,11 +132,12 @@ func TestUsersHandler_Post(t *testing.T) {
 		user        model.User
 		createdUser *model.User
 		err         error
+		mailErr     error
 	}
 	type args struct {
 		w *httptest.ResponseRecorder
 	}
-	u := model.User{Username: "u1", Password: "p1", Email: "e1"}
+	u := model.User{Username: "u1", Password: "p1", Email: "e1@example.com"}
 	tests := []struct {
 		name           string
 		fields         fields
@@ -92,9 +145,10 @@ func TestUsersHandler_Post(t *testing.T) {
 		body           string
 		wantStatusCode int
 	}{
-		{"users svc error", fields{user: u, createdUser: &u, err: fmt.Errorf("")}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1"}`, http.StatusInternalServerError},
-		{"user already exists", fields{user: u, createdUser: nil, err: nil}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1"}`, http.StatusConflict},
-		{"ok", fields{user: u, createdUser: &u, err: nil}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1"}`, http.StatusOK},
+		{"users svc error", fields{user: u, createdUser: &u, err: fmt.Errorf("")}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1@example.com"}`, http.StatusInternalServerError},
+		{"user already exists", fields{user: u, createdUser: nil, err: nil}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1@example.com"}`, http.StatusConflict},
+		{"ok", fields{user: u, createdUser: &u, err: nil}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1@example.com"}`, http.StatusOK},
+		{"verification not sent", fields{user: u, createdUser: &u, err: nil, mailErr: fmt.Errorf("smtp")}, args{httptest.NewRecorder()}, `{"username":"u1","password":"p1","email":"e1@example.com"}`, http.StatusOK},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -102,14 +156,19 @@ func TestUsersHandler_Post(t *testing.T) {
 
 			mockUsersSvc := new(mockUsersSvc)
 			mockUsersSvc.On("Create", tt.fields.user).Return(tt.fields.createdUser, tt.fields.err)
+			mockUserAccounts := new(mockUserAccounts)
+			if tt.wantStatusCode == http.StatusOK {
+				mockUserAccounts.On("SendVerification", *tt.fields.createdUser).Return(tt.fields.mailErr)
+			}
 
-			u := UsersHandler{Svc: mockUsersSvc}
+			u := UsersHandler{Svc: mockUsersSvc, Accounts: mockUserAccounts}
 			u.Post(tt.args.w, r)
 
 			if tt.args.w.Code != tt.wantStatusCode {
 				t.Fatalf("unexpected status code: got %v want %v", tt.args.w.Code, tt.wantStatusCode)
 			}
 			mockUsersSvc.AssertExpectations(t)
+			mockUserAccounts.AssertExpectations(t)
 		})
 	}
 }
@@ -190,3 +249,230 @@ func TestUsersHandler_GetByUsername(t *testing.T) {
 		})
 	}
 }
+
+func TestUsersHandler_PutRole(t *testing.T) {
+	u := model.User{Username: "u1", Email: "e1", Role: model.RoleSupport}
+	tests := []struct {
+		name           string
+		body           string
+		user           *model.User
+		err            error
+		callsSvc       bool
+		wantStatusCode int
+	}{
+		{"ok", `{"role":"support"}`, &u, nil, true, http.StatusOK},
+		{"non-json body", ``, nil, nil, false, http.StatusBadRequest},
+		{"unknown role", `{"role":"root"}`, nil, nil, false, http.StatusBadRequest},
+		{"no such user", `{"role":"support"}`, nil, nil, true, http.StatusNotFound},
+		{"users svc error", `{"role":"support"}`, nil, fmt.Errorf(""), true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PUT", testAppConfig.UsersApiV1+"/u1/role", strings.NewReader(tt.body))
+			r = r.WithContext(testCtx{username: "u1"})
+
+			mockUsersSvc := new(mockUsersSvc)
+			if tt.callsSvc {
+				mockUsersSvc.On("UpdateRole", "u1", model.RoleSupport).Return(tt.user, tt.err)
+			}
+
+			h := UsersHandler{Svc: mockUsersSvc}
+			h.PutRole(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockUsersSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersHandler_Patch(t *testing.T) {
+	u := model.User{Username: "u1", Email: "e2@example.com"}
+	tests := []struct {
+		name           string
+		body           string
+		user           *model.User
+		err            error
+		callsSvc       bool
+		wantStatusCode int
+	}{
+		{"ok", `{"email":"e2@example.com"}`, &u, nil, true, http.StatusOK},
+		{"non-json body", ``, nil, nil, false, http.StatusBadRequest},
+		{"blank email", `{"email":" "}`, nil, nil, false, http.StatusBadRequest},
+		{"invalid email", `{"email":"e2"}`, nil, nil, false, http.StatusBadRequest},
+		{"email with a name", `{"email":"Monika <e2@example.com>"}`, nil, nil, false, http.StatusBadRequest},
+		{"no such user", `{"email":"e2@example.com"}`, nil, nil, true, http.StatusNotFound},
+		{"users svc error", `{"email":"e2@example.com"}`, nil, fmt.Errorf(""), true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PATCH", testAppConfig.UsersApiV1+"/u1", strings.NewReader(tt.body))
+			r = r.WithContext(testCtx{username: "u1"})
+
+			mockUsersSvc := new(mockUsersSvc)
+			if tt.callsSvc {
+				mockUsersSvc.On("UpdateEmail", "u1", "e2@example.com").Return(tt.user, tt.err)
+			}
+			mockUserAccounts := new(mockUserAccounts)
+			if tt.wantStatusCode == http.StatusOK {
+				mockUserAccounts.On("SendVerification", *tt.user).Return(nil)
+			}
+
+			h := UsersHandler{Svc: mockUsersSvc, Accounts: mockUserAccounts}
+			h.Patch(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockUsersSvc.AssertExpectations(t)
+			mockUserAccounts.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersHandler_ResendVerification(t *testing.T) {
+	unverified := model.User{Username: "u1", Email: "e1"}
+	verified := model.User{Username: "u1", Email: "e1", EmailVerified: true}
+	tests := []struct {
+		name           string
+		user           *model.User
+		err            error
+		sends          bool
+		mailErr        error
+		wantStatusCode int
+	}{
+		{"ok", &unverified, nil, true, nil, http.StatusAccepted},
+		{"already verified", &verified, nil, false, nil, http.StatusConflict},
+		{"no such user", nil, nil, false, nil, http.StatusNotFound},
+		{"users svc error", nil, fmt.Errorf(""), false, nil, http.StatusInternalServerError},
+		{"mailer error", &unverified, nil, true, fmt.Errorf("smtp"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", testAppConfig.UsersApiV1+"/u1/verification", nil)
+			r = mux.SetURLVars(r, map[string]string{"username": "u1"})
+
+			mockUsersSvc := new(mockUsersSvc)
+			mockUsersSvc.On("GetByUsername", "u1", false).Return(tt.user, tt.err)
+			mockUserAccounts := new(mockUserAccounts)
+			if tt.sends {
+				mockUserAccounts.On("SendVerification", *tt.user).Return(tt.mailErr)
+			}
+
+			h := UsersHandler{Svc: mockUsersSvc, Accounts: mockUserAccounts}
+			h.ResendVerification(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockUsersSvc.AssertExpectations(t)
+			mockUserAccounts.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersHandler_ChangePassword(t *testing.T) {
+	body := `{"currentPassword":"p1","newPassword":"p2"}`
+	tests := []struct {
+		name           string
+		body           string
+		cookie         *http.Cookie
+		changed        bool
+		err            error
+		sessionsErr    error
+		keysErr        error
+		callsSvc       bool
+		wantKeep       string
+		wantStatusCode int
+	}{
+		{"ok keeps current session", body, &http.Cookie{Name: "session", Value: "sid1"}, true, nil, nil, nil, true, "sid1", http.StatusNoContent},
+		{"ok without session", body, nil, true, nil, nil, nil, true, "", http.StatusNoContent},
+		{"non-json body", ``, nil, false, nil, nil, nil, false, "", http.StatusBadRequest},
+		{"blank current password", `{"currentPassword":"","newPassword":"p2"}`, nil, false, nil, nil, nil, false, "", http.StatusBadRequest},
+		{"blank new password", `{"currentPassword":"p1","newPassword":""}`, nil, false, nil, nil, nil, false, "", http.StatusBadRequest},
+		{"wrong current password", body, nil, false, nil, nil, nil, true, "", http.StatusForbidden},
+		{"users svc error", body, nil, false, fmt.Errorf(""), nil, nil, true, "", http.StatusInternalServerError},
+		{"sessions error", body, nil, true, nil, fmt.Errorf(""), nil, true, "", http.StatusInternalServerError},
+		{"api keys error", body, nil, true, nil, nil, fmt.Errorf(""), true, "", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", testAppConfig.UsersApiV1+"/u1/password", strings.NewReader(tt.body))
+			r = r.WithContext(testCtx{username: "u1"})
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+
+			mockUsersSvc := new(mockUsersSvc)
+			mockSessions := new(mockUserSessions)
+			mockKeys := new(mockUserKeys)
+			if tt.callsSvc {
+				mockUsersSvc.On("ChangePassword", "u1", "p1", "p2").Return(tt.changed, tt.err)
+			}
+			if tt.changed {
+				mockSessions.On("DeleteOthers", "u1", tt.wantKeep).Return(tt.sessionsErr)
+			}
+			if tt.changed && tt.sessionsErr == nil {
+				mockKeys.On("RevokeAll", "u1").Return(2, tt.keysErr)
+			}
+
+			h := UsersHandler{Svc: mockUsersSvc, Sessions: mockSessions, Keys: mockKeys, SessionCookieName: "session"}
+			h.ChangePassword(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockUsersSvc.AssertExpectations(t)
+			mockSessions.AssertExpectations(t)
+			mockKeys.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller         string
+		deleted        bool
+		err            error
+		wantStatusCode int
+		wantCookie     bool
+	}{
+		{"ok", "u1", true, nil, http.StatusNoContent, true},
+		{"ok by admin", "admin", true, nil, http.StatusNoContent, false},
+		{"holds assets", "u1", false, svc.UserHasAssetsError{Username: "u1", Assets: 1}, http.StatusConflict, false},
+		{"no such user", "u1", false, nil, http.StatusNotFound, false},
+		{"users svc error", "u1", false, fmt.Errorf(""), http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", testAppConfig.UsersApiV1+"/u1", nil)
+			r = mux.SetURLVars(r.WithContext(context.WithValue(r.Context(), auth.CallerCtxKey, tt.caller)), map[string]string{"username": "u1"})
+
+			mockUsersSvc := new(mockUsersSvc)
+			mockUsersSvc.On("Delete", "u1").Return(tt.deleted, tt.err)
+			mockSessions := new(mockUserSessions)
+			if tt.deleted {
+				mockSessions.On("DeleteOthers", "u1", "").Return(nil)
+			}
+
+			h := UsersHandler{Svc: mockUsersSvc, Sessions: mockSessions, SessionCookieName: "session"}
+			h.Delete(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			if gotCookie := w.Header().Get("Set-Cookie") != ""; gotCookie != tt.wantCookie {
+				t.Errorf("session cookie cleared = %v, want %v", gotCookie, tt.wantCookie)
+			}
+			mockUsersSvc.AssertExpectations(t)
+			mockSessions.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/auth"
+	"github.com/MonikaPalova/currency-master/httputils"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/gorilla/mux"
+)
+
+// Watchlists API handler. Only the owner can modify a watchlist, public watchlists can be read by every user.
+type WatchlistsHandler struct {
+	Svc watchlistsSvc
+}
+
+type watchlistsSvc interface {
+	// get priced watchlists of user, only the public ones if publicOnly is set
+	GetByUsername(username string, publicOnly bool) ([]model.Watchlist, error)
+	// get priced watchlist of user by id, nil if it doesn't exist
+	GetByID(username, id string) (*model.Watchlist, error)
+	// create a watchlist
+	Create(watchlist model.Watchlist) (*model.Watchlist, error)
+	// replace the name, visibility and assets of a watchlist, nil if it doesn't exist
+	Update(watchlist model.Watchlist) (*model.Watchlist, error)
+	// delete watchlist of user, false if it doesn't exist
+	Delete(username, id string) (bool, error)
+}
+
+// gets the watchlists of user, other users get only the public ones
+func (h WatchlistsHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	publicOnly := auth.GetUser(r) != username
+
+	watchlists, err := h.Svc.GetByUsername(username, publicOnly)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not retrieve watchlists of user %s from database", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(watchlists)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert watchlists to JSON")
+		return
+	}
+	log.Printf("Retrieved watchlists of user %s", username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// gets watchlist of user by id, private watchlists are not found for other users
+func (h WatchlistsHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	id := mux.Vars(r)["id"]
+
+	watchlist, err := h.Svc.GetByID(username, id)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not retrieve watchlist %s of user %s from database", id, username))
+		return
+	}
+	if watchlist == nil || (!watchlist.Public && auth.GetUser(r) != username) {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user %s doesn't have watchlist with id %s", username, id))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(watchlist)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert watchlist to JSON")
+		return
+	}
+	log.Printf("Retrieved watchlist %s of user %s", id, username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// creates a watchlist for user
+func (h WatchlistsHandler) Post(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "watchlists")
+	if !ok {
+		return
+	}
+
+	watchlist, ok := parseWatchlist(w, r)
+	if !ok {
+		return
+	}
+	watchlist.Username = username
+
+	created, err := h.Svc.Create(*watchlist)
+	if err != nil {
+		respondWithRatesError(w, err, fmt.Sprintf("could not create watchlist for user %s", username))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(created)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert created watchlist to JSON")
+		return
+	}
+	log.Printf("Created watchlist %s for user %s", created.ID, username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// replaces the name, visibility and assets of a watchlist of user
+func (h WatchlistsHandler) Put(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "watchlists")
+	if !ok {
+		return
+	}
+
+	watchlist, ok := parseWatchlist(w, r)
+	if !ok {
+		return
+	}
+	watchlist.Username = username
+	watchlist.ID = mux.Vars(r)["id"]
+
+	updated, err := h.Svc.Update(*watchlist)
+	if err != nil {
+		respondWithRatesError(w, err, fmt.Sprintf("could not update watchlist %s of user %s", watchlist.ID, username))
+		return
+	}
+	if updated == nil {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user %s doesn't have watchlist with id %s", username, watchlist.ID))
+		return
+	}
+
+	jsonResponse, err := json.Marshal(updated)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, "could not convert updated watchlist to JSON")
+		return
+	}
+	log.Printf("Updated watchlist %s of user %s", updated.ID, username)
+	httputils.RespondWithOK(w, jsonResponse)
+}
+
+// deletes watchlist of user
+func (h WatchlistsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	username, ok := authorizeOwner(w, r, "watchlists")
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	deleted, err := h.Svc.Delete(username, id)
+	if err != nil {
+		httputils.RespondWithError(w, http.StatusInternalServerError, err, fmt.Sprintf("could not delete watchlist %s of user %s", id, username))
+		return
+	}
+	if !deleted {
+		httputils.RespondWithError(w, http.StatusNotFound, nil, fmt.Sprintf("user %s doesn't have watchlist with id %s", username, id))
+		return
+	}
+
+	log.Printf("Deleted watchlist %s of user %s", id, username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parses and validates the watchlist in the request body, responds with bad request if it is invalid
+func parseWatchlist(w http.ResponseWriter, r *http.Request) (*model.Watchlist, bool) {
+	var watchlist model.Watchlist
+	if err := json.NewDecoder(r.Body).Decode(&watchlist); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "could not parse request body to watchlist")
+		return nil, false
+	}
+	if err := watchlist.ValidateData(); err != nil {
+		httputils.RespondWithError(w, http.StatusBadRequest, err, "watchlist body is invalid")
+		return nil, false
+	}
+	return &watchlist, true
+}
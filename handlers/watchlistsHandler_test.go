@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/svc"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockWatchlistsSvc struct {
+	mock.Mock
+}
+
+func (m *mockWatchlistsSvc) GetByUsername(username string, publicOnly bool) ([]model.Watchlist, error) {
+	args := m.Called(username, publicOnly)
+	return args.Get(0).([]model.Watchlist), args.Error(1)
+}
+
+func (m *mockWatchlistsSvc) GetByID(username, id string) (*model.Watchlist, error) {
+	args := m.Called(username, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.Watchlist), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockWatchlistsSvc) Create(watchlist model.Watchlist) (*model.Watchlist, error) {
+	args := m.Called(watchlist)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.Watchlist), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockWatchlistsSvc) Update(watchlist model.Watchlist) (*model.Watchlist, error) {
+	args := m.Called(watchlist)
+	if args.Get(0) != nil {
+		return args.Get(0).(*model.Watchlist), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockWatchlistsSvc) Delete(username, id string) (bool, error) {
+	args := m.Called(username, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestWatchlistsHandler_GetAll(t *testing.T) {
+	tests := []struct {
+		name           string
+		owner          bool
+		err            error
+		wantStatusCode int
+	}{
+		{"owner", true, nil, http.StatusOK},
+		{"other user sees public only", false, nil, http.StatusOK},
+		{"svc error", true, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/v1/users/user/watchlists", nil)
+			if tt.owner {
+				r = r.WithContext(testCtx{username: "user"})
+			} else {
+				r = r.WithContext(forbiddenCtx{testCtx{username: "user"}})
+			}
+
+			mockWatchlistsSvc := new(mockWatchlistsSvc)
+			mockWatchlistsSvc.On("GetByUsername", "user", !tt.owner).Return([]model.Watchlist{}, tt.err)
+
+			h := WatchlistsHandler{Svc: mockWatchlistsSvc}
+			h.GetAll(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockWatchlistsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWatchlistsHandler_GetByID(t *testing.T) {
+	private := model.Watchlist{ID: "1", Username: "user", Name: "private"}
+	public := model.Watchlist{ID: "1", Username: "user", Name: "public", Public: true}
+	tests := []struct {
+		name           string
+		owner          bool
+		watchlist      *model.Watchlist
+		err            error
+		wantStatusCode int
+	}{
+		{"owner private", true, &private, nil, http.StatusOK},
+		{"other user public", false, &public, nil, http.StatusOK},
+		{"other user private", false, &private, nil, http.StatusNotFound},
+		{"not found", true, nil, nil, http.StatusNotFound},
+		{"svc error", true, nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/v1/users/user/watchlists/1", nil)
+			if tt.owner {
+				r = r.WithContext(testCtx{username: "user", id: "1"})
+			} else {
+				r = r.WithContext(forbiddenCtx{testCtx{username: "user", id: "1"}})
+			}
+
+			mockWatchlistsSvc := new(mockWatchlistsSvc)
+			mockWatchlistsSvc.On("GetByID", "user", "1").Return(tt.watchlist, tt.err)
+
+			h := WatchlistsHandler{Svc: mockWatchlistsSvc}
+			h.GetByID(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockWatchlistsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWatchlistsHandler_Post(t *testing.T) {
+	watchlist := model.Watchlist{Username: "user", Name: "coins", AssetIds: []string{"BTC", "ETH"}}
+	body := `{"name":" coins ","assetIds":["btc","ETH","btc"]}`
+	tests := []struct {
+		name           string
+		created        *model.Watchlist
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", &watchlist, nil, http.StatusOK},
+		{"missing asset", nil, svc.AssetNotFoundError{ID: "BTC"}, http.StatusNotFound},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/watchlists", strings.NewReader(body))
+			r = r.WithContext(testCtx{username: "user"})
+
+			mockWatchlistsSvc := new(mockWatchlistsSvc)
+			mockWatchlistsSvc.On("Create", watchlist).Return(tt.created, tt.err)
+
+			h := WatchlistsHandler{Svc: mockWatchlistsSvc}
+			h.Post(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockWatchlistsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWatchlistsHandler_Post_BadRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed body", `{"name":`},
+		{"blank name", `{"name":" ","assetIds":["BTC"]}`},
+		{"long name", `{"name":"` + strings.Repeat("a", 51) + `"}`},
+		{"blank asset id", `{"name":"coins","assetIds":["BTC",""]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/watchlists", strings.NewReader(tt.body))
+			r = r.WithContext(testCtx{username: "user"})
+
+			h := WatchlistsHandler{Svc: new(mockWatchlistsSvc)}
+			h.Post(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestWatchlistsHandler_Put(t *testing.T) {
+	watchlist := model.Watchlist{ID: "1", Username: "user", Name: "coins", Public: true, AssetIds: []string{"BTC"}}
+	body := `{"name":"coins","public":true,"assetIds":["BTC"]}`
+	tests := []struct {
+		name           string
+		updated        *model.Watchlist
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", &watchlist, nil, http.StatusOK},
+		{"not found", nil, nil, http.StatusNotFound},
+		{"missing asset", nil, svc.AssetNotFoundError{ID: "BTC"}, http.StatusNotFound},
+		{"svc error", nil, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PUT", "/api/v1/users/user/watchlists/1", strings.NewReader(body))
+			r = r.WithContext(testCtx{username: "user", id: "1"})
+
+			mockWatchlistsSvc := new(mockWatchlistsSvc)
+			mockWatchlistsSvc.On("Update", watchlist).Return(tt.updated, tt.err)
+
+			h := WatchlistsHandler{Svc: mockWatchlistsSvc}
+			h.Put(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockWatchlistsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWatchlistsHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		deleted        bool
+		err            error
+		wantStatusCode int
+	}{
+		{"ok", true, nil, http.StatusNoContent},
+		{"not found", false, nil, http.StatusNotFound},
+		{"svc error", false, fmt.Errorf(""), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "/api/v1/users/user/watchlists/1", nil)
+			r = r.WithContext(testCtx{username: "user", id: "1"})
+
+			mockWatchlistsSvc := new(mockWatchlistsSvc)
+			mockWatchlistsSvc.On("Delete", "user", "1").Return(tt.deleted, tt.err)
+
+			h := WatchlistsHandler{Svc: mockWatchlistsSvc}
+			h.Delete(w, r)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, tt.wantStatusCode)
+			}
+			mockWatchlistsSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWatchlistsHandler_ModifyForbidden(t *testing.T) {
+	h := WatchlistsHandler{Svc: new(mockWatchlistsSvc)}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"post", h.Post},
+		{"put", h.Put},
+		{"delete", h.Delete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/v1/users/user/watchlists", strings.NewReader(`{"name":"coins"}`))
+			r = r.WithContext(forbiddenCtx{testCtx{username: "user", id: "1"}})
+
+			tt.handler(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("unexpected status code: got %v want %v", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
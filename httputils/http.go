@@ -4,6 +4,7 @@ package httputils
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 )
 
@@ -30,3 +31,12 @@ func RespondWithOK(w http.ResponseWriter, jsonResponse []byte) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(jsonResponse)
 }
+
+// gets the ip address of the client which sent the request, without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,110 @@
+// Package mail sends emails through SMTP, or writes them to a file or the log during development
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+// An email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Creates the configured mailer.
+// Returns error if the mailer is unknown
+func New(cfg *config.Mail) (Mailer, error) {
+	switch cfg.Mailer {
+	case config.MailerSMTP:
+		return SMTPMailer{Host: cfg.SMTPHost, Port: cfg.SMTPPort, From: cfg.From}, nil
+	case config.MailerFile:
+		return &FileMailer{Path: cfg.File, From: cfg.From}, nil
+	case config.MailerLog:
+		return LogMailer{From: cfg.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %s", cfg.Mailer)
+	}
+}
+
+// the headers can't contain line breaks, which would let recipients inject headers
+func (m Message) validate() error {
+	if strings.TrimSpace(m.To) == "" || strings.ContainsAny(m.To, "\r\n") {
+		return fmt.Errorf("invalid email address %q", m.To)
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("invalid email subject %q", m.Subject)
+	}
+	return nil
+}
+
+func (m Message) format(from string) string {
+	return fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", from, m.To, m.Subject, m.Body)
+}
+
+// Sends emails through an SMTP server without authentication
+type SMTPMailer struct {
+	Host string
+	Port string
+	From string
+}
+
+func (s SMTPMailer) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.Host+":"+s.Port, nil, s.From, []string{msg.To}, []byte(msg.format(s.From))); err != nil {
+		return fmt.Errorf("could not send email to %s, %v", msg.To, err)
+	}
+	return nil
+}
+
+// Appends emails to a file, safe for concurrent use
+type FileMailer struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+func (f *FileMailer) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open mail file %s, %v", f.Path, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(msg.format(f.From) + "\r\n"); err != nil {
+		return fmt.Errorf("could not write email to %s in mail file, %v", msg.To, err)
+	}
+	return nil
+}
+
+// Writes emails to the log
+type LogMailer struct {
+	From string
+}
+
+func (l LogMailer) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	log.Printf("Email:\n%s", msg.format(l.From))
+	return nil
+}
@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/mail/mailtest"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		mailer  string
+		want    Mailer
+		wantErr bool
+	}{
+		{"smtp", config.MailerSMTP, SMTPMailer{Host: "h", Port: "25", From: "f"}, false},
+		{"file", config.MailerFile, &FileMailer{Path: "mail.log", From: "f"}, false},
+		{"log", config.MailerLog, LogMailer{From: "f"}, false},
+		{"unknown", "pigeon", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(&config.Mail{Mailer: tt.mailer, SMTPHost: "h", SMTPPort: "25", From: "f", File: "mail.log"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if file, ok := got.(*FileMailer); ok {
+				if want := tt.want.(*FileMailer); file.Path != want.Path || file.From != want.From {
+					t.Errorf("New() = %+v, want %+v", got, tt.want)
+				}
+			} else if got != tt.want {
+				t.Errorf("New() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+	mailer, _ := New(server.MailConfig())
+
+	if err := mailer.Send(Message{To: "user@mail.com", Subject: "Hello", Body: "Hi there"}); err != nil {
+		t.Fatalf("SMTPMailer.Send() error = %v", err)
+	}
+
+	msg := <-server.Messages
+	for _, want := range []string{"To: user@mail.com", "Subject: Hello", "Hi there"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("SMTPMailer.Send() sent %q, want it to contain %q", msg, want)
+		}
+	}
+}
+
+func TestFileMailer_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	mailer := &FileMailer{Path: path, From: "f@mail.com"}
+
+	for _, to := range []string{"u1@mail.com", "u2@mail.com"} {
+		if err := mailer.Send(Message{To: to, Subject: "Hello", Body: "Hi"}); err != nil {
+			t.Fatalf("FileMailer.Send() error = %v", err)
+		}
+	}
+
+	written, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(written), "To: u1@mail.com") || !strings.Contains(string(written), "To: u2@mail.com") {
+		t.Errorf("FileMailer.Send() wrote %q, want both emails", written)
+	}
+}
+
+func TestMailers_Send_InvalidHeaders(t *testing.T) {
+	mailers := map[string]Mailer{"smtp": SMTPMailer{}, "file": &FileMailer{Path: filepath.Join(t.TempDir(), "mail.log")}, "log": LogMailer{}}
+	messages := map[string]Message{
+		"address with line breaks": {To: "user@mail.com\r\nBcc: other@mail.com"},
+		"blank address":            {To: " "},
+		"subject with line breaks": {To: "user@mail.com", Subject: "Hello\r\nBcc: other@mail.com"},
+	}
+	for name, mailer := range mailers {
+		for msgName, msg := range messages {
+			t.Run(name+" "+msgName, func(t *testing.T) {
+				if err := mailer.Send(msg); err == nil {
+					t.Errorf("Send() expected error")
+				}
+			})
+		}
+	}
+}
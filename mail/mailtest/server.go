@@ -0,0 +1,99 @@
+// Package mailtest provides a local SMTP stand-in which receives the emails sent during tests and development.
+package mailtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+// SMTP server accepting every email without authentication
+type Server struct {
+	Host string
+	Port string
+	// received emails with their headers
+	Messages <-chan string
+
+	listener net.Listener
+	messages chan string
+}
+
+// Starts a server on a local port. It should be closed after use.
+// Panics if it can't listen, like httptest.NewServer
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("mailtest: failed to listen on a port: %v", err))
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	messages := make(chan string, 100)
+	s := &Server{Host: host, Port: port, Messages: messages, listener: listener, messages: messages}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// Stops accepting emails
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Gets the mail configuration which sends emails to the server
+func (s *Server) MailConfig() *config.Mail {
+	cfg := config.NewMail()
+	cfg.Mailer = config.MailerSMTP
+	cfg.SMTPHost = s.Host
+	cfg.SMTPPort = s.Port
+	return cfg
+}
+
+// handles a single SMTP session, sending the received emails on the messages channel
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	reply("220 localhost")
+	var data strings.Builder
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if inData {
+			if line == ".\r\n" {
+				inData = false
+				s.messages <- data.String()
+				data.Reset()
+				reply("250 OK")
+				continue
+			}
+			data.WriteString(line)
+			continue
+		}
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case cmd == "DATA":
+			inData = true
+			reply("354 End data with <CR><LF>.<CR><LF>")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
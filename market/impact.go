@@ -0,0 +1,151 @@
+package market
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+// Result of executing an order against the virtual order book
+type Fill struct {
+	// executed quantity
+	Quantity float64
+	// quantity which couldn't be executed because the book ran out of liquidity
+	Remaining float64
+	// average price of the executed quantity
+	AvgPriceUSD float64
+	TotalUSD    float64
+
+	// book the fill was executed against, the depth it used is taken from it by Impact.Take
+	book book
+	// usd depth of the whole book
+	bookUSD float64
+}
+
+// order book of one side of an asset, buys take depth from the asks and sells from the bids
+type book struct {
+	assetID string
+	side    float64
+}
+
+// usd depth taken from the top of a book by earlier orders
+type depletion struct {
+	usd float64
+	at  time.Time
+}
+
+// Market impact model which executes orders against a virtual order book built from the traded volume of an asset.
+// The book has levels of equal usd depth, each one further from the quoted price, so large orders get worse prices
+// and orders larger than the book are filled partially.
+// Depth taken by an order recovers linearly over the recovery time, so an order split into smaller ones
+// in quick succession walks the book like the whole order would. Without recovery time every order sees the full book.
+// The zero value fills every order of a priced asset at the quoted price
+type Impact struct {
+	// part of the daily volume available in the book
+	depthFraction float64
+	levels        int
+	// distance between levels in basis points of the quoted price
+	levelStepBps float64
+	// time in which a fully taken book recovers, books are not depleted if zero
+	recovery time.Duration
+	clock    clock.Clock
+	// taken depth of the books, shared by copies of the Impact
+	mu       *sync.Mutex
+	depleted map[book]depletion
+}
+
+// Impact constructor, clk tells how much of the taken depth has recovered
+func NewImpact(cfg *config.Execution, clk clock.Clock) *Impact {
+	return &Impact{depthFraction: cfg.DepthFraction, levels: cfg.Levels, levelStepBps: cfg.LevelStepBps,
+		recovery: cfg.Recovery, clock: clk, mu: &sync.Mutex{}, depleted: map[book]depletion{}}
+}
+
+// Buys quantity of asset starting from its ask price
+func (i Impact) Buy(asset coinapi.Asset, quantity float64) Fill {
+	return i.execute(asset.Ask(), book{assetID: asset.ID, side: 1}, asset.Volume24hUSD, quantity)
+}
+
+// Sells quantity of asset starting from its bid price
+func (i Impact) Sell(asset coinapi.Asset, quantity float64) Fill {
+	return i.execute(asset.Bid(), book{assetID: asset.ID, side: -1}, asset.Volume24hUSD, quantity)
+}
+
+// Takes the depth used by fill from its book, so the next orders get worse prices until it recovers.
+// Called once the trade of the fill is done, so rejected orders don't deplete the book.
+// Orders executed at the same time see the same depth
+func (i Impact) Take(fill Fill) {
+	if !i.depletes() || fill.bookUSD <= 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := i.clock.Now()
+	taken := math.Min(i.taken(fill.book, fill.bookUSD, now)+fill.TotalUSD, fill.bookUSD)
+	i.depleted[fill.book] = depletion{usd: taken, at: now}
+}
+
+// walks the book levels away from quote in direction of the book side until quantity is executed or the book is exhausted.
+// Orders start below the depth taken by earlier orders. Assets without volume data are executed at the quoted price,
+// assets without a price are not executed at all
+func (i Impact) execute(quote float64, b book, volumeUSD, quantity float64) Fill {
+	if quote <= 0 {
+		return Fill{Remaining: quantity}
+	}
+	if i.levels <= 0 || i.depthFraction <= 0 || volumeUSD <= 0 {
+		return Fill{Quantity: quantity, AvgPriceUSD: quote, TotalUSD: quantity * quote}
+	}
+
+	bookUSD := volumeUSD * i.depthFraction
+	levelUSD := bookUSD / float64(i.levels)
+	taken := 0.0
+	if i.depletes() {
+		i.mu.Lock()
+		taken = i.taken(b, bookUSD, i.clock.Now())
+		i.mu.Unlock()
+	}
+	remaining := quantity
+	total := 0.0
+	for level := 0; level < i.levels && remaining > 0; level++ {
+		price := quote * (1 + b.side*float64(level)*i.levelStepBps/10000)
+		if price <= 0 {
+			break
+		}
+		// part of the level taken by earlier orders
+		levelTaken := math.Max(0, math.Min(levelUSD, taken-float64(level)*levelUSD))
+		executed := math.Min(remaining, (levelUSD-levelTaken)/price)
+		total += executed * price
+		remaining -= executed
+	}
+	// rounding leftovers of a fully executed order
+	if remaining < quantity*1e-12 {
+		remaining = 0
+	}
+
+	fill := Fill{Quantity: quantity - remaining, Remaining: remaining, TotalUSD: total, book: b, bookUSD: bookUSD}
+	if fill.Quantity > 0 {
+		fill.AvgPriceUSD = total / fill.Quantity
+	}
+	return fill
+}
+
+func (i Impact) depletes() bool {
+	return i.recovery > 0 && i.clock != nil && i.depleted != nil
+}
+
+// usd depth of book b still taken at now, called with the lock held
+func (i Impact) taken(b book, bookUSD float64, now time.Time) float64 {
+	d, ok := i.depleted[b]
+	if !ok {
+		return 0
+	}
+	recovered := bookUSD * float64(now.Sub(d.at)) / float64(i.recovery)
+	if recovered >= d.usd {
+		delete(i.depleted, b)
+		return 0
+	}
+	return d.usd - recovered
+}
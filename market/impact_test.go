@@ -0,0 +1,130 @@
+package market
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+func TestImpact_Execute(t *testing.T) {
+	// 4 levels of 25 usd, 1% apart
+	book := NewImpact(&config.Execution{DepthFraction: 0.1, Levels: 4, LevelStepBps: 100}, clock.NewFake(time.Now()))
+	asset := coinapi.Asset{ID: "XYZ", PriceUSD: 1, BidUSD: 0.99, AskUSD: 1.01, Volume24hUSD: 1000}
+	tests := []struct {
+		name     string
+		impact   Impact
+		buy      bool
+		quantity float64
+		want     Fill
+	}{
+		{"small buy at ask", *book, true, 10, Fill{Quantity: 10, AvgPriceUSD: 1.01, TotalUSD: 10.1}},
+		{"small sell at bid", *book, false, 10, Fill{Quantity: 10, AvgPriceUSD: 0.99, TotalUSD: 9.9}},
+		{"buy walks the book", *book, true, 25/1.01 + 10, Fill{Quantity: 25/1.01 + 10, AvgPriceUSD: (25 + 10*1.01*1.01) / (25/1.01 + 10), TotalUSD: 25 + 10*1.01*1.01}},
+		{"sell larger than the book", *book, false, 1000, Fill{Quantity: 25/0.99 + 25/(0.99*0.99) + 25/(0.99*0.98) + 25/(0.99*0.97), Remaining: 1000 - (25/0.99 + 25/(0.99*0.99) + 25/(0.99*0.98) + 25/(0.99*0.97)), TotalUSD: 100}},
+		{"no impact model", Impact{}, true, 1000, Fill{Quantity: 1000, AvgPriceUSD: 1.01, TotalUSD: 1010}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Fill
+			if tt.buy {
+				got = tt.impact.Buy(asset, tt.quantity)
+			} else {
+				got = tt.impact.Sell(asset, tt.quantity)
+			}
+			if tt.want.AvgPriceUSD == 0 && tt.want.Quantity > 0 {
+				tt.want.AvgPriceUSD = tt.want.TotalUSD / tt.want.Quantity
+			}
+			if !closeFills(got, tt.want) {
+				t.Errorf("Impact fill = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImpact_NoVolumeData(t *testing.T) {
+	book := NewImpact(&config.Execution{DepthFraction: 0.1, Levels: 4, LevelStepBps: 100}, clock.NewFake(time.Now()))
+	got := book.Buy(coinapi.Asset{ID: "EUR", PriceUSD: 1.1}, 1e6)
+	if got.Quantity != 1e6 || got.Remaining != 0 || got.AvgPriceUSD != 1.1 {
+		t.Errorf("Impact.Buy() without volume data = %+v, want full fill at the quoted price", got)
+	}
+}
+
+func TestImpact_NoPrice(t *testing.T) {
+	asset := coinapi.Asset{ID: "XYZ", Volume24hUSD: 1000}
+	for _, impact := range []Impact{*NewImpact(&config.Execution{DepthFraction: 0.1, Levels: 4, LevelStepBps: 100}, clock.NewFake(time.Now())), {}} {
+		if got := impact.Buy(asset, 10); got.Quantity != 0 || got.Remaining != 10 || got.TotalUSD != 0 {
+			t.Errorf("Impact.Buy() without price = %+v, want nothing filled", got)
+		}
+		if got := impact.Sell(asset, 10); got.Quantity != 0 || got.Remaining != 10 || got.TotalUSD != 0 {
+			t.Errorf("Impact.Sell() without price = %+v, want nothing filled", got)
+		}
+	}
+}
+
+func TestImpact_SplitOrders(t *testing.T) {
+	clk := clock.NewFake(time.Date(2022, 2, 17, 10, 0, 0, 0, time.UTC))
+	// 4 levels of 25 usd, 1% apart, recovering in 4 minutes
+	book := NewImpact(&config.Execution{DepthFraction: 0.1, Levels: 4, LevelStepBps: 100, Recovery: 4 * time.Minute}, clk)
+	asset := coinapi.Asset{ID: "XYZ", PriceUSD: 1, BidUSD: 0.99, AskUSD: 1.01, Volume24hUSD: 1000}
+	other := coinapi.Asset{ID: "ABC", PriceUSD: 1, BidUSD: 0.99, AskUSD: 1.01, Volume24hUSD: 1000}
+
+	whole := book.Buy(asset, 25/1.01+10)
+	first := book.Buy(asset, 25/1.01)
+	book.Take(first)
+	second := book.Buy(asset, 10)
+	book.Take(second)
+	if split := (Fill{Quantity: first.Quantity + second.Quantity, TotalUSD: first.TotalUSD + second.TotalUSD, AvgPriceUSD: whole.AvgPriceUSD}); !closeFills(split, whole) {
+		t.Fatalf("split order filled %+v, want the fill of the whole order %+v", split, whole)
+	}
+
+	available := 25 - (whole.TotalUSD - 25)
+	tests := []struct {
+		name     string
+		after    time.Duration
+		buy      bool
+		asset    coinapi.Asset
+		quantity float64
+		want     Fill
+	}{
+		{"sells use the bids", 0, false, asset, 10, Fill{Quantity: 10, AvgPriceUSD: 0.99, TotalUSD: 9.9}},
+		{"other assets have their own book", 0, true, other, 10, Fill{Quantity: 10, AvgPriceUSD: 1.01, TotalUSD: 10.1}},
+		// 25 usd recovered, so the first level is available again but for the depth taken over 25 usd
+		{"partly recovered", time.Minute, true, asset, available/1.01 + 1, Fill{Quantity: available/1.01 + 1, TotalUSD: available + 1.01*1.01}},
+		{"recovered", 3 * time.Minute, true, asset, 10, Fill{Quantity: 10, AvgPriceUSD: 1.01, TotalUSD: 10.1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk.Advance(tt.after)
+			var got Fill
+			if tt.buy {
+				got = book.Buy(tt.asset, tt.quantity)
+			} else {
+				got = book.Sell(tt.asset, tt.quantity)
+			}
+			if tt.want.AvgPriceUSD == 0 {
+				tt.want.AvgPriceUSD = tt.want.TotalUSD / tt.want.Quantity
+			}
+			if !closeFills(got, tt.want) {
+				t.Errorf("Impact fill = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImpact_WithoutRecovery(t *testing.T) {
+	book := NewImpact(&config.Execution{DepthFraction: 0.1, Levels: 4, LevelStepBps: 100}, clock.NewFake(time.Now()))
+	asset := coinapi.Asset{ID: "XYZ", PriceUSD: 1, BidUSD: 0.99, AskUSD: 1.01, Volume24hUSD: 1000}
+	book.Take(book.Buy(asset, 1000))
+	if got := book.Buy(asset, 10); got.AvgPriceUSD != 1.01 {
+		t.Errorf("Impact.Buy() after a large order = %+v, want the full book without recovery time", got)
+	}
+}
+
+func closeFills(a, b Fill) bool {
+	close := func(x, y float64) bool { return math.Abs(x-y) <= 1e-9*math.Max(1, math.Abs(y)) }
+	return close(a.Quantity, b.Quantity) && close(a.Remaining, b.Remaining) && close(a.AvgPriceUSD, b.AvgPriceUSD) && close(a.TotalUSD, b.TotalUSD)
+}
@@ -0,0 +1,61 @@
+// Package market simulates how orders are executed - the spread between bid and ask prices
+package market
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+// limits of the volume spread model in basis points
+const (
+	minVolumeSpreadBps = 1
+	maxVolumeSpreadBps = 500
+	// daily volume in usd at which the volume model quotes the configured spread
+	referenceVolumeUSD = 1e9
+)
+
+// Spread model which quotes bid and ask prices around the mid price of an asset
+type Spread struct {
+	model    string
+	bps      float64
+	assetBps map[string]float64
+}
+
+// Spread constructor.
+// Returns error if the model is unknown
+func NewSpread(cfg *config.Spread) (*Spread, error) {
+	switch cfg.Model {
+	case config.SpreadFixed, config.SpreadVolume:
+	default:
+		return nil, fmt.Errorf("unknown spread model %s", cfg.Model)
+	}
+	return &Spread{model: cfg.Model, bps: cfg.Bps, assetBps: cfg.AssetBps}, nil
+}
+
+// Gets the spread of asset in basis points.
+// The volume model widens the spread with the inverse square root of the daily volume, so illiquid assets are expensive to trade
+func (s Spread) Bps(asset coinapi.Asset) float64 {
+	if bps, ok := s.assetBps[asset.ID]; ok {
+		return bps
+	}
+	if s.model == config.SpreadFixed {
+		return s.bps
+	}
+	if asset.Volume24hUSD <= 0 {
+		return maxVolumeSpreadBps
+	}
+	bps := s.bps * math.Sqrt(referenceVolumeUSD/asset.Volume24hUSD)
+	return math.Max(minVolumeSpreadBps, math.Min(maxVolumeSpreadBps, bps))
+}
+
+// Sets the bid and ask prices of assets half the spread below and above their mid price
+func (s Spread) Quote(assets []coinapi.Asset) {
+	for i := range assets {
+		half := assets[i].PriceUSD * s.Bps(assets[i]) / 10000 / 2
+		assets[i].BidUSD = assets[i].PriceUSD - half
+		assets[i].AskUSD = assets[i].PriceUSD + half
+	}
+}
@@ -0,0 +1,58 @@
+package market
+
+import (
+	"math"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+func TestSpread_Bps(t *testing.T) {
+	volume := config.Spread{Model: config.SpreadVolume, Bps: 10, AssetBps: map[string]float64{"USD": 0}}
+	fixed := config.Spread{Model: config.SpreadFixed, Bps: 20}
+	tests := []struct {
+		name  string
+		cfg   config.Spread
+		asset coinapi.Asset
+		want  float64
+	}{
+		{"volume at reference", volume, coinapi.Asset{ID: "BTC", Volume24hUSD: 1e9}, 10},
+		{"volume liquid", volume, coinapi.Asset{ID: "BTC", Volume24hUSD: 1e11}, 1},
+		{"volume illiquid", volume, coinapi.Asset{ID: "XYZ", Volume24hUSD: 1e7}, 100},
+		{"volume capped", volume, coinapi.Asset{ID: "XYZ", Volume24hUSD: 10}, maxVolumeSpreadBps},
+		{"volume floored", volume, coinapi.Asset{ID: "XYZ", Volume24hUSD: 1e15}, minVolumeSpreadBps},
+		{"no volume data", volume, coinapi.Asset{ID: "XYZ"}, maxVolumeSpreadBps},
+		{"asset override", volume, coinapi.Asset{ID: "USD", Volume24hUSD: 10}, 0},
+		{"fixed", fixed, coinapi.Asset{ID: "XYZ", Volume24hUSD: 10}, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSpread(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Bps(tt.asset); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Spread.Bps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpread_Quote(t *testing.T) {
+	s, err := NewSpread(&config.Spread{Model: config.SpreadFixed, Bps: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets := []coinapi.Asset{{ID: "BTC", PriceUSD: 40000}}
+	s.Quote(assets)
+	if assets[0].BidUSD != 39800 || assets[0].AskUSD != 40200 {
+		t.Errorf("Spread.Quote() bid %v ask %v, want 39800 and 40200", assets[0].BidUSD, assets[0].AskUSD)
+	}
+}
+
+func TestNewSpread_UnknownModel(t *testing.T) {
+	if _, err := NewSpread(&config.Spread{Model: "orderbook"}); err == nil {
+		t.Error("NewSpread() expected error for unknown model")
+	}
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// conditions on which a price alert is triggered
+const (
+	// price crosses the threshold upwards
+	AlertAbove = "above"
+	// price crosses the threshold downwards
+	AlertBelow = "below"
+	// price moves more than threshold percent within a day
+	AlertChange = "change"
+)
+
+// modes of price alerts
+const (
+	// alert is deactivated after it is triggered
+	AlertOnce = "once"
+	// alert is triggered again after its cooldown passes
+	AlertRepeat = "repeat"
+)
+
+// channels through which notifications are delivered
+const (
+	ChannelInbox   = "inbox"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// price alert set by a user for an asset
+type PriceAlert struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	AssetId  string `json:"assetId"`
+
+	// above, below or change
+	Condition string `json:"condition"`
+
+	// price in USD for above and below, percent for change
+	Threshold float64 `json:"threshold"`
+
+	// once or repeat
+	Mode string `json:"mode"`
+
+	// minutes to wait before a repeating alert is triggered again
+	CooldownMinutes int `json:"cooldownMinutes"`
+
+	// inbox, email or webhook
+	Channel string `json:"channel"`
+
+	// email address or webhook url, the user email is used if not set for email
+	Target string `json:"target,omitempty"`
+
+	// last seen price for above and below, price the change is measured from for change
+	ReferencePrice float64   `json:"referencePrice"`
+	ReferenceTime  time.Time `json:"referenceTime"`
+
+	LastTriggered *time.Time `json:"lastTriggered,omitempty"`
+	Active        bool       `json:"active"`
+	Created       time.Time  `json:"created"`
+}
+
+func (a PriceAlert) ValidateData() error {
+	if strings.TrimSpace(a.AssetId) == "" {
+		return fmt.Errorf(notBlankErrTemplate, "assetId")
+	}
+	switch a.Condition {
+	case AlertAbove, AlertBelow, AlertChange:
+	default:
+		return fmt.Errorf("condition should be one of %s, %s, %s", AlertAbove, AlertBelow, AlertChange)
+	}
+	if a.Threshold <= 0 {
+		return fmt.Errorf("threshold should be a positive number")
+	}
+	switch a.Mode {
+	case AlertOnce, AlertRepeat:
+	default:
+		return fmt.Errorf("mode should be one of %s, %s", AlertOnce, AlertRepeat)
+	}
+	if a.CooldownMinutes < 0 {
+		return fmt.Errorf("cooldownMinutes should not be negative")
+	}
+	switch a.Channel {
+	case ChannelInbox, ChannelEmail:
+	case ChannelWebhook:
+		if !strings.HasPrefix(a.Target, "http://") && !strings.HasPrefix(a.Target, "https://") {
+			return fmt.Errorf("target should be an http or https url for webhook alerts")
+		}
+	default:
+		return fmt.Errorf("channel should be one of %s, %s, %s", ChannelInbox, ChannelEmail, ChannelWebhook)
+	}
+
+	return nil
+}
+
+// notification created when a price alert is triggered
+type Notification struct {
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	AlertID  string    `json:"alertId"`
+	Message  string    `json:"message"`
+	Created  time.Time `json:"created"`
+	Read     bool      `json:"read"`
+}
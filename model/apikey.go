@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scopes of an api key
+const (
+	// read any resource of the user
+	ScopeRead = "read"
+	// buy and sell assets
+	ScopeTrade = "trade"
+	// transfer money and assets to other users
+	ScopeTransfer = "transfer"
+)
+
+const maxAPIKeyNameLength = 50
+
+// personal api key with which a user authenticates bots and command line tools
+type APIKey struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+
+	// first characters of the key, so the user can recognize it
+	Prefix string `json:"prefix"`
+
+	// sha256 of the key, the key itself is not stored
+	Hash string `json:"-"`
+
+	// secret with which requests are signed, the server needs it to verify their signatures.
+	// Stored encrypted and set only when a signed request is verified
+	Secret string `json:"-"`
+
+	Scopes []string `json:"scopes"`
+
+	// the key is not valid after it, nil if it never expires
+	Expires *time.Time `json:"expires,omitempty"`
+
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+}
+
+// api key with the key itself and its signing secret, returned only once when the key is created.
+// The secret is empty if signed requests are disabled
+type CreatedAPIKey struct {
+	APIKey
+	Key    string `json:"key"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// Validates the user input of an api key and removes duplicate scopes
+func (k *APIKey) ValidateData() error {
+	k.Name = strings.TrimSpace(k.Name)
+	if k.Name == "" {
+		return fmt.Errorf(notBlankErrTemplate, "name")
+	}
+	if len(k.Name) > maxAPIKeyNameLength {
+		return fmt.Errorf("name should be at most %d characters", maxAPIKeyNameLength)
+	}
+
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, scope := range k.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope != ScopeRead && scope != ScopeTrade && scope != ScopeTransfer {
+			return fmt.Errorf("scope should be one of %s, %s and %s, got %q", ScopeRead, ScopeTrade, ScopeTransfer, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	k.Scopes = scopes
+	return nil
+}
+
+// checks whether the key has scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// checks whether the key is expired at time now
+func (k APIKey) IsExpired(now time.Time) bool {
+	return k.Expires != nil && !k.Expires.After(now)
+}
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// result of converting an amount of one asset to another
+type Conversion struct {
+	// id of the asset converted from
+	From string `json:"from"`
+
+	// id of the asset converted to
+	To string `json:"to"`
+
+	// amount of the from asset
+	Amount float64 `json:"amount"`
+
+	// units of the to asset for one unit of the from asset
+	Rate float64 `json:"rate"`
+
+	// amount of the to asset
+	Result float64 `json:"result"`
+
+	// information about the prices used for the conversion
+	Prices PricesInfo `json:"prices"`
+}
+
+// cross rates of all assets against a base asset
+type Rates struct {
+	// id of the base asset
+	Base string `json:"base"`
+
+	// units of each asset for one unit of the base asset, by asset id
+	Rates map[string]float64 `json:"rates"`
+
+	// requested asset ids which are not available
+	Missing []string `json:"missing"`
+
+	// information about the prices used for the rates
+	Prices PricesInfo `json:"prices"`
+}
+
+// timestamp and age of the USD prices rates are computed from
+type PricesInfo struct {
+	// when the prices were fetched from the external api
+	Updated time.Time `json:"updated"`
+
+	// seconds since the prices were fetched
+	AgeSeconds float64 `json:"ageSeconds"`
+}
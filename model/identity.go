@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// object to represent the link of a user to their account at an OpenID Connect identity provider
+type Identity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Created  time.Time
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Kinds of subjects whose failed logins are counted
+const (
+	LockoutUsername = "username"
+	LockoutIP       = "ip"
+)
+
+// whether kind is a kind of lockout subjects
+func ValidLockoutKind(kind string) bool {
+	return kind == LockoutUsername || kind == LockoutIP
+}
+
+// object to represent the failed logins of a username or an ip address within the current window
+type LoginFailures struct {
+	Kind         string
+	Subject      string
+	Failures     int
+	FirstFailure time.Time
+	LastFailure  time.Time
+	// set while and after the subject is locked out, until the next failure
+	LockedUntil *time.Time
+}
+
+// whether the subject is locked out at now
+func (f LoginFailures) IsLocked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
+
+// object to represent a username or an ip address which can't log in
+type Lockout struct {
+	Kind     string    `json:"kind"`
+	Subject  string    `json:"subject"`
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+}
+
+// Types of security events
+const (
+	EventLoginLockout   = "login_lockout"
+	EventLockoutCleared = "lockout_cleared"
+)
+
+// object to represent an event which is kept for security review
+type SecurityEvent struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject"`
+	Message string    `json:"message"`
+	Created time.Time `json:"created"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// usd price of an asset in a stored snapshot of prices
+type PricePoint struct {
+	AssetId  string
+	PriceUSD float64
+	Recorded time.Time
+}
+
+// asset whose price changed over a period
+type Mover struct {
+	AssetId      string  `json:"assetId"`
+	Name         string  `json:"name"`
+	PriceUSD     float64 `json:"priceUSD"`
+	PastPriceUSD float64 `json:"pastPriceUSD"`
+	// change since the past price in percent
+	ChangePercent float64 `json:"changePercent"`
+}
+
+// acquisitions of an asset over a period
+type TradedAsset struct {
+	AssetId   string  `json:"assetId"`
+	Name      string  `json:"name"`
+	Trades    int     `json:"trades"`
+	VolumeUSD float64 `json:"volumeUSD"`
+}
+
+// market movers over a period, such as 24h
+type MoversPeriod struct {
+	Period             string        `json:"period"`
+	Since              time.Time     `json:"since"`
+	Gainers            []Mover       `json:"gainers"`
+	Losers             []Mover       `json:"losers"`
+	MostTradedByCount  []TradedAsset `json:"mostTradedByCount"`
+	MostTradedByVolume []TradedAsset `json:"mostTradedByVolume"`
+}
+
+// market movers computed from the prices fetched at updated
+type Movers struct {
+	Updated time.Time      `json:"updated"`
+	Periods []MoversPeriod `json:"periods"`
+}
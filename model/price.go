@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// price status of a user asset valuated without a current price
+const (
+	// the price provider couldn't be reached, the last known price is used
+	PriceStale = "stale"
+	// the asset is no longer listed, the last known price is used
+	PriceDelisted = "delisted"
+)
+
+// last known usd price of an asset, kept for valuation when the asset has no current price
+type LastPrice struct {
+	AssetId  string    `json:"assetId"`
+	Name     string    `json:"name"`
+	PriceUSD float64   `json:"priceUSD"`
+	// price at which the asset was sold, 0 if it was stored before bids were kept
+	BidUSD  float64   `json:"bidUSD"`
+	Updated time.Time `json:"updated"`
+}
+
+// Gets the last price at which the asset was sold, the mid price if the bid is unknown
+func (p LastPrice) Bid() float64 {
+	if p.BidUSD > 0 {
+		return p.BidUSD
+	}
+	return p.PriceUSD
+}
+
+// delisted asset which is still held by users
+type DelistedAsset struct {
+	AssetId  string  `json:"assetId"`
+	Name     string  `json:"name"`
+	Holders  int     `json:"holders"`
+	Quantity float64 `json:"quantity"`
+	// last known price, nil if the asset was never priced
+	LastPrice *LastPrice `json:"lastPrice,omitempty"`
+}
+
+// result of settling a delisted asset into usd
+type Settlement struct {
+	AssetId  string           `json:"assetId"`
+	PriceUSD float64          `json:"priceUSD"`
+	Holders  []SettledHolding `json:"holders"`
+	TotalUSD float64          `json:"totalUSD"`
+}
+
+// holding of a user settled into usd
+type SettledHolding struct {
+	Username string  `json:"username"`
+	Quantity float64 `json:"quantity"`
+	USD      float64 `json:"usd"`
+}
@@ -9,6 +9,7 @@ type Session struct {
 	Expiration time.Time
 }
 
-func (s Session) IsExpired() bool {
-	return s.Expiration.Before(time.Now())
+// checks whether the session is expired at time now
+func (s Session) IsExpired(now time.Time) bool {
+	return s.Expiration.Before(now)
 }
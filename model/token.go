@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Purposes of the single-use tokens of users
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	// login waiting for the second factor
+	PurposeLogin2FA = "login_2fa"
+)
+
+// object to represent a single-use token of a user, sent by email or returned by a login, stored hashed
+type Token struct {
+	Hash     string
+	Username string
+	Purpose  string
+	Expires  time.Time
+}
+
+// whether the token is expired at now
+func (t Token) IsExpired(now time.Time) bool {
+	return !now.Before(t.Expires)
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// object to represent the TOTP two-factor authentication of a user
+type TwoFactor struct {
+	Username string
+	// base32 TOTP secret
+	Secret string
+	// false until the user confirms the enrollment with a code
+	Enabled bool
+	// step of the last accepted code, so codes can't be reused
+	LastStep int64
+}
+
+// secret of a new enrollment, shown once
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	// otpauth URI to show as a QR code to authenticator apps
+	URI string `json:"uri"`
+}
+
+// single-use codes replacing the authenticator app when it is lost, shown once
+type RecoveryCodes struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
+// code of the authenticator app or a recovery code
+type TwoFactorCode struct {
+	Code string `json:"code"`
+}
+
+func (c TwoFactorCode) ValidateData() error {
+	if strings.TrimSpace(c.Code) == "" {
+		return fmt.Errorf(notBlankErrTemplate, "code")
+	}
+	return nil
+}
+
+// login of a user with two-factor authentication waiting for the second factor
+type LoginChallenge struct {
+	// token to send with the code, valid for one attempt
+	Token string `json:"mfaToken"`
+}
+
+// second step of a login with two-factor authentication
+type LoginSecondFactor struct {
+	Token string `json:"mfaToken"`
+	Code  string `json:"code"`
+}
+
+func (l LoginSecondFactor) ValidateData() error {
+	if l.Token == "" {
+		return fmt.Errorf(notBlankErrTemplate, "mfaToken")
+	}
+	if strings.TrimSpace(l.Code) == "" {
+		return fmt.Errorf(notBlankErrTemplate, "code")
+	}
+	return nil
+}
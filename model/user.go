@@ -2,13 +2,31 @@ package model
 
 import (
 	"fmt"
+	"net/mail"
 	"strings"
+
+	"github.com/MonikaPalova/currency-master/password"
 )
 
 const (
 	notBlankErrTemplate = "%s should not be blank"
 )
 
+// Roles of users
+const (
+	// trades with own account
+	RoleUser = "user"
+	// reads the profiles of all users
+	RoleSupport = "support"
+	// manages users and reads all acquisitions
+	RoleAdmin = "admin"
+)
+
+// whether role is one of the user roles
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleSupport || role == RoleAdmin
+}
+
 // object to represent a user
 type User struct {
 	// username
@@ -20,6 +38,12 @@ type User struct {
 	// email
 	Email string `json:"email"`
 
+	// whether the email was verified, users can't trade before
+	EmailVerified bool `json:"emailVerified"`
+
+	// role, set only by admins
+	Role string `json:"role"`
+
 	// current USD balance
 	USD float64 `json:"usd"`
 
@@ -34,12 +58,81 @@ func (u User) ValidateData() error {
 	if strings.TrimSpace(u.Username) == "" {
 		return fmt.Errorf(notBlankErrTemplate, "username")
 	}
-	if strings.TrimSpace(u.Password) == "" {
-		return fmt.Errorf(notBlankErrTemplate, "password")
+	if err := validatePassword(u.Password, "password"); err != nil {
+		return err
+	}
+	if err := validateEmail(u.Email); err != nil {
+		return err
 	}
-	if strings.TrimSpace(u.Email) == "" {
+
+	return nil
+}
+
+// only a bare address, names like "Monika <m@example.com>" are not emails of users
+func validateEmail(email string) error {
+	if strings.TrimSpace(email) == "" {
 		return fmt.Errorf(notBlankErrTemplate, "email")
 	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return fmt.Errorf("email %q is not a valid email address", email)
+	}
+	return nil
+}
 
+func validatePassword(pw, field string) error {
+	if strings.TrimSpace(pw) == "" {
+		return fmt.Errorf(notBlankErrTemplate, field)
+	}
+	if len(pw) > password.MaxLength {
+		return fmt.Errorf("%s should be at most %d bytes long", field, password.MaxLength)
+	}
 	return nil
 }
+
+// changes of the profile of a user
+type ProfileUpdate struct {
+	Email string `json:"email"`
+}
+
+func (p ProfileUpdate) ValidateData() error {
+	return validateEmail(p.Email)
+}
+
+// change of the password of a user, who proves they know the current one
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func (p PasswordChange) ValidateData() error {
+	if p.CurrentPassword == "" {
+		return fmt.Errorf(notBlankErrTemplate, "currentPassword")
+	}
+	return validatePassword(p.NewPassword, "newPassword")
+}
+
+// request of a password reset email for a user
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+func (p PasswordResetRequest) ValidateData() error {
+	if strings.TrimSpace(p.Username) == "" {
+		return fmt.Errorf(notBlankErrTemplate, "username")
+	}
+	return nil
+}
+
+// reset of a forgotten password with the token sent by email
+type PasswordReset struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (p PasswordReset) ValidateData() error {
+	if p.Token == "" {
+		return fmt.Errorf(notBlankErrTemplate, "token")
+	}
+	return validatePassword(p.NewPassword, "newPassword")
+}
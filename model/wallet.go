@@ -18,6 +18,12 @@ type UserAsset struct {
 
 	// the usd value of the quantity if sold now
 	Valuation float64 `json:"valuation"`
+
+	// stale or delisted if the valuation uses the last known price, empty for a current price
+	PriceStatus string `json:"priceStatus,omitempty"`
+
+	// time of the last known price used for the valuation
+	PriceUpdated *time.Time `json:"priceUpdated,omitempty"`
 }
 
 // information about a specific asset purchase - receipt
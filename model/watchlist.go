@@ -0,0 +1,73 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// limits of a watchlist
+const (
+	maxWatchlistNameLength = 50
+	maxWatchlistAssets     = 100
+)
+
+// named list of assets a user tracks without owning them
+type Watchlist struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+
+	// public lists can be read by every user, only the owner can modify them
+	Public bool `json:"public"`
+
+	// ids of the tracked assets in the order they were added
+	AssetIds []string `json:"assetIds"`
+
+	// tracked assets with their current price, set in responses only
+	Assets []WatchlistAsset `json:"assets,omitempty"`
+
+	Created time.Time `json:"created"`
+}
+
+// asset of a watchlist with its price
+type WatchlistAsset struct {
+	AssetId  string  `json:"assetId"`
+	Name     string  `json:"name"`
+	PriceUSD float64 `json:"priceUSD"`
+
+	// percent change of the price over the last 24 hours, nil until there is price history for the period
+	Change24h *float64 `json:"change24h,omitempty"`
+
+	// stale or delisted if the last known price is used, empty for a current price
+	PriceStatus string `json:"priceStatus,omitempty"`
+}
+
+// Validates the user input of a watchlist and normalizes its asset ids to upper case without duplicates
+func (w *Watchlist) ValidateData() error {
+	w.Name = strings.TrimSpace(w.Name)
+	if w.Name == "" {
+		return fmt.Errorf(notBlankErrTemplate, "name")
+	}
+	if len(w.Name) > maxWatchlistNameLength {
+		return fmt.Errorf("name should be at most %d characters", maxWatchlistNameLength)
+	}
+
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, id := range w.AssetIds {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if id == "" {
+			return fmt.Errorf("assetIds should not contain blank ids")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxWatchlistAssets {
+		return fmt.Errorf("a watchlist can contain at most %d assets", maxWatchlistAssets)
+	}
+	w.AssetIds = ids
+	return nil
+}
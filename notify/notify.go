@@ -0,0 +1,97 @@
+// Package notify delivers notifications of triggered price alerts
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/mail"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// Notifier delivers a notification about a triggered alert.
+type Notifier interface {
+	Notify(alert model.PriceAlert, notification model.Notification) error
+}
+
+// Dispatcher delivers notifications through the notifier of the alert channel.
+type Dispatcher map[string]Notifier
+
+// Creates dispatcher with inbox, email and webhook notifiers. Webhooks are posted only to addresses the guard allows
+func NewDispatcher(inbox inboxDB, mailer mail.Mailer, config *config.Notify, guard *WebhookGuard) Dispatcher {
+	return Dispatcher{
+		model.ChannelInbox:   InboxNotifier{DB: inbox},
+		model.ChannelEmail:   EmailNotifier{Mailer: mailer},
+		model.ChannelWebhook: WebhookNotifier{Client: guard.Client(config.WebhookTimeout)},
+	}
+}
+
+// Delivers the notification through the channel of the alert.
+// Returns error if there is no notifier for the channel or the delivery fails
+func (d Dispatcher) Notify(alert model.PriceAlert, notification model.Notification) error {
+	notifier, ok := d[alert.Channel]
+	if !ok {
+		return fmt.Errorf("there is no notifier for channel %s", alert.Channel)
+	}
+	return notifier.Notify(alert, notification)
+}
+
+type inboxDB interface {
+	// saves a new notification
+	Create(notification model.Notification) (*model.Notification, error)
+}
+
+// Saves notifications in the in-app inbox of the user.
+type InboxNotifier struct {
+	DB inboxDB
+}
+
+func (i InboxNotifier) Notify(alert model.PriceAlert, notification model.Notification) error {
+	_, err := i.DB.Create(notification)
+	return err
+}
+
+// Sends notifications by email to the alert target.
+type EmailNotifier struct {
+	Mailer mail.Mailer
+}
+
+func (e EmailNotifier) Notify(alert model.PriceAlert, notification model.Notification) error {
+	msg := mail.Message{To: alert.Target, Subject: fmt.Sprintf("Price alert for %s", alert.AssetId), Body: notification.Message}
+	if err := e.Mailer.Send(msg); err != nil {
+		return fmt.Errorf("could not send email notification, %v", err)
+	}
+	return nil
+}
+
+// body posted to webhooks
+type webhookPayload struct {
+	Alert        model.PriceAlert   `json:"alert"`
+	Notification model.Notification `json:"notification"`
+}
+
+// Posts notifications as JSON to the alert target url.
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+func (wh WebhookNotifier) Notify(alert model.PriceAlert, notification model.Notification) error {
+	body, err := json.Marshal(webhookPayload{Alert: alert, Notification: notification})
+	if err != nil {
+		return fmt.Errorf("could not convert webhook notification to JSON, %v", err)
+	}
+
+	response, err := wh.Client.Post(alert.Target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not post webhook notification to %s, %v", alert.Target, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned code %d", alert.Target, response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/mail"
+	"github.com/MonikaPalova/currency-master/mail/mailtest"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubInboxDB struct {
+	created []model.Notification
+}
+
+func (s *stubInboxDB) Create(notification model.Notification) (*model.Notification, error) {
+	s.created = append(s.created, notification)
+	return &notification, nil
+}
+
+func TestDispatcher_Notify(t *testing.T) {
+	inbox := &stubInboxDB{}
+	d := NewDispatcher(inbox, mail.LogMailer{}, config.NewNotify(), &WebhookGuard{})
+
+	notification := model.Notification{ID: "n1", Username: "user", Message: "BTC rose above 100 USD"}
+	if err := d.Notify(model.PriceAlert{Channel: model.ChannelInbox}, notification); err != nil {
+		t.Fatalf("Dispatcher.Notify() error = %v", err)
+	}
+	if len(inbox.created) != 1 || inbox.created[0].ID != "n1" {
+		t.Errorf("Dispatcher.Notify() saved %+v in inbox", inbox.created)
+	}
+
+	if err := d.Notify(model.PriceAlert{Channel: "pigeon"}, notification); err == nil {
+		t.Errorf("Dispatcher.Notify() expected error for unknown channel")
+	}
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"server error", http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got webhookPayload
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("could not decode webhook body, %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			wh := WebhookNotifier{Client: server.Client()}
+			alert := model.PriceAlert{ID: "a1", Channel: model.ChannelWebhook, Target: server.URL}
+			err := wh.Notify(alert, model.Notification{ID: "n1", AlertID: "a1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WebhookNotifier.Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Alert.ID != "a1" || got.Notification.ID != "n1" {
+				t.Errorf("WebhookNotifier.Notify() posted %+v", got)
+			}
+		})
+	}
+}
+
+func TestEmailNotifier_Notify(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+	mailer, _ := mail.New(server.MailConfig())
+	e := EmailNotifier{Mailer: mailer}
+
+	alert := model.PriceAlert{AssetId: "BTC", Channel: model.ChannelEmail, Target: "user@mail.com"}
+	if err := e.Notify(alert, model.Notification{Message: "BTC rose above 100 USD"}); err != nil {
+		t.Fatalf("EmailNotifier.Notify() error = %v", err)
+	}
+
+	msg := <-server.Messages
+	if !strings.Contains(msg, "To: user@mail.com") || !strings.Contains(msg, "Subject: Price alert for BTC") || !strings.Contains(msg, "BTC rose above 100 USD") {
+		t.Errorf("EmailNotifier.Notify() sent %q", msg)
+	}
+}
+
+func TestEmailNotifier_Notify_InvalidTarget(t *testing.T) {
+	e := EmailNotifier{Mailer: mail.SMTPMailer{}}
+	alert := model.PriceAlert{AssetId: "BTC", Channel: model.ChannelEmail, Target: "user@mail.com\r\nBcc: other@mail.com"}
+	if err := e.Notify(alert, model.Notification{}); err == nil {
+		t.Errorf("EmailNotifier.Notify() expected error for target with line breaks")
+	}
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// shared address space of carrier-grade NAT, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookGuard keeps webhooks from reaching the server itself and its private network.
+// Addresses in Allowed, such as an internal webhook receiver, are reachable anyway
+type WebhookGuard struct {
+	Allowed []*net.IPNet
+	// resolves host names, net.LookupIP if nil
+	LookupIP func(host string) ([]net.IP, error)
+}
+
+// Creates guard which allows the private networks in CIDR notation.
+// Returns error if a network is not valid
+func NewWebhookGuard(allowed []string) (*WebhookGuard, error) {
+	guard := &WebhookGuard{}
+	for _, cidr := range allowed {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook network %s, %v", cidr, err)
+		}
+		guard.Allowed = append(guard.Allowed, network)
+	}
+	return guard, nil
+}
+
+// Error returned for webhook urls which notifications can't be posted to
+type WebhookTargetError struct {
+	Target string
+	Reason string
+}
+
+func (e WebhookTargetError) Error() string {
+	return fmt.Sprintf("webhook %s %s", e.Target, e.Reason)
+}
+
+// Checks that target is an http or https url of a host resolving only to public or allowed addresses.
+// Returns WebhookTargetError otherwise
+func (g WebhookGuard) CheckURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return WebhookTargetError{Target: target, Reason: "should be an http or https url"}
+	}
+
+	ips, err := g.lookup(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return WebhookTargetError{Target: target, Reason: "has a host which can't be resolved"}
+	}
+	for _, ip := range ips {
+		if !g.allows(ip) {
+			return WebhookTargetError{Target: target, Reason: fmt.Sprintf("resolves to address %s, which is not public", ip)}
+		}
+	}
+	return nil
+}
+
+// Checks the address of every webhook connection, so a host can't resolve to a private address after CheckURL.
+// Has the signature of net.Dialer.Control
+func (g WebhookGuard) Control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !g.allows(ip) {
+		return fmt.Errorf("webhook connection to address %s is not allowed", host)
+	}
+	return nil
+}
+
+// Creates http client which connects only to public or allowed addresses, redirects included
+func (g WebhookGuard) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: g.Control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// a proxy would be connected to instead of the webhook
+	transport.Proxy = nil
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func (g WebhookGuard) lookup(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if g.LookupIP != nil {
+		return g.LookupIP(host)
+	}
+	return net.LookupIP(host)
+}
+
+func (g WebhookGuard) allows(ip net.IP) bool {
+	for _, network := range g.Allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return isPublic(ip)
+}
+
+// whether ip is reachable from the internet, rather than the server itself or its networks
+func isPublic(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		return false
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
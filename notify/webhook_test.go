@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+func TestWebhookGuard_CheckURL(t *testing.T) {
+	hosts := map[string][]net.IP{
+		"hooks.example.com":    {net.ParseIP("93.184.216.34")},
+		"internal.example.com": {net.ParseIP("93.184.216.34"), net.ParseIP("10.0.0.5")},
+		"receiver.corp":        {net.ParseIP("10.1.2.3")},
+	}
+	lookup := func(host string) ([]net.IP, error) {
+		if ips, ok := hosts[host]; ok {
+			return ips, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+	guard, err := NewWebhookGuard([]string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("NewWebhookGuard() error = %v", err)
+	}
+	guard.LookupIP = lookup
+	tests := []struct {
+		target  string
+		wantErr bool
+	}{
+		{"https://hooks.example.com/alerts", false},
+		{"http://93.184.216.34:8080/alerts", false},
+		{"http://receiver.corp/alerts", false},
+		{"http://127.0.0.1:7777/api/v1/admin/delisted", true},
+		{"http://localhost/alerts", true},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://[::1]/alerts", true},
+		{"http://[::ffff:127.0.0.1]/alerts", true},
+		{"http://0.0.0.0/alerts", true},
+		{"http://192.168.1.1/alerts", true},
+		{"http://100.64.0.1/alerts", true},
+		{"http://internal.example.com/alerts", true},
+		{"http://unknown.example.com/alerts", true},
+		{"ftp://hooks.example.com/alerts", true},
+		{"https:///alerts", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			err := guard.CheckURL(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WebhookGuard.CheckURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(WebhookTargetError); err != nil && !ok {
+				t.Errorf("WebhookGuard.CheckURL() error = %v, want WebhookTargetError", err)
+			}
+		})
+	}
+}
+
+func TestNewWebhookGuard_InvalidNetwork(t *testing.T) {
+	if _, err := NewWebhookGuard([]string{"10.0.0.0"}); err == nil {
+		t.Errorf("NewWebhookGuard() expected error for a network without prefix length")
+	}
+}
+
+func TestWebhookGuard_Client(t *testing.T) {
+	posted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = true
+	}))
+	defer server.Close()
+	alert := model.PriceAlert{ID: "a1", Channel: model.ChannelWebhook, Target: server.URL}
+
+	wh := WebhookNotifier{Client: (&WebhookGuard{}).Client(time.Second)}
+	if err := wh.Notify(alert, model.Notification{ID: "n1"}); err == nil || posted {
+		t.Errorf("WebhookNotifier.Notify() to a loopback address error = %v, posted %v", err, posted)
+	}
+
+	guard, _ := NewWebhookGuard([]string{"127.0.0.0/8"})
+	wh = WebhookNotifier{Client: guard.Client(time.Second)}
+	if err := wh.Notify(alert, model.Notification{ID: "n1"}); err != nil || !posted {
+		t.Errorf("WebhookNotifier.Notify() to an allowed address error = %v, posted %v", err, posted)
+	}
+}
@@ -0,0 +1,349 @@
+// Package oidc logs users in with an OpenID Connect identity provider using
+// the authorization code flow with PKCE and verifies the RS256 ID tokens it issues
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// accepted difference between the clocks of the provider and the application
+	leeway = time.Minute
+	// random bytes of states, nonces and PKCE verifiers
+	randomBytes = 32
+)
+
+// Identity of a user at the provider, taken from a verified ID token
+type Claims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+// all claims of an ID token which are checked
+type idToken struct {
+	Claims
+	Audience audience `json:"aud"`
+	AZP      string   `json:"azp"`
+	Expires  int64    `json:"exp"`
+	IssuedAt int64    `json:"iat"`
+	Nonce    string   `json:"nonce"`
+}
+
+// the aud claim is a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// endpoints of the provider from its discovery document
+type provider struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Client of an identity provider. The provider is discovered on first use,
+// so the application starts while the provider is down
+type Client struct {
+	Config *config.OIDC
+	HTTP   *http.Client
+	Clock  clock.Clock
+
+	mu       sync.Mutex
+	provider *provider
+	keys     map[string]*rsa.PublicKey
+}
+
+func NewClient(cfg *config.OIDC, clk clock.Clock) *Client {
+	return &Client{Config: cfg, HTTP: &http.Client{Timeout: 10 * time.Second}, Clock: clk}
+}
+
+// Generates a random state, nonce or PKCE verifier
+func NewRandom() (string, error) {
+	random := make([]byte, randomBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// S256 PKCE challenge of verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Url of the provider's login page, which redirects back to the redirect url with a code and state
+func (c *Client) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	p, err := c.discover()
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", c.Config.ClientID)
+	query.Set("redirect_uri", c.Config.RedirectURL)
+	query.Set("scope", strings.Join(c.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", Challenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		separator = "&"
+	}
+	return p.AuthURL + separator + query.Encode(), nil
+}
+
+// Exchanges the code of a login started with verifier and nonce for the identity of the user
+func (c *Client) Exchange(code, verifier, nonce string) (*Claims, error) {
+	p, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.Config.RedirectURL)
+	form.Set("client_id", c.Config.ClientID)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequest(http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("could not create token request, %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.Config.ClientID), url.QueryEscape(c.Config.ClientSecret))
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed, %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint responded with status %d", resp.StatusCode)
+	}
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("could not decode token response, %v", err)
+	}
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id token")
+	}
+	return c.Verify(body.IDToken, nonce)
+}
+
+// Verifies the signature, issuer, audience, expiry and nonce of an ID token and gets its claims
+func (c *Client) Verify(rawIDToken, nonce string) (*Claims, error) {
+	p, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("id token is not a JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("could not decode id token header, %v", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("id token is signed with unsupported algorithm %q", header.Alg)
+	}
+	key, err := c.key(p, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("could not decode id token signature, %v", err)
+	}
+	signed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, signed[:], signature); err != nil {
+		return nil, fmt.Errorf("id token signature is not valid, %v", err)
+	}
+
+	var token idToken
+	if err := decodeSegment(parts[1], &token); err != nil {
+		return nil, fmt.Errorf("could not decode id token claims, %v", err)
+	}
+	if err := c.check(p, token, nonce); err != nil {
+		return nil, err
+	}
+	return &token.Claims, nil
+}
+
+func (c *Client) check(p *provider, token idToken, nonce string) error {
+	if token.Issuer != p.Issuer {
+		return fmt.Errorf("id token is issued by %s, not %s", token.Issuer, p.Issuer)
+	}
+	if !token.Audience.contains(c.Config.ClientID) {
+		return fmt.Errorf("id token is not issued for this client")
+	}
+	if len(token.Audience) > 1 && token.AZP != c.Config.ClientID {
+		return fmt.Errorf("id token is authorized for another party")
+	}
+	now := c.Clock.Now()
+	if !now.Before(time.Unix(token.Expires, 0).Add(leeway)) {
+		return fmt.Errorf("id token is expired")
+	}
+	if time.Unix(token.IssuedAt, 0).After(now.Add(leeway)) {
+		return fmt.Errorf("id token is issued in the future")
+	}
+	if subtle.ConstantTimeCompare([]byte(token.Nonce), []byte(nonce)) != 1 {
+		return fmt.Errorf("id token nonce doesn't match the login")
+	}
+	if token.Subject == "" {
+		return fmt.Errorf("id token has no subject")
+	}
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// gets the provider from its discovery document, retried on the next use if it fails
+func (c *Client) discover() (*provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider != nil {
+		return c.provider, nil
+	}
+
+	var p provider
+	if err := c.getJSON(strings.TrimSuffix(c.Config.Issuer, "/")+discoveryPath, &p); err != nil {
+		return nil, fmt.Errorf("could not discover identity provider, %v", err)
+	}
+	if p.Issuer != c.Config.Issuer {
+		return nil, fmt.Errorf("identity provider issuer %s doesn't match the configured %s", p.Issuer, c.Config.Issuer)
+	}
+	if p.AuthURL == "" || p.TokenURL == "" || p.JWKSURL == "" {
+		return nil, fmt.Errorf("identity provider discovery document is missing endpoints")
+	}
+	c.provider = &p
+	return c.provider, nil
+}
+
+// gets the signing key with id kid, the keys are fetched again for an unknown id so the provider can rotate them
+func (c *Client) key(p *provider, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.getJSON(p.JWKSURL, &set); err != nil {
+		return nil, fmt.Errorf("could not get signing keys of identity provider, %v", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = key
+	}
+	c.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("id token is signed with unknown key %q", kid)
+	}
+	return key, nil
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode modulus of key %q, %v", k.Kid, err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("could not decode exponent of key %q", k.Kid)
+	}
+	exponent := 0
+	for _, b := range e {
+		exponent = exponent<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+}
+
+func (c *Client) getJSON(url string, v interface{}) error {
+	resp, err := c.HTTP.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
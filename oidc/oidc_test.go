@@ -0,0 +1,158 @@
+package oidc
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/oidc/oidctest"
+)
+
+const testRedirectURL = "http://cm.local/login/oidc/callback"
+
+// logs in at the provider and gets the code and state it redirects back with
+func authorize(t *testing.T, c *Client, state, nonce, verifier string) (string, string) {
+	authURL, err := c.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("Client.AuthCodeURL() error = %v", err)
+	}
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirects.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request failed, %v", err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if resp.StatusCode != http.StatusFound || err != nil || !strings.HasPrefix(location.String(), testRedirectURL) {
+		t.Fatalf("authorization responded %d redirecting to %s", resp.StatusCode, location)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestClient_Flow(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	c := NewClient(server.Config(testRedirectURL), clock.Real{})
+
+	code, state := authorize(t, c, "s1", "n1", "v1")
+	if state != "s1" {
+		t.Errorf("provider redirected with state %s, want s1", state)
+	}
+	claims, err := c.Exchange(code, "v1", "n1")
+	if err != nil {
+		t.Fatalf("Client.Exchange() error = %v", err)
+	}
+	want := Claims{Issuer: server.URL, Subject: "sub-1", Email: "monika@example.com", EmailVerified: true, PreferredUsername: "monika"}
+	if *claims != want {
+		t.Errorf("Client.Exchange() = %+v, want %+v", *claims, want)
+	}
+
+	if _, err := c.Exchange(code, "v1", "n1"); err == nil {
+		t.Errorf("Client.Exchange() accepted a used code")
+	}
+}
+
+func TestClient_Exchange_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		nonce    string
+	}{
+		{"wrong verifier", "other", "n1"},
+		{"wrong nonce", "v1", "other"},
+	}
+	server := oidctest.NewServer()
+	defer server.Close()
+	c := NewClient(server.Config(testRedirectURL), clock.Real{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := authorize(t, c, "s1", "n1", "v1")
+			if _, err := c.Exchange(code, tt.verifier, tt.nonce); err == nil {
+				t.Errorf("Client.Exchange() expected error")
+			}
+		})
+	}
+}
+
+func TestClient_Verify(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	user := server.User
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		now     time.Time
+		wantErr bool
+	}{
+		{"ok", nil, time.Now(), false},
+		{"expired", map[string]interface{}{"exp": time.Now().Add(-2 * time.Minute).Unix()}, time.Now(), true},
+		{"expired within leeway", map[string]interface{}{"exp": time.Now().Add(-30 * time.Second).Unix()}, time.Now(), false},
+		{"issued in the future", nil, time.Now().Add(-time.Hour), true},
+		{"other issuer", map[string]interface{}{"iss": "http://evil.local"}, time.Now(), true},
+		{"other audience", map[string]interface{}{"aud": "other"}, time.Now(), true},
+		{"audiences with client as authorized party", map[string]interface{}{"aud": []string{"other", oidctest.ClientID}, "azp": oidctest.ClientID}, time.Now(), false},
+		{"audiences without authorized party", map[string]interface{}{"aud": []string{"other", oidctest.ClientID}}, time.Now(), true},
+		{"no subject", map[string]interface{}{"sub": ""}, time.Now(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.Claims = tt.claims
+			c := NewClient(server.Config(testRedirectURL), clock.NewFake(tt.now))
+			_, err := c.Verify(server.IDToken(user, "n1"), "n1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client.Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_Verify_Tampered(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	c := NewClient(server.Config(testRedirectURL), clock.Real{})
+	parts := strings.Split(server.IDToken(server.User, "n1"), ".")
+
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"` + server.URL + `","aud":"` + oidctest.ClientID + `","sub":"admin","nonce":"n1","exp":9999999999}`))
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"changed claims", parts[0] + "." + forged + "." + parts[2]},
+		{"alg none", unsigned + "." + parts[1] + "."},
+		{"not a jwt", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Verify(tt.token, "n1"); err == nil {
+				t.Errorf("Client.Verify() accepted a tampered token")
+			}
+		})
+	}
+}
+
+func TestClient_Discovery(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	cfg := server.Config(testRedirectURL)
+	cfg.Issuer += "/"
+	if _, err := NewClient(cfg, clock.Real{}).AuthCodeURL("s", "n", "v"); err == nil {
+		t.Errorf("Client.AuthCodeURL() accepted a provider with another issuer")
+	}
+
+	server.Close()
+	if _, err := NewClient(server.Config(testRedirectURL), clock.Real{}).AuthCodeURL("s", "n", "v"); err == nil {
+		t.Errorf("Client.AuthCodeURL() expected error when the provider is down")
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// example of RFC 7636 appendix B
+	if got := Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("Challenge() = %s", got)
+	}
+}
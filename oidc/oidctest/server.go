@@ -0,0 +1,186 @@
+// Package oidctest provides a local OpenID Connect identity provider which logs in
+// a configured user without asking, for tests and development of the login flow.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/config"
+)
+
+const (
+	ClientID     = "currency-master"
+	ClientSecret = "secret"
+	keyID        = "test-key"
+)
+
+// User logged in by the provider
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+// Identity provider with an authorization endpoint which immediately redirects back with a code,
+// a token endpoint checking the PKCE verifier and the signing key of its ID tokens
+type Server struct {
+	// issuer of the ID tokens, the base url of the server
+	URL string
+	// user logged in by the next authorization
+	User User
+	// claims added to or replacing the claims of the next ID tokens, to issue invalid ones
+	Claims map[string]interface{}
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	codes  map[string]authorization
+}
+
+// login waiting for its code to be exchanged
+type authorization struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+}
+
+// Starts a provider on a local port logging in the user "sub-1". It should be closed after use.
+// Panics if it can't generate its key, like httptest.NewServer when it can't listen
+func NewServer() *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: failed to generate key: %v", err))
+	}
+	s := &Server{key: key, codes: map[string]authorization{},
+		User: User{Subject: "sub-1", Email: "monika@example.com", EmailVerified: true, PreferredUsername: "monika"}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/keys", s.keys)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s
+}
+
+// Stops the provider
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Gets the configuration of a client of the provider redirecting to redirectURL
+func (s *Server) Config(redirectURL string) *config.OIDC {
+	return &config.OIDC{Issuer: s.URL, ClientID: ClientID, ClientSecret: ClientSecret, RedirectURL: redirectURL, Scopes: []string{"openid", "email", "profile"}}
+}
+
+// Signs an ID token of the user with nonce, with the extra Claims of the server
+func (s *Server) IDToken(user User, nonce string) string {
+	now := time.Now()
+	claims := map[string]interface{}{"iss": s.URL, "aud": ClientID, "sub": user.Subject, "email": user.Email, "email_verified": user.EmailVerified,
+		"preferred_username": user.PreferredUsername, "nonce": nonce, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()}
+	s.mu.Lock()
+	for name, value := range s.Claims {
+		claims[name] = value
+	}
+	s.mu.Unlock()
+
+	header := encodeSegment(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	signed := header + "." + encodeSegment(claims)
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(fmt.Sprintf("oidctest: failed to sign id token: %v", err))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"issuer": s.URL, "authorization_endpoint": s.URL + "/authorize", "token_endpoint": s.URL + "/token", "jwks_uri": s.URL + "/keys"})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != ClientID || query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authorization{redirectURI: query.Get("redirect_uri"), challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), user: s.User}
+	s.mu.Unlock()
+
+	back := redirect.Query()
+	back.Set("code", code)
+	back.Set("state", query.Get("state"))
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if r.Method != http.MethodPost || !ok || id != ClientID || secret != ClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	auth, ok := s.codes[r.PostForm.Get("code")]
+	// codes can be exchanged once
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if !ok || auth.redirectURI != r.PostForm.Get("redirect_uri") || subtle.ConstantTimeCompare([]byte(challenge), []byte(auth.challenge)) != 1 {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"access_token": randomString(), "token_type": "Bearer", "expires_in": 3600, "id_token": s.IDToken(auth.user, auth.nonce)})
+}
+
+func (s *Server) keys(w http.ResponseWriter, r *http.Request) {
+	e := big.NewInt(int64(s.key.PublicKey.E)).Bytes()
+	writeJSON(w, map[string]interface{}{"keys": []map[string]string{{"kty": "RSA", "alg": "RS256", "use": "sig", "kid": keyID,
+		"n": base64.RawURLEncoding.EncodeToString(s.key.PublicKey.N.Bytes()), "e": base64.RawURLEncoding.EncodeToString(e)}}})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func encodeSegment(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func randomString() string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return base64.RawURLEncoding.EncodeToString(random)
+}
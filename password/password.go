@@ -0,0 +1,59 @@
+// Package password hashes and verifies user passwords
+package password
+
+import (
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxLength is the longest password in bytes, bcrypt ignores the bytes after it
+const MaxLength = 72
+
+// Hasher hashes passwords with bcrypt, which salts every password
+type Hasher struct {
+	// bcrypt cost, hashes of a lower cost are rehashed on successful verification
+	Cost int
+}
+
+var (
+	// hash compared when there is no stored password, so unknown users take as long as wrong passwords
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// Hashes password with a random salt
+func (h Hasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verifies password against the stored one in constant time.
+// The stored password is either a hash or the plaintext of a user created before passwords were hashed,
+// rehash is set when it matches but should be stored hashed again. A blank stored password never matches
+func (h Hasher) Verify(stored, password string) (ok, rehash bool) {
+	if stored == "" {
+		dummyHashOnce.Do(func() { dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), h.Cost) })
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false, false
+	}
+	if !isHash(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1, true
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost < h.Cost
+}
+
+// bcrypt hashes are 60 characters long, longer than any plaintext password stored before hashing
+func isHash(stored string) bool {
+	return len(stored) == 60 && (strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$"))
+}
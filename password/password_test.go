@@ -0,0 +1,48 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasher_HashAndVerify(t *testing.T) {
+	h := Hasher{Cost: bcrypt.MinCost}
+	hash, err := h.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hasher.Hash() error = %v", err)
+	}
+	if hash == "secret" || !isHash(hash) {
+		t.Fatalf("Hasher.Hash() = %q, want a bcrypt hash", hash)
+	}
+	if other, _ := h.Hash("secret"); other == hash {
+		t.Errorf("Hasher.Hash() returned the same hash twice, want a salted hash")
+	}
+
+	weak, _ := Hasher{Cost: bcrypt.MinCost}.Hash("secret")
+	tests := []struct {
+		name       string
+		hasher     Hasher
+		stored     string
+		password   string
+		wantOk     bool
+		wantRehash bool
+	}{
+		{"hash matches", h, hash, "secret", true, false},
+		{"hash doesn't match", h, hash, "Secret", false, false},
+		{"plaintext matches", h, "secret", "secret", true, true},
+		{"plaintext doesn't match", h, "secret", "secret2", false, true},
+		{"weaker hash matches", Hasher{Cost: bcrypt.MinCost + 1}, weak, "secret", true, true},
+		{"no stored password", h, "", "", false, false},
+		{"plaintext which looks like a hash prefix", h, "$2a$" + strings.Repeat("x", 10), "$2a$" + strings.Repeat("x", 10), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash := tt.hasher.Verify(tt.stored, tt.password)
+			if ok != tt.wantOk || (ok && rehash != tt.wantRehash) {
+				t.Errorf("Hasher.Verify() = %v, %v, want %v, %v", ok, rehash, tt.wantOk, tt.wantRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package replay
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+)
+
+// Replayer is a price provider which plays a tape on an accelerated clock.
+// The market time moves speed times faster than the wall time and stops at the end of the tape.
+// Safe for concurrent use
+type Replayer struct {
+	mu    sync.Mutex
+	tape  *Tape
+	clock clock.Clock
+
+	// market time at the wall time of the last change, the market time moves from there
+	anchorMarket time.Time
+	anchorWall   time.Time
+	speed        float64
+	paused       bool
+}
+
+// State of the replay
+type Status struct {
+	MarketTime time.Time `json:"marketTime"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Speed      float64   `json:"speed"`
+	Paused     bool      `json:"paused"`
+	Finished   bool      `json:"finished"`
+}
+
+// Replayer constructor which starts playing the tape from its start with the given speed, the market time moves with clk.
+// Returns error if speed is not positive
+func New(tape *Tape, speed float64, clk clock.Clock) (*Replayer, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("replay speed should be positive")
+	}
+	return &Replayer{tape: tape, clock: clk, speed: speed, anchorMarket: tape.Start(), anchorWall: clk.Now()}, nil
+}
+
+// Gets the prices of the assets at the current market time
+func (r *Replayer) GetAssets() ([]coinapi.Asset, error) {
+	r.mu.Lock()
+	at := r.marketTime()
+	r.mu.Unlock()
+
+	ticks := r.tape.At(at)
+	assets := make([]coinapi.Asset, 0, len(ticks))
+	for _, tick := range ticks {
+		name := tick.Name
+		if name == "" {
+			name = tick.AssetId
+		}
+		assets = append(assets, coinapi.Asset{
+			ID:           tick.AssetId,
+			Name:         name,
+			IsCrypto:     tick.IsCrypto,
+			PriceUSD:     tick.PriceUSD,
+			Volume1hUSD:  tick.Volume24hUSD / 24,
+			Volume24hUSD: tick.Volume24hUSD,
+			Volume30dUSD: tick.Volume24hUSD * 30,
+		})
+	}
+	return assets, nil
+}
+
+// Gets the state of the replay
+func (r *Replayer) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at := r.marketTime()
+	return Status{MarketTime: at, Start: r.tape.Start(), End: r.tape.End(), Speed: r.speed, Paused: r.paused, Finished: !at.Before(r.tape.End())}
+}
+
+// Stops the market time
+func (r *Replayer) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reanchor()
+	r.paused = true
+}
+
+// Continues the market time from where it was paused
+func (r *Replayer) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reanchor()
+	r.paused = false
+}
+
+// Moves the market time to at.
+// Returns error if at is outside of the tape
+func (r *Replayer) Seek(at time.Time) error {
+	if at.Before(r.tape.Start()) || at.After(r.tape.End()) {
+		return fmt.Errorf("time %v is outside of the replay from %v to %v", at, r.tape.Start(), r.tape.End())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.anchorMarket = at
+	r.anchorWall = r.clock.Now()
+	return nil
+}
+
+// Changes how many times the market time is faster than the wall time.
+// Returns error if speed is not positive
+func (r *Replayer) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("replay speed should be positive")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reanchor()
+	r.speed = speed
+	return nil
+}
+
+// market time at the current wall time, should be called with lock
+func (r *Replayer) marketTime() time.Time {
+	if r.paused {
+		return r.anchorMarket
+	}
+	elapsed := time.Duration(float64(r.clock.Now().Sub(r.anchorWall)) * r.speed)
+	at := r.anchorMarket.Add(elapsed)
+	if at.After(r.tape.End()) {
+		return r.tape.End()
+	}
+	return at
+}
+
+// moves the anchor to now, so changes apply from now on, should be called with lock
+func (r *Replayer) reanchor() {
+	r.anchorMarket = r.marketTime()
+	r.anchorWall = r.clock.Now()
+}
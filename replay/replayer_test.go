@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+)
+
+func newTestReplayer(t *testing.T, speed float64) (*Replayer, *clock.Fake) {
+	tape, err := LoadFile("testdata/crash2021.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wall := clock.NewFake(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	r, err := New(tape, speed, wall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, wall
+}
+
+func priceOf(t *testing.T, r *Replayer, id string) float64 {
+	assets, err := r.GetAssets()
+	if err != nil {
+		t.Fatalf("GetAssets() error = %v", err)
+	}
+	for _, asset := range assets {
+		if asset.ID == id {
+			return asset.PriceUSD
+		}
+	}
+	return 0
+}
+
+func TestReplayer_Accelerated(t *testing.T) {
+	// a day of market passes in an hour
+	r, wall := newTestReplayer(t, 24)
+
+	if got := priceOf(t, r, "BTC"); got != 56704.57 {
+		t.Fatalf("price at start = %v", got)
+	}
+	wall.Advance(time.Hour)
+	if got := priceOf(t, r, "BTC"); got != 49150.54 {
+		t.Errorf("price after a market day = %v, want 49150.54", got)
+	}
+
+	wall.Advance(24 * time.Hour)
+	status := r.Status()
+	if !status.Finished || !status.MarketTime.Equal(day(23, 0)) {
+		t.Errorf("Status() after the end = %+v", status)
+	}
+	if got := priceOf(t, r, "BTC"); got != 34770.58 {
+		t.Errorf("price after the end = %v, want last price 34770.58", got)
+	}
+}
+
+func TestReplayer_PauseResume(t *testing.T) {
+	r, wall := newTestReplayer(t, 24)
+
+	wall.Advance(30 * time.Minute)
+	r.Pause()
+	wall.Advance(10 * time.Hour)
+	if got := r.Status(); !got.Paused || !got.MarketTime.Equal(day(12, 12)) {
+		t.Fatalf("Status() when paused = %+v", got)
+	}
+
+	r.Resume()
+	wall.Advance(30 * time.Minute)
+	if got := r.Status().MarketTime; !got.Equal(day(13, 0)) {
+		t.Errorf("market time after resume = %v, want %v", got, day(13, 0))
+	}
+}
+
+func TestReplayer_Seek(t *testing.T) {
+	r, wall := newTestReplayer(t, 1)
+
+	if err := r.Seek(day(19, 12)); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+	if got := priceOf(t, r, "ETH"); got != 2650 {
+		t.Errorf("price after seek = %v, want 2650", got)
+	}
+	wall.Advance(time.Hour)
+	if got := r.Status().MarketTime; !got.Equal(day(19, 13)) {
+		t.Errorf("market time after seek = %v, want %v", got, day(19, 13))
+	}
+
+	if err := r.Seek(day(1, 0)); err == nil {
+		t.Errorf("Seek() expected error before the start")
+	}
+	if err := r.Seek(day(30, 0)); err == nil {
+		t.Errorf("Seek() expected error after the end")
+	}
+}
+
+func TestReplayer_SetSpeed(t *testing.T) {
+	r, wall := newTestReplayer(t, 1)
+
+	wall.Advance(time.Hour)
+	if err := r.SetSpeed(48); err != nil {
+		t.Fatalf("SetSpeed() error = %v", err)
+	}
+	wall.Advance(time.Hour)
+	if got := r.Status().MarketTime; !got.Equal(day(14, 1)) {
+		t.Errorf("market time after speed change = %v, want %v", got, day(14, 1))
+	}
+
+	if err := r.SetSpeed(0); err == nil {
+		t.Errorf("SetSpeed() expected error for zero speed")
+	}
+}
@@ -0,0 +1,210 @@
+// Package replay replays historical prices from files on an accelerated clock, so users can paper-trade through past markets
+package replay
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// price of an asset at a moment in the past
+type Tick struct {
+	Time         time.Time `json:"timestamp"`
+	AssetId      string    `json:"assetId"`
+	PriceUSD     float64   `json:"priceUSD"`
+	Name         string    `json:"name"`
+	IsCrypto     bool      `json:"isCrypto"`
+	Volume24hUSD float64   `json:"volume24hUSD"`
+}
+
+// Tape keeps the ticks of each asset sorted by time
+type Tape struct {
+	ids   []string
+	ticks map[string][]Tick
+	start time.Time
+	end   time.Time
+}
+
+// Creates tape from ticks in any order.
+// Returns error if there are no ticks or a tick is invalid
+func NewTape(ticks []Tick) (*Tape, error) {
+	if len(ticks) == 0 {
+		return nil, fmt.Errorf("there are no prices to replay")
+	}
+
+	t := &Tape{ticks: map[string][]Tick{}, start: ticks[0].Time, end: ticks[0].Time}
+	for _, tick := range ticks {
+		if tick.AssetId == "" {
+			return nil, fmt.Errorf("price at %v doesn't have asset id", tick.Time)
+		}
+		if tick.PriceUSD <= 0 {
+			return nil, fmt.Errorf("price of %s at %v should be positive", tick.AssetId, tick.Time)
+		}
+		if _, ok := t.ticks[tick.AssetId]; !ok {
+			t.ids = append(t.ids, tick.AssetId)
+		}
+		t.ticks[tick.AssetId] = append(t.ticks[tick.AssetId], tick)
+		if tick.Time.Before(t.start) {
+			t.start = tick.Time
+		}
+		if tick.Time.After(t.end) {
+			t.end = tick.Time
+		}
+	}
+
+	for _, assetTicks := range t.ticks {
+		sort.SliceStable(assetTicks, func(i, j int) bool { return assetTicks[i].Time.Before(assetTicks[j].Time) })
+	}
+	return t, nil
+}
+
+// Time of the first tick
+func (t *Tape) Start() time.Time {
+	return t.start
+}
+
+// Time of the last tick
+func (t *Tape) End() time.Time {
+	return t.end
+}
+
+// Gets the last tick of every asset at or before at, in the order the assets first appear in the file.
+// Assets without ticks before at are omitted
+func (t *Tape) At(at time.Time) []Tick {
+	var ticks []Tick
+	for _, id := range t.ids {
+		assetTicks := t.ticks[id]
+		pos := sort.Search(len(assetTicks), func(i int) bool { return assetTicks[i].Time.After(at) })
+		if pos > 0 {
+			ticks = append(ticks, assetTicks[pos-1])
+		}
+	}
+	return ticks
+}
+
+// Loads tape from CSV or JSONL file depending on its extension.
+//
+// CSV files have a header with timestamp, asset_id and price_usd columns and optional name, is_crypto and volume_24h_usd.
+// JSONL files have a tick object on each line.
+// Timestamps are RFC3339 or unix seconds
+func LoadFile(path string) (*Tape, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open replay file %s, %v", path, err)
+	}
+	defer file.Close()
+
+	var ticks []Tick
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		ticks, err = readCSV(file)
+	case ".jsonl", ".ndjson":
+		ticks, err = readJSONL(file)
+	default:
+		return nil, fmt.Errorf("replay file %s should be .csv or .jsonl", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read replay file %s, %v", path, err)
+	}
+	return NewTape(ticks)
+}
+
+func readCSV(r io.Reader) ([]Tick, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header, %v", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"timestamp", "asset_id", "price_usd"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("header should have column %s", required)
+		}
+	}
+	// gets value of optional column, empty if the column is missing
+	value := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var ticks []Tick
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return ticks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var tick Tick
+		if tick.Time, err = parseTimestamp(value(record, "timestamp")); err != nil {
+			return nil, fmt.Errorf("line %d, %v", line, err)
+		}
+		tick.AssetId = strings.ToUpper(value(record, "asset_id"))
+		if tick.PriceUSD, err = strconv.ParseFloat(value(record, "price_usd"), 64); err != nil {
+			return nil, fmt.Errorf("line %d, invalid price, %v", line, err)
+		}
+		tick.Name = value(record, "name")
+		tick.IsCrypto = value(record, "is_crypto") == "1" || strings.EqualFold(value(record, "is_crypto"), "true")
+		if volume := value(record, "volume_24h_usd"); volume != "" {
+			if tick.Volume24hUSD, err = strconv.ParseFloat(volume, 64); err != nil {
+				return nil, fmt.Errorf("line %d, invalid volume, %v", line, err)
+			}
+		}
+		ticks = append(ticks, tick)
+	}
+}
+
+func readJSONL(r io.Reader) ([]Tick, error) {
+	var ticks []Tick
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var tick struct {
+			Tick
+			Time json.RawMessage `json:"timestamp"`
+		}
+		if err := json.Unmarshal([]byte(text), &tick); err != nil {
+			return nil, fmt.Errorf("line %d, %v", line, err)
+		}
+		var err error
+		if tick.Tick.Time, err = parseTimestamp(strings.Trim(string(tick.Time), `"`)); err != nil {
+			return nil, fmt.Errorf("line %d, %v", line, err)
+		}
+		tick.AssetId = strings.ToUpper(tick.AssetId)
+		ticks = append(ticks, tick.Tick)
+	}
+	return ticks, scanner.Err()
+}
+
+// parses RFC3339 or unix seconds timestamp
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q, should be RFC3339 or unix seconds", value)
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,92 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(d, h int) time.Time {
+	return time.Date(2021, 5, d, h, 0, 0, 0, time.UTC)
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantIds   []string
+	}{
+		{"csv", "testdata/crash2021.csv", day(12, 0), day(23, 0), []string{"BTC", "ETH", "USD"}},
+		{"jsonl", "testdata/crash2021.jsonl", day(12, 0), day(19, 12), []string{"BTC", "ETH"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tape, err := LoadFile(tt.path)
+			if err != nil {
+				t.Fatalf("LoadFile() error = %v", err)
+			}
+			if !tape.Start().Equal(tt.wantStart) || !tape.End().Equal(tt.wantEnd) {
+				t.Errorf("LoadFile() tape from %v to %v, want from %v to %v", tape.Start(), tape.End(), tt.wantStart, tt.wantEnd)
+			}
+			if !reflect.DeepEqual(tape.ids, tt.wantIds) {
+				t.Errorf("LoadFile() ids = %v, want %v", tape.ids, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"missing-column.csv": "timestamp,asset_id\n2021-05-12T00:00:00Z,BTC\n",
+		"bad-time.csv":       "timestamp,asset_id,price_usd\nyesterday,BTC,1\n",
+		"bad-price.csv":      "timestamp,asset_id,price_usd\n2021-05-12T00:00:00Z,BTC,free\n",
+		"zero-price.csv":     "timestamp,asset_id,price_usd\n2021-05-12T00:00:00Z,BTC,0\n",
+		"empty.csv":          "timestamp,asset_id,price_usd\n",
+		"bad.jsonl":          "{\"timestamp\":\n",
+		"prices.txt":         "BTC 1\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			os.WriteFile(path, []byte(content), 0644)
+			if _, err := LoadFile(path); err == nil {
+				t.Errorf("LoadFile() expected error")
+			}
+		})
+	}
+}
+
+func TestTape_At(t *testing.T) {
+	tape, err := LoadFile("testdata/crash2021.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want map[string]float64
+	}{
+		{"before start", day(11, 0), map[string]float64{}},
+		{"start", day(12, 0), map[string]float64{"BTC": 56704.57, "ETH": 4167.78, "USD": 1}},
+		{"between ticks keeps last price", day(15, 0), map[string]float64{"BTC": 49150.54, "ETH": 3715.15, "USD": 1}},
+		{"crash", day(19, 12), map[string]float64{"BTC": 36000, "ETH": 2650, "USD": 1}},
+		{"after end", day(30, 0), map[string]float64{"BTC": 34770.58, "ETH": 2100.58, "USD": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]float64{}
+			for _, tick := range tape.At(tt.at) {
+				got[tick.AssetId] = tick.PriceUSD
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tape.At() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
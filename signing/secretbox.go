@@ -0,0 +1,65 @@
+package signing
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// size in bytes of the key of a SecretBox, which selects AES-256
+const SecretKeySize = 32
+
+// SecretBox encrypts the signing secrets of api keys, so they are not stored in plain text.
+// The server still needs the secrets to verify signatures, so they are encrypted rather than hashed
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// Creates box with key, the base64 encoding of SecretKeySize random bytes.
+// Returns error if the key is not valid
+func NewSecretBox(key string) (*SecretBox, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("secret key should be base64 encoded, %v", err)
+	}
+	if len(raw) != SecretKeySize {
+		return nil, fmt.Errorf("secret key should be %d bytes, got %d", SecretKeySize, len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not create secret key cipher, %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("could not create secret key cipher, %v", err)
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Encrypts secret with AES-GCM under a random nonce, returns the base64 encoding of the nonce and the ciphertext
+func (b SecretBox) Seal(secret string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("could not generate nonce, %v", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypts a secret encrypted by Seal.
+// Returns error if it was not encrypted with the key of the box or was changed
+func (b SecretBox) Open(sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", fmt.Errorf("secret is not encrypted")
+	}
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	secret, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt secret, %v", err)
+	}
+	return string(secret), nil
+}
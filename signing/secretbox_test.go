@@ -0,0 +1,67 @@
+package signing
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var testSecretKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", SecretKeySize)))
+
+func TestSecretBox_SealOpen(t *testing.T) {
+	box, err := NewSecretBox(testSecretKey)
+	if err != nil {
+		t.Fatalf("NewSecretBox() error = %v", err)
+	}
+	sealed, err := box.Seal("secret")
+	if err != nil {
+		t.Fatalf("SecretBox.Seal() error = %v", err)
+	}
+	if strings.Contains(sealed, "secret") {
+		t.Fatalf("SecretBox.Seal() = %s, want it encrypted", sealed)
+	}
+	if again, _ := box.Seal("secret"); again == sealed {
+		t.Errorf("SecretBox.Seal() should use a new nonce every time")
+	}
+	if secret, err := box.Open(sealed); err != nil || secret != "secret" {
+		t.Fatalf("SecretBox.Open() = %s, %v, want secret", secret, err)
+	}
+
+	other, _ := NewSecretBox(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", SecretKeySize))))
+	tampered := []byte(sealed)
+	tampered[len(tampered)-2] ^= 1
+	tests := []struct {
+		name   string
+		box    *SecretBox
+		sealed string
+	}{
+		{"other key", other, sealed},
+		{"tampered", box, string(tampered)},
+		{"plain text", box, "secret"},
+		{"too short", box, base64.StdEncoding.EncodeToString([]byte("short"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if secret, err := tt.box.Open(tt.sealed); err == nil {
+				t.Errorf("SecretBox.Open() = %s, want error", secret)
+			}
+		})
+	}
+}
+
+func TestNewSecretBox_InvalidKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"not base64", "not base64!"},
+		{"too short", base64.StdEncoding.EncodeToString([]byte("short"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSecretBox(tt.key); err == nil {
+				t.Errorf("NewSecretBox() accepted key %s", tt.key)
+			}
+		})
+	}
+}
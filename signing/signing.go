@@ -0,0 +1,86 @@
+// Package signing signs and verifies requests of api key clients with HMAC-SHA256
+package signing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headers of a signed request
+const (
+	// id of the api key whose secret signed the request
+	KeyHeader = "X-CM-Key"
+	// unix time in seconds at which the request was signed
+	TimestampHeader = "X-CM-Timestamp"
+	// random value used once, so a captured request can't be replayed
+	NonceHeader = "X-CM-Nonce"
+	// hex HMAC-SHA256 of the canonical request
+	SignatureHeader = "X-CM-Signature"
+)
+
+// Builds the string which is signed: the timestamp, nonce, method, escaped path,
+// sorted query and hex sha256 of the body, separated by new lines
+func Canonical(timestamp, nonce, method, path, query string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{timestamp, nonce, strings.ToUpper(method), path, query, hex.EncodeToString(bodyHash[:])}, "\n")
+}
+
+// Signs the canonical request with secret
+func Sign(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Checks in constant time whether signature is the signature of the canonical request with secret
+func Verify(secret, canonical, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Canonical string of an http request with the given signing headers.
+// The body is read and replaced, so it can be read again
+func CanonicalRequest(r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return "", err
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return Canonical(r.Header.Get(TimestampHeader), r.Header.Get(NonceHeader), r.Method, r.URL.EscapedPath(), r.URL.Query().Encode(), body), nil
+}
+
+// Signs request r of a Go client at time now with the api key id and secret, setting the signing headers
+func SignRequest(r *http.Request, keyID, secret string, now time.Time) error {
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	r.Header.Set(KeyHeader, keyID)
+	r.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	r.Header.Set(NonceHeader, hex.EncodeToString(nonce))
+
+	canonical, err := CanonicalRequest(r)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(SignatureHeader, Sign(secret, canonical))
+	return nil
+}
@@ -0,0 +1,54 @@
+package signing
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignRequest(t *testing.T) {
+	now := time.Date(2022, 2, 17, 10, 0, 0, 0, time.UTC)
+	r := httptest.NewRequest("POST", "/api/v1/users/u1/assets/BTC/buy?quantity=1&b=2", strings.NewReader(`{"a":1}`))
+	if err := SignRequest(r, "key1", "secret", now); err != nil {
+		t.Fatalf("SignRequest() error = %v", err)
+	}
+	if r.Header.Get(KeyHeader) != "key1" || r.Header.Get(TimestampHeader) != "1645092000" || r.Header.Get(NonceHeader) == "" {
+		t.Fatalf("SignRequest() headers = %v", r.Header)
+	}
+	if body, _ := ioutil.ReadAll(r.Body); string(body) != `{"a":1}` {
+		t.Fatalf("SignRequest() body = %s, want it readable again", body)
+	}
+	r.Body = ioutil.NopCloser(strings.NewReader(`{"a":1}`))
+
+	canonical, err := CanonicalRequest(r)
+	if err != nil {
+		t.Fatalf("CanonicalRequest() error = %v", err)
+	}
+	want := "1645092000\n" + r.Header.Get(NonceHeader) + "\nPOST\n/api/v1/users/u1/assets/BTC/buy\nb=2&quantity=1\n015abd7f5cc57a2dd94b7590f04ad8084273905ee33ec5cebeae62276a97f862"
+	if canonical != want {
+		t.Errorf("CanonicalRequest() = %q, want %q", canonical, want)
+	}
+
+	signature := r.Header.Get(SignatureHeader)
+	tests := []struct {
+		name      string
+		secret    string
+		canonical string
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", canonical, signature, true},
+		{"wrong secret", "other", canonical, signature, false},
+		{"tampered request", "secret", strings.Replace(canonical, "quantity=1", "quantity=9", 1), signature, false},
+		{"malformed signature", "secret", canonical, "xyz", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.canonical, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
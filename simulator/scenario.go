@@ -0,0 +1,134 @@
+// Package simulator generates synthetic asset prices, so the application can run without the external api
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// models of price movement
+const (
+	// geometric Brownian motion, the price drifts and moves randomly proportionally to itself
+	ModelGBM = "gbm"
+	// the logarithm of the price is pulled back towards the logarithm of the mean price
+	ModelMeanReversion = "meanReversion"
+	// the price never changes
+	ModelFixed = "fixed"
+)
+
+// simulated asset and the model of its price
+type AssetSpec struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	IsCrypto bool    `json:"isCrypto"`
+	Price    float64 `json:"price"`
+
+	// gbm, meanReversion or fixed
+	Model string `json:"model"`
+
+	// yearly drift and volatility, e.g. 0.8 is 80% a year
+	Drift      float64 `json:"drift"`
+	Volatility float64 `json:"volatility"`
+
+	// price to which meanReversion pulls and the yearly speed of the pull
+	MeanPrice float64 `json:"meanPrice"`
+	Reversion float64 `json:"reversion"`
+
+	Volume24hUSD float64 `json:"volume24hUSD"`
+}
+
+// scripted sudden move of prices
+type Shock struct {
+	// step in which the shock happens, the first generated prices are step 0
+	Step int `json:"step"`
+	// all assets which are not fixed are moved if empty
+	AssetId string `json:"assetId"`
+	// e.g. -30 drops the price by 30%
+	Percent float64 `json:"percent"`
+}
+
+// simulated market
+type Scenario struct {
+	Assets []AssetSpec `json:"assets"`
+	Shocks []Shock     `json:"shocks"`
+}
+
+// Loads scenario from JSON file.
+// Returns error if the file can't be read or the scenario is invalid
+func LoadScenario(path string) (*Scenario, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open scenario %s, %v", path, err)
+	}
+	defer file.Close()
+
+	var scenario Scenario
+	if err := json.NewDecoder(file).Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("could not parse scenario %s, %v", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s is invalid, %v", path, err)
+	}
+	return &scenario, nil
+}
+
+// Default market with a few well known assets.
+func DefaultScenario() *Scenario {
+	return &Scenario{Assets: []AssetSpec{
+		{ID: "USD", Name: "US Dollar", Price: 1, Model: ModelFixed},
+		{ID: "EUR", Name: "Euro", Price: 1.13, Model: ModelMeanReversion, MeanPrice: 1.13, Reversion: 5, Volatility: 0.08, Volume24hUSD: 5e9},
+		{ID: "BTC", Name: "Bitcoin", IsCrypto: true, Price: 40000, Model: ModelGBM, Drift: 0.3, Volatility: 0.8, Volume24hUSD: 3e10},
+		{ID: "ETH", Name: "Ethereum", IsCrypto: true, Price: 3000, Model: ModelGBM, Drift: 0.4, Volatility: 0.9, Volume24hUSD: 1.5e10},
+		{ID: "LTC", Name: "Litecoin", IsCrypto: true, Price: 120, Model: ModelGBM, Drift: 0.1, Volatility: 1, Volume24hUSD: 1e9},
+		{ID: "DOGE", Name: "Dogecoin", IsCrypto: true, Price: 0.14, Model: ModelMeanReversion, MeanPrice: 0.12, Reversion: 4, Volatility: 1.2, Volume24hUSD: 8e8},
+		{ID: "USDT", Name: "Tether", IsCrypto: true, Price: 1, Model: ModelMeanReversion, MeanPrice: 1, Reversion: 200, Volatility: 0.05, Volume24hUSD: 5e10},
+	}}
+}
+
+// Validates the assets and shocks of the scenario.
+func (s Scenario) Validate() error {
+	if len(s.Assets) == 0 {
+		return fmt.Errorf("scenario should have at least one asset")
+	}
+
+	ids := map[string]bool{}
+	for _, asset := range s.Assets {
+		if asset.ID == "" {
+			return fmt.Errorf("asset id should not be blank")
+		}
+		if ids[asset.ID] {
+			return fmt.Errorf("asset %s is defined more than once", asset.ID)
+		}
+		ids[asset.ID] = true
+
+		if asset.Price <= 0 {
+			return fmt.Errorf("price of asset %s should be positive", asset.ID)
+		}
+		if asset.Volatility < 0 {
+			return fmt.Errorf("volatility of asset %s should not be negative", asset.ID)
+		}
+		switch asset.Model {
+		case ModelGBM, ModelFixed:
+		case ModelMeanReversion:
+			if asset.MeanPrice <= 0 || asset.Reversion <= 0 {
+				return fmt.Errorf("mean price and reversion of asset %s should be positive", asset.ID)
+			}
+		default:
+			return fmt.Errorf("model of asset %s should be one of %s, %s, %s", asset.ID, ModelGBM, ModelMeanReversion, ModelFixed)
+		}
+	}
+
+	for _, shock := range s.Shocks {
+		if shock.Step < 0 {
+			return fmt.Errorf("step of shock should not be negative")
+		}
+		if shock.Percent <= -100 {
+			return fmt.Errorf("shock can't drop price by %f%%", -shock.Percent)
+		}
+		if shock.AssetId != "" && !ids[shock.AssetId] {
+			return fmt.Errorf("shock moves asset %s which is not simulated", shock.AssetId)
+		}
+	}
+	return nil
+}
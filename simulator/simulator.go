@@ -0,0 +1,105 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+)
+
+// length of the year for the yearly drift and volatility
+const year = 365 * 24 * time.Hour
+
+// Simulator is a price provider which can replace the external api.
+// Every call to GetAssets advances the market by one step, so the same scenario and seed
+// always produce the same sequence of prices. Safe for concurrent use
+type Simulator struct {
+	mu       sync.Mutex
+	scenario Scenario
+	rnd      *rand.Rand
+	// simulated time between two steps
+	step   time.Duration
+	prices []float64
+	// number of generated steps
+	steps int
+}
+
+// Simulator constructor, step is the simulated time which passes between two calls to GetAssets.
+// Returns error if the scenario is invalid
+func New(scenario Scenario, seed int64, step time.Duration) (*Simulator, error) {
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	if step <= 0 {
+		return nil, fmt.Errorf("simulation step should be positive")
+	}
+
+	prices := make([]float64, len(scenario.Assets))
+	for i, asset := range scenario.Assets {
+		prices[i] = asset.Price
+	}
+	return &Simulator{scenario: scenario, rnd: rand.New(rand.NewSource(seed)), step: step, prices: prices}, nil
+}
+
+// Generates the prices of the next step.
+// The first call returns the initial prices of the scenario
+func (s *Simulator) GetAssets() ([]coinapi.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.steps > 0 {
+		s.advance()
+	}
+	s.applyShocks(s.steps)
+	s.steps++
+
+	assets := make([]coinapi.Asset, len(s.scenario.Assets))
+	for i, spec := range s.scenario.Assets {
+		assets[i] = coinapi.Asset{
+			ID:           spec.ID,
+			Name:         spec.Name,
+			IsCrypto:     spec.IsCrypto,
+			PriceUSD:     s.prices[i],
+			Volume1hUSD:  spec.Volume24hUSD / 24,
+			Volume24hUSD: spec.Volume24hUSD,
+			Volume30dUSD: spec.Volume24hUSD * 30,
+		}
+	}
+	return assets, nil
+}
+
+// moves all prices according to their models
+func (s *Simulator) advance() {
+	dt := float64(s.step) / float64(year)
+	for i, spec := range s.scenario.Assets {
+		if spec.Model == ModelFixed {
+			continue
+		}
+
+		noise := spec.Volatility * math.Sqrt(dt) * s.rnd.NormFloat64()
+		switch spec.Model {
+		case ModelGBM:
+			s.prices[i] *= math.Exp((spec.Drift-spec.Volatility*spec.Volatility/2)*dt + noise)
+		case ModelMeanReversion:
+			logPrice := math.Log(s.prices[i])
+			logPrice += spec.Reversion*(math.Log(spec.MeanPrice)-logPrice)*dt + noise
+			s.prices[i] = math.Exp(logPrice)
+		}
+	}
+}
+
+func (s *Simulator) applyShocks(step int) {
+	for _, shock := range s.scenario.Shocks {
+		if shock.Step != step {
+			continue
+		}
+		for i, spec := range s.scenario.Assets {
+			if spec.ID == shock.AssetId || (shock.AssetId == "" && spec.Model != ModelFixed) {
+				s.prices[i] *= 1 + shock.Percent/100
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package simulator
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+)
+
+func prices(t *testing.T, s *Simulator, steps int) [][]float64 {
+	var all [][]float64
+	for i := 0; i < steps; i++ {
+		assets, err := s.GetAssets()
+		if err != nil {
+			t.Fatalf("GetAssets() error = %v", err)
+		}
+		step := make([]float64, len(assets))
+		for j, asset := range assets {
+			step[j] = asset.PriceUSD
+		}
+		all = append(all, step)
+	}
+	return all
+}
+
+func TestSimulator_Deterministic(t *testing.T) {
+	s1, _ := New(*DefaultScenario(), 42, time.Hour)
+	s2, _ := New(*DefaultScenario(), 42, time.Hour)
+	s3, _ := New(*DefaultScenario(), 43, time.Hour)
+
+	p1, p2, p3 := prices(t, s1, 50), prices(t, s2, 50), prices(t, s3, 50)
+	if !reflect.DeepEqual(p1, p2) {
+		t.Errorf("simulators with the same seed generated different prices")
+	}
+	if reflect.DeepEqual(p1, p3) {
+		t.Errorf("simulators with different seeds generated the same prices")
+	}
+}
+
+func TestSimulator_GetAssets(t *testing.T) {
+	scenario := Scenario{Assets: []AssetSpec{
+		{ID: "USD", Price: 1, Model: ModelFixed},
+		{ID: "BTC", IsCrypto: true, Price: 40000, Model: ModelGBM, Drift: 0.3, Volatility: 0.8, Volume24hUSD: 2400},
+	}}
+	s, err := New(scenario, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := s.GetAssets()
+	want := []coinapi.Asset{
+		{ID: "USD", PriceUSD: 1},
+		{ID: "BTC", IsCrypto: true, PriceUSD: 40000, Volume1hUSD: 100, Volume24hUSD: 2400, Volume30dUSD: 72000},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Fatalf("GetAssets() first step = %+v, want initial prices %+v", first, want)
+	}
+
+	for _, step := range prices(t, s, 100) {
+		if step[0] != 1 {
+			t.Fatalf("fixed price moved to %f", step[0])
+		}
+		if step[1] <= 0 || step[1] == 40000 {
+			t.Fatalf("gbm price %f didn't move or isn't positive", step[1])
+		}
+	}
+}
+
+func TestSimulator_MeanReversion(t *testing.T) {
+	scenario := Scenario{Assets: []AssetSpec{{ID: "DOGE", Price: 1, Model: ModelMeanReversion, MeanPrice: 0.1, Reversion: 50, Volatility: 0.1}}}
+	s, _ := New(scenario, 1, 24*time.Hour)
+
+	all := prices(t, s, 200)
+	if last := all[len(all)-1][0]; math.Abs(last-0.1) > 0.02 {
+		t.Errorf("price %f didn't revert to mean 0.1", last)
+	}
+}
+
+func TestSimulator_Shocks(t *testing.T) {
+	scenario := Scenario{
+		Assets: []AssetSpec{
+			{ID: "USD", Price: 1, Model: ModelFixed},
+			{ID: "BTC", Price: 100, Model: ModelGBM},
+			{ID: "ETH", Price: 10, Model: ModelGBM},
+		},
+		Shocks: []Shock{{Step: 0, AssetId: "BTC", Percent: 50}, {Step: 2, Percent: -50}},
+	}
+	s, _ := New(scenario, 1, time.Hour)
+
+	// without volatility and drift only the shocks move prices
+	got := prices(t, s, 3)
+	want := [][]float64{{1, 150, 10}, {1, 150, 10}, {1, 75, 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("prices with shocks = %v, want %v", got, want)
+	}
+}
+
+func TestScenario_Validate(t *testing.T) {
+	btc := AssetSpec{ID: "BTC", Price: 100, Model: ModelGBM}
+	tests := []struct {
+		name     string
+		scenario Scenario
+		wantErr  bool
+	}{
+		{"default", *DefaultScenario(), false},
+		{"no assets", Scenario{}, true},
+		{"blank id", Scenario{Assets: []AssetSpec{{Price: 1, Model: ModelFixed}}}, true},
+		{"duplicate id", Scenario{Assets: []AssetSpec{btc, btc}}, true},
+		{"no price", Scenario{Assets: []AssetSpec{{ID: "BTC", Model: ModelGBM}}}, true},
+		{"unknown model", Scenario{Assets: []AssetSpec{{ID: "BTC", Price: 1, Model: "random"}}}, true},
+		{"negative volatility", Scenario{Assets: []AssetSpec{{ID: "BTC", Price: 1, Model: ModelGBM, Volatility: -1}}}, true},
+		{"mean reversion without mean", Scenario{Assets: []AssetSpec{{ID: "BTC", Price: 1, Model: ModelMeanReversion, Reversion: 1}}}, true},
+		{"shock of unknown asset", Scenario{Assets: []AssetSpec{btc}, Shocks: []Shock{{AssetId: "ETH", Percent: 10}}}, true},
+		{"shock wipes price", Scenario{Assets: []AssetSpec{btc}, Shocks: []Shock{{Percent: -100}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.scenario.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Scenario.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	os.WriteFile(valid, []byte(`{"assets":[{"id":"BTC","price":100,"model":"gbm","volatility":0.5}],"shocks":[{"step":10,"percent":-30}]}`), 0644)
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"assets":[{"id":"BTC","price":100,"model":"random"}]}`), 0644)
+
+	scenario, err := LoadScenario(valid)
+	if err != nil {
+		t.Fatalf("LoadScenario() error = %v", err)
+	}
+	if len(scenario.Assets) != 1 || scenario.Shocks[0].Percent != -30 {
+		t.Errorf("LoadScenario() = %+v", scenario)
+	}
+	if _, err := LoadScenario(invalid); err == nil {
+		t.Errorf("LoadScenario() expected error for invalid scenario")
+	}
+	if _, err := LoadScenario(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("LoadScenario() expected error for missing file")
+	}
+}
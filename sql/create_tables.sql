@@ -4,11 +4,70 @@ USE `currency-master`;
 
 CREATE TABLE IF NOT EXISTS `USERS` (
     `username` VARCHAR(36) NOT NULL PRIMARY KEY,
-    `password` VARCHAR(36) NOT NULL,
+    `password` VARCHAR(255) NOT NULL,
     `email` VARCHAR(64) NOT NULL,
     `usd` FLOAT NOT NULL
 );
 
+-- passwords are stored hashed, which don't fit in the original column
+ALTER TABLE `USERS` MODIFY `password` VARCHAR(255) NOT NULL;
+
+-- users without a row have the user role
+CREATE TABLE IF NOT EXISTS `USER_ROLES` (
+    `username` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `role` VARCHAR(16) NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+-- users with a row haven't verified their email yet, users created before verification have none
+CREATE TABLE IF NOT EXISTS `UNVERIFIED_EMAILS` (
+    `username` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `email` VARCHAR(64) NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+-- users with a row verified the email in it, users created before verification have none
+CREATE TABLE IF NOT EXISTS `VERIFIED_EMAILS` (
+    `username` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `email` VARCHAR(64) NOT NULL,
+    `verified` DATETIME NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+CREATE TABLE IF NOT EXISTS `USER_TOKENS` (
+    `token_hash` CHAR(64) NOT NULL PRIMARY KEY,
+    `username` VARCHAR(36) NOT NULL,
+    `purpose` VARCHAR(16) NOT NULL,
+    `expires` DATETIME NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+-- a row means the user enrolled in two-factor authentication, enabled once they confirmed it with a code
+CREATE TABLE IF NOT EXISTS `USER_TOTP` (
+    `username` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `secret` VARCHAR(64) NOT NULL,
+    `enabled` BOOLEAN NOT NULL,
+    `last_step` BIGINT NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+CREATE TABLE IF NOT EXISTS `USER_RECOVERY_CODES` (
+    `username` VARCHAR(36) NOT NULL,
+    `code_hash` CHAR(64) NOT NULL,
+    CONSTRAINT PK_USER_RECOVERY_CODE PRIMARY KEY (username,code_hash),
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+-- accounts at OpenID Connect identity providers users log in with
+CREATE TABLE IF NOT EXISTS `USER_IDENTITIES` (
+    `issuer` VARCHAR(255) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `username` VARCHAR(36) NOT NULL,
+    `created` DATETIME NOT NULL,
+    CONSTRAINT PK_USER_IDENTITY PRIMARY KEY (issuer,subject),
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
 CREATE TABLE IF NOT EXISTS `USER_ASSETS` (
     `username` VARCHAR(36) NOT NULL,
     `asset_id` VARCHAR(10) NOT NULL,
@@ -26,4 +85,142 @@ CREATE TABLE IF NOT EXISTS `ACQUISITIONS` (
     `created` DATETIME NOT NULL,
     FOREIGN KEY (username) REFERENCES USERS(username),
     CONSTRAINT PK_USER_ASSET PRIMARY KEY (username,asset_id,created)
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS `PRICE_ALERTS` (
+    `id` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `username` VARCHAR(36) NOT NULL,
+    `asset_id` VARCHAR(10) NOT NULL,
+    `alert_condition` VARCHAR(10) NOT NULL,
+    `threshold` DOUBLE NOT NULL,
+    `mode` VARCHAR(10) NOT NULL,
+    `cooldown_minutes` INT NOT NULL,
+    `channel` VARCHAR(10) NOT NULL,
+    `target` VARCHAR(255) NOT NULL,
+    `reference_price` DOUBLE NOT NULL,
+    `reference_time` DATETIME NOT NULL,
+    `last_triggered` DATETIME NULL,
+    `active` BOOLEAN NOT NULL,
+    `created` DATETIME NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+CREATE TABLE IF NOT EXISTS `NOTIFICATIONS` (
+    `id` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `username` VARCHAR(36) NOT NULL,
+    `alert_id` VARCHAR(36) NOT NULL,
+    `message` VARCHAR(255) NOT NULL,
+    `created` DATETIME NOT NULL,
+    `is_read` BOOLEAN NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+CREATE TABLE IF NOT EXISTS `LAST_PRICES` (
+    `asset_id` VARCHAR(10) NOT NULL PRIMARY KEY,
+    `name` VARCHAR(100) NOT NULL,
+    `price_usd` DOUBLE NOT NULL,
+    `bid_usd` DOUBLE NOT NULL DEFAULT 0,
+    `updated` DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `WATCHLISTS` (
+    `id` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `username` VARCHAR(36) NOT NULL,
+    `name` VARCHAR(50) NOT NULL,
+    `public` BOOLEAN NOT NULL,
+    `created` DATETIME NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+CREATE TABLE IF NOT EXISTS `WATCHLIST_ASSETS` (
+    `watchlist_id` VARCHAR(36) NOT NULL,
+    `asset_id` VARCHAR(10) NOT NULL,
+    `position` INT NOT NULL,
+    FOREIGN KEY (watchlist_id) REFERENCES WATCHLISTS(id) ON DELETE CASCADE,
+    CONSTRAINT PK_WATCHLIST_ASSET PRIMARY KEY (watchlist_id,asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS `PRICE_HISTORY` (
+    `asset_id` VARCHAR(10) NOT NULL,
+    `price_usd` DOUBLE NOT NULL,
+    `recorded` DATETIME NOT NULL,
+    CONSTRAINT PK_PRICE_HISTORY PRIMARY KEY (asset_id,recorded),
+    INDEX IDX_PRICE_HISTORY_RECORDED (recorded)
+);
+
+CREATE TABLE IF NOT EXISTS `SESSIONS` (
+    `id` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `username` VARCHAR(36) NOT NULL,
+    `expiration` DATETIME NOT NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username),
+    INDEX IDX_SESSIONS_EXPIRATION (expiration)
+);
+
+CREATE TABLE IF NOT EXISTS `API_KEYS` (
+    `id` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `username` VARCHAR(36) NOT NULL,
+    `name` VARCHAR(50) NOT NULL,
+    `prefix` VARCHAR(16) NOT NULL,
+    `key_hash` CHAR(64) NOT NULL UNIQUE,
+    `secret` VARCHAR(128) NOT NULL,
+    `scopes` VARCHAR(100) NOT NULL,
+    `expires` DATETIME NULL,
+    `created` DATETIME NOT NULL,
+    `last_used` DATETIME NULL,
+    FOREIGN KEY (username) REFERENCES USERS(username)
+);
+
+-- signing secrets are stored encrypted, which don't fit in the original column
+ALTER TABLE `API_KEYS` MODIFY `secret` VARCHAR(128) NOT NULL;
+
+-- deleted users and their acquisitions are kept for bookkeeping, a username can be registered again after deletion
+CREATE TABLE IF NOT EXISTS `DELETED_USERS` (
+    `username` VARCHAR(36) NOT NULL,
+    `email` VARCHAR(64) NOT NULL,
+    `usd` FLOAT NOT NULL,
+    `deleted` DATETIME NOT NULL,
+    CONSTRAINT PK_DELETED_USER PRIMARY KEY (username,deleted)
+);
+
+CREATE TABLE IF NOT EXISTS `ACQUISITIONS_ARCHIVE` (
+    `username` VARCHAR(36) NOT NULL,
+    `asset_id` VARCHAR(10) NOT NULL,
+    `quantity` FLOAT NOT NULL,
+    `price_usd` FLOAT NOT NULL,
+    `created` DATETIME NOT NULL,
+    `deleted` DATETIME NOT NULL,
+    INDEX IDX_ACQUISITIONS_ARCHIVE_USERNAME (username)
+);
+
+-- failed logins are counted by username and by ip address, unknown usernames too
+CREATE TABLE IF NOT EXISTS `LOGIN_FAILURES` (
+    `kind` VARCHAR(10) NOT NULL,
+    `subject` VARCHAR(64) NOT NULL,
+    `failures` INT NOT NULL,
+    `first_failure` DATETIME NOT NULL,
+    `last_failure` DATETIME NOT NULL,
+    `locked_until` DATETIME NULL,
+    CONSTRAINT PK_LOGIN_FAILURE PRIMARY KEY (kind,subject),
+    INDEX IDX_LOGIN_FAILURES_LOCKED_UNTIL (locked_until)
+);
+
+CREATE TABLE IF NOT EXISTS `SECURITY_EVENTS` (
+    `id` VARCHAR(36) NOT NULL PRIMARY KEY,
+    `type` VARCHAR(30) NOT NULL,
+    `kind` VARCHAR(10) NOT NULL,
+    `subject` VARCHAR(64) NOT NULL,
+    `message` VARCHAR(255) NOT NULL,
+    `created` DATETIME NOT NULL,
+    INDEX IDX_SECURITY_EVENTS_CREATED (created)
+);
+
+-- holdings of delisted assets settled into usd
+CREATE TABLE IF NOT EXISTS `SETTLEMENTS` (
+    `username` VARCHAR(36) NOT NULL,
+    `asset_id` VARCHAR(10) NOT NULL,
+    `quantity` FLOAT NOT NULL,
+    `price_usd` FLOAT NOT NULL,
+    `usd` FLOAT NOT NULL,
+    `settled` DATETIME NOT NULL,
+    CONSTRAINT PK_SETTLEMENT PRIMARY KEY (username,asset_id,settled)
+);
@@ -0,0 +1,168 @@
+// Package stream fans out asset price updates to subscribed clients
+package stream
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+)
+
+// number of updates buffered per subscription before the oldest one is dropped
+const defaultBufferSize = 4
+
+// Prices of the subscribed assets after a cache refresh
+type Update struct {
+	// when the prices were fetched from the external api
+	Updated time.Time `json:"updated"`
+
+	// subscribed assets present in the refreshed cache
+	Assets []coinapi.Asset `json:"assets"`
+}
+
+// Hub keeps the subscriptions and publishes updates to them.
+// Publishing never blocks: when a subscriber is slower than the updates,
+// its oldest pending update is dropped, since every update carries the full set of subscribed prices
+type Hub struct {
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+	bufferSize    int
+}
+
+// Hub constructor.
+func NewHub() *Hub {
+	return &Hub{subscriptions: map[*Subscription]struct{}{}, bufferSize: defaultBufferSize}
+}
+
+// Subscribes to updates of assets with ids.
+func (h *Hub) Subscribe(ids []string) *Subscription {
+	s := &Subscription{ids: map[string]bool{}, updates: make(chan Update, h.bufferSize)}
+	s.Set(ids)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscriptions[s] = struct{}{}
+	return s
+}
+
+// Removes subscription and closes its updates channel.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscriptions[s]; ok {
+		delete(h.subscriptions, s)
+		close(s.updates)
+	}
+}
+
+// Returns the number of active subscriptions.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscriptions)
+}
+
+// Publishes the refreshed assets to every subscription interested in them.
+// Has the signature of a svc.RefreshListener
+func (h *Hub) Publish(assets []coinapi.Asset, updated time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscriptions {
+		update := Update{Updated: updated, Assets: s.Filter(assets)}
+		if len(update.Assets) == 0 {
+			continue
+		}
+		s.send(update)
+	}
+}
+
+// Subscription of a single client.
+type Subscription struct {
+	mu      sync.RWMutex
+	ids     map[string]bool
+	updates chan Update
+	dropped int
+}
+
+// Channel with updates, closed when unsubscribed.
+func (s *Subscription) Updates() <-chan Update {
+	return s.updates
+}
+
+// Replaces the subscribed asset ids.
+func (s *Subscription) Set(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = map[string]bool{}
+	for _, id := range ids {
+		s.ids[id] = true
+	}
+}
+
+// Adds asset ids to the subscription.
+func (s *Subscription) Add(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.ids[id] = true
+	}
+}
+
+// Removes asset ids from the subscription.
+func (s *Subscription) Remove(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.ids, id)
+	}
+}
+
+// Returns the number of subscribed asset ids.
+func (s *Subscription) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.ids)
+}
+
+// Returns the subscribed assets out of assets.
+func (s *Subscription) Filter(assets []coinapi.Asset) []coinapi.Asset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	filtered := []coinapi.Asset{}
+	for _, asset := range assets {
+		if s.ids[asset.ID] {
+			filtered = append(filtered, asset)
+		}
+	}
+	return filtered
+}
+
+// Returns how many updates were dropped because the subscriber was too slow.
+func (s *Subscription) Dropped() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dropped
+}
+
+// sends without blocking, dropping the oldest pending update if the buffer is full.
+// Called only by the hub while holding its lock, so it is the only sender
+func (s *Subscription) send(update Update) {
+	for {
+		select {
+		case s.updates <- update:
+			return
+		default:
+		}
+
+		select {
+		case <-s.updates:
+			s.mu.Lock()
+			s.dropped++
+			dropped := s.dropped
+			s.mu.Unlock()
+			log.Printf("Subscriber is too slow, dropped %d price updates so far", dropped)
+		default:
+		}
+	}
+}
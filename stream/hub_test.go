@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+)
+
+func TestHub_Publish(t *testing.T) {
+	btc := coinapi.Asset{ID: "BTC", PriceUSD: 40000}
+	eth := coinapi.Asset{ID: "ETH", PriceUSD: 3000}
+	updated := time.Date(2022, time.February, 17, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		ids    []string
+		assets []coinapi.Asset
+		want   []Update
+	}{
+		{"subscribed assets only", []string{"BTC"}, []coinapi.Asset{btc, eth}, []Update{{updated, []coinapi.Asset{btc}}}},
+		{"several assets", []string{"BTC", "ETH"}, []coinapi.Asset{btc, eth}, []Update{{updated, []coinapi.Asset{btc, eth}}}},
+		{"no subscribed asset refreshed", []string{"DOGE"}, []coinapi.Asset{btc, eth}, []Update{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			s := h.Subscribe(tt.ids)
+
+			h.Publish(tt.assets, updated)
+			h.Unsubscribe(s)
+
+			got := []Update{}
+			for update := range s.Updates() {
+				got = append(got, update)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Hub.Publish() delivered %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHub_Publish_SlowSubscriber(t *testing.T) {
+	h := NewHub()
+	slow := h.Subscribe([]string{"BTC"})
+	fast := h.Subscribe([]string{"BTC"})
+
+	received := 0
+	for i := 1; i <= 10; i++ {
+		h.Publish([]coinapi.Asset{{ID: "BTC", PriceUSD: float64(i)}}, time.Now())
+		<-fast.Updates()
+		received++
+	}
+
+	if received != 10 {
+		t.Fatalf("fast subscriber should receive every update, got %d", received)
+	}
+	if slow.Dropped() != 10-defaultBufferSize {
+		t.Fatalf("slow subscriber should drop the oldest updates, dropped %d want %d", slow.Dropped(), 10-defaultBufferSize)
+	}
+	h.Unsubscribe(slow)
+	var prices []float64
+	for update := range slow.Updates() {
+		prices = append(prices, update.Assets[0].PriceUSD)
+	}
+	if want := []float64{7, 8, 9, 10}; !reflect.DeepEqual(prices, want) {
+		t.Fatalf("slow subscriber should keep the latest updates, got %v want %v", prices, want)
+	}
+}
+
+func TestSubscription_Change(t *testing.T) {
+	btc := coinapi.Asset{ID: "BTC"}
+	eth := coinapi.Asset{ID: "ETH"}
+	doge := coinapi.Asset{ID: "DOGE"}
+	s := NewHub().Subscribe([]string{"BTC"})
+
+	s.Add([]string{"ETH", "DOGE"})
+	s.Remove([]string{"BTC"})
+
+	if got, want := s.Filter([]coinapi.Asset{btc, eth, doge}), []coinapi.Asset{eth, doge}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Subscription.Filter() = %v, want %v", got, want)
+	}
+	if s.Len() != 2 {
+		t.Fatalf("Subscription.Len() = %d, want 2", s.Len())
+	}
+
+	s.Set([]string{"BTC"})
+	if got, want := s.Filter([]coinapi.Asset{btc, eth, doge}), []coinapi.Asset{btc}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Subscription.Filter() = %v, want %v", got, want)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("Subscription.Len() = %d, want 1", s.Len())
+	}
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	h := NewHub()
+	s := h.Subscribe([]string{"BTC"})
+
+	h.Unsubscribe(s)
+	h.Unsubscribe(s)
+	h.Publish([]coinapi.Asset{{ID: "BTC"}}, time.Now())
+
+	if h.Len() != 0 {
+		t.Fatalf("hub should have no subscriptions, got %d", h.Len())
+	}
+	if _, ok := <-s.Updates(); ok {
+		t.Fatalf("updates channel should be closed")
+	}
+}
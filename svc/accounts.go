@@ -0,0 +1,137 @@
+package svc
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/mail"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// Accounts service to verify emails and reset forgotten passwords with single-use tokens sent by email
+type Accounts struct {
+	UDB    accountsUsersDB
+	TDB    tokensDB
+	Mailer mail.Mailer
+	Hasher passwordHasher
+	Config *config.Account
+	Clock  clock.Clock
+}
+
+type accountsUsersDB interface {
+	GetByUsername(username string) (*model.User, error)
+	SetEmailVerified(username string, verified time.Time) error
+	UpdatePassword(username, password string) error
+}
+
+type tokensDB interface {
+	Create(token model.Token) error
+	Use(hash, purpose string) (*model.Token, error)
+	DeleteByUsername(username, purpose string) error
+}
+
+// Sends a verification link to the email of user, replacing the links sent before
+func (a Accounts) SendVerification(user model.User) error {
+	token, err := createToken(a.TDB, a.Clock.Now(), user.Username, model.PurposeVerifyEmail, a.Config.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := a.Config.PublicURL + "/api/v1/account/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello %s,\r\n\r\nconfirm your email by opening %s\r\nThe link expires in %v. You can trade once your email is confirmed.",
+		user.Username, link, a.Config.VerificationTTL)
+	if err := a.Mailer.Send(mail.Message{To: user.Email, Subject: "Confirm your email", Body: body}); err != nil {
+		return fmt.Errorf("could not send verification email to user %s, %v", user.Username, err)
+	}
+	log.Printf("Sent verification email to user %s", user.Username)
+	return nil
+}
+
+// Verifies the email of the user the token was sent to, false if the token is invalid or expired
+func (a Accounts) Verify(token string) (bool, error) {
+	used, err := useToken(a.TDB, a.Clock.Now(), token, model.PurposeVerifyEmail)
+	if err != nil || used == nil {
+		return false, err
+	}
+	if err := a.UDB.SetEmailVerified(used.Username, a.Clock.Now()); err != nil {
+		return false, err
+	}
+	log.Printf("Verified email of user %s", used.Username)
+	return true, nil
+}
+
+// Sends a password reset token to the email of user, replacing the tokens sent before.
+// Nothing is sent if the user doesn't exist, which is not reported so usernames can't be probed
+func (a Accounts) RequestPasswordReset(username string) error {
+	user, err := a.UDB.GetByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		log.Printf("Password reset requested for unknown user %s", username)
+		return nil
+	}
+
+	token, err := createToken(a.TDB, a.Clock.Now(), username, model.PurposeResetPassword, a.Config.ResetTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\r\n\r\nreset your password by posting {\"token\": \"%s\", \"newPassword\": \"...\"} to %s/api/v1/account/password-reset/confirm\r\n"+
+		"The token expires in %v. Ignore this email if you didn't ask for a reset.", username, token, a.Config.PublicURL, a.Config.ResetTTL)
+	if err := a.Mailer.Send(mail.Message{To: user.Email, Subject: "Reset your password", Body: body}); err != nil {
+		return fmt.Errorf("could not send password reset email to user %s, %v", username, err)
+	}
+	log.Printf("Sent password reset email to user %s", username)
+	return nil
+}
+
+// Replaces the password of the user the token was sent to and gets their username, empty if the token is invalid or expired
+func (a Accounts) ResetPassword(token, newPassword string) (string, error) {
+	used, err := useToken(a.TDB, a.Clock.Now(), token, model.PurposeResetPassword)
+	if err != nil || used == nil {
+		return "", err
+	}
+
+	hash, err := a.Hasher.Hash(newPassword)
+	if err != nil {
+		return "", fmt.Errorf("could not hash password, %v", err)
+	}
+	if err := a.UDB.UpdatePassword(used.Username, hash); err != nil {
+		return "", err
+	}
+	log.Printf("Reset password of user %s", used.Username)
+	return used.Username, nil
+}
+
+// creates a token of user for purpose valid for ttl from now and deletes their older ones, only its hash is stored
+func createToken(tdb tokensDB, now time.Time, username, purpose string, ttl time.Duration) (string, error) {
+	plain, err := randomString()
+	if err != nil {
+		return "", fmt.Errorf("could not generate token, %v", err)
+	}
+	if err := tdb.DeleteByUsername(username, purpose); err != nil {
+		return "", err
+	}
+	token := model.Token{Hash: hashToken(plain), Username: username, Purpose: purpose, Expires: now.Add(ttl)}
+	if err := tdb.Create(token); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// uses up the token for purpose, nil if it doesn't exist or is expired at now
+func useToken(tdb tokensDB, now time.Time, plain, purpose string) (*model.Token, error) {
+	token, err := tdb.Use(hashToken(plain), purpose)
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if token.IsExpired(now) {
+		return nil, nil
+	}
+	return token, nil
+}
@@ -0,0 +1,192 @@
+package svc
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/mail"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubTokensDB struct {
+	tokens map[string]model.Token
+}
+
+func (s stubTokensDB) Create(token model.Token) error {
+	s.tokens[token.Hash] = token
+	return nil
+}
+func (s stubTokensDB) Use(hash, purpose string) (*model.Token, error) {
+	token, ok := s.tokens[hash]
+	if !ok || token.Purpose != purpose {
+		return nil, nil
+	}
+	delete(s.tokens, hash)
+	return &token, nil
+}
+func (s stubTokensDB) DeleteByUsername(username, purpose string) error {
+	for hash, token := range s.tokens {
+		if token.Username == username && token.Purpose == purpose {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
+
+type stubAccountsUDB struct {
+	user     *model.User
+	password *string
+}
+
+func (s stubAccountsUDB) GetByUsername(username string) (*model.User, error) {
+	if s.user == nil || s.user.Username != username {
+		return nil, nil
+	}
+	return s.user, nil
+}
+func (s stubAccountsUDB) SetEmailVerified(username string, verified time.Time) error {
+	s.user.EmailVerified = true
+	return nil
+}
+func (s stubAccountsUDB) UpdatePassword(username, password string) error {
+	*s.password = password
+	return nil
+}
+
+type stubMailer struct {
+	sent []mail.Message
+	err  error
+}
+
+func (s *stubMailer) Send(msg mail.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+var (
+	verifyTokenRe = regexp.MustCompile(`token=([\w-]+)`)
+	resetTokenRe  = regexp.MustCompile(`"token": "([\w-]+)"`)
+)
+
+func newTestAccounts(user *model.User, password *string) (Accounts, *stubMailer, *clock.Fake) {
+	mailer := &stubMailer{}
+	clk := clock.NewFake(testNow)
+	return Accounts{UDB: stubAccountsUDB{user: user, password: password}, TDB: stubTokensDB{tokens: map[string]model.Token{}}, Mailer: mailer, Hasher: stubHasher{},
+		Config: &config.Account{PublicURL: "http://cm.local", VerificationTTL: time.Hour, ResetTTL: time.Minute}, Clock: clk}, mailer, clk
+}
+
+func TestAccounts_Verify(t *testing.T) {
+	user := &model.User{Username: "u1", Email: "u1@mail.com"}
+	a, mailer, _ := newTestAccounts(user, nil)
+
+	if err := a.SendVerification(*user); err != nil {
+		t.Fatalf("Accounts.SendVerification() error = %v", err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "u1@mail.com" {
+		t.Fatalf("Accounts.SendVerification() sent %+v", mailer.sent)
+	}
+	match := verifyTokenRe.FindStringSubmatch(mailer.sent[0].Body)
+	if match == nil {
+		t.Fatalf("Accounts.SendVerification() sent no link with token, %s", mailer.sent[0].Body)
+	}
+
+	if ok, err := a.Verify("wrong"); ok || err != nil {
+		t.Errorf("Accounts.Verify() of wrong token = %v, %v, want false", ok, err)
+	}
+	if ok, err := a.Verify(match[1]); !ok || err != nil || !user.EmailVerified {
+		t.Errorf("Accounts.Verify() = %v, %v, want the email verified", ok, err)
+	}
+	if ok, _ := a.Verify(match[1]); ok {
+		t.Errorf("Accounts.Verify() accepted a used token")
+	}
+}
+
+func TestAccounts_Verify_Expired(t *testing.T) {
+	user := &model.User{Username: "u1", Email: "u1@mail.com"}
+	a, mailer, clk := newTestAccounts(user, nil)
+	a.SendVerification(*user)
+	token := verifyTokenRe.FindStringSubmatch(mailer.sent[0].Body)[1]
+
+	clk.Advance(time.Hour)
+	if ok, _ := a.Verify(token); ok || user.EmailVerified {
+		t.Errorf("Accounts.Verify() accepted an expired token")
+	}
+}
+
+func TestAccounts_SendVerification_ReplacesOlderTokens(t *testing.T) {
+	user := &model.User{Username: "u1", Email: "u1@mail.com"}
+	a, mailer, _ := newTestAccounts(user, nil)
+	a.SendVerification(*user)
+	a.SendVerification(*user)
+
+	if ok, _ := a.Verify(verifyTokenRe.FindStringSubmatch(mailer.sent[0].Body)[1]); ok {
+		t.Errorf("Accounts.Verify() accepted a replaced token")
+	}
+	if ok, _ := a.Verify(verifyTokenRe.FindStringSubmatch(mailer.sent[1].Body)[1]); !ok {
+		t.Errorf("Accounts.Verify() rejected the latest token")
+	}
+}
+
+func TestAccounts_SendVerification_MailerError(t *testing.T) {
+	user := &model.User{Username: "u1", Email: "u1@mail.com"}
+	a, mailer, _ := newTestAccounts(user, nil)
+	mailer.err = fmt.Errorf("smtp")
+
+	if err := a.SendVerification(*user); err == nil {
+		t.Errorf("Accounts.SendVerification() expected error when the mail can't be sent")
+	}
+}
+
+func TestAccounts_ResetPassword(t *testing.T) {
+	stored := "hashed:P1"
+	a, mailer, clk := newTestAccounts(&model.User{Username: "u1", Email: "u1@mail.com"}, &stored)
+
+	if err := a.RequestPasswordReset("unknown"); err != nil || len(mailer.sent) != 0 {
+		t.Fatalf("Accounts.RequestPasswordReset() of unknown user = %v, sent %+v", err, mailer.sent)
+	}
+	if err := a.RequestPasswordReset("u1"); err != nil || len(mailer.sent) != 1 || mailer.sent[0].To != "u1@mail.com" {
+		t.Fatalf("Accounts.RequestPasswordReset() = %v, sent %+v", err, mailer.sent)
+	}
+	token := resetTokenRe.FindStringSubmatch(mailer.sent[0].Body)[1]
+
+	if username, _ := a.ResetPassword("wrong", "P2"); username != "" {
+		t.Errorf("Accounts.ResetPassword() accepted a wrong token")
+	}
+	// verification tokens can't reset passwords
+	if username, _ := a.ResetPassword(token, "P2"); username != "u1" || stored != "hashed:P2" {
+		t.Errorf("Accounts.ResetPassword() = %q, stored %q, want u1 with the new password", username, stored)
+	}
+	if username, _ := a.ResetPassword(token, "P3"); username != "" || stored != "hashed:P2" {
+		t.Errorf("Accounts.ResetPassword() accepted a used token")
+	}
+
+	a.RequestPasswordReset("u1")
+	clk.Advance(time.Minute)
+	if username, _ := a.ResetPassword(resetTokenRe.FindStringSubmatch(mailer.sent[1].Body)[1], "P3"); username != "" {
+		t.Errorf("Accounts.ResetPassword() accepted an expired token")
+	}
+}
+
+func TestAccounts_TokenPurposes(t *testing.T) {
+	stored := "hashed:P1"
+	user := &model.User{Username: "u1", Email: "u1@mail.com"}
+	a, mailer, _ := newTestAccounts(user, &stored)
+	a.SendVerification(*user)
+	a.RequestPasswordReset("u1")
+	verifyToken := verifyTokenRe.FindStringSubmatch(mailer.sent[0].Body)[1]
+	resetToken := resetTokenRe.FindStringSubmatch(mailer.sent[1].Body)[1]
+
+	if username, _ := a.ResetPassword(verifyToken, "P2"); username != "" {
+		t.Errorf("Accounts.ResetPassword() accepted a verification token")
+	}
+	if ok, _ := a.Verify(resetToken); ok {
+		t.Errorf("Accounts.Verify() accepted a password reset token")
+	}
+}
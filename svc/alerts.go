@@ -0,0 +1,203 @@
+package svc
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/google/uuid"
+)
+
+// period over which the change of price alerts is measured
+const alertChangePeriod = 24 * time.Hour
+
+// Alerts service which manages price alerts, evaluates them on cache refresh and keeps the notifications inbox
+type Alerts struct {
+	AlDB     alertsDB
+	NDB      notificationsDB
+	UDB      usersDB
+	ASvc     *Assets
+	Notifier notifier
+	Webhooks webhookChecker
+	Clock    clock.Clock
+}
+
+type alertsDB interface {
+	GetByUsername(username string) ([]model.PriceAlert, error)
+	GetActive() ([]model.PriceAlert, error)
+	GetByID(username, id string) (*model.PriceAlert, error)
+	Create(alert model.PriceAlert) (*model.PriceAlert, error)
+	UpdateState(alert model.PriceAlert) error
+	Delete(username, id string) (bool, error)
+}
+
+type notificationsDB interface {
+	GetByUsername(username string) ([]model.Notification, error)
+	MarkRead(username, id string) (bool, error)
+}
+
+type notifier interface {
+	Notify(alert model.PriceAlert, notification model.Notification) error
+}
+
+type webhookChecker interface {
+	// check that notifications can be posted to the webhook url target
+	CheckURL(target string) error
+}
+
+// Error returned when alert notifications can't be delivered to the target
+type AlertTargetError struct {
+	Reason string
+}
+
+func (e AlertTargetError) Error() string {
+	return e.Reason
+}
+
+// get price alerts of user
+func (a Alerts) GetByUsername(username string) ([]model.PriceAlert, error) {
+	return a.AlDB.GetByUsername(username)
+}
+
+// get price alert of user by id, nil if it doesn't exist
+func (a Alerts) GetByID(username, id string) (*model.PriceAlert, error) {
+	return a.AlDB.GetByID(username, id)
+}
+
+// create a price alert, the current price of the asset becomes its reference.
+// Email alerts are sent to the verified email of the user and webhooks only to public addresses.
+// Returns AssetNotFoundError if the asset is not in the cache or has no price and AlertTargetError if the target is not allowed
+func (a Alerts) Create(alert model.PriceAlert) (*model.PriceAlert, error) {
+	asset, err := a.ASvc.GetAssetById(alert.AssetId)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil || asset.PriceUSD <= 0 {
+		return nil, AssetNotFoundError{ID: alert.AssetId}
+	}
+
+	switch alert.Channel {
+	case model.ChannelEmail:
+		user, err := a.UDB.GetByUsername(alert.Username)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("cannot create alert, user with username %s doesn't exist", alert.Username)
+		}
+		if alert.Target != "" && !strings.EqualFold(alert.Target, user.Email) {
+			return nil, AlertTargetError{Reason: "email alerts can be sent only to the email of the user"}
+		}
+		if !user.EmailVerified {
+			return nil, AlertTargetError{Reason: "verify your email before creating email alerts"}
+		}
+		alert.Target = user.Email
+	case model.ChannelWebhook:
+		if err := a.Webhooks.CheckURL(alert.Target); err != nil {
+			return nil, AlertTargetError{Reason: err.Error()}
+		}
+	}
+
+	now := a.Clock.Now().UTC()
+	alert.ID = uuid.New().String()
+	alert.ReferencePrice = asset.PriceUSD
+	alert.ReferenceTime = now
+	alert.LastTriggered = nil
+	alert.Active = true
+	alert.Created = now
+	return a.AlDB.Create(alert)
+}
+
+// delete price alert of user, false if it doesn't exist
+func (a Alerts) Delete(username, id string) (bool, error) {
+	return a.AlDB.Delete(username, id)
+}
+
+// get the inbox of user
+func (a Alerts) GetNotifications(username string) ([]model.Notification, error) {
+	return a.NDB.GetByUsername(username)
+}
+
+// mark notification of user as read, false if it doesn't exist
+func (a Alerts) MarkNotificationRead(username, id string) (bool, error) {
+	return a.NDB.MarkRead(username, id)
+}
+
+// Evaluates all active alerts against the refreshed prices and notifies the owners of triggered ones.
+// Has the signature of a RefreshListener, but accesses the database, so it should be run asynchronously
+func (a Alerts) Evaluate(assets []coinapi.Asset, updated time.Time) {
+	alerts, err := a.AlDB.GetActive()
+	if err != nil {
+		log.Printf("Could not evaluate price alerts, %v", err)
+		return
+	}
+
+	prices := make(map[string]float64, len(assets))
+	for _, asset := range assets {
+		prices[asset.ID] = asset.PriceUSD
+	}
+
+	triggered := 0
+	for _, alert := range alerts {
+		// assets without a price are not evaluated
+		price, ok := prices[alert.AssetId]
+		if !ok || price <= 0 {
+			continue
+		}
+
+		if msg, ok := evaluateAlert(&alert, price, updated); ok {
+			triggered++
+			notification := model.Notification{ID: uuid.New().String(), Username: alert.Username, AlertID: alert.ID, Message: msg, Created: updated.UTC()}
+			if err := a.Notifier.Notify(alert, notification); err != nil {
+				log.Printf("Could not deliver notification of alert %s over %s, %v", alert.ID, alert.Channel, err)
+			}
+		}
+		if err := a.AlDB.UpdateState(alert); err != nil {
+			log.Printf("Could not update state of alert %s, %v", alert.ID, err)
+		}
+	}
+	log.Printf("Evaluated %d price alerts, triggered %d", len(alerts), triggered)
+}
+
+// checks alert against price at time now and advances its state.
+// Returns the notification message if the alert is triggered
+func evaluateAlert(alert *model.PriceAlert, price float64, now time.Time) (string, bool) {
+	var msg string
+	crossed := false
+	switch alert.Condition {
+	case model.AlertAbove:
+		crossed = alert.ReferencePrice < alert.Threshold && price >= alert.Threshold
+		msg = fmt.Sprintf("%s rose above %f USD, current price %f USD", alert.AssetId, alert.Threshold, price)
+	case model.AlertBelow:
+		crossed = alert.ReferencePrice > alert.Threshold && price <= alert.Threshold
+		msg = fmt.Sprintf("%s fell below %f USD, current price %f USD", alert.AssetId, alert.Threshold, price)
+	case model.AlertChange:
+		// the change can't be measured from a missing price, the current one becomes the reference
+		if alert.ReferencePrice <= 0 {
+			break
+		}
+		change := (price - alert.ReferencePrice) / alert.ReferencePrice * 100
+		crossed = math.Abs(change) >= alert.Threshold
+		msg = fmt.Sprintf("%s moved %+.2f%% since %s, current price %f USD", alert.AssetId, change, alert.ReferenceTime.UTC().Format(time.RFC3339), price)
+	}
+
+	coolingDown := alert.LastTriggered != nil && now.Before(alert.LastTriggered.Add(time.Duration(alert.CooldownMinutes)*time.Minute))
+	triggered := crossed && !coolingDown
+
+	// above and below compare against the previous price, change against the price at the start of the period
+	if alert.Condition != model.AlertChange || triggered || alert.ReferencePrice <= 0 || now.Sub(alert.ReferenceTime) >= alertChangePeriod {
+		alert.ReferencePrice = price
+		alert.ReferenceTime = now
+	}
+	if triggered {
+		triggeredAt := now
+		alert.LastTriggered = &triggeredAt
+		alert.Active = alert.Mode == model.AlertRepeat
+	}
+	return msg, triggered
+}
@@ -0,0 +1,182 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubAlDB struct {
+	alerts  []model.PriceAlert
+	err     error
+	updated []model.PriceAlert
+}
+
+func (s *stubAlDB) GetByUsername(username string) ([]model.PriceAlert, error) {
+	return s.alerts, s.err
+}
+func (s *stubAlDB) GetActive() ([]model.PriceAlert, error) {
+	return s.alerts, s.err
+}
+func (s *stubAlDB) GetByID(username, id string) (*model.PriceAlert, error) {
+	return nil, s.err
+}
+func (s *stubAlDB) Create(alert model.PriceAlert) (*model.PriceAlert, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &alert, nil
+}
+func (s *stubAlDB) UpdateState(alert model.PriceAlert) error {
+	s.updated = append(s.updated, alert)
+	return s.err
+}
+func (s *stubAlDB) Delete(username, id string) (bool, error) {
+	return false, s.err
+}
+
+type stubNotifier struct {
+	sent []model.Notification
+	err  error
+}
+
+func (s *stubNotifier) Notify(alert model.PriceAlert, notification model.Notification) error {
+	s.sent = append(s.sent, notification)
+	return s.err
+}
+
+type stubWebhooks struct {
+	err error
+}
+
+func (s stubWebhooks) CheckURL(target string) error {
+	return s.err
+}
+
+func TestAlerts_Create(t *testing.T) {
+	type fields struct {
+		client   coinAPIClient
+		udb      usersDB
+		webhooks webhookChecker
+	}
+	btc := coinapi.Asset{ID: "BTC", PriceUSD: 40000}
+	doge := coinapi.Asset{ID: "DOGE"}
+	assets := []coinapi.Asset{btc, doge}
+	user := model.User{Username: "user", Email: "user@mail.com", EmailVerified: true}
+	unverified := model.User{Username: "user", Email: "user@mail.com"}
+	hook := "https://hooks.example.com/alerts"
+	tests := []struct {
+		name          string
+		fields        fields
+		alert         model.PriceAlert
+		wantTarget    string
+		wantNotFound  bool
+		wantTargetErr bool
+		wantErr       bool
+	}{
+		{"inbox", fields{stubClient{assets, nil}, stubUDB{}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelInbox}, "", false, false, false},
+		{"email default target", fields{stubClient{assets, nil}, stubUDB{user: &user}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelEmail}, "user@mail.com", false, false, false},
+		{"email own target", fields{stubClient{assets, nil}, stubUDB{user: &user}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelEmail, Target: "USER@mail.com"}, "user@mail.com", false, false, false},
+		{"email other target", fields{stubClient{assets, nil}, stubUDB{user: &user}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelEmail, Target: "other@mail.com"}, "", false, true, true},
+		{"email not verified", fields{stubClient{assets, nil}, stubUDB{user: &unverified}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelEmail}, "", false, true, true},
+		{"email missing user", fields{stubClient{assets, nil}, stubUDB{}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelEmail}, "", false, false, true},
+		{"webhook allowed", fields{stubClient{assets, nil}, stubUDB{}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelWebhook, Target: hook}, hook, false, false, false},
+		{"webhook rejected", fields{stubClient{assets, nil}, stubUDB{}, stubWebhooks{fmt.Errorf("private address")}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelWebhook, Target: "http://127.0.0.1/"}, "", false, true, true},
+		{"missing asset", fields{stubClient{assets, nil}, stubUDB{}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "ETH", Channel: model.ChannelInbox}, "", true, false, true},
+		{"priceless asset", fields{stubClient{assets, nil}, stubUDB{}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "DOGE", Channel: model.ChannelInbox}, "", true, false, true},
+		{"cache update error", fields{stubClient{nil, fmt.Errorf("")}, stubUDB{}, stubWebhooks{}}, model.PriceAlert{Username: "user", AssetId: "BTC", Channel: model.ChannelInbox}, "", false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Alerts{AlDB: &stubAlDB{}, UDB: tt.fields.udb, ASvc: NewAssets(tt.fields.client), Webhooks: tt.fields.webhooks, Clock: clock.NewFake(testNow)}
+			got, err := a.Create(tt.alert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Alerts.Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if notFound := errors.As(err, &AssetNotFoundError{}); notFound != tt.wantNotFound {
+				t.Fatalf("Alerts.Create() not found error = %v, want %v", notFound, tt.wantNotFound)
+			}
+			if targetErr := errors.As(err, &AlertTargetError{}); targetErr != tt.wantTargetErr {
+				t.Fatalf("Alerts.Create() target error = %v, want %v", targetErr, tt.wantTargetErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.ID == "" || !got.Active || got.ReferencePrice != btc.PriceUSD || got.Target != tt.wantTarget || !got.Created.Equal(testNow) {
+				t.Errorf("Alerts.Create() = %+v", got)
+			}
+		})
+	}
+}
+
+func Test_evaluateAlert(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	recently := now.Add(-5 * time.Minute)
+	type want struct {
+		triggered      bool
+		active         bool
+		referencePrice float64
+	}
+	tests := []struct {
+		name  string
+		alert model.PriceAlert
+		price float64
+		want  want
+	}{
+		{"above crossed", model.PriceAlert{Condition: model.AlertAbove, Threshold: 100, Mode: model.AlertOnce, ReferencePrice: 90, ReferenceTime: now}, 105, want{true, false, 105}},
+		{"above already above", model.PriceAlert{Condition: model.AlertAbove, Threshold: 100, Mode: model.AlertOnce, ReferencePrice: 101, ReferenceTime: now}, 105, want{false, true, 105}},
+		{"above not reached", model.PriceAlert{Condition: model.AlertAbove, Threshold: 100, Mode: model.AlertOnce, ReferencePrice: 90, ReferenceTime: now}, 95, want{false, true, 95}},
+		{"below crossed repeat", model.PriceAlert{Condition: model.AlertBelow, Threshold: 100, Mode: model.AlertRepeat, ReferencePrice: 110, ReferenceTime: now}, 100, want{true, true, 100}},
+		{"below crossed cooling down", model.PriceAlert{Condition: model.AlertBelow, Threshold: 100, Mode: model.AlertRepeat, CooldownMinutes: 10, ReferencePrice: 110, ReferenceTime: now, LastTriggered: &recently}, 99, want{false, true, 99}},
+		{"below crossed after cooldown", model.PriceAlert{Condition: model.AlertBelow, Threshold: 100, Mode: model.AlertRepeat, CooldownMinutes: 1, ReferencePrice: 110, ReferenceTime: now, LastTriggered: &recently}, 99, want{true, true, 99}},
+		{"change up", model.PriceAlert{Condition: model.AlertChange, Threshold: 10, Mode: model.AlertOnce, ReferencePrice: 100, ReferenceTime: now.Add(-time.Hour)}, 111, want{true, false, 111}},
+		{"change down", model.PriceAlert{Condition: model.AlertChange, Threshold: 10, Mode: model.AlertRepeat, ReferencePrice: 100, ReferenceTime: now.Add(-time.Hour)}, 90, want{true, true, 90}},
+		{"change too small keeps reference", model.PriceAlert{Condition: model.AlertChange, Threshold: 10, Mode: model.AlertOnce, ReferencePrice: 100, ReferenceTime: now.Add(-time.Hour)}, 105, want{false, true, 100}},
+		{"change without reference takes the price", model.PriceAlert{Condition: model.AlertChange, Threshold: 10, Mode: model.AlertOnce, ReferenceTime: now.Add(-time.Hour)}, 105, want{false, true, 105}},
+		{"change period passed resets reference", model.PriceAlert{Condition: model.AlertChange, Threshold: 10, Mode: model.AlertOnce, ReferencePrice: 100, ReferenceTime: now.Add(-25 * time.Hour)}, 105, want{false, true, 105}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alert := tt.alert
+			alert.Active = true
+			msg, triggered := evaluateAlert(&alert, tt.price, now)
+			got := want{triggered, alert.Active, alert.ReferencePrice}
+			if got != tt.want {
+				t.Fatalf("evaluateAlert() = %+v, want %+v", got, tt.want)
+			}
+			if triggered && (msg == "" || alert.LastTriggered == nil || !alert.LastTriggered.Equal(now)) {
+				t.Errorf("evaluateAlert() message %q, last triggered %v", msg, alert.LastTriggered)
+			}
+		})
+	}
+}
+
+func TestAlerts_Evaluate(t *testing.T) {
+	now := testNow
+	alerts := []model.PriceAlert{
+		{ID: "1", Username: "user", AssetId: "BTC", Condition: model.AlertAbove, Threshold: 100, Mode: model.AlertOnce, ReferencePrice: 90, ReferenceTime: now, Active: true},
+		{ID: "2", Username: "user", AssetId: "BTC", Condition: model.AlertBelow, Threshold: 50, Mode: model.AlertOnce, ReferencePrice: 90, ReferenceTime: now, Active: true},
+		{ID: "3", Username: "user", AssetId: "ETH", Condition: model.AlertAbove, Threshold: 100, Mode: model.AlertOnce, ReferencePrice: 90, ReferenceTime: now, Active: true},
+	}
+	alDB := &stubAlDB{alerts: alerts}
+	n := &stubNotifier{err: fmt.Errorf("delivery failed")}
+	a := Alerts{AlDB: alDB, Notifier: n}
+
+	a.Evaluate([]coinapi.Asset{{ID: "BTC", PriceUSD: 120}}, now)
+
+	if len(n.sent) != 1 || n.sent[0].AlertID != "1" || n.sent[0].Username != "user" {
+		t.Fatalf("Alerts.Evaluate() sent notifications %+v", n.sent)
+	}
+	// alerts of assets without price are not touched
+	if len(alDB.updated) != 2 {
+		t.Fatalf("Alerts.Evaluate() updated %d alerts, want 2", len(alDB.updated))
+	}
+	if alDB.updated[0].Active || !alDB.updated[1].Active {
+		t.Errorf("Alerts.Evaluate() updated alerts %+v", alDB.updated)
+	}
+}
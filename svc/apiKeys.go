@@ -0,0 +1,193 @@
+package svc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// prefix of every api key, tells keys apart from other tokens
+	apiKeyPrefix = "cm_"
+	// random bytes of an api key
+	apiKeyBytes = 32
+	// characters of the key shown when api keys are listed
+	apiKeyShownLength = 10
+)
+
+// Api keys service which creates personal api keys and authenticates requests with them
+type APIKeys struct {
+	KDB apiKeysDB
+	// encrypts the signing secrets, keys have no secret if nil
+	Secrets secretBox
+	Clock   clock.Clock
+}
+
+type secretBox interface {
+	// encrypts the secret to be stored
+	Seal(secret string) (string, error)
+	// decrypts a stored secret
+	Open(sealed string) (string, error)
+}
+
+type apiKeysDB interface {
+	GetByUsername(username string) ([]model.APIKey, error)
+	GetByHash(hash string) (*model.APIKey, error)
+	GetByID(id string) (*model.APIKey, error)
+	Create(key model.APIKey) (*model.APIKey, error)
+	Delete(username, id string) (bool, error)
+	DeleteByUsername(username string) (int, error)
+	UpdateLastUsed(id string, used time.Time) error
+}
+
+// Error when an api key is created with expiry in the past
+type APIKeyExpiredError struct {
+	Expires time.Time
+}
+
+func (e APIKeyExpiredError) Error() string {
+	return fmt.Sprintf("api key expiry %v is in the past", e.Expires)
+}
+
+// get api keys of user, without the keys themselves and their secrets
+func (a APIKeys) GetByUsername(username string) ([]model.APIKey, error) {
+	keys, err := a.KDB.GetByUsername(username)
+	for i := range keys {
+		keys[i].Secret = ""
+	}
+	return keys, err
+}
+
+// create an api key, only its hash is stored so the key is returned only here.
+// Returns APIKeyExpiredError if the expiry is in the past
+func (a APIKeys) Create(key model.APIKey) (*model.CreatedAPIKey, error) {
+	now := a.Clock.Now().UTC()
+	if key.IsExpired(now) {
+		return nil, APIKeyExpiredError{Expires: *key.Expires}
+	}
+
+	random, err := randomString()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate api key, %v", err)
+	}
+	plain := apiKeyPrefix + random
+	secret, sealed, err := a.newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	key.ID = uuid.New().String()
+	key.Prefix = plain[:apiKeyShownLength]
+	key.Hash = hashToken(plain)
+	key.Secret = sealed
+	key.Created = now
+	key.LastUsed = nil
+	created, err := a.KDB.Create(key)
+	if err != nil {
+		return nil, err
+	}
+	created.Secret = ""
+	return &model.CreatedAPIKey{APIKey: *created, Key: plain, Secret: secret}, nil
+}
+
+// generates a signing secret and encrypts it, both are empty if signed requests are disabled
+func (a APIKeys) newSecret() (string, string, error) {
+	if a.Secrets == nil {
+		return "", "", nil
+	}
+	secret, err := randomString()
+	if err != nil {
+		return "", "", fmt.Errorf("could not generate api key secret, %v", err)
+	}
+	sealed, err := a.Secrets.Seal(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("could not encrypt api key secret, %v", err)
+	}
+	return secret, sealed, nil
+}
+
+// revoke api key of user, false if it doesn't exist
+func (a APIKeys) Delete(username, id string) (bool, error) {
+	return a.KDB.Delete(username, id)
+}
+
+// revoke all api keys of user, e.g. when their password changes, and get how many there were
+func (a APIKeys) RevokeAll(username string) (int, error) {
+	return a.KDB.DeleteByUsername(username)
+}
+
+// Gets the api key of the plain key and records its use.
+// Returns nil if there is no such key or it is expired
+func (a APIKeys) Authenticate(plain string) (*model.APIKey, error) {
+	if !strings.HasPrefix(plain, apiKeyPrefix) {
+		return nil, nil
+	}
+	key, err := a.KDB.GetByHash(hashToken(plain))
+	if err != nil || key == nil {
+		return nil, err
+	}
+	key.Secret = ""
+	return a.use(key), nil
+}
+
+// Gets the api key with id, whose decrypted secret verifies signed requests, and records its use.
+// The secret is empty if it can't be decrypted, e.g. the key was created without one
+// Returns nil if there is no such key or it is expired
+func (a APIKeys) AuthenticateID(id string) (*model.APIKey, error) {
+	key, err := a.KDB.GetByID(id)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	key.Secret = a.openSecret(*key)
+	return a.use(key), nil
+}
+
+func (a APIKeys) openSecret(key model.APIKey) string {
+	if a.Secrets == nil || key.Secret == "" {
+		return ""
+	}
+	secret, err := a.Secrets.Open(key.Secret)
+	if err != nil {
+		log.Printf("Could not decrypt secret of api key %s, %v", key.ID, err)
+		return ""
+	}
+	return secret
+}
+
+// records the use of key, nil if it is expired
+func (a APIKeys) use(key *model.APIKey) *model.APIKey {
+	now := a.Clock.Now().UTC()
+	if key.IsExpired(now) {
+		return nil
+	}
+	go func() {
+		if err := a.KDB.UpdateLastUsed(key.ID, now); err != nil {
+			log.Printf("Could not record use of api key %s, %v", key.ID, err)
+		}
+	}()
+	return key
+}
+
+// random url safe string of apiKeyBytes bytes
+func randomString() (string, error) {
+	random := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// api keys and email tokens are long and random, so a fast hash is enough and lets them be looked up by it
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,211 @@
+package svc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubKDB struct {
+	keys map[string]model.APIKey
+	err  error
+	used chan string
+}
+
+func (s stubKDB) GetByUsername(username string) ([]model.APIKey, error) {
+	keys := []model.APIKey{}
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, s.err
+}
+func (s stubKDB) GetByHash(hash string) (*model.APIKey, error) {
+	key, ok := s.keys[hash]
+	if !ok {
+		return nil, s.err
+	}
+	return &key, s.err
+}
+func (s stubKDB) GetByID(id string) (*model.APIKey, error) {
+	for _, key := range s.keys {
+		if key.ID == id {
+			return &key, s.err
+		}
+	}
+	return nil, s.err
+}
+func (s stubKDB) Create(key model.APIKey) (*model.APIKey, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.keys[key.Hash] = key
+	return &key, nil
+}
+func (s stubKDB) Delete(username, id string) (bool, error) {
+	return false, s.err
+}
+func (s stubKDB) DeleteByUsername(username string) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	deleted := 0
+	for hash, key := range s.keys {
+		if key.Username == username {
+			delete(s.keys, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+func (s stubKDB) UpdateLastUsed(id string, used time.Time) error {
+	s.used <- id
+	return s.err
+}
+
+// encrypts by prefixing the secret
+type stubBox struct{}
+
+func (stubBox) Seal(secret string) (string, error) {
+	return "sealed:" + secret, nil
+}
+func (stubBox) Open(sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, "sealed:") {
+		return "", fmt.Errorf("not sealed")
+	}
+	return strings.TrimPrefix(sealed, "sealed:"), nil
+}
+
+func TestAPIKeys_CreateAndAuthenticate(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	kdb := stubKDB{keys: map[string]model.APIKey{}, used: make(chan string, 1)}
+	a := APIKeys{KDB: kdb, Secrets: stubBox{}, Clock: clk}
+
+	expires := testNow.Add(time.Hour)
+	created, err := a.Create(model.APIKey{Username: "u1", Name: "bot", Scopes: []string{model.ScopeRead}, Expires: &expires})
+	if err != nil {
+		t.Fatalf("APIKeys.Create() error = %v", err)
+	}
+	if created.Secret == "" || created.APIKey.Secret != "" {
+		t.Fatalf("APIKeys.Create() = %+v, want a signing secret returned once", created)
+	}
+	if !strings.HasPrefix(created.Key, apiKeyPrefix) || !strings.HasPrefix(created.Key, created.Prefix) || created.Hash == created.Key {
+		t.Fatalf("APIKeys.Create() = %+v, want a prefixed key stored hashed", created)
+	}
+	for _, stored := range kdb.keys {
+		if strings.Contains(stored.Hash, created.Key) {
+			t.Fatalf("APIKeys.Create() stored the key itself")
+		}
+		if stored.Secret != "sealed:"+created.Secret {
+			t.Fatalf("APIKeys.Create() stored secret %s, want it encrypted", stored.Secret)
+		}
+	}
+	keys, err := a.GetByUsername("u1")
+	if err != nil || len(keys) != 1 || keys[0].Secret != "" {
+		t.Fatalf("APIKeys.GetByUsername() = %+v, %v, want the key without its secret", keys, err)
+	}
+
+	key, err := a.Authenticate(created.Key)
+	if err != nil || key == nil || key.Username != "u1" || key.Secret != "" {
+		t.Fatalf("APIKeys.Authenticate() = %+v, %v, want the key of u1 without its secret", key, err)
+	}
+	if id := <-kdb.used; id != created.ID {
+		t.Errorf("APIKeys.Authenticate() recorded use of %s, want %s", id, created.ID)
+	}
+
+	key, err = a.AuthenticateID(created.ID)
+	if err != nil || key == nil || key.Secret != created.Secret {
+		t.Fatalf("APIKeys.AuthenticateID() = %+v, %v, want the key with its secret", key, err)
+	}
+	<-kdb.used
+	if key, _ := a.AuthenticateID("unknown"); key != nil {
+		t.Errorf("APIKeys.AuthenticateID() accepted an unknown id")
+	}
+
+	if key, _ := a.Authenticate(created.Key + "x"); key != nil {
+		t.Errorf("APIKeys.Authenticate() accepted an unknown key")
+	}
+	if key, _ := a.Authenticate("Bearer"); key != nil {
+		t.Errorf("APIKeys.Authenticate() accepted a key without prefix")
+	}
+	clk.Advance(time.Hour)
+	if key, _ := a.Authenticate(created.Key); key != nil {
+		t.Errorf("APIKeys.Authenticate() accepted an expired key")
+	}
+	if key, _ := a.AuthenticateID(created.ID); key != nil {
+		t.Errorf("APIKeys.AuthenticateID() accepted an expired key")
+	}
+}
+
+func TestAPIKeys_Create_Errors(t *testing.T) {
+	past := testNow.Add(-time.Second)
+	tests := []struct {
+		name    string
+		kdb     stubKDB
+		key     model.APIKey
+		wantErr error
+	}{
+		{"expiry in the past", stubKDB{keys: map[string]model.APIKey{}}, model.APIKey{Name: "bot", Expires: &past}, APIKeyExpiredError{Expires: past}},
+		{"db error", stubKDB{keys: map[string]model.APIKey{}, err: fmt.Errorf("db")}, model.APIKey{Name: "bot"}, fmt.Errorf("db")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := APIKeys{KDB: tt.kdb, Clock: clock.NewFake(testNow)}
+			if _, err := a.Create(tt.key); err == nil || err.Error() != tt.wantErr.Error() {
+				t.Errorf("APIKeys.Create() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIKeys_AuthenticateID_Secret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets secretBox
+		stored  string
+		want    string
+	}{
+		{"encrypted", stubBox{}, "sealed:s1", "s1"},
+		{"not encrypted", stubBox{}, "s1", ""},
+		{"created without secret", stubBox{}, "", ""},
+		{"signing disabled", nil, "sealed:s1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kdb := stubKDB{keys: map[string]model.APIKey{"hash": {ID: "1", Username: "u1", Secret: tt.stored}}, used: make(chan string, 1)}
+			a := APIKeys{KDB: kdb, Secrets: tt.secrets, Clock: clock.NewFake(testNow)}
+			key, err := a.AuthenticateID("1")
+			if err != nil || key == nil || key.Secret != tt.want {
+				t.Fatalf("APIKeys.AuthenticateID() = %+v, %v, want secret %q", key, err, tt.want)
+			}
+			<-kdb.used
+		})
+	}
+}
+
+func TestAPIKeys_Create_SigningDisabled(t *testing.T) {
+	kdb := stubKDB{keys: map[string]model.APIKey{}}
+	a := APIKeys{KDB: kdb, Clock: clock.NewFake(testNow)}
+	created, err := a.Create(model.APIKey{Username: "u1", Name: "bot", Scopes: []string{model.ScopeRead}})
+	if err != nil || created.Key == "" || created.Secret != "" {
+		t.Fatalf("APIKeys.Create() = %+v, %v, want a key without signing secret", created, err)
+	}
+	if stored := kdb.keys[created.Hash]; stored.Secret != "" {
+		t.Errorf("APIKeys.Create() stored secret %s, want none", stored.Secret)
+	}
+}
+
+func TestAPIKeys_RevokeAll(t *testing.T) {
+	kdb := stubKDB{keys: map[string]model.APIKey{"h1": {ID: "1", Username: "u1"}, "h2": {ID: "2", Username: "u1"}, "h3": {ID: "3", Username: "u2"}}}
+	a := APIKeys{KDB: kdb, Clock: clock.NewFake(testNow)}
+	revoked, err := a.RevokeAll("u1")
+	if err != nil || revoked != 2 {
+		t.Fatalf("APIKeys.RevokeAll() = %d, %v, want 2", revoked, err)
+	}
+	if _, ok := kdb.keys["h3"]; !ok || len(kdb.keys) != 1 {
+		t.Errorf("APIKeys.RevokeAll() left keys %v, want only the key of u2", kdb.keys)
+	}
+}
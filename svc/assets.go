@@ -3,33 +3,146 @@ package svc
 import (
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/MonikaPalova/currency-master/clock"
 	"github.com/MonikaPalova/currency-master/coinapi"
 	"github.com/MonikaPalova/currency-master/model"
 )
 
-// Assets service which handles assets retrieval from cache and external api
+// Assets service which handles assets retrieval from cache and external api or another price provider
 type Assets struct {
-	cache  *coinapi.Cache
-	client coinAPIClient
+	cache     *coinapi.Cache
+	client    coinAPIClient
+	refreshMu *sync.Mutex
+	listeners []RefreshListener
+	// last known prices used when an asset has no current price, nil if they are not kept
+	lastPrices lastPricesDB
+	// quotes bid and ask prices of fetched assets, they trade at the mid price if nil
+	spread quoter
+	// clock of the cache, tells the age of the prices
+	clock clock.Clock
 }
 
 type coinAPIClient interface {
 	GetAssets() ([]coinapi.Asset, error)
 }
 
+type quoter interface {
+	Quote(assets []coinapi.Asset)
+}
+
+type lastPricesDB interface {
+	GetByID(id string) (*model.LastPrice, error)
+	Save(prices []model.LastPrice) error
+}
+
+// Function called with the new assets every time the cache is refreshed
+type RefreshListener func(assets []coinapi.Asset, updated time.Time)
+
 // Constructor
 func NewAssets(client coinAPIClient) *Assets {
-	return &Assets{cache: coinapi.NewCache(), client: client}
+	return NewAssetsWithCache(client, coinapi.NewCache(), clock.Real{})
+}
+
+// Constructor with a configured cache and the clock it was created with
+func NewAssetsWithCache(client coinAPIClient, cache *coinapi.Cache, clk clock.Clock) *Assets {
+	return &Assets{cache: cache, client: client, refreshMu: &sync.Mutex{}, clock: clk}
+}
+
+// Registers a listener for cache refreshes.
+// Listeners are called synchronously, so they should not block
+func (a *Assets) OnRefresh(listener RefreshListener) {
+	a.listeners = append(a.listeners, listener)
+}
+
+// Quotes bid and ask prices of the fetched assets with spread
+func (a *Assets) UseSpread(spread quoter) {
+	a.spread = spread
+}
+
+// Keeps the prices of every cache refresh in db, so valuation can fall back to them
+// when an asset is delisted or the price provider is down
+func (a *Assets) KeepLastPrices(db lastPricesDB) {
+	a.lastPrices = db
+	a.OnRefresh(func(assets []coinapi.Asset, updated time.Time) { go a.saveLastPrices(assets, updated) })
+}
+
+// saves the prices of the priced assets, an asset listed without a price keeps its last known one
+func (a Assets) saveLastPrices(assets []coinapi.Asset, updated time.Time) {
+	prices := make([]model.LastPrice, 0, len(assets))
+	for _, asset := range assets {
+		if !listed(&asset) {
+			continue
+		}
+		prices = append(prices, model.LastPrice{AssetId: asset.ID, Name: asset.Name, PriceUSD: asset.PriceUSD, BidUSD: asset.Bid(), Updated: updated.UTC()})
+	}
+	if err := a.lastPrices.Save(prices); err != nil {
+		log.Printf("Could not save last known prices, %v", err)
+	}
+}
+
+// Gets the last known price of asset, nil if it is unknown or last prices are not kept
+func (a Assets) LastPrice(id string) *model.LastPrice {
+	if a.lastPrices == nil {
+		return nil
+	}
+	price, err := a.lastPrices.GetByID(id)
+	if err != nil {
+		log.Printf("Could not retrieve last known price of asset %s, %v", id, err)
+		return nil
+	}
+	return price
+}
+
+// Loads the snapshot at path into the cache if it is not older than maxAge and saves a new snapshot on every refresh.
+// The loaded assets are served until a refresh succeeds or they get older than maxAge
+func (a *Assets) KeepSnapshot(path string, maxAge time.Duration) {
+	snapshot, err := coinapi.LoadSnapshot(path)
+	switch {
+	case err != nil:
+		log.Printf("Could not load cache snapshot, %v", err)
+	case snapshot == nil:
+		log.Printf("There is no cache snapshot at %s", path)
+	case a.cache.Restore(*snapshot, maxAge):
+		log.Printf("Loaded cache snapshot of %d assets fetched at %v", len(snapshot.Assets), snapshot.Updated)
+	default:
+		log.Printf("Cache snapshot fetched at %v is older than %v, not loaded", snapshot.Updated, maxAge)
+	}
+
+	saveMu := &sync.Mutex{}
+	a.OnRefresh(func(assets []coinapi.Asset, updated time.Time) {
+		go func() {
+			saveMu.Lock()
+			defer saveMu.Unlock()
+			if err := coinapi.SaveSnapshot(path, coinapi.Snapshot{Updated: updated, Assets: assets}); err != nil {
+				log.Printf("Could not save cache snapshot, %v", err)
+			}
+		}()
+	})
+}
+
+// Refreshes the cache from the external api if it is expired or serves a snapshot
+func (a Assets) Refresh() error {
+	if !a.cache.NeedsRefresh() {
+		return nil
+	}
+	return a.updateCache(false)
 }
 
-// Gets a specific asset page
-func (a Assets) GetAssetPage(page, size int) (*coinapi.AssetPage, error) {
+// Refreshes the cache from the external api even if it is not expired
+func (a Assets) Reload() error {
+	return a.updateCache(true)
+}
+
+// Gets a specific page of the assets matching the query
+func (a Assets) GetAssetPage(query coinapi.AssetQuery, page, size int) (*coinapi.AssetPage, error) {
 	if err := a.updateCacheIfNeeded(); err != nil {
 		return nil, err
 	}
 
-	assetsPage := a.cache.GetPage(page, size)
+	assetsPage := a.cache.Find(query, page, size)
 	return &assetsPage, nil
 }
 
@@ -43,27 +156,69 @@ func (a Assets) GetAssetById(id string) (*coinapi.Asset, error) {
 }
 
 func (a Assets) updateCacheIfNeeded() error {
-	if a.cache.IsExpired() {
-		assets, err := a.client.GetAssets()
-		if err != nil {
-			return fmt.Errorf("error retrieving assets from external api: %v", err)
-		}
-		a.cache.Fill(assets)
-		log.Println("Updated cache")
+	if !a.cache.IsExpired() {
+		return nil
+	}
+	return a.updateCache(false)
+}
+
+func (a Assets) updateCache(force bool) error {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+	// another request could have refreshed the cache while waiting for the lock
+	if !force && !a.cache.NeedsRefresh() {
+		return nil
 	}
 
+	assets, err := a.client.GetAssets()
+	if err != nil {
+		return fmt.Errorf("error retrieving assets from external api: %v", err)
+	}
+	if a.spread != nil {
+		a.spread.Quote(assets)
+	}
+	a.cache.Fill(assets)
+	log.Println("Updated cache")
+
+	updated := a.cache.Updated()
+	for _, listener := range a.listeners {
+		listener(assets, updated)
+	}
 	return nil
 }
 
-// Calculates the gain if all quantity is sold now
-func (a Assets) Valuate(ua model.UserAsset) (float64, error) {
-	asset, err := a.GetAssetById(ua.AssetId)
-	if err != nil {
-		return -1, err
+// Calculates the gain if all quantity is sold now at the bid price.
+// An asset without current price is valuated with its last known bid and marked as stale or delisted,
+// its valuation is 0 if the price was never known
+func (a Assets) Valuate(ua model.UserAsset) model.UserAsset {
+	return a.valuate(ua, a.updateCacheIfNeeded())
+}
+
+// valuates ua with the cached prices, refreshErr is the error of refreshing them before the valuation.
+// Valuations of many assets refresh once, so a price provider which is down is not called for every asset
+func (a Assets) valuate(ua model.UserAsset, refreshErr error) model.UserAsset {
+	var asset *coinapi.Asset
+	if refreshErr == nil {
+		asset = a.cache.GetAsset(ua.AssetId)
+	}
+	if listed(asset) {
+		ua.Valuation = asset.Bid() * ua.Quantity
+		return ua
 	}
-	if asset == nil {
-		return -1, fmt.Errorf("there is no asset with id %s", ua.AssetId)
+
+	ua.PriceStatus = model.PriceDelisted
+	if refreshErr != nil {
+		ua.PriceStatus = model.PriceStale
+	}
+	ua.Valuation = 0
+	if last := a.LastPrice(ua.AssetId); last != nil {
+		ua.Valuation = last.Bid() * ua.Quantity
+		ua.PriceUpdated = &last.Updated
 	}
+	return ua
+}
 
-	return asset.PriceUSD * ua.Quantity, nil
+// whether the price provider lists asset with a price, assets listed without one can't be traded or valuated
+func listed(asset *coinapi.Asset) bool {
+	return asset != nil && asset.PriceUSD > 0
 }
@@ -3,8 +3,10 @@ package svc
 
 import (
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/MonikaPalova/currency-master/coinapi"
 	"github.com/MonikaPalova/currency-master/model"
@@ -42,7 +44,7 @@ func TestAssets_GetAssetPage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := NewAssets(tt.fields.client)
-			got, err := a.GetAssetPage(tt.args.page, tt.args.size)
+			got, err := a.GetAssetPage(coinapi.AssetQuery{}, tt.args.page, tt.args.size)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Assets.GetAssetPage() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -88,35 +90,160 @@ func TestAssets_GetAssetById(t *testing.T) {
 	}
 }
 
+type stubLpDB struct {
+	prices map[string]model.LastPrice
+	err    error
+	// prices passed to Save, not recorded if nil
+	saved *[]model.LastPrice
+}
+
+func (s stubLpDB) GetByID(id string) (*model.LastPrice, error) {
+	price, ok := s.prices[id]
+	if s.err != nil || !ok {
+		return nil, s.err
+	}
+	return &price, nil
+}
+func (s stubLpDB) Save(prices []model.LastPrice) error {
+	if s.saved != nil {
+		*s.saved = append(*s.saved, prices...)
+	}
+	return s.err
+}
+
+// price provider which counts its calls
+type countingClient struct {
+	calls *int
+	err   error
+}
+
+func (c countingClient) GetAssets() ([]coinapi.Asset, error) {
+	*c.calls++
+	return []coinapi.Asset{}, c.err
+}
+
 func TestAssets_Valuate(t *testing.T) {
 	type fields struct {
-		client coinAPIClient
+		client     coinAPIClient
+		lastPrices lastPricesDB
 	}
 	type args struct {
 		ua model.UserAsset
 	}
+	lastUpdated := testNow.Add(-time.Hour)
+	lastPrices := stubLpDB{prices: map[string]model.LastPrice{"id3": {AssetId: "id3", PriceUSD: 0.5, Updated: lastUpdated}, "id1": {AssetId: "id1", PriceUSD: 0.02, Updated: lastUpdated},
+		"id5": {AssetId: "id5", PriceUSD: 0.5, BidUSD: 0.4, Updated: lastUpdated}}}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    float64
-		wantErr bool
+		name   string
+		fields fields
+		args   args
+		want   model.UserAsset
 	}{
-		{"no such asset", fields{stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 0.01}}, nil}}, args{model.UserAsset{AssetId: "id3", Quantity: 2}}, -1, true},
-		{"valid asset", fields{stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 0.01}}, nil}}, args{model.UserAsset{AssetId: "id1", Quantity: 2}}, 0.02, false},
-		{"cache update error", fields{stubClient{[]coinapi.Asset{}, fmt.Errorf("")}}, args{model.UserAsset{AssetId: "id1", Quantity: 2}}, -1, true},
+		{"valid asset", fields{stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 0.01}}, nil}, lastPrices}, args{model.UserAsset{AssetId: "id1", Quantity: 2}}, model.UserAsset{AssetId: "id1", Quantity: 2, Valuation: 0.02}},
+		{"delisted asset", fields{stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 0.01}}, nil}, lastPrices}, args{model.UserAsset{AssetId: "id3", Quantity: 2}}, model.UserAsset{AssetId: "id3", Quantity: 2, Valuation: 1, PriceStatus: model.PriceDelisted, PriceUpdated: &lastUpdated}},
+		{"never priced asset", fields{stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 0.01}}, nil}, lastPrices}, args{model.UserAsset{AssetId: "id4", Quantity: 2}}, model.UserAsset{AssetId: "id4", Quantity: 2, PriceStatus: model.PriceDelisted}},
+		{"last prices not kept", fields{stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 0.01}}, nil}, nil}, args{model.UserAsset{AssetId: "id3", Quantity: 2}}, model.UserAsset{AssetId: "id3", Quantity: 2, PriceStatus: model.PriceDelisted}},
+		{"last prices db error", fields{stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 0.01}}, nil}, stubLpDB{err: fmt.Errorf("")}}, args{model.UserAsset{AssetId: "id3", Quantity: 2}}, model.UserAsset{AssetId: "id3", Quantity: 2, PriceStatus: model.PriceDelisted}},
+		{"cache update error", fields{stubClient{[]coinapi.Asset{}, fmt.Errorf("")}, lastPrices}, args{model.UserAsset{AssetId: "id1", Quantity: 2}}, model.UserAsset{AssetId: "id1", Quantity: 2, Valuation: 0.04, PriceStatus: model.PriceStale, PriceUpdated: &lastUpdated}},
+		{"listed without price", fields{stubClient{[]coinapi.Asset{{ID: "id3"}}, nil}, lastPrices}, args{model.UserAsset{AssetId: "id3", Quantity: 2}}, model.UserAsset{AssetId: "id3", Quantity: 2, Valuation: 1, PriceStatus: model.PriceDelisted, PriceUpdated: &lastUpdated}},
+		{"delisted at the last bid", fields{stubClient{[]coinapi.Asset{}, nil}, lastPrices}, args{model.UserAsset{AssetId: "id5", Quantity: 2}}, model.UserAsset{AssetId: "id5", Quantity: 2, Valuation: 0.8, PriceStatus: model.PriceDelisted, PriceUpdated: &lastUpdated}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := NewAssets(tt.fields.client)
-			got, err := a.Valuate(tt.args.ua)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Assets.Valuate() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			if tt.fields.lastPrices != nil {
+				a.KeepLastPrices(tt.fields.lastPrices)
 			}
-			if got != tt.want {
-				t.Errorf("Assets.Valuate() = %v, want %v", got, tt.want)
+			if got := a.Valuate(tt.args.ua); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Assets.Valuate() = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestAssets_saveLastPrices(t *testing.T) {
+	saved := []model.LastPrice{}
+	a := NewAssets(stubClient{})
+	a.UseSpread(stubQuoter{})
+	a.KeepLastPrices(stubLpDB{saved: &saved})
+
+	assets := []coinapi.Asset{{ID: "BTC", Name: "Bitcoin", PriceUSD: 40000, BidUSD: 39000}, {ID: "LUNA", Name: "Terra"}}
+	a.saveLastPrices(assets, testNow)
+	want := []model.LastPrice{{AssetId: "BTC", Name: "Bitcoin", PriceUSD: 40000, BidUSD: 39000, Updated: testNow}}
+	if !reflect.DeepEqual(saved, want) {
+		t.Errorf("Assets.saveLastPrices() saved %+v, want %+v without the asset without price", saved, want)
+	}
+}
+
+func TestValuator_RefreshesOnce(t *testing.T) {
+	calls := 0
+	v := valuator{svc: NewAssets(countingClient{calls: &calls, err: fmt.Errorf("down")})}
+	holdings := []model.UserAsset{{AssetId: "BTC", Quantity: 1}, {AssetId: "ETH", Quantity: 1}, {AssetId: "DOGE", Quantity: 1}}
+	users := v.valUsers([]model.User{{Username: "u1", Assets: holdings}, {Username: "u2", Assets: holdings}})
+
+	if calls != 1 {
+		t.Errorf("valuator.valUsers() called the price provider %d times, want once", calls)
+	}
+	if users[1].Assets[2].PriceStatus != model.PriceStale {
+		t.Errorf("valuator.valUsers() = %+v, want stale prices", users[1].Assets)
+	}
+}
+
+func TestAssets_KeepSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshot := coinapi.Snapshot{Updated: time.Now().Add(-time.Hour), Assets: []coinapi.Asset{{ID: "BTC", PriceUSD: 44000}}}
+	if err := coinapi.SaveSnapshot(path, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	// provider is down after the restart, the snapshot is served
+	down := NewAssets(stubClient{nil, fmt.Errorf("down")})
+	down.KeepSnapshot(path, 24*time.Hour)
+	if err := down.Refresh(); err == nil {
+		t.Error("Assets.Refresh() expected error when the provider is down")
+	}
+	if got, err := down.GetAssetById("BTC"); err != nil || got == nil || got.PriceUSD != 44000 {
+		t.Fatalf("Assets.GetAssetById() = %v, %v, want the snapshot price", got, err)
+	}
+
+	// provider is up, the refresh replaces the snapshot on disk
+	up := NewAssets(stubClient{[]coinapi.Asset{{ID: "BTC", PriceUSD: 45000}}, nil})
+	up.KeepSnapshot(path, 24*time.Hour)
+	if err := up.Refresh(); err != nil {
+		t.Fatalf("Assets.Refresh() error = %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		saved, err := coinapi.LoadSnapshot(path)
+		if err == nil && saved != nil && len(saved.Assets) == 1 && saved.Assets[0].PriceUSD == 45000 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshot was not saved after refresh, got %v, %v", saved, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type stubQuoter struct{}
+
+func (stubQuoter) Quote(assets []coinapi.Asset) {
+	for i := range assets {
+		assets[i].BidUSD = assets[i].PriceUSD * 0.9
+		assets[i].AskUSD = assets[i].PriceUSD * 1.1
+	}
+}
+
+func TestAssets_UseSpread(t *testing.T) {
+	a := NewAssets(stubClient{[]coinapi.Asset{{ID: "id1", PriceUSD: 10}}, nil})
+	a.UseSpread(stubQuoter{})
+
+	asset, err := a.GetAssetById("id1")
+	if err != nil || asset == nil || asset.BidUSD != 9 || asset.AskUSD != 11 {
+		t.Fatalf("Assets.GetAssetById() = %+v, %v, want quoted bid and ask", asset, err)
+	}
+	// holders get the bid if they sell now
+	if got := a.Valuate(model.UserAsset{AssetId: "id1", Quantity: 2}); got.Valuation != 18 {
+		t.Errorf("Assets.Valuate() = %v, want valuation at the bid 18", got.Valuation)
+	}
+}
@@ -0,0 +1,94 @@
+package svc
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// Price history service which stores downsampled snapshots of the fetched prices and finds past prices in them
+type PriceHistory struct {
+	DB     priceHistoryDB
+	Config *config.History
+
+	mu sync.Mutex
+	// time of the latest stored snapshot, read from db on the first record
+	lastRecorded *time.Time
+	loaded       bool
+}
+
+type priceHistoryDB interface {
+	GetLatestRecorded() (*time.Time, error)
+	GetAllAt(from, to time.Time) ([]model.PricePoint, error)
+	GetAt(id string, from, to time.Time) (*model.PricePoint, error)
+	Save(points []model.PricePoint) error
+	DeleteBefore(t time.Time) error
+}
+
+// Stores the prices fetched at updated if the latest snapshot is at least an interval older, and deletes the expired snapshots
+func (h *PriceHistory) Record(assets []coinapi.Asset, updated time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.loaded {
+		latest, err := h.DB.GetLatestRecorded()
+		if err != nil {
+			log.Printf("Could not retrieve latest price snapshot, %v", err)
+			return
+		}
+		h.lastRecorded, h.loaded = latest, true
+	}
+	updated = updated.UTC().Truncate(time.Second)
+	if h.lastRecorded != nil && updated.Sub(*h.lastRecorded) < h.Config.Interval {
+		return
+	}
+
+	points := make([]model.PricePoint, 0, len(assets))
+	for _, asset := range assets {
+		if asset.PriceUSD > 0 {
+			points = append(points, model.PricePoint{AssetId: asset.ID, PriceUSD: asset.PriceUSD, Recorded: updated})
+		}
+	}
+	if err := h.DB.Save(points); err != nil {
+		log.Printf("Could not save price snapshot, %v", err)
+		return
+	}
+	h.lastRecorded = &updated
+	log.Printf("Saved price snapshot of %d assets", len(points))
+
+	if err := h.DB.DeleteBefore(updated.Add(-h.Config.Retention)); err != nil {
+		log.Printf("Could not delete expired price snapshots, %v", err)
+	}
+}
+
+// Gets the usd prices of all assets at time at by asset id, from the latest snapshot taken at most max gap before it.
+// Returns empty map if there is no such snapshot
+func (h *PriceHistory) PricesAt(at time.Time) (map[string]float64, error) {
+	points, err := h.DB.GetAllAt(at.Add(-h.Config.MaxGap).UTC(), at.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]float64, len(points))
+	for _, point := range points {
+		prices[point.AssetId] = point.PriceUSD
+	}
+	return prices, nil
+}
+
+// Gets the usd price of asset at time at, false if there is no snapshot with it taken at most max gap before it
+func (h *PriceHistory) PriceAt(id string, at time.Time) (float64, bool) {
+	point, err := h.DB.GetAt(id, at.Add(-h.Config.MaxGap).UTC(), at.UTC())
+	if err != nil {
+		log.Printf("Could not retrieve price of asset %s at %v, %v", id, at, err)
+		return 0, false
+	}
+	if point == nil {
+		return 0, false
+	}
+	return point.PriceUSD, true
+}
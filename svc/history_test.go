@@ -0,0 +1,107 @@
+package svc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubPhDB struct {
+	latest  *time.Time
+	points  []model.PricePoint
+	err     error
+	saved   *[][]model.PricePoint
+	deleted *[]time.Time
+}
+
+func (s stubPhDB) GetLatestRecorded() (*time.Time, error) {
+	return s.latest, s.err
+}
+func (s stubPhDB) GetAllAt(from, to time.Time) ([]model.PricePoint, error) {
+	points := []model.PricePoint{}
+	for _, point := range s.points {
+		if !point.Recorded.Before(from) && !point.Recorded.After(to) {
+			points = append(points, point)
+		}
+	}
+	return points, s.err
+}
+func (s stubPhDB) GetAt(id string, from, to time.Time) (*model.PricePoint, error) {
+	for _, point := range s.points {
+		if point.AssetId == id && !point.Recorded.Before(from) && !point.Recorded.After(to) {
+			return &point, s.err
+		}
+	}
+	return nil, s.err
+}
+func (s stubPhDB) Save(points []model.PricePoint) error {
+	*s.saved = append(*s.saved, points)
+	return s.err
+}
+func (s stubPhDB) DeleteBefore(t time.Time) error {
+	*s.deleted = append(*s.deleted, t)
+	return s.err
+}
+
+var testHistoryConfig = &config.History{Interval: 5 * time.Minute, Retention: 8 * 24 * time.Hour, MaxGap: time.Hour}
+
+func TestPriceHistory_Record(t *testing.T) {
+	assets := []coinapi.Asset{{ID: "BTC", PriceUSD: 40000}, {ID: "NOPRICE"}}
+	recent, old := testNow.Add(-time.Minute), testNow.Add(-10*time.Minute)
+	tests := []struct {
+		name      string
+		latest    *time.Time
+		err       error
+		wantSaved bool
+	}{
+		{"first snapshot", nil, nil, true},
+		{"interval passed", &old, nil, true},
+		{"downsampled", &recent, nil, false},
+		{"db error", nil, fmt.Errorf(""), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved, deleted := [][]model.PricePoint{}, []time.Time{}
+			h := &PriceHistory{DB: stubPhDB{latest: tt.latest, err: tt.err, saved: &saved, deleted: &deleted}, Config: testHistoryConfig}
+			h.Record(assets, testNow)
+
+			if !tt.wantSaved {
+				if len(saved) != 0 {
+					t.Errorf("PriceHistory.Record() saved %v, want no snapshot", saved)
+				}
+				return
+			}
+			if len(saved) != 1 || len(saved[0]) != 1 || saved[0][0] != (model.PricePoint{AssetId: "BTC", PriceUSD: 40000, Recorded: testNow}) {
+				t.Fatalf("PriceHistory.Record() saved %v, want the priced assets at %v", saved, testNow)
+			}
+			if len(deleted) != 1 || !deleted[0].Equal(testNow.Add(-testHistoryConfig.Retention)) {
+				t.Errorf("PriceHistory.Record() deleted before %v, want before %v", deleted, testNow.Add(-testHistoryConfig.Retention))
+			}
+
+			// the next refresh within the interval is not stored
+			h.Record(assets, testNow.Add(time.Minute))
+			if len(saved) != 1 {
+				t.Errorf("PriceHistory.Record() saved %d snapshots within the interval, want 1", len(saved))
+			}
+		})
+	}
+}
+
+func TestPriceHistory_PriceAt(t *testing.T) {
+	db := stubPhDB{points: []model.PricePoint{{AssetId: "BTC", PriceUSD: 39000, Recorded: testNow.Add(-25 * time.Hour)}}}
+	h := &PriceHistory{DB: db, Config: testHistoryConfig}
+
+	if price, ok := h.PriceAt("BTC", testNow.Add(-24*time.Hour)); !ok || price != 39000 {
+		t.Errorf("PriceHistory.PriceAt() = %v, %v, want 39000, true", price, ok)
+	}
+	if _, ok := h.PriceAt("BTC", testNow.Add(-time.Hour)); ok {
+		t.Errorf("PriceHistory.PriceAt() found a snapshot older than the max gap")
+	}
+	if _, ok := h.PriceAt("ETH", testNow.Add(-24*time.Hour)); ok {
+		t.Errorf("PriceHistory.PriceAt() found a price of an asset not in history")
+	}
+}
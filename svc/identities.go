@@ -0,0 +1,156 @@
+package svc
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/oidc"
+)
+
+const (
+	// length of the USERS username column
+	maxUsernameLength = 36
+	// usernames tried for a provisioned user, the preferred one and then the ones with a number appended
+	provisionAttempts = 20
+)
+
+// Identities service to log in users with their accounts at an OpenID Connect identity provider
+type Identities struct {
+	IDB   identitiesDB
+	UDB   identityUsersDB
+	USvc  userCreator
+	Clock clock.Clock
+}
+
+type identitiesDB interface {
+	GetUsername(issuer, subject string) (string, error)
+	Create(identity model.Identity) (bool, error)
+}
+
+type identityUsersDB interface {
+	GetVerifiedByEmail(email string) ([]model.User, error)
+	// when user verified their current email, nil if there is no record of it
+	GetEmailVerified(username string) (*time.Time, error)
+	SetEmailVerified(username string, verified time.Time) error
+}
+
+type userCreator interface {
+	// create a user with the starting balance, nil if the username is taken
+	Create(user model.User) (*model.User, error)
+}
+
+// Error returned when a user without a linked account should be provisioned but the provider didn't share their email
+type IdentityWithoutEmailError struct {
+	Subject string
+}
+
+func (e IdentityWithoutEmailError) Error() string {
+	return fmt.Sprintf("identity provider didn't share the email of %s, which new users need", e.Subject)
+}
+
+// Gets the user linked to the account of claims. An unlinked account is linked to the only user who verified the same email
+// if the provider verified it too and the user has a record of verifying it, otherwise a new user with the starting balance is created for it
+func (i Identities) Login(claims oidc.Claims) (string, error) {
+	username, err := i.IDB.GetUsername(claims.Issuer, claims.Subject)
+	if err != nil || username != "" {
+		return username, err
+	}
+
+	if claims.EmailVerified && claims.Email != "" {
+		users, err := i.UDB.GetVerifiedByEmail(claims.Email)
+		if err != nil {
+			return "", err
+		}
+		if len(users) == 1 {
+			// users created before verification count as verified without having proven they own the email
+			verified, err := i.UDB.GetEmailVerified(users[0].Username)
+			if err != nil {
+				return "", err
+			}
+			if verified != nil {
+				return i.link(claims, users[0].Username)
+			}
+		}
+	}
+
+	username, err = i.provision(claims)
+	if err != nil {
+		return "", err
+	}
+	return i.link(claims, username)
+}
+
+// creates a user for claims with a random password, they can set one with a password reset
+func (i Identities) provision(claims oidc.Claims) (string, error) {
+	if claims.Email == "" {
+		return "", IdentityWithoutEmailError{Subject: claims.Subject}
+	}
+	password, err := randomString()
+	if err != nil {
+		return "", fmt.Errorf("could not generate password, %v", err)
+	}
+
+	base := preferredUsername(claims)
+	for attempt := 1; attempt <= provisionAttempts; attempt++ {
+		username := base
+		if attempt > 1 {
+			username += strconv.Itoa(attempt)
+		}
+		user, err := i.USvc.Create(model.User{Username: username, Email: claims.Email, Password: password})
+		if err != nil {
+			return "", err
+		}
+		if user == nil {
+			continue
+		}
+		if claims.EmailVerified {
+			if err := i.UDB.SetEmailVerified(username, i.Clock.Now()); err != nil {
+				return "", err
+			}
+		}
+		log.Printf("Provisioned user %s for %s at %s", username, claims.Subject, claims.Issuer)
+		return username, nil
+	}
+	return "", fmt.Errorf("could not find a free username for %s after %d attempts", base, provisionAttempts)
+}
+
+func (i Identities) link(claims oidc.Claims, username string) (string, error) {
+	created, err := i.IDB.Create(model.Identity{Issuer: claims.Issuer, Subject: claims.Subject, Username: username, Created: i.Clock.Now()})
+	if err != nil {
+		return "", err
+	}
+	if !created {
+		// linked by a concurrent login
+		return i.IDB.GetUsername(claims.Issuer, claims.Subject)
+	}
+	log.Printf("Linked user %s to %s at %s", username, claims.Subject, claims.Issuer)
+	return username, nil
+}
+
+// username from the preferred username or the email of claims, with only lower case letters, digits, dots, dashes and underscores
+func preferredUsername(claims oidc.Claims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	username := b.String()
+	if username == "" {
+		username = "user"
+	}
+	// room for the appended number
+	if len(username) > maxUsernameLength-2 {
+		username = username[:maxUsernameLength-2]
+	}
+	return username
+}
@@ -0,0 +1,170 @@
+package svc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/oidc"
+)
+
+type stubIdentitiesDB struct {
+	usernames map[string]string
+	err       error
+}
+
+func (s stubIdentitiesDB) GetUsername(issuer, subject string) (string, error) {
+	return s.usernames[issuer+" "+subject], s.err
+}
+func (s stubIdentitiesDB) Create(identity model.Identity) (bool, error) {
+	key := identity.Issuer + " " + identity.Subject
+	if _, ok := s.usernames[key]; ok {
+		return false, s.err
+	}
+	s.usernames[key] = identity.Username
+	return true, s.err
+}
+
+// users by username, a user with a true value verified their email and one with a time has a record of when
+type stubIdentityUsers struct {
+	users    map[string]*model.User
+	verified map[string]bool
+	records  map[string]time.Time
+}
+
+func (s stubIdentityUsers) GetVerifiedByEmail(email string) ([]model.User, error) {
+	users := []model.User{}
+	for _, user := range s.users {
+		if user.Email == email && s.verified[user.Username] {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+func (s stubIdentityUsers) GetEmailVerified(username string) (*time.Time, error) {
+	if verified, ok := s.records[username]; ok {
+		return &verified, nil
+	}
+	return nil, nil
+}
+func (s stubIdentityUsers) SetEmailVerified(username string, verified time.Time) error {
+	s.verified[username] = true
+	s.records[username] = verified
+	return nil
+}
+func (s stubIdentityUsers) Create(user model.User) (*model.User, error) {
+	if _, ok := s.users[user.Username]; ok {
+		return nil, nil
+	}
+	s.users[user.Username] = &user
+	return &user, nil
+}
+
+func newTestIdentities(users ...model.User) (Identities, stubIdentitiesDB, stubIdentityUsers) {
+	idb := stubIdentitiesDB{usernames: map[string]string{}}
+	udb := stubIdentityUsers{users: map[string]*model.User{}, verified: map[string]bool{}, records: map[string]time.Time{}}
+	for i := range users {
+		udb.users[users[i].Username] = &users[i]
+		udb.verified[users[i].Username] = users[i].EmailVerified
+		if users[i].EmailVerified {
+			udb.records[users[i].Username] = testNow
+		}
+	}
+	return Identities{IDB: idb, UDB: udb, USvc: udb, Clock: clock.NewFake(testNow)}, idb, udb
+}
+
+func TestIdentities_Login(t *testing.T) {
+	claims := oidc.Claims{Issuer: "http://idp", Subject: "sub-1", Email: "monika@example.com", EmailVerified: true, PreferredUsername: "Monika P"}
+	unverifiedClaims := claims
+	unverifiedClaims.EmailVerified = false
+	noEmail := claims
+	noEmail.Email = ""
+	tests := []struct {
+		name         string
+		users        []model.User
+		linked       string
+		claims       oidc.Claims
+		want         string
+		wantVerified bool
+		wantErr      bool
+	}{
+		{"linked user", []model.User{{Username: "u1", Email: "other@example.com"}}, "u1", claims, "u1", false, false},
+		{"user with the same verified email", []model.User{{Username: "u1", Email: "monika@example.com", EmailVerified: true}}, "", claims, "u1", true, false},
+		{"user with the same unverified email", []model.User{{Username: "u1", Email: "monika@example.com"}}, "", claims, "monikap", true, false},
+		{"email not verified by the provider", []model.User{{Username: "u1", Email: "monika@example.com", EmailVerified: true}}, "", unverifiedClaims, "monikap", false, false},
+		{"users with the same email", []model.User{{Username: "u1", Email: "monika@example.com", EmailVerified: true}, {Username: "u2", Email: "monika@example.com", EmailVerified: true}}, "", claims, "monikap", true, false},
+		{"new user", nil, "", claims, "monikap", true, false},
+		{"taken username", []model.User{{Username: "monikap"}, {Username: "monikap2"}}, "", claims, "monikap3", true, false},
+		{"new user without email", nil, "", noEmail, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, idb, udb := newTestIdentities(tt.users...)
+			if tt.linked != "" {
+				idb.usernames["http://idp sub-1"] = tt.linked
+			}
+
+			got, err := i.Login(tt.claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Identities.Login() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Identities.Login() = %q, want %q", got, tt.want)
+			}
+			if got == "" {
+				return
+			}
+			if idb.usernames["http://idp sub-1"] != got {
+				t.Errorf("Identities.Login() linked %q, want %q", idb.usernames["http://idp sub-1"], got)
+			}
+			if udb.verified[got] != tt.wantVerified {
+				t.Errorf("email of %s verified = %v, want %v", got, udb.verified[got], tt.wantVerified)
+			}
+		})
+	}
+}
+
+func TestIdentities_Login_LegacyUser(t *testing.T) {
+	claims := oidc.Claims{Issuer: "http://idp", Subject: "sub-1", Email: "monika@example.com", EmailVerified: true, PreferredUsername: "Monika P"}
+	// created before verification, so the email counts as verified without a record
+	i, idb, udb := newTestIdentities(model.User{Username: "u1", Email: "monika@example.com", EmailVerified: true})
+	delete(udb.records, "u1")
+
+	got, err := i.Login(claims)
+	if err != nil {
+		t.Fatalf("Identities.Login() error = %v", err)
+	}
+	if got != "monikap" || idb.usernames["http://idp sub-1"] != "monikap" {
+		t.Errorf("Identities.Login() = %q linked to %q, want a new user monikap", got, idb.usernames["http://idp sub-1"])
+	}
+	if _, ok := udb.records["monikap"]; !ok {
+		t.Errorf("Identities.Login() didn't record the verified email of the new user")
+	}
+}
+
+func TestIdentities_Login_DBError(t *testing.T) {
+	i, _, _ := newTestIdentities()
+	i.IDB = stubIdentitiesDB{err: fmt.Errorf("db")}
+	if _, err := i.Login(oidc.Claims{Issuer: "http://idp", Subject: "sub-1"}); err == nil {
+		t.Errorf("Identities.Login() expected error")
+	}
+}
+
+func TestPreferredUsername(t *testing.T) {
+	tests := []struct {
+		claims oidc.Claims
+		want   string
+	}{
+		{oidc.Claims{PreferredUsername: "Monika.P", Email: "m@example.com"}, "monika.p"},
+		{oidc.Claims{Email: "m_p-1@example.com"}, "m_p-1"},
+		{oidc.Claims{PreferredUsername: "Моника"}, "user"},
+		{oidc.Claims{PreferredUsername: "a123456789012345678901234567890123456789"}, "a123456789012345678901234567890123"},
+	}
+	for _, tt := range tests {
+		if got := preferredUsername(tt.claims); got != tt.want {
+			t.Errorf("preferredUsername() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package svc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// Lockouts service which slows down and locks out logins of usernames and ip addresses failing too often
+type Lockouts struct {
+	DB     loginFailuresDB
+	Events securityEventEmitter
+	Config *config.Lockout
+	Clock  clock.Clock
+}
+
+type loginFailuresDB interface {
+	Get(kind, subject string) (*model.LoginFailures, error)
+	GetLocked(now time.Time) ([]model.LoginFailures, error)
+	// updates the failures of subject of kind with count, nil if there are none, while no other login can count them; no failures are deleted
+	Count(kind, subject string, count func(*model.LoginFailures) *model.LoginFailures) error
+	Delete(kind, subject string) (bool, error)
+}
+
+type securityEventEmitter interface {
+	// logs and keeps an event of typ about subject of kind
+	Emit(typ, kind, subject, message string)
+}
+
+// returned when a login is attempted too early after failed ones
+type LoginBlockedError struct {
+	// whether the username or the ip address is locked out, not only slowed down
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, locked out for %d seconds", e.Seconds())
+	}
+	return fmt.Sprintf("too many failed logins, retry after %d seconds", e.Seconds())
+}
+
+// the wait in whole seconds, rounded up
+func (e LoginBlockedError) Seconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// a username or an ip address whose failed logins are counted
+type loginSubject struct {
+	kind    string
+	subject string
+}
+
+// usernames longer than the users can have are not counted
+func loginSubjects(username, ip string) []loginSubject {
+	subjects := []loginSubject{}
+	if username != "" && len(username) <= maxUsernameLength {
+		subjects = append(subjects, loginSubject{model.LockoutUsername, username})
+	}
+	if ip != "" {
+		subjects = append(subjects, loginSubject{model.LockoutIP, ip})
+	}
+	return subjects
+}
+
+// Reserves a login of username from ip before its password is checked by counting it as a failed one right away,
+// so concurrent logins wait and can't try more passwords than logins one after another.
+// Returns LoginBlockedError with the longest wait if the username or the ip address is locked out or failed too recently,
+// nothing is counted then
+func (l Lockouts) Attempt(username, ip string) error {
+	now := l.Clock.Now()
+	var blocked *LoginBlockedError
+	reserved := []loginSubject{}
+	for _, s := range loginSubjects(username, ip) {
+		var wait time.Duration
+		var locked bool
+		err := l.DB.Count(s.kind, s.subject, func(failures *model.LoginFailures) *model.LoginFailures {
+			if failures != nil {
+				if wait, locked = l.wait(*failures, now, l.maxFailures(s.kind)); wait > 0 {
+					return nil
+				}
+			}
+			return countAttempt(failures, s, now, l.Config.FailureWindow)
+		})
+		if err != nil {
+			l.release(reserved)
+			return err
+		}
+		if wait <= 0 {
+			reserved = append(reserved, s)
+			continue
+		}
+		if blocked == nil {
+			blocked = &LoginBlockedError{}
+		}
+		blocked.Locked = blocked.Locked || locked
+		if wait > blocked.RetryAfter {
+			blocked.RetryAfter = wait
+		}
+	}
+	if blocked != nil {
+		if err := l.release(reserved); err != nil {
+			return err
+		}
+		return *blocked
+	}
+	return nil
+}
+
+// Locks out the username or the ip address of a reserved login which failed
+// when it failed too often within the window. Every lockout emits a security event
+func (l Lockouts) Fail(username, ip string) error {
+	now := l.Clock.Now()
+	for _, s := range loginSubjects(username, ip) {
+		var locked *model.LoginFailures
+		err := l.DB.Count(s.kind, s.subject, func(failures *model.LoginFailures) *model.LoginFailures {
+			// not failed often enough yet or another login locked it out at the same time
+			if failures == nil || failures.Failures < l.maxFailures(s.kind) || failures.LockedUntil != nil {
+				return nil
+			}
+			until := now.Add(l.Config.Duration)
+			failures.LockedUntil = &until
+			locked = failures
+			return failures
+		})
+		if err != nil {
+			return err
+		}
+		if locked == nil {
+			continue
+		}
+		l.Events.Emit(model.EventLoginLockout, s.kind, s.subject,
+			fmt.Sprintf("%d failed logins within %v, locked out until %s", locked.Failures, l.Config.FailureWindow, locked.LockedUntil.UTC().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// Forgets the failed logins of username after a reserved login succeeded.
+// Only the reserved login of the ip address is forgotten, so logging in to an own account doesn't allow more guesses
+func (l Lockouts) Succeed(username, ip string) error {
+	if len(loginSubjects(username, "")) > 0 {
+		if _, err := l.DB.Delete(model.LockoutUsername, username); err != nil {
+			return err
+		}
+	}
+	return l.release(loginSubjects("", ip))
+}
+
+// Forgets a reserved login of username from ip whose password couldn't be checked
+func (l Lockouts) Cancel(username, ip string) error {
+	return l.release(loginSubjects(username, ip))
+}
+
+// forgets one reserved login of each of subjects, the next login still waits after it as after the failures before it
+func (l Lockouts) release(subjects []loginSubject) error {
+	for _, s := range subjects {
+		err := l.DB.Count(s.kind, s.subject, func(failures *model.LoginFailures) *model.LoginFailures {
+			if failures == nil {
+				return nil
+			}
+			failures.Failures--
+			return failures
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gets the usernames and ip addresses which are locked out now
+func (l Lockouts) GetLocked() ([]model.Lockout, error) {
+	locked, err := l.DB.GetLocked(l.Clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts := []model.Lockout{}
+	for _, failures := range locked {
+		lockouts = append(lockouts, model.Lockout{Kind: failures.Kind, Subject: failures.Subject, Failures: failures.Failures, Until: *failures.LockedUntil})
+	}
+	return lockouts, nil
+}
+
+// Forgets the failed logins of subject of kind, so it can log in right away, and emits a security event.
+// Returns false if it had no failed logins
+func (l Lockouts) Clear(kind, subject string) (bool, error) {
+	cleared, err := l.DB.Delete(kind, subject)
+	if err != nil || !cleared {
+		return false, err
+	}
+	l.Events.Emit(model.EventLockoutCleared, kind, subject, "failed logins cleared by an admin")
+	return true, nil
+}
+
+// how long after failures the subject has to wait at now and whether it is locked out
+func (l Lockouts) wait(failures model.LoginFailures, now time.Time, maxFailures int) (time.Duration, bool) {
+	if failures.IsLocked(now) {
+		return failures.LockedUntil.Sub(now), true
+	}
+	// the failures start again after an expired lockout or the window
+	if failures.LockedUntil != nil || failures.FirstFailure.Before(now.Add(-l.Config.FailureWindow)) {
+		return 0, false
+	}
+	// the last login allowed is still checked and locks the subject out if it fails
+	if failures.Failures >= maxFailures {
+		return failures.FirstFailure.Add(l.Config.FailureWindow).Sub(now), true
+	}
+	return failures.LastFailure.Add(l.delay(failures.Failures)).Sub(now), false
+}
+
+// failures of s with a login at now counted, starting again after an expired lockout or the window
+func countAttempt(failures *model.LoginFailures, s loginSubject, now time.Time, window time.Duration) *model.LoginFailures {
+	if failures == nil || failures.LockedUntil != nil || failures.FirstFailure.Before(now.Add(-window)) {
+		failures = &model.LoginFailures{Kind: s.kind, Subject: s.subject, FirstFailure: now}
+	}
+	failures.Failures++
+	failures.LastFailure = now
+	return failures
+}
+
+// wait after the failures, doubled by each failure after the first up to the longest wait
+func (l Lockouts) delay(failures int) time.Duration {
+	delay := l.Config.Delay
+	for i := 1; i < failures && delay < l.Config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.Config.MaxDelay {
+		return l.Config.MaxDelay
+	}
+	return delay
+}
+
+func (l Lockouts) maxFailures(kind string) int {
+	if kind == model.LockoutIP {
+		return l.Config.IPMaxFailures
+	}
+	return l.Config.MaxFailures
+}
@@ -0,0 +1,331 @@
+package svc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// keeps failed logins like LOGIN_FAILURES, counting them one at a time
+type stubLoginFailuresDB struct {
+	mu       sync.Mutex
+	failures map[loginSubject]*model.LoginFailures
+}
+
+func (s *stubLoginFailuresDB) Get(kind, subject string) (*model.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(kind, subject), nil
+}
+func (s *stubLoginFailuresDB) get(kind, subject string) *model.LoginFailures {
+	if failures, ok := s.failures[loginSubject{kind, subject}]; ok {
+		copied := *failures
+		return &copied
+	}
+	return nil
+}
+func (s *stubLoginFailuresDB) GetLocked(now time.Time) ([]model.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	locked := []model.LoginFailures{}
+	for _, failures := range s.failures {
+		if failures.IsLocked(now) {
+			locked = append(locked, *failures)
+		}
+	}
+	return locked, nil
+}
+func (s *stubLoginFailuresDB) Count(kind, subject string, count func(*model.LoginFailures) *model.LoginFailures) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated := count(s.get(kind, subject))
+	if updated == nil {
+		return nil
+	}
+	if updated.Failures <= 0 {
+		delete(s.failures, loginSubject{kind, subject})
+		return nil
+	}
+	copied := *updated
+	s.failures[loginSubject{kind, subject}] = &copied
+	return nil
+}
+func (s *stubLoginFailuresDB) Delete(kind, subject string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.failures[loginSubject{kind, subject}]
+	delete(s.failures, loginSubject{kind, subject})
+	return ok, nil
+}
+
+type stubSecurityEvents struct {
+	events []model.SecurityEvent
+}
+
+func (s *stubSecurityEvents) Emit(typ, kind, subject, message string) {
+	s.events = append(s.events, model.SecurityEvent{Type: typ, Kind: kind, Subject: subject, Message: message})
+}
+
+func newTestLockouts() (Lockouts, *stubSecurityEvents, *clock.Fake) {
+	events := &stubSecurityEvents{}
+	clk := clock.NewFake(testNow)
+	cfg := &config.Lockout{MaxFailures: 3, IPMaxFailures: 5, FailureWindow: 15 * time.Minute, Duration: 10 * time.Minute, Delay: time.Second, MaxDelay: 4 * time.Second}
+	return Lockouts{DB: &stubLoginFailuresDB{failures: map[loginSubject]*model.LoginFailures{}}, Events: events, Config: cfg, Clock: clk}, events, clk
+}
+
+// tries to log in as username from ip, the login is reserved unless it is blocked
+func attemptTestLogin(t *testing.T, l Lockouts, username, ip string) *LoginBlockedError {
+	err := l.Attempt(username, ip)
+	if err == nil {
+		return nil
+	}
+	blocked, ok := err.(LoginBlockedError)
+	if !ok {
+		t.Fatalf("Lockouts.Attempt() error = %v", err)
+	}
+	return &blocked
+}
+
+// fails to log in as username from ip and waits until the next login is allowed, unless it was locked out
+func failTestLogin(t *testing.T, l Lockouts, clk *clock.Fake, username, ip string) *LoginBlockedError {
+	if blocked := attemptTestLogin(t, l, username, ip); blocked != nil {
+		t.Fatalf("Lockouts.Attempt() = %v, want the login allowed", blocked)
+	}
+	if err := l.Fail(username, ip); err != nil {
+		t.Fatalf("Lockouts.Fail() error = %v", err)
+	}
+	blocked := attemptTestLogin(t, l, username, ip)
+	if blocked == nil {
+		t.Fatalf("Lockouts.Attempt() right after a failure allowed the login")
+	}
+	if !blocked.Locked {
+		clk.Advance(blocked.RetryAfter)
+	}
+	return blocked
+}
+
+// logs in as username from ip if it is allowed, without checking the password
+func allowTestLogin(t *testing.T, l Lockouts, username, ip string) bool {
+	if blocked := attemptTestLogin(t, l, username, ip); blocked != nil {
+		return false
+	}
+	if err := l.Cancel(username, ip); err != nil {
+		t.Fatalf("Lockouts.Cancel() error = %v", err)
+	}
+	return true
+}
+
+func TestLockouts_Attempt_ProgressiveDelay(t *testing.T) {
+	l, _, clk := newTestLockouts()
+	l.Config.MaxFailures = 10
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		blocked := failTestLogin(t, l, clk, "u1", "")
+		if blocked == nil || blocked.Locked || blocked.RetryAfter != want {
+			t.Fatalf("Lockouts.Attempt() after %d failures = %v, want a wait of %v", i+1, blocked, want)
+		}
+	}
+	if !allowTestLogin(t, l, "u1", "") {
+		t.Fatalf("Lockouts.Attempt() after the wait blocked the login")
+	}
+}
+
+func TestLockouts_Fail_LocksOutUsername(t *testing.T) {
+	l, events, clk := newTestLockouts()
+	for i := 0; i < 2; i++ {
+		if blocked := failTestLogin(t, l, clk, "u1", "10.0.0.1"); blocked == nil || blocked.Locked {
+			t.Fatalf("Lockouts.Attempt() after %d failures = %v, want a delay", i+1, blocked)
+		}
+	}
+
+	blocked := failTestLogin(t, l, clk, "u1", "10.0.0.1")
+	if blocked == nil || !blocked.Locked || blocked.RetryAfter != 10*time.Minute {
+		t.Fatalf("Lockouts.Attempt() after 3 failures = %v, want locked for 10m", blocked)
+	}
+	if len(events.events) != 1 {
+		t.Fatalf("Lockouts.Fail() emitted %d events, want 1", len(events.events))
+	}
+	if event := events.events[0]; event.Type != model.EventLoginLockout || event.Kind != model.LockoutUsername || event.Subject != "u1" {
+		t.Errorf("Lockouts.Fail() emitted %+v", event)
+	}
+	// the ip address is not locked out, only delayed
+	clk.Advance(4 * time.Second)
+	if !allowTestLogin(t, l, "u2", "10.0.0.1") {
+		t.Errorf("Lockouts.Attempt() blocked another username")
+	}
+
+	clk.Advance(10 * time.Minute)
+	if !allowTestLogin(t, l, "u1", "10.0.0.1") {
+		t.Fatalf("Lockouts.Attempt() after the lockout blocked the login")
+	}
+	// the failures start again
+	if blocked := failTestLogin(t, l, clk, "u1", "10.0.0.2"); blocked == nil || blocked.Locked || blocked.RetryAfter != time.Second {
+		t.Errorf("Lockouts.Attempt() after a failure following the lockout = %v, want a wait of 1s", blocked)
+	}
+}
+
+func TestLockouts_Fail_LocksOutIP(t *testing.T) {
+	l, events, clk := newTestLockouts()
+	for _, username := range []string{"u1", "u2", "u3", "u4"} {
+		failTestLogin(t, l, clk, username, "10.0.0.1")
+	}
+	blocked := failTestLogin(t, l, clk, "u5", "10.0.0.1")
+	if blocked == nil || !blocked.Locked {
+		t.Fatalf("Lockouts.Attempt() after 5 failures from an ip address = %v, want locked", blocked)
+	}
+	if allowTestLogin(t, l, "u6", "10.0.0.1") {
+		t.Errorf("Lockouts.Attempt() allowed another username from a locked out ip address")
+	}
+	if !allowTestLogin(t, l, "u6", "10.0.0.2") {
+		t.Errorf("Lockouts.Attempt() blocked another ip address")
+	}
+	if len(events.events) != 1 || events.events[0].Kind != model.LockoutIP || events.events[0].Subject != "10.0.0.1" {
+		t.Errorf("Lockouts.Fail() emitted %+v, want a lockout of the ip address", events.events)
+	}
+}
+
+func TestLockouts_Fail_WindowExpires(t *testing.T) {
+	l, events, clk := newTestLockouts()
+	failTestLogin(t, l, clk, "u1", "")
+	failTestLogin(t, l, clk, "u1", "")
+	clk.Advance(15 * time.Minute)
+
+	if blocked := failTestLogin(t, l, clk, "u1", ""); blocked == nil || blocked.Locked || blocked.RetryAfter != time.Second {
+		t.Errorf("Lockouts.Attempt() after a failure in a new window = %v, want a wait of 1s", blocked)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("Lockouts.Fail() emitted %+v, want none", events.events)
+	}
+}
+
+func TestLockouts_Attempt_BlockedNotCounted(t *testing.T) {
+	l, _, clk := newTestLockouts()
+	for i := 0; i < 3; i++ {
+		failTestLogin(t, l, clk, "u1", "")
+	}
+
+	if blocked := attemptTestLogin(t, l, "u1", "10.0.0.1"); blocked == nil || !blocked.Locked {
+		t.Fatalf("Lockouts.Attempt() of a locked out username = %v, want locked", blocked)
+	}
+	if failures, _ := l.DB.Get(model.LockoutUsername, "u1"); failures == nil || failures.Failures != 3 {
+		t.Errorf("Lockouts.Attempt() counted a blocked login of the username: %+v", failures)
+	}
+	if failures, _ := l.DB.Get(model.LockoutIP, "10.0.0.1"); failures != nil {
+		t.Errorf("Lockouts.Attempt() counted a blocked login of the ip address: %+v", failures)
+	}
+}
+
+func TestLockouts_Attempt_Concurrent(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay time.Duration
+		want  int
+	}{
+		{"one login until the delay", time.Second, 1},
+		{"no more logins than failures to lock out", 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, events, _ := newTestLockouts()
+			l.Config.Delay = tt.delay
+
+			var wg sync.WaitGroup
+			allowed := make(chan bool, 20)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if l.Attempt("u1", "") != nil {
+						allowed <- false
+						return
+					}
+					allowed <- true
+					if err := l.Fail("u1", ""); err != nil {
+						t.Errorf("Lockouts.Fail() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			close(allowed)
+
+			got := 0
+			for ok := range allowed {
+				if ok {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Lockouts.Attempt() allowed %d concurrent logins, want %d", got, tt.want)
+			}
+			if failures, _ := l.DB.Get(model.LockoutUsername, "u1"); failures == nil || failures.Failures != tt.want {
+				t.Errorf("Lockouts.Attempt() counted %+v, want %d failures", failures, tt.want)
+			}
+			if locked := tt.want >= l.Config.MaxFailures; locked != (len(events.events) == 1) {
+				t.Errorf("Lockouts.Fail() emitted %+v", events.events)
+			}
+		})
+	}
+}
+
+func TestLockouts_Succeed(t *testing.T) {
+	l, _, clk := newTestLockouts()
+	failTestLogin(t, l, clk, "u1", "10.0.0.1")
+	failTestLogin(t, l, clk, "u1", "10.0.0.1")
+
+	if blocked := attemptTestLogin(t, l, "u1", "10.0.0.1"); blocked != nil {
+		t.Fatalf("Lockouts.Attempt() = %v, want the login allowed", blocked)
+	}
+	if err := l.Succeed("u1", "10.0.0.1"); err != nil {
+		t.Fatalf("Lockouts.Succeed() error = %v", err)
+	}
+	if failures, _ := l.DB.Get(model.LockoutUsername, "u1"); failures != nil {
+		t.Errorf("Lockouts.Succeed() kept the failures of the username: %+v", failures)
+	}
+	if failures, _ := l.DB.Get(model.LockoutIP, "10.0.0.1"); failures == nil || failures.Failures != 2 {
+		t.Errorf("Lockouts.Succeed() changed the failures of the ip address: %+v", failures)
+	}
+}
+
+func TestLockouts_Fail_LongUsernameNotCounted(t *testing.T) {
+	l, _, clk := newTestLockouts()
+	username := "a-username-longer-than-any-user-can-have"
+	failTestLogin(t, l, clk, username, "10.0.0.1")
+
+	if failures, _ := l.DB.Get(model.LockoutUsername, username); failures != nil {
+		t.Errorf("Lockouts.Fail() counted a long username: %+v", failures)
+	}
+	if failures, _ := l.DB.Get(model.LockoutIP, "10.0.0.1"); failures == nil {
+		t.Errorf("Lockouts.Fail() didn't count the ip address of a long username")
+	}
+}
+
+func TestLockouts_GetLockedAndClear(t *testing.T) {
+	l, events, clk := newTestLockouts()
+	for i := 0; i < 3; i++ {
+		failTestLogin(t, l, clk, "u1", "")
+	}
+
+	locked, err := l.GetLocked()
+	if err != nil || len(locked) != 1 {
+		t.Fatalf("Lockouts.GetLocked() = %v, %v, want 1 lockout", locked, err)
+	}
+	if want := (model.Lockout{Kind: model.LockoutUsername, Subject: "u1", Failures: 3, Until: clk.Now().Add(10 * time.Minute)}); locked[0] != want {
+		t.Errorf("Lockouts.GetLocked() = %+v, want %+v", locked[0], want)
+	}
+
+	if cleared, err := l.Clear(model.LockoutUsername, "u1"); !cleared || err != nil {
+		t.Fatalf("Lockouts.Clear() = %v, %v", cleared, err)
+	}
+	if !allowTestLogin(t, l, "u1", "") {
+		t.Errorf("Lockouts.Attempt() after clearing blocked the login")
+	}
+	if last := events.events[len(events.events)-1]; last.Type != model.EventLockoutCleared || last.Subject != "u1" {
+		t.Errorf("Lockouts.Clear() emitted %+v", last)
+	}
+	if cleared, _ := l.Clear(model.LockoutUsername, "u1"); cleared {
+		t.Errorf("Lockouts.Clear() of a username without failures = true")
+	}
+}
@@ -0,0 +1,161 @@
+package svc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// periods over which the market movers are computed
+var moversPeriods = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// Market movers service which computes the top gainers, losers and most traded assets on every refresh of the prices
+type Movers struct {
+	History moversHistory
+	ADB     tradedAssetsDB
+	// number of assets in every list
+	Limit int
+
+	mu      sync.Mutex
+	current *model.Movers
+}
+
+type moversHistory interface {
+	// stores the prices fetched at updated
+	Record(assets []coinapi.Asset, updated time.Time)
+	// usd prices of all assets at time at by asset id
+	PricesAt(at time.Time) (map[string]float64, error)
+}
+
+type tradedAssetsDB interface {
+	GetTradedSince(since time.Time) ([]model.TradedAsset, error)
+}
+
+// Error when the movers are requested before the first refresh of the prices
+type MoversNotReadyError struct{}
+
+func (e MoversNotReadyError) Error() string {
+	return "market movers are not computed yet"
+}
+
+// Gets the movers computed on the latest refresh of the prices.
+// Returns MoversNotReadyError if the prices were not refreshed yet
+func (m *Movers) Get() (*model.Movers, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current == nil {
+		return nil, MoversNotReadyError{}
+	}
+	return m.current, nil
+}
+
+// Records the prices fetched at updated in the history and computes the movers from them
+func (m *Movers) Update(assets []coinapi.Asset, updated time.Time) {
+	m.History.Record(assets, updated)
+
+	movers, err := m.compute(assets, updated)
+	if err != nil {
+		log.Printf("Could not compute market movers, %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// a slower update of older prices doesn't replace newer movers
+	if m.current == nil || !movers.Updated.Before(m.current.Updated) {
+		m.current = movers
+	}
+}
+
+func (m *Movers) compute(assets []coinapi.Asset, updated time.Time) (*model.Movers, error) {
+	names := make(map[string]string, len(assets))
+	for _, asset := range assets {
+		names[asset.ID] = asset.Name
+	}
+
+	movers := &model.Movers{Updated: updated.UTC(), Periods: []model.MoversPeriod{}}
+	for _, period := range moversPeriods {
+		since := updated.Add(-period.duration).UTC()
+		past, err := m.History.PricesAt(since)
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve prices %s ago, %v", period.name, err)
+		}
+		traded, err := m.ADB.GetTradedSince(since)
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve assets traded in %s, %v", period.name, err)
+		}
+
+		moversPeriod := model.MoversPeriod{Period: period.name, Since: since}
+		moversPeriod.Gainers, moversPeriod.Losers = m.priceMovers(assets, past)
+		moversPeriod.MostTradedByCount, moversPeriod.MostTradedByVolume = m.mostTraded(traded, names)
+		movers.Periods = append(movers.Periods, moversPeriod)
+	}
+	return movers, nil
+}
+
+// assets with the biggest rise and the biggest fall of price since the past prices
+func (m *Movers) priceMovers(assets []coinapi.Asset, past map[string]float64) ([]model.Mover, []model.Mover) {
+	changed := []model.Mover{}
+	for _, asset := range assets {
+		pastPrice, ok := past[asset.ID]
+		if !ok || pastPrice <= 0 || asset.PriceUSD <= 0 || asset.PriceUSD == pastPrice {
+			continue
+		}
+		change := (asset.PriceUSD - pastPrice) / pastPrice * 100
+		changed = append(changed, model.Mover{AssetId: asset.ID, Name: asset.Name, PriceUSD: asset.PriceUSD, PastPriceUSD: pastPrice, ChangePercent: change})
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		if changed[i].ChangePercent != changed[j].ChangePercent {
+			return changed[i].ChangePercent > changed[j].ChangePercent
+		}
+		return changed[i].AssetId < changed[j].AssetId
+	})
+
+	gainers, losers := []model.Mover{}, []model.Mover{}
+	for i := 0; i < len(changed) && len(gainers) < m.Limit && changed[i].ChangePercent > 0; i++ {
+		gainers = append(gainers, changed[i])
+	}
+	for i := len(changed) - 1; i >= 0 && len(losers) < m.Limit && changed[i].ChangePercent < 0; i-- {
+		losers = append(losers, changed[i])
+	}
+	return gainers, losers
+}
+
+// assets with the most acquisitions and with the biggest usd volume of acquisitions
+func (m *Movers) mostTraded(traded []model.TradedAsset, names map[string]string) ([]model.TradedAsset, []model.TradedAsset) {
+	for i := range traded {
+		traded[i].Name = names[traded[i].AssetId]
+	}
+
+	byCount := append([]model.TradedAsset{}, traded...)
+	sort.Slice(byCount, func(i, j int) bool {
+		if byCount[i].Trades != byCount[j].Trades {
+			return byCount[i].Trades > byCount[j].Trades
+		}
+		return byCount[i].AssetId < byCount[j].AssetId
+	})
+	byVolume := append([]model.TradedAsset{}, traded...)
+	sort.Slice(byVolume, func(i, j int) bool {
+		if byVolume[i].VolumeUSD != byVolume[j].VolumeUSD {
+			return byVolume[i].VolumeUSD > byVolume[j].VolumeUSD
+		}
+		return byVolume[i].AssetId < byVolume[j].AssetId
+	})
+
+	if len(traded) > m.Limit {
+		byCount, byVolume = byCount[:m.Limit], byVolume[:m.Limit]
+	}
+	return byCount, byVolume
+}
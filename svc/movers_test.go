@@ -0,0 +1,98 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubMoversHistory struct {
+	// prices by how long ago they were
+	prices   map[time.Duration]map[string]float64
+	err      error
+	recorded *int
+}
+
+func (s stubMoversHistory) Record(assets []coinapi.Asset, updated time.Time) {
+	*s.recorded++
+}
+func (s stubMoversHistory) PricesAt(at time.Time) (map[string]float64, error) {
+	return s.prices[testNow.Sub(at)], s.err
+}
+
+type stubTradedDB struct {
+	traded []model.TradedAsset
+	err    error
+}
+
+func (s stubTradedDB) GetTradedSince(since time.Time) ([]model.TradedAsset, error) {
+	return append([]model.TradedAsset{}, s.traded...), s.err
+}
+
+func TestMovers_Update(t *testing.T) {
+	assets := []coinapi.Asset{{ID: "BTC", Name: "Bitcoin", PriceUSD: 44000}, {ID: "ETH", Name: "Ethereum", PriceUSD: 2700}, {ID: "DOGE", Name: "Dogecoin", PriceUSD: 0.1}, {ID: "USDT", PriceUSD: 1}}
+	past := map[string]float64{"BTC": 40000, "ETH": 3000, "DOGE": 0.05, "USDT": 1}
+	traded := []model.TradedAsset{{AssetId: "BTC", Trades: 1, VolumeUSD: 40000}, {AssetId: "DOGE", Trades: 5, VolumeUSD: 10}, {AssetId: "ETH", Trades: 2, VolumeUSD: 6000}}
+	tests := []struct {
+		name    string
+		history stubMoversHistory
+		adb     stubTradedDB
+		want    model.MoversPeriod
+		wantErr error
+	}{
+		{"movers", stubMoversHistory{prices: map[time.Duration]map[string]float64{24 * time.Hour: past}}, stubTradedDB{traded: traded}, model.MoversPeriod{
+			Period:             "24h",
+			Since:              testNow.Add(-24 * time.Hour),
+			Gainers:            []model.Mover{{AssetId: "DOGE", Name: "Dogecoin", PriceUSD: 0.1, PastPriceUSD: 0.05, ChangePercent: 100}, {AssetId: "BTC", Name: "Bitcoin", PriceUSD: 44000, PastPriceUSD: 40000, ChangePercent: 10}},
+			Losers:             []model.Mover{{AssetId: "ETH", Name: "Ethereum", PriceUSD: 2700, PastPriceUSD: 3000, ChangePercent: -10}},
+			MostTradedByCount:  []model.TradedAsset{{AssetId: "DOGE", Name: "Dogecoin", Trades: 5, VolumeUSD: 10}, {AssetId: "ETH", Name: "Ethereum", Trades: 2, VolumeUSD: 6000}},
+			MostTradedByVolume: []model.TradedAsset{{AssetId: "BTC", Name: "Bitcoin", Trades: 1, VolumeUSD: 40000}, {AssetId: "ETH", Name: "Ethereum", Trades: 2, VolumeUSD: 6000}},
+		}, nil},
+		{"no history", stubMoversHistory{}, stubTradedDB{}, model.MoversPeriod{Period: "24h", Since: testNow.Add(-24 * time.Hour),
+			Gainers: []model.Mover{}, Losers: []model.Mover{}, MostTradedByCount: []model.TradedAsset{}, MostTradedByVolume: []model.TradedAsset{}}, nil},
+		{"history error", stubMoversHistory{err: fmt.Errorf("")}, stubTradedDB{}, model.MoversPeriod{}, MoversNotReadyError{}},
+		{"db error", stubMoversHistory{}, stubTradedDB{err: fmt.Errorf("")}, model.MoversPeriod{}, MoversNotReadyError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorded := 0
+			tt.history.recorded = &recorded
+			m := &Movers{History: tt.history, ADB: tt.adb, Limit: 2}
+			m.Update(assets, testNow)
+
+			if recorded != 1 {
+				t.Errorf("Movers.Update() recorded prices %d times, want 1", recorded)
+			}
+			got, err := m.Get()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Movers.Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got.Periods) != len(moversPeriods) || !got.Updated.Equal(testNow) {
+				t.Fatalf("Movers.Get() = %+v, want movers of %d periods at %v", got, len(moversPeriods), testNow)
+			}
+			if !reflect.DeepEqual(got.Periods[1], tt.want) {
+				t.Errorf("Movers.Get() 24h = %+v, want %+v", got.Periods[1], tt.want)
+			}
+		})
+	}
+}
+
+func TestMovers_UpdateKeepsNewer(t *testing.T) {
+	recorded := 0
+	m := &Movers{History: stubMoversHistory{recorded: &recorded}, ADB: stubTradedDB{}, Limit: 2}
+	m.Update(nil, testNow)
+	m.Update(nil, testNow.Add(-time.Minute))
+
+	got, err := m.Get()
+	if err != nil || !got.Updated.Equal(testNow) {
+		t.Errorf("Movers.Get() = %+v, %v, want the movers of the newest prices", got, err)
+	}
+}
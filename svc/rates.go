@@ -0,0 +1,100 @@
+package svc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// id of the asset all prices are denominated in
+const usdAssetID = "USD"
+
+// Error returned when an asset needed for a computation is not in the cache
+type AssetNotFoundError struct {
+	ID string
+}
+
+func (e AssetNotFoundError) Error() string {
+	return fmt.Sprintf("asset with id %s doesn't exist", e.ID)
+}
+
+// Converts amount of asset from to asset to using the cached USD prices.
+// Returns AssetNotFoundError if one of the assets is not in the cache
+func (a Assets) Convert(from, to string, amount float64) (*model.Conversion, error) {
+	if err := a.updateCacheIfNeeded(); err != nil {
+		return nil, err
+	}
+
+	fromPrice, err := a.priceUSD(from)
+	if err != nil {
+		return nil, err
+	}
+	toPrice, err := a.priceUSD(to)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := fromPrice / toPrice
+	return &model.Conversion{From: from, To: to, Amount: amount, Rate: rate, Result: amount * rate, Prices: a.pricesInfo()}, nil
+}
+
+// Gets the cross rates of assets against base using the cached USD prices.
+// If ids are given only their rates are returned and the ones not in the cache are reported as missing,
+// otherwise the rates of all cached assets are returned. Assets without a price are reported as missing.
+// Returns AssetNotFoundError if the base asset is not in the cache
+func (a Assets) GetRates(base string, ids []string) (*model.Rates, error) {
+	if err := a.updateCacheIfNeeded(); err != nil {
+		return nil, err
+	}
+
+	basePrice, err := a.priceUSD(base)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := model.Rates{Base: base, Rates: map[string]float64{}, Missing: []string{}, Prices: a.pricesInfo()}
+	if len(ids) == 0 {
+		for _, asset := range a.cache.All() {
+			if asset.PriceUSD <= 0 {
+				rates.Missing = append(rates.Missing, asset.ID)
+				continue
+			}
+			rates.Rates[asset.ID] = basePrice / asset.PriceUSD
+		}
+		return &rates, nil
+	}
+
+	for _, id := range ids {
+		price, err := a.priceUSD(id)
+		if err != nil {
+			rates.Missing = append(rates.Missing, id)
+			continue
+		}
+		rates.Rates[id] = basePrice / price
+	}
+	return &rates, nil
+}
+
+// USD is always available, even if the external api does not list it
+func (a Assets) priceUSD(id string) (float64, error) {
+	asset := a.cache.GetAsset(id)
+	if asset == nil || asset.PriceUSD <= 0 {
+		if strings.EqualFold(id, usdAssetID) {
+			return 1, nil
+		}
+		return -1, AssetNotFoundError{ID: id}
+	}
+	return asset.PriceUSD, nil
+}
+
+// Gets when the cached prices were fetched from the external api
+func (a Assets) PricesUpdated() time.Time {
+	return a.cache.Updated()
+}
+
+func (a Assets) pricesInfo() model.PricesInfo {
+	updated := a.PricesUpdated()
+	return model.PricesInfo{Updated: updated, AgeSeconds: a.clock.Now().Sub(updated).Seconds()}
+}
@@ -0,0 +1,122 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+)
+
+func TestAssets_Convert(t *testing.T) {
+	type fields struct {
+		client coinAPIClient
+	}
+	type args struct {
+		from   string
+		to     string
+		amount float64
+	}
+	btc := coinapi.Asset{ID: "BTC", PriceUSD: 40000}
+	eth := coinapi.Asset{ID: "ETH", PriceUSD: 2000}
+	tests := []struct {
+		name         string
+		fields       fields
+		args         args
+		wantResult   float64
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{"crypto to crypto", fields{stubClient{[]coinapi.Asset{btc, eth}, nil}}, args{"BTC", "ETH", 0.1}, 2, false, false},
+		{"crypto to usd not listed", fields{stubClient{[]coinapi.Asset{btc}, nil}}, args{"BTC", "USD", 2}, 80000, false, false},
+		{"usd to crypto", fields{stubClient{[]coinapi.Asset{eth}, nil}}, args{"USD", "ETH", 1000}, 0.5, false, false},
+		{"missing from asset", fields{stubClient{[]coinapi.Asset{eth}, nil}}, args{"BTC", "ETH", 1}, 0, true, true},
+		{"missing to asset", fields{stubClient{[]coinapi.Asset{btc}, nil}}, args{"BTC", "ETH", 1}, 0, true, true},
+		{"cache update error", fields{stubClient{[]coinapi.Asset{}, fmt.Errorf("")}}, args{"BTC", "ETH", 1}, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAssets(tt.fields.client)
+			got, err := a.Convert(tt.args.from, tt.args.to, tt.args.amount)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Assets.Convert() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if errors.As(err, &AssetNotFoundError{}) != tt.wantNotFound {
+				t.Errorf("Assets.Convert() error = %v, want not found %v", err, tt.wantNotFound)
+				return
+			}
+			if err == nil && got.Result != tt.wantResult {
+				t.Errorf("Assets.Convert() result = %v, want %v", got.Result, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestAssets_GetRates(t *testing.T) {
+	type fields struct {
+		client coinAPIClient
+	}
+	type args struct {
+		base string
+		ids  []string
+	}
+	btc := coinapi.Asset{ID: "BTC", PriceUSD: 40000}
+	eth := coinapi.Asset{ID: "ETH", PriceUSD: 2000}
+	xyz := coinapi.Asset{ID: "XYZ"}
+	tests := []struct {
+		name        string
+		fields      fields
+		args        args
+		wantRates   map[string]float64
+		wantMissing []string
+		wantErr     bool
+	}{
+		{"all assets", fields{stubClient{[]coinapi.Asset{btc, eth}, nil}}, args{"BTC", nil}, map[string]float64{"BTC": 1, "ETH": 20}, []string{}, false},
+		{"requested assets with missing", fields{stubClient{[]coinapi.Asset{btc, eth}, nil}}, args{"ETH", []string{"BTC", "DOGE", "USD"}}, map[string]float64{"BTC": 0.05, "USD": 2000}, []string{"DOGE"}, false},
+		{"all assets with priceless", fields{stubClient{[]coinapi.Asset{btc, xyz}, nil}}, args{"BTC", nil}, map[string]float64{"BTC": 1}, []string{"XYZ"}, false},
+		{"requested priceless asset", fields{stubClient{[]coinapi.Asset{btc, xyz}, nil}}, args{"BTC", []string{"XYZ"}}, map[string]float64{}, []string{"XYZ"}, false},
+		{"priceless base", fields{stubClient{[]coinapi.Asset{btc, xyz}, nil}}, args{"XYZ", nil}, nil, nil, true},
+		{"missing base", fields{stubClient{[]coinapi.Asset{eth}, nil}}, args{"BTC", nil}, nil, nil, true},
+		{"cache update error", fields{stubClient{[]coinapi.Asset{}, fmt.Errorf("")}}, args{"BTC", nil}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAssets(tt.fields.client)
+			got, err := a.GetRates(tt.args.base, tt.args.ids)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Assets.GetRates() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Rates, tt.wantRates) {
+				t.Errorf("Assets.GetRates() rates = %v, want %v", got.Rates, tt.wantRates)
+			}
+			if !reflect.DeepEqual(got.Missing, tt.wantMissing) {
+				t.Errorf("Assets.GetRates() missing = %v, want %v", got.Missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestAssets_GetRates_PricesAge(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	a := NewAssetsWithCache(stubClient{[]coinapi.Asset{{ID: "BTC", PriceUSD: 40000}}, nil}, coinapi.NewCacheWithTTL(time.Hour, clk), clk)
+	if _, err := a.GetRates("BTC", nil); err != nil {
+		t.Fatalf("Assets.GetRates() error = %v", err)
+	}
+
+	clk.Advance(30 * time.Second)
+	got, err := a.GetRates("BTC", nil)
+	if err != nil {
+		t.Fatalf("Assets.GetRates() error = %v", err)
+	}
+	if !got.Prices.Updated.Equal(testNow) || got.Prices.AgeSeconds != 30 {
+		t.Errorf("Assets.GetRates() prices = %+v, want updated at %v and 30 seconds old", got.Prices, testNow)
+	}
+}
@@ -0,0 +1,38 @@
+package svc
+
+import (
+	"log"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/google/uuid"
+)
+
+// latest security events returned for review
+const securityEventsLimit = 100
+
+// SecurityEvents service which logs events such as lockouts and keeps them for review
+type SecurityEvents struct {
+	DB    securityEventsDB
+	Clock clock.Clock
+}
+
+type securityEventsDB interface {
+	Create(event model.SecurityEvent) error
+	GetLatest(limit int) ([]model.SecurityEvent, error)
+}
+
+// Logs and saves an event of typ about subject of kind.
+// An event which can't be saved is still logged
+func (s SecurityEvents) Emit(typ, kind, subject, message string) {
+	event := model.SecurityEvent{ID: uuid.New().String(), Type: typ, Kind: kind, Subject: subject, Message: message, Created: s.Clock.Now().UTC()}
+	log.Printf("Security event %s of %s %s: %s", typ, kind, subject, message)
+	if err := s.DB.Create(event); err != nil {
+		log.Printf("Could not save security event %s, %v", event.ID, err)
+	}
+}
+
+// gets the latest security events, newest first
+func (s SecurityEvents) GetLatest() ([]model.SecurityEvent, error) {
+	return s.DB.GetLatest(securityEventsLimit)
+}
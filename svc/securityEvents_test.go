@@ -0,0 +1,49 @@
+package svc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubSecurityEventsDB struct {
+	events []model.SecurityEvent
+	err    error
+}
+
+func (s *stubSecurityEventsDB) Create(event model.SecurityEvent) error {
+	if s.err == nil {
+		s.events = append(s.events, event)
+	}
+	return s.err
+}
+func (s *stubSecurityEventsDB) GetLatest(limit int) ([]model.SecurityEvent, error) {
+	return s.events, s.err
+}
+
+func TestSecurityEvents_Emit(t *testing.T) {
+	db := &stubSecurityEventsDB{}
+	s := SecurityEvents{DB: db, Clock: clock.NewFake(testNow)}
+
+	s.Emit(model.EventLoginLockout, model.LockoutUsername, "u1", "locked")
+	s.Emit(model.EventLoginLockout, model.LockoutIP, "10.0.0.1", "locked")
+
+	events, err := s.GetLatest()
+	if err != nil || len(events) != 2 {
+		t.Fatalf("SecurityEvents.GetLatest() = %v, %v, want 2 events", events, err)
+	}
+	event := events[0]
+	if event.ID == "" || event.ID == events[1].ID || event.Type != model.EventLoginLockout || event.Kind != model.LockoutUsername ||
+		event.Subject != "u1" || event.Message != "locked" || !event.Created.Equal(testNow) {
+		t.Errorf("SecurityEvents.Emit() saved %+v", event)
+	}
+}
+
+func TestSecurityEvents_Emit_NotSaved(t *testing.T) {
+	s := SecurityEvents{DB: &stubSecurityEventsDB{err: fmt.Errorf("db down")}, Clock: clock.NewFake(testNow)}
+
+	// the event is only logged
+	s.Emit(model.EventLoginLockout, model.LockoutUsername, "u1", "locked")
+}
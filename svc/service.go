@@ -1,10 +1,22 @@
 package svc
 
 import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
 	"github.com/MonikaPalova/currency-master/coinapi"
 	"github.com/MonikaPalova/currency-master/config"
 	"github.com/MonikaPalova/currency-master/db"
-	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/mail"
+	"github.com/MonikaPalova/currency-master/market"
+	"github.com/MonikaPalova/currency-master/notify"
+	"github.com/MonikaPalova/currency-master/oidc"
+	"github.com/MonikaPalova/currency-master/password"
+	"github.com/MonikaPalova/currency-master/replay"
+	"github.com/MonikaPalova/currency-master/signing"
+	"github.com/MonikaPalova/currency-master/simulator"
 )
 
 // object that contains all services used in project
@@ -13,14 +25,121 @@ type Service struct {
 	USvc  *Users
 	UaSvc *UserAssets
 	SSvc  *Sessions
+	KSvc  *APIKeys
+	AcSvc *Accounts
+	TfSvc *TwoFactor
+	IdSvc *Identities
+	LSvc  *Lockouts
+	EvSvc *SecurityEvents
+	AlSvc *Alerts
+	StSvc *Settlements
+	WSvc  *Watchlists
+	MSvc  *Movers
+	// controls the replay of historical prices, nil if prices are not replayed
+	Replay *replay.Replayer
+	// client of the OpenID Connect provider, nil if the login with it is disabled
+	OIDC *oidc.Client
+	// executes buy and sell orders with market impact
+	Exec *market.Impact
+	// clock of all services
+	Clock clock.Clock
 }
 
 // cosntructor
-func NewSvc(db *db.Database) *Service {
-	aSvc := NewAssets(coinapi.NewClient())
-	uSvc := &Users{UDB: db.UsersDBHandler, v: valuator{svc: aSvc}}
+// returns error if the configured price provider, mailer, signing key or webhook networks can't be created
+func NewSvc(db *db.Database) (*Service, error) {
+	clk := clock.Real{}
+	pricesConfig := config.NewPrices()
+	provider, err := newPriceProvider(pricesConfig, clk)
+	if err != nil {
+		return nil, err
+	}
+	spread, err := market.NewSpread(config.NewSpread())
+	if err != nil {
+		return nil, err
+	}
+	aSvc := NewAssetsWithCache(provider, coinapi.NewCacheWithTTL(pricesConfig.CacheTTL, clk), clk)
+	aSvc.UseSpread(spread)
+	aSvc.KeepLastPrices(db.LastPricesDBHandler)
+	// simulated and replayed prices are generated again on restart
+	if pricesConfig.Source == config.PriceSourceCoinAPI {
+		aSvc.KeepSnapshot(pricesConfig.SnapshotFile, pricesConfig.SnapshotMaxAge)
+	}
+	uSvc := &Users{UDB: db.UsersDBHandler, Hasher: password.Hasher{Cost: config.NewPassword().HashCost}, Clock: clk, v: valuator{svc: aSvc}}
 	uaSvc := &UserAssets{UaDB: db.UserAssetsDBHandler, v: valuator{svc: aSvc}}
-	sSvc := &Sessions{sessions: map[string]model.Session{}, Config: config.NewSession()}
+	sessionConfig := config.NewSession()
+	sessionStore, err := NewSessionStore(sessionConfig, db.SessionsDBHandler)
+	if err != nil {
+		return nil, err
+	}
+	sSvc := &Sessions{Store: sessionStore, Config: sessionConfig, Clock: clk}
+	kSvc := &APIKeys{KDB: db.APIKeysDBHandler, Clock: clk}
+	if signingConfig := config.NewSigning(); signingConfig.SecretKey != "" {
+		box, err := signing.NewSecretBox(signingConfig.SecretKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not read SIGNING_SECRET_KEY, %v", err)
+		}
+		kSvc.Secrets = box
+	}
+	mailer, err := mail.New(config.NewMail())
+	if err != nil {
+		return nil, err
+	}
+	acSvc := &Accounts{UDB: db.UsersDBHandler, TDB: db.TokensDBHandler, Mailer: mailer, Hasher: uSvc.Hasher, Config: config.NewAccount(), Clock: clk}
+	tfSvc := &TwoFactor{DB: db.TwoFactorDBHandler, TDB: db.TokensDBHandler, Config: config.NewTwoFactor(), Clock: clk}
+	idSvc := &Identities{IDB: db.IdentitiesDBHandler, UDB: db.UsersDBHandler, USvc: uSvc, Clock: clk}
+	var oidcClient *oidc.Client
+	if oidcConfig := config.NewOIDC(); oidcConfig.Issuer != "" {
+		oidcClient = oidc.NewClient(oidcConfig, clk)
+	}
+	evSvc := &SecurityEvents{DB: db.SecurityEventsDBHandler, Clock: clk}
+	lSvc := &Lockouts{DB: db.LoginFailuresDBHandler, Events: evSvc, Config: config.NewLockout(), Clock: clk}
+	notifyConfig := config.NewNotify()
+	webhooks, err := notify.NewWebhookGuard(notifyConfig.WebhookAllowedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	alSvc := &Alerts{AlDB: db.PriceAlertsDBHandler, NDB: db.NotificationsDBHandler, UDB: db.UsersDBHandler, ASvc: aSvc, Clock: clk,
+		Notifier: notify.NewDispatcher(db.NotificationsDBHandler, mailer, notifyConfig, webhooks), Webhooks: webhooks}
+	aSvc.OnRefresh(func(assets []coinapi.Asset, updated time.Time) { go alSvc.Evaluate(assets, updated) })
+	stSvc := &Settlements{UaDB: db.UserAssetsDBHandler, ASvc: aSvc, Clock: clk}
+	history := &PriceHistory{DB: db.PriceHistoryDBHandler, Config: config.NewHistory()}
+	wSvc := &Watchlists{WDB: db.WatchlistsDBHandler, ASvc: aSvc, History: history, Clock: clk}
+	mSvc := &Movers{History: history, ADB: db.AcquisitionsDBHandler, Limit: config.NewMovers().Limit}
+	aSvc.OnRefresh(func(assets []coinapi.Asset, updated time.Time) { go mSvc.Update(assets, updated) })
+
+	replayer, _ := provider.(*replay.Replayer)
+	return &Service{ASvc: aSvc, USvc: uSvc, UaSvc: uaSvc, SSvc: sSvc, KSvc: kSvc, AcSvc: acSvc, TfSvc: tfSvc, IdSvc: idSvc, LSvc: lSvc, EvSvc: evSvc, AlSvc: alSvc, StSvc: stSvc, WSvc: wSvc, MSvc: mSvc, Replay: replayer,
+		OIDC: oidcClient, Exec: market.NewImpact(config.NewExecution(), clk), Clock: clk}, nil
+}
 
-	return &Service{ASvc: aSvc, USvc: uSvc, UaSvc: uaSvc, SSvc: sSvc}
+// creates the source of asset prices - the external api, the market simulator or the replay of historical prices
+// the replay moves on clk
+func newPriceProvider(prices *config.Prices, clk clock.Clock) (coinAPIClient, error) {
+	switch prices.Source {
+	case "", config.PriceSourceCoinAPI:
+		return coinapi.NewClient(), nil
+	case config.PriceSourceSimulator:
+		scenario := simulator.DefaultScenario()
+		if prices.SimulatorScenario != "" {
+			var err error
+			if scenario, err = simulator.LoadScenario(prices.SimulatorScenario); err != nil {
+				return nil, err
+			}
+		}
+		log.Printf("Simulating prices of %d assets with seed %d", len(scenario.Assets), prices.SimulatorSeed)
+		return simulator.New(*scenario, prices.SimulatorSeed, prices.SimulatorStep)
+	case config.PriceSourceReplay:
+		if prices.ReplayFile == "" {
+			return nil, fmt.Errorf("replay file is not set")
+		}
+		tape, err := replay.LoadFile(prices.ReplayFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Replaying prices from %v to %v with speed %f", tape.Start(), tape.End(), prices.ReplaySpeed)
+		return replay.New(tape, prices.ReplaySpeed, clk)
+	default:
+		return nil, fmt.Errorf("unknown price source %s", prices.Source)
+	}
 }
@@ -0,0 +1,64 @@
+package svc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/simulator"
+)
+
+func Test_newPriceProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		prices  config.Prices
+		want    string
+		wantErr bool
+	}{
+		{"coinapi", config.Prices{Source: config.PriceSourceCoinAPI}, "*coinapi.Client", false},
+		{"default", config.Prices{}, "*coinapi.Client", false},
+		{"simulator", config.Prices{Source: config.PriceSourceSimulator, SimulatorSeed: 1, SimulatorStep: time.Minute}, "*simulator.Simulator", false},
+		{"simulator missing scenario", config.Prices{Source: config.PriceSourceSimulator, SimulatorScenario: "missing.json", SimulatorStep: time.Minute}, "", true},
+		{"replay", config.Prices{Source: config.PriceSourceReplay, ReplayFile: "../replay/testdata/crash2021.csv", ReplaySpeed: 60}, "*replay.Replayer", false},
+		{"replay without file", config.Prices{Source: config.PriceSourceReplay, ReplaySpeed: 60}, "", true},
+		{"unknown", config.Prices{Source: "oracle"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newPriceProvider(&tt.prices, clock.NewFake(testNow))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPriceProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if gotType := fmt.Sprintf("%T", got); gotType != tt.want {
+				t.Errorf("newPriceProvider() = %s, want %s", gotType, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssets_Simulated(t *testing.T) {
+	sim, err := simulator.New(*simulator.DefaultScenario(), 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(testNow)
+	a := NewAssetsWithCache(sim, coinapi.NewCacheWithTTL(time.Hour, clk), clk)
+
+	before, err := a.GetAssetById("BTC")
+	if err != nil || before == nil {
+		t.Fatalf("GetAssetById() = %v, %v", before, err)
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := a.GetAssetById("BTC")
+	if after.PriceUSD == before.PriceUSD {
+		t.Errorf("simulated price didn't move after reload")
+	}
+}
@@ -0,0 +1,98 @@
+package svc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// Storage of sessions, safe for concurrent use
+type SessionStore interface {
+	// get session by id, nil if it doesn't exist
+	Get(id string) (*model.Session, error)
+	// save new session or replace the session with the same id
+	Save(session model.Session) error
+	// delete session by id, nothing happens if it doesn't exist
+	Delete(id string) error
+	// delete the sessions expired at now and return their number
+	DeleteExpired(now time.Time) (int, error)
+	// delete the sessions of username except the one with id except and return their number
+	DeleteByUsername(username, except string) (int, error)
+}
+
+// Creates the configured session store, whose expired sessions are deleted by Sessions.StartCleanup.
+// Sessions in memory are lost on restart, the ones in the database are shared by all instances
+func NewSessionStore(cfg *config.Session, db SessionStore) (SessionStore, error) {
+	switch cfg.Store {
+	case "", config.SessionStoreMemory:
+		return NewMemorySessionStore(), nil
+	case config.SessionStoreMysql:
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown session store %s", cfg.Store)
+	}
+}
+
+// Session store which keeps sessions in memory
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]model.Session
+}
+
+// Constructor
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]model.Session{}}
+}
+
+func (m *MemorySessionStore) Get(id string) (*model.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (m *MemorySessionStore) Save(session model.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteExpired(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for id, session := range m.sessions {
+		if session.IsExpired(now) {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemorySessionStore) DeleteByUsername(username, except string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for id, session := range m.sessions {
+		if session.Username == username && id != except {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/MonikaPalova/currency-master/clock"
 	"github.com/MonikaPalova/currency-master/config"
 	"github.com/MonikaPalova/currency-master/model"
 	"github.com/google/uuid"
@@ -13,31 +15,38 @@ import (
 
 // Sessions service to handle sessions
 type Sessions struct {
-	sessions map[string]model.Session
-	Config   *config.Session
+	Store  SessionStore
+	Config *config.Session
+	Clock  clock.Clock
 }
 
 // Gets session by id if exists.
 // Returns error if sessions doesn't exist or is expired
 func (s Sessions) GetByID(id string) (*model.Session, error) {
-	session, ok := s.sessions[id]
-	if !ok {
+	session, err := s.Store.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve session with id %s, %v", id, err)
+	}
+	if session == nil {
 		return nil, fmt.Errorf("session with id %s doesn't exist", id)
 	}
-	if session.IsExpired() {
+	if session.IsExpired(s.Clock.Now()) {
 		return nil, fmt.Errorf("session with id %s is expired", id)
 	}
-	return &session, nil
+	return session, nil
 }
 
-// Creates new session for username and a session cookie
-func (s Sessions) CreateCookie(username string) *http.Cookie {
+// Creates new session for username and a session cookie.
+// Returns error if the session can't be stored
+func (s Sessions) CreateCookie(username string) (*http.Cookie, error) {
 	session := model.Session{
 		ID:         uuid.New().String(),
 		Username:   username,
-		Expiration: time.Now().Add(s.Config.SessionDuration),
+		Expiration: s.Clock.Now().Add(s.Config.SessionDuration),
+	}
+	if err := s.Store.Save(session); err != nil {
+		return nil, fmt.Errorf("could not store session, %v", err)
 	}
-	s.sessions[session.ID] = session
 
 	sessionCookie := http.Cookie{
 		Name:    s.Config.SessionCookieName,
@@ -45,29 +54,65 @@ func (s Sessions) CreateCookie(username string) *http.Cookie {
 		Expires: session.Expiration,
 	}
 
-	return &sessionCookie
+	return &sessionCookie, nil
+}
+
+// Deletes the session, so its cookie is no longer valid
+func (s Sessions) Delete(id string) error {
+	if err := s.Store.Delete(id); err != nil {
+		return fmt.Errorf("could not delete session with id %s, %v", id, err)
+	}
+	log.Printf("Deleted session with id %s", id)
+	return nil
 }
 
-// Invalidates the cookie but keeps it in the array, to be deleted by the cron job
-func (s Sessions) Delete(id string) {
-	session, ok := s.sessions[id]
-	if ok {
-		session.Expiration = time.Now().Add(-time.Hour)
-		s.sessions[id] = session
+// Deletes the sessions of username except the one with id keep, so only its cookie stays valid.
+// All sessions are deleted if keep is empty
+func (s Sessions) DeleteOthers(username, keep string) error {
+	deleted, err := s.Store.DeleteByUsername(username, keep)
+	if err != nil {
+		return fmt.Errorf("could not delete sessions of user %s, %v", username, err)
 	}
-	log.Printf("Invalidated session with id %s", id)
+	log.Printf("Deleted %d other sessions of user %s", deleted, username)
+	return nil
 }
 
-// Clears expired sessions
-func (s *Sessions) ClearExpired() {
-	old := len(s.sessions)
-	validSessions := map[string]model.Session{}
-	for _, session := range s.sessions {
-		if !session.IsExpired() {
-			fmt.Println("adding session " + session.ID)
-			validSessions[session.ID] = session
+// Deletes the sessions expired now and returns their number
+func (s Sessions) DeleteExpired() (int, error) {
+	deleted, err := s.Store.DeleteExpired(s.Clock.Now())
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired sessions, %v", err)
+	}
+	return deleted, nil
+}
+
+// Deletes the expired sessions every cleanup interval until stop is called, which waits for a running cleanup
+func (s Sessions) StartCleanup() (stop func()) {
+	ticker := time.NewTicker(s.Config.CleanupInterval)
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				deleted, err := s.DeleteExpired()
+				if err != nil {
+					log.Printf("Could not clear expired sessions, %v", err)
+					continue
+				}
+				log.Printf("Cleared expired sessions. Deleted: %d", deleted)
+			}
 		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+		<-exited
 	}
-	s.sessions = validSessions
-	log.Printf("Cleared expired sessions. Deleted: %d", old-len(s.sessions))
 }
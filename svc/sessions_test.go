@@ -1,13 +1,20 @@
 package svc
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
 	"github.com/MonikaPalova/currency-master/model"
 )
 
+// time at which the fake clocks of the service tests start
+var testNow = time.Date(2022, 2, 17, 10, 0, 0, 0, time.UTC)
+
 func TestSessions_GetByID(t *testing.T) {
 	type fields struct {
 		session model.Session
@@ -21,13 +28,15 @@ func TestSessions_GetByID(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		{"ok", fields{model.Session{ID: "sid1", Username: "u1", Expiration: time.Now().Add(time.Hour)}}, args{"sid1"}, false},
+		{"ok", fields{model.Session{ID: "sid1", Username: "u1", Expiration: testNow.Add(time.Hour)}}, args{"sid1"}, false},
 		{"not exist", fields{model.Session{ID: "sid2"}}, args{"sid1"}, true},
-		{"expired", fields{model.Session{ID: "sid1", Username: "u1", Expiration: time.Now().Add(-time.Hour)}}, args{"sid1"}, true},
+		{"expired", fields{model.Session{ID: "sid1", Username: "u1", Expiration: testNow.Add(-time.Hour)}}, args{"sid1"}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := Sessions{sessions: map[string]model.Session{tt.fields.session.ID: tt.fields.session}}
+			store := NewMemorySessionStore()
+			store.Save(tt.fields.session)
+			s := Sessions{Store: store, Clock: clock.NewFake(testNow)}
 			got, err := s.GetByID(tt.args.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Sessions.GetByID() error = %v, wantErr %v", err, tt.wantErr)
@@ -45,10 +54,14 @@ func TestSessions_GetByID(t *testing.T) {
 }
 
 func TestSessions_Delete(t *testing.T) {
-	sid1 := model.Session{ID: "sid1", Username: "u1", Expiration: time.Now().Add(time.Hour)}
-	s := Sessions{sessions: map[string]model.Session{sid1.ID: sid1}}
+	sid1 := model.Session{ID: "sid1", Username: "u1", Expiration: testNow.Add(time.Hour)}
+	store := NewMemorySessionStore()
+	store.Save(sid1)
+	s := Sessions{Store: store, Clock: clock.NewFake(testNow)}
 
-	s.Delete(sid1.ID)
+	if err := s.Delete(sid1.ID); err != nil {
+		t.Fatalf("Sessions.Delete() error = %v", err)
+	}
 
 	_, err := s.GetByID(sid1.ID)
 	if err == nil {
@@ -56,15 +69,207 @@ func TestSessions_Delete(t *testing.T) {
 	}
 }
 
-func TestSessions_ClearExpired(t *testing.T) {
-	sid1 := model.Session{ID: "sid1", Username: "u1", Expiration: time.Now().Add(time.Hour)}
-	sid2 := model.Session{ID: "sid2", Username: "u2", Expiration: time.Now().Add(-time.Hour)}
-	s := Sessions{sessions: map[string]model.Session{sid1.ID: sid1, sid2.ID: sid2}}
+func TestSessions_DeleteOthers(t *testing.T) {
+	tests := []struct {
+		name string
+		keep string
+		want []string
+	}{
+		{"keeps current session", "sid1", []string{"sid1", "sid3"}},
+		{"deletes all sessions", "", []string{"sid3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemorySessionStore()
+			store.Save(model.Session{ID: "sid1", Username: "u1", Expiration: testNow.Add(time.Hour)})
+			store.Save(model.Session{ID: "sid2", Username: "u1", Expiration: testNow.Add(time.Hour)})
+			store.Save(model.Session{ID: "sid3", Username: "u2", Expiration: testNow.Add(time.Hour)})
+			s := Sessions{Store: store, Clock: clock.NewFake(testNow)}
 
-	s.ClearExpired()
+			if err := s.DeleteOthers("u1", tt.keep); err != nil {
+				t.Fatalf("Sessions.DeleteOthers() error = %v", err)
+			}
+			for _, id := range []string{"sid1", "sid2", "sid3"} {
+				_, err := s.GetByID(id)
+				if valid, want := err == nil, contains(tt.want, id); valid != want {
+					t.Errorf("session %s valid = %v, want %v", id, valid, want)
+				}
+			}
+		})
+	}
+}
+
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMemorySessionStore_DeleteExpired(t *testing.T) {
+	sid1 := model.Session{ID: "sid1", Username: "u1", Expiration: testNow.Add(time.Hour)}
+	sid2 := model.Session{ID: "sid2", Username: "u2", Expiration: testNow.Add(-time.Hour)}
+	store := NewMemorySessionStore()
+	store.Save(sid1)
+	store.Save(sid2)
+
+	deleted, err := store.DeleteExpired(testNow)
+	if err != nil || deleted != 1 {
+		t.Fatalf("MemorySessionStore.DeleteExpired() = %d, %v, want 1, nil", deleted, err)
+	}
 
 	want := map[string]model.Session{sid1.ID: sid1}
-	if !reflect.DeepEqual(s.sessions, want) {
-		t.Fatalf("expired sessions should be deleted. Want: %v, got: %v", want, s.sessions)
+	if !reflect.DeepEqual(store.sessions, want) {
+		t.Fatalf("expired sessions should be deleted. Want: %v, got: %v", want, store.sessions)
+	}
+}
+
+func TestMemorySessionStore_Concurrent(t *testing.T) {
+	store := NewMemorySessionStore()
+	s := Sessions{Store: store, Config: &config.Session{SessionDuration: time.Hour}, Clock: clock.NewFake(testNow)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cookie, err := s.CreateCookie("u1")
+			if err != nil {
+				t.Errorf("Sessions.CreateCookie() error = %v", err)
+				return
+			}
+			s.GetByID(cookie.Value)
+			store.DeleteExpired(testNow)
+			s.Delete(cookie.Value)
+		}()
+	}
+	wg.Wait()
+
+	if len(store.sessions) != 0 {
+		t.Errorf("all sessions should be deleted, got %v", store.sessions)
+	}
+}
+
+type stubSessionStore struct {
+	err error
+}
+
+func (s stubSessionStore) Get(id string) (*model.Session, error) {
+	return nil, s.err
+}
+func (s stubSessionStore) Save(session model.Session) error {
+	return s.err
+}
+func (s stubSessionStore) Delete(id string) error {
+	return s.err
+}
+func (s stubSessionStore) DeleteExpired(now time.Time) (int, error) {
+	return 0, s.err
+}
+func (s stubSessionStore) DeleteByUsername(username, except string) (int, error) {
+	return 0, s.err
+}
+
+func TestSessions_StoreError(t *testing.T) {
+	s := Sessions{Store: stubSessionStore{err: fmt.Errorf("")}, Config: &config.Session{SessionDuration: time.Hour}, Clock: clock.NewFake(testNow)}
+
+	if _, err := s.CreateCookie("u1"); err == nil {
+		t.Errorf("Sessions.CreateCookie() should fail when the session can't be stored")
+	}
+	if _, err := s.GetByID("sid1"); err == nil {
+		t.Errorf("Sessions.GetByID() should fail when the store fails")
+	}
+	if err := s.Delete("sid1"); err == nil {
+		t.Errorf("Sessions.Delete() should fail when the store fails")
+	}
+	if err := s.DeleteOthers("u1", "sid1"); err == nil {
+		t.Errorf("Sessions.DeleteOthers() should fail when the store fails")
+	}
+}
+
+func TestNewSessionStore(t *testing.T) {
+	db := stubSessionStore{}
+	tests := []struct {
+		name    string
+		store   string
+		want    SessionStore
+		wantErr bool
+	}{
+		{"memory", config.SessionStoreMemory, NewMemorySessionStore(), false},
+		{"mysql", config.SessionStoreMysql, db, false},
+		{"unknown", "redis", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSessionStore(&config.Session{Store: tt.store}, db)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSessionStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewSessionStore() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessions_DeleteExpired(t *testing.T) {
+	store := NewMemorySessionStore()
+	store.Save(model.Session{ID: "sid1", Username: "u1", Expiration: testNow.Add(-time.Minute)})
+	store.Save(model.Session{ID: "sid2", Username: "u1", Expiration: testNow.Add(time.Minute)})
+	s := Sessions{Store: store, Clock: clock.NewFake(testNow)}
+
+	deleted, err := s.DeleteExpired()
+	if err != nil || deleted != 1 {
+		t.Fatalf("Sessions.DeleteExpired() = %v, %v, want 1", deleted, err)
+	}
+	if got, _ := store.Get("sid2"); got == nil {
+		t.Errorf("Sessions.DeleteExpired() deleted a session which expires later by the clock")
+	}
+}
+
+func TestSessions_StartCleanup(t *testing.T) {
+	store := NewMemorySessionStore()
+	s := Sessions{Store: store, Config: &config.Session{CleanupInterval: time.Millisecond}, Clock: clock.NewFake(testNow)}
+	stop := s.StartCleanup()
+
+	store.Save(model.Session{ID: "sid1", Username: "u1", Expiration: testNow.Add(-time.Minute)})
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if got, _ := store.Get("sid1"); got == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Sessions.StartCleanup() didn't delete the expired session")
+		}
+	}
+
+	stop()
+	stop()
+	store.Save(model.Session{ID: "sid2", Username: "u1", Expiration: testNow.Add(-time.Minute)})
+	time.Sleep(20 * time.Millisecond)
+	if got, _ := store.Get("sid2"); got == nil {
+		t.Errorf("Sessions.StartCleanup() kept deleting sessions after it was stopped")
+	}
+}
+
+func TestSessions_CreateCookie(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	s := Sessions{Store: NewMemorySessionStore(), Config: &config.Session{SessionDuration: time.Hour, SessionCookieName: "session"}, Clock: clk}
+
+	cookie, err := s.CreateCookie("u1")
+	if err != nil {
+		t.Fatalf("Sessions.CreateCookie() error = %v", err)
+	}
+	if !cookie.Expires.Equal(testNow.Add(time.Hour)) {
+		t.Fatalf("cookie should expire at %v, got %v", testNow.Add(time.Hour), cookie.Expires)
+	}
+	if _, err := s.GetByID(cookie.Value); err != nil {
+		t.Fatalf("session should be valid before it expires, %v", err)
+	}
+
+	clk.Advance(time.Hour + time.Second)
+	if _, err := s.GetByID(cookie.Value); err == nil {
+		t.Errorf("session should be expired after the session duration")
 	}
 }
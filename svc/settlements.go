@@ -0,0 +1,107 @@
+package svc
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+// Settlements service which lists delisted assets still held by users and settles them into usd
+type Settlements struct {
+	UaDB  heldAssetsDB
+	ASvc  *Assets
+	Clock clock.Clock
+}
+
+type heldAssetsDB interface {
+	GetHeldAssetIds() ([]string, error)
+	GetByAssetId(id string) ([]model.UserAsset, error)
+	// remove the holding, credit the user and record the settlement at once, nil if the user doesn't hold the asset
+	Settle(username, id string, priceUSD float64, settled time.Time) (*model.SettledHolding, error)
+}
+
+// Error returned when settling an asset which still has a current price
+type AssetListedError struct {
+	ID string
+}
+
+func (e AssetListedError) Error() string {
+	return fmt.Sprintf("asset with id %s is still listed", e.ID)
+}
+
+// Gets the assets held by users which are no longer listed by the price provider or are listed without a price
+func (s Settlements) GetDelisted() ([]model.DelistedAsset, error) {
+	ids, err := s.UaDB.GetHeldAssetIds()
+	if err != nil {
+		return nil, err
+	}
+
+	delisted := []model.DelistedAsset{}
+	for _, id := range ids {
+		asset, err := s.ASvc.GetAssetById(id)
+		if err != nil {
+			return nil, err
+		}
+		if listed(asset) {
+			continue
+		}
+
+		holdings, err := s.UaDB.GetByAssetId(id)
+		if err != nil {
+			return nil, err
+		}
+		d := model.DelistedAsset{AssetId: id, Holders: len(holdings), LastPrice: s.ASvc.LastPrice(id)}
+		for _, holding := range holdings {
+			d.Name = holding.Name
+			d.Quantity += holding.Quantity
+		}
+		delisted = append(delisted, d)
+	}
+	return delisted, nil
+}
+
+// Settles all holdings of a delisted asset into usd at priceUSD.
+// Every holder is credited with the usd value of their quantity and the holding is removed in one transaction,
+// so a failed settlement leaves the holding and can be retried.
+// Returns AssetListedError if the asset is still listed with a price and AssetNotFoundError if nobody holds it
+func (s Settlements) Settle(id string, priceUSD float64) (*model.Settlement, error) {
+	if priceUSD < 0 {
+		return nil, fmt.Errorf("settlement price should not be negative")
+	}
+	asset, err := s.ASvc.GetAssetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if listed(asset) {
+		return nil, AssetListedError{ID: id}
+	}
+
+	holdings, err := s.UaDB.GetByAssetId(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(holdings) == 0 {
+		return nil, AssetNotFoundError{ID: id}
+	}
+
+	settlement := model.Settlement{AssetId: id, PriceUSD: priceUSD, Holders: []model.SettledHolding{}}
+	settled := s.Clock.Now()
+	for _, holding := range holdings {
+		settledHolding, err := s.UaDB.Settle(holding.Username, id, priceUSD, settled)
+		if err != nil {
+			return nil, fmt.Errorf("could not settle holding of asset %s of user %s after settling %d holdings, %v", id, holding.Username, len(settlement.Holders), err)
+		}
+		// sold since it was listed
+		if settledHolding == nil {
+			continue
+		}
+		log.Printf("Settled %f of delisted asset %s of user %s for %f usd", settledHolding.Quantity, id, holding.Username, settledHolding.USD)
+
+		settlement.Holders = append(settlement.Holders, *settledHolding)
+		settlement.TotalUSD += settledHolding.USD
+	}
+	return &settlement, nil
+}
@@ -0,0 +1,160 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubHeldDB struct {
+	holdings map[string][]model.UserAsset
+	err      error
+	// usd of the users, crediting a user without balance fails
+	balances map[string]float64
+	settled  *[]model.SettledHolding
+}
+
+func (s stubHeldDB) GetHeldAssetIds() ([]string, error) {
+	ids := []string{}
+	for id := range s.holdings {
+		ids = append(ids, id)
+	}
+	return ids, s.err
+}
+func (s stubHeldDB) GetByAssetId(id string) ([]model.UserAsset, error) {
+	return s.holdings[id], s.err
+}
+func (s stubHeldDB) Settle(username, id string, priceUSD float64, settled time.Time) (*model.SettledHolding, error) {
+	for i, holding := range s.holdings[id] {
+		if holding.Username != username {
+			continue
+		}
+		if _, ok := s.balances[username]; !ok {
+			return nil, fmt.Errorf("user %s doesn't exist", username)
+		}
+		settledHolding := model.SettledHolding{Username: username, Quantity: holding.Quantity, USD: holding.Quantity * priceUSD}
+		s.holdings[id] = append(append([]model.UserAsset{}, s.holdings[id][:i]...), s.holdings[id][i+1:]...)
+		s.balances[username] += settledHolding.USD
+		*s.settled = append(*s.settled, settledHolding)
+		return &settledHolding, nil
+	}
+	return nil, nil
+}
+
+func TestSettlements_GetDelisted(t *testing.T) {
+	lastPrice := model.LastPrice{AssetId: "LUNA", PriceUSD: 0.5, Updated: testNow}
+	held := map[string][]model.UserAsset{
+		"BTC":  {{Username: "u1", AssetId: "BTC", Name: "Bitcoin", Quantity: 1}},
+		"LUNA": {{Username: "u1", AssetId: "LUNA", Name: "Terra", Quantity: 2}, {Username: "u2", AssetId: "LUNA", Name: "Terra", Quantity: 3}},
+	}
+	tests := []struct {
+		name    string
+		client  coinAPIClient
+		held    stubHeldDB
+		want    []model.DelistedAsset
+		wantErr bool
+	}{
+		{"delisted", stubClient{[]coinapi.Asset{{ID: "BTC", PriceUSD: 40000}}, nil}, stubHeldDB{holdings: held}, []model.DelistedAsset{{AssetId: "LUNA", Name: "Terra", Holders: 2, Quantity: 5, LastPrice: &lastPrice}}, false},
+		{"nothing delisted", stubClient{[]coinapi.Asset{{ID: "BTC", PriceUSD: 40000}, {ID: "LUNA", PriceUSD: 0.5}}, nil}, stubHeldDB{holdings: held}, []model.DelistedAsset{}, false},
+		{"listed without price", stubClient{[]coinapi.Asset{{ID: "BTC", PriceUSD: 40000}, {ID: "LUNA"}}, nil}, stubHeldDB{holdings: held}, []model.DelistedAsset{{AssetId: "LUNA", Name: "Terra", Holders: 2, Quantity: 5, LastPrice: &lastPrice}}, false},
+		{"price provider error", stubClient{nil, fmt.Errorf("")}, stubHeldDB{holdings: held}, nil, true},
+		{"db error", stubClient{nil, nil}, stubHeldDB{err: fmt.Errorf("")}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAssets(tt.client)
+			a.KeepLastPrices(stubLpDB{prices: map[string]model.LastPrice{"LUNA": lastPrice}})
+			s := Settlements{UaDB: tt.held, ASvc: a}
+			got, err := s.GetDelisted()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Settlements.GetDelisted() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Settlements.GetDelisted() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettlements_Settle(t *testing.T) {
+	tests := []struct {
+		name         string
+		client       coinAPIClient
+		id           string
+		price        float64
+		want         *model.Settlement
+		wantUSD      map[string]float64
+		wantListed   bool
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{"settled", stubClient{[]coinapi.Asset{{ID: "BTC"}}, nil}, "LUNA", 0.5, &model.Settlement{AssetId: "LUNA", PriceUSD: 0.5, TotalUSD: 2.5,
+			Holders: []model.SettledHolding{{Username: "u1", Quantity: 2, USD: 1}, {Username: "u2", Quantity: 3, USD: 1.5}}}, map[string]float64{"u1": 101, "u2": 101.5}, false, false, false},
+		{"written off", stubClient{[]coinapi.Asset{{ID: "BTC"}}, nil}, "LUNA", 0, &model.Settlement{AssetId: "LUNA", TotalUSD: 0,
+			Holders: []model.SettledHolding{{Username: "u1", Quantity: 2}, {Username: "u2", Quantity: 3}}}, map[string]float64{"u1": 100, "u2": 100}, false, false, false},
+		{"still listed", stubClient{[]coinapi.Asset{{ID: "LUNA", PriceUSD: 0.6}}, nil}, "LUNA", 0.5, nil, map[string]float64{"u1": 100, "u2": 100}, true, false, true},
+		{"listed without price", stubClient{[]coinapi.Asset{{ID: "LUNA"}}, nil}, "LUNA", 0.5, &model.Settlement{AssetId: "LUNA", PriceUSD: 0.5, TotalUSD: 2.5,
+			Holders: []model.SettledHolding{{Username: "u1", Quantity: 2, USD: 1}, {Username: "u2", Quantity: 3, USD: 1.5}}}, map[string]float64{"u1": 101, "u2": 101.5}, false, false, false},
+		{"not held", stubClient{[]coinapi.Asset{{ID: "BTC"}}, nil}, "DOGE", 0.5, nil, map[string]float64{"u1": 100, "u2": 100}, false, true, true},
+		{"negative price", stubClient{[]coinapi.Asset{{ID: "BTC"}}, nil}, "LUNA", -1, nil, map[string]float64{"u1": 100, "u2": 100}, false, false, true},
+		{"price provider error", stubClient{nil, fmt.Errorf("")}, "LUNA", 0.5, nil, map[string]float64{"u1": 100, "u2": 100}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			held := map[string][]model.UserAsset{"LUNA": {{Username: "u1", AssetId: "LUNA", Quantity: 2}, {Username: "u2", AssetId: "LUNA", Quantity: 3}}}
+			balances := map[string]float64{"u1": 100, "u2": 100}
+			settled := []model.SettledHolding{}
+			s := Settlements{UaDB: stubHeldDB{holdings: held, balances: balances, settled: &settled}, ASvc: NewAssets(tt.client), Clock: clock.NewFake(testNow)}
+			got, err := s.Settle(tt.id, tt.price)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Settlements.Settle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if listed := errors.As(err, &AssetListedError{}); listed != tt.wantListed {
+				t.Fatalf("Settlements.Settle() listed error = %v, want %v", listed, tt.wantListed)
+			}
+			if notFound := errors.As(err, &AssetNotFoundError{}); notFound != tt.wantNotFound {
+				t.Fatalf("Settlements.Settle() not found error = %v, want %v", notFound, tt.wantNotFound)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Settlements.Settle() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(balances, tt.wantUSD) {
+				t.Errorf("Settlements.Settle() balances = %v, want %v", balances, tt.wantUSD)
+			}
+			if err == nil && len(held[tt.id]) != 0 {
+				t.Errorf("Settlements.Settle() kept holdings %+v", held[tt.id])
+			}
+		})
+	}
+}
+
+func TestSettlements_Settle_CreditFails(t *testing.T) {
+	held := map[string][]model.UserAsset{"LUNA": {{Username: "u1", AssetId: "LUNA", Quantity: 2}, {Username: "u2", AssetId: "LUNA", Quantity: 3}}}
+	// u2 can't be credited
+	balances := map[string]float64{"u1": 100}
+	settled := []model.SettledHolding{}
+	s := Settlements{UaDB: stubHeldDB{holdings: held, balances: balances, settled: &settled}, ASvc: NewAssets(stubClient{[]coinapi.Asset{{ID: "BTC"}}, nil}), Clock: clock.NewFake(testNow)}
+
+	if _, err := s.Settle("LUNA", 0.5); err == nil {
+		t.Fatalf("Settlements.Settle() error = nil, want the credit error")
+	}
+	if want := []model.UserAsset{{Username: "u2", AssetId: "LUNA", Quantity: 3}}; !reflect.DeepEqual(held["LUNA"], want) {
+		t.Errorf("Settlements.Settle() holdings = %+v, want the holding of u2 to survive", held["LUNA"])
+	}
+	if want := []model.SettledHolding{{Username: "u1", Quantity: 2, USD: 1}}; !reflect.DeepEqual(settled, want) {
+		t.Errorf("Settlements.Settle() recorded %+v, want %+v", settled, want)
+	}
+
+	// the settlement is retried once the user can be credited
+	balances["u2"] = 100
+	got, err := s.Settle("LUNA", 0.5)
+	if err != nil || len(got.Holders) != 1 || got.Holders[0].Username != "u2" || balances["u2"] != 101.5 {
+		t.Errorf("Settlements.Settle() retry = %+v, %v, balances %v", got, err, balances)
+	}
+}
@@ -0,0 +1,207 @@
+package svc
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/totp"
+)
+
+const (
+	// recovery codes given to a user at a time
+	recoveryCodesCount = 10
+	// characters of a recovery code, shown in two halves
+	recoveryCodeLength = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactor service for TOTP two-factor authentication with recovery codes
+type TwoFactor struct {
+	DB     twoFactorDB
+	TDB    tokensDB
+	Config *config.TwoFactor
+	Clock  clock.Clock
+}
+
+type twoFactorDB interface {
+	Get(username string) (*model.TwoFactor, error)
+	Enroll(username, secret string) error
+	Enable(username string, step int64, codeHashes []string) (bool, error)
+	UseStep(username string, step int64) (bool, error)
+	UseRecoveryCode(username, hash string) (bool, error)
+	ReplaceRecoveryCodes(username string, codeHashes []string) error
+	Delete(username string) error
+}
+
+// whether user enabled two-factor authentication
+func (t TwoFactor) IsEnabled(username string) (bool, error) {
+	tf, err := t.DB.Get(username)
+	if err != nil || tf == nil {
+		return false, err
+	}
+	return tf.Enabled, nil
+}
+
+// Starts the enrollment of user with a new secret, replacing an unconfirmed one.
+// Returns nil if two-factor authentication is already enabled
+func (t TwoFactor) Enroll(username string) (*model.TwoFactorEnrollment, error) {
+	enabled, err := t.IsEnabled(username)
+	if err != nil || enabled {
+		return nil, err
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate totp secret, %v", err)
+	}
+	if err := t.DB.Enroll(username, secret); err != nil {
+		return nil, err
+	}
+	return &model.TwoFactorEnrollment{Secret: secret, URI: totp.URI(t.Config.Issuer, username, secret)}, nil
+}
+
+// Enables two-factor authentication of user if code is a current code of their enrollment and gets their recovery codes.
+// Returns nil if the user has no unconfirmed enrollment or the code is not valid
+func (t TwoFactor) Confirm(username, code string) ([]string, error) {
+	tf, err := t.DB.Get(username)
+	if err != nil || tf == nil || tf.Enabled {
+		return nil, err
+	}
+	step, ok := totp.Match(tf.Secret, code, t.Clock.Now())
+	if !ok {
+		return nil, nil
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := t.DB.Enable(username, step, hashes)
+	if err != nil || !enabled {
+		return nil, err
+	}
+	log.Printf("Enabled two-factor authentication of user %s", username)
+	return codes, nil
+}
+
+// Disables two-factor authentication of user if code is a current or recovery code.
+// Returns false if it isn't enabled or the code is not valid
+func (t TwoFactor) Disable(username, code string) (bool, error) {
+	tf, err := t.DB.Get(username)
+	if err != nil || tf == nil || !tf.Enabled {
+		return false, err
+	}
+	if ok, err := t.verifyCodeOrRecoveryCode(*tf, code); err != nil || !ok {
+		return false, err
+	}
+
+	if err := t.DB.Delete(username); err != nil {
+		return false, err
+	}
+	log.Printf("Disabled two-factor authentication of user %s", username)
+	return true, nil
+}
+
+// Replaces the recovery codes of user with new ones.
+// Returns nil if two-factor authentication isn't enabled
+func (t TwoFactor) RegenerateRecoveryCodes(username string) ([]string, error) {
+	enabled, err := t.IsEnabled(username)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.DB.ReplaceRecoveryCodes(username, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Checks a code required by a sensitive action. True if user didn't enable two-factor authentication
+// or code is a current code of their authenticator app which wasn't used before
+func (t TwoFactor) VerifyFresh(username, code string) (bool, error) {
+	tf, err := t.DB.Get(username)
+	if err != nil {
+		return false, err
+	}
+	if tf == nil || !tf.Enabled {
+		return true, nil
+	}
+	return t.verifyCode(*tf, code)
+}
+
+// Starts a login of user waiting for the second factor and gets the token to complete it with
+func (t TwoFactor) StartLogin(username string) (string, error) {
+	return createToken(t.TDB, t.Clock.Now(), username, model.PurposeLogin2FA, t.Config.LoginTTL)
+}
+
+// Completes the login of token with a current or recovery code and gets the username.
+// The token is used up even by a wrong code, so every guess needs the password again.
+// Returns empty username if the token or the code is not valid
+func (t TwoFactor) CompleteLogin(token, code string) (string, error) {
+	used, err := useToken(t.TDB, t.Clock.Now(), token, model.PurposeLogin2FA)
+	if err != nil || used == nil {
+		return "", err
+	}
+	tf, err := t.DB.Get(used.Username)
+	if err != nil || tf == nil || !tf.Enabled {
+		return "", err
+	}
+	if ok, err := t.verifyCodeOrRecoveryCode(*tf, code); err != nil || !ok {
+		return "", err
+	}
+	return used.Username, nil
+}
+
+// checks code against the authenticator app and uses up its step
+func (t TwoFactor) verifyCode(tf model.TwoFactor, code string) (bool, error) {
+	step, ok := totp.Match(tf.Secret, strings.TrimSpace(code), t.Clock.Now())
+	if !ok {
+		return false, nil
+	}
+	return t.DB.UseStep(tf.Username, step)
+}
+
+// checks code against the authenticator app or the recovery codes, which are longer
+func (t TwoFactor) verifyCodeOrRecoveryCode(tf model.TwoFactor, code string) (bool, error) {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return t.verifyCode(tf, code)
+	}
+	used, err := t.DB.UseRecoveryCode(tf.Username, hashToken(normalized))
+	if used {
+		log.Printf("User %s used a recovery code", tf.Username)
+	}
+	return used, err
+}
+
+// generates recovery codes formatted as xxxxx-xxxxx and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		random := make([]byte, recoveryCodeLength*5/8)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, fmt.Errorf("could not generate recovery code, %v", err)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(random))
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i] = hashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// recovery codes are accepted without the dash, with spaces and in upper case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
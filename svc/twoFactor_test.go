@@ -0,0 +1,237 @@
+package svc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/config"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/MonikaPalova/currency-master/totp"
+)
+
+type stubTwoFactorDB struct {
+	tfs   map[string]*model.TwoFactor
+	codes map[string]bool
+	err   error
+}
+
+func newStubTwoFactorDB() *stubTwoFactorDB {
+	return &stubTwoFactorDB{tfs: map[string]*model.TwoFactor{}, codes: map[string]bool{}}
+}
+
+func (s *stubTwoFactorDB) Get(username string) (*model.TwoFactor, error) {
+	if tf, ok := s.tfs[username]; ok {
+		copied := *tf
+		return &copied, s.err
+	}
+	return nil, s.err
+}
+func (s *stubTwoFactorDB) Enroll(username, secret string) error {
+	s.tfs[username] = &model.TwoFactor{Username: username, Secret: secret}
+	return s.err
+}
+func (s *stubTwoFactorDB) Enable(username string, step int64, codeHashes []string) (bool, error) {
+	tf, ok := s.tfs[username]
+	if !ok || tf.Enabled {
+		return false, s.err
+	}
+	tf.Enabled, tf.LastStep = true, step
+	return true, s.ReplaceRecoveryCodes(username, codeHashes)
+}
+func (s *stubTwoFactorDB) UseStep(username string, step int64) (bool, error) {
+	tf := s.tfs[username]
+	if tf.LastStep >= step {
+		return false, s.err
+	}
+	tf.LastStep = step
+	return true, s.err
+}
+func (s *stubTwoFactorDB) UseRecoveryCode(username, hash string) (bool, error) {
+	used := s.codes[hash]
+	delete(s.codes, hash)
+	return used, s.err
+}
+func (s *stubTwoFactorDB) ReplaceRecoveryCodes(username string, codeHashes []string) error {
+	s.codes = map[string]bool{}
+	for _, hash := range codeHashes {
+		s.codes[hash] = true
+	}
+	return s.err
+}
+func (s *stubTwoFactorDB) Delete(username string) error {
+	delete(s.tfs, username)
+	s.codes = map[string]bool{}
+	return s.err
+}
+
+func newTestTwoFactor() (TwoFactor, *stubTwoFactorDB, *clock.Fake) {
+	db := newStubTwoFactorDB()
+	clk := clock.NewFake(testNow)
+	return TwoFactor{DB: db, TDB: stubTokensDB{tokens: map[string]model.Token{}}, Config: &config.TwoFactor{Issuer: "CM", LoginTTL: time.Minute}, Clock: clk}, db, clk
+}
+
+// enrolls and enables two-factor authentication of u1 and gets the secret and recovery codes
+func enableTestTwoFactor(t *testing.T, tf TwoFactor, clk *clock.Fake) (string, []string) {
+	enrollment, err := tf.Enroll("u1")
+	if err != nil || enrollment == nil {
+		t.Fatalf("TwoFactor.Enroll() = %v, %v", enrollment, err)
+	}
+	codes, err := tf.Confirm("u1", testCode(enrollment.Secret, clk.Now()))
+	if err != nil || len(codes) != recoveryCodesCount {
+		t.Fatalf("TwoFactor.Confirm() = %v, %v", codes, err)
+	}
+	// later codes are of the next step
+	clk.Advance(totp.Period)
+	return enrollment.Secret, codes
+}
+
+func testCode(secret string, now time.Time) string {
+	code, _ := totp.Code(secret, totp.Step(now))
+	return code
+}
+
+func TestTwoFactor_Enroll(t *testing.T) {
+	tf, _, clk := newTestTwoFactor()
+	enrollment, err := tf.Enroll("u1")
+	if err != nil {
+		t.Fatalf("TwoFactor.Enroll() error = %v", err)
+	}
+	if enrollment.URI != totp.URI("CM", "u1", enrollment.Secret) {
+		t.Errorf("TwoFactor.Enroll() uri = %s", enrollment.URI)
+	}
+	if enabled, _ := tf.IsEnabled("u1"); enabled {
+		t.Errorf("TwoFactor.IsEnabled() = true before confirmation")
+	}
+	if codes, _ := tf.Confirm("u1", "000000"); codes != nil {
+		t.Errorf("TwoFactor.Confirm() accepted a wrong code")
+	}
+
+	enableTestTwoFactor(t, tf, clk)
+	if enabled, _ := tf.IsEnabled("u1"); !enabled {
+		t.Errorf("TwoFactor.IsEnabled() = false after confirmation")
+	}
+	if enrollment, err := tf.Enroll("u1"); enrollment != nil || err != nil {
+		t.Errorf("TwoFactor.Enroll() when enabled = %v, %v, want nil", enrollment, err)
+	}
+}
+
+func TestTwoFactor_VerifyFresh(t *testing.T) {
+	tf, _, clk := newTestTwoFactor()
+	if ok, _ := tf.VerifyFresh("u1", ""); !ok {
+		t.Errorf("TwoFactor.VerifyFresh() required a code of a user without two-factor authentication")
+	}
+
+	secret, recoveryCodes := enableTestTwoFactor(t, tf, clk)
+	code := testCode(secret, clk.Now())
+	if ok, _ := tf.VerifyFresh("u1", ""); ok {
+		t.Errorf("TwoFactor.VerifyFresh() accepted a missing code")
+	}
+	if ok, _ := tf.VerifyFresh("u1", recoveryCodes[0]); ok {
+		t.Errorf("TwoFactor.VerifyFresh() accepted a recovery code")
+	}
+	if ok, _ := tf.VerifyFresh("u1", code); !ok {
+		t.Errorf("TwoFactor.VerifyFresh() rejected a current code")
+	}
+	if ok, _ := tf.VerifyFresh("u1", code); ok {
+		t.Errorf("TwoFactor.VerifyFresh() accepted a used code")
+	}
+}
+
+func TestTwoFactor_Confirm_CodeCantBeReused(t *testing.T) {
+	tf, _, clk := newTestTwoFactor()
+	enrollment, _ := tf.Enroll("u1")
+	code := testCode(enrollment.Secret, clk.Now())
+	tf.Confirm("u1", code)
+
+	if ok, _ := tf.VerifyFresh("u1", code); ok {
+		t.Errorf("TwoFactor.VerifyFresh() accepted the code which confirmed the enrollment")
+	}
+}
+
+func TestTwoFactor_Login(t *testing.T) {
+	tf, _, clk := newTestTwoFactor()
+	secret, recoveryCodes := enableTestTwoFactor(t, tf, clk)
+
+	token, _ := tf.StartLogin("u1")
+	if username, _ := tf.CompleteLogin(token, testCode(secret, clk.Now())); username != "u1" {
+		t.Errorf("TwoFactor.CompleteLogin() = %q, want u1", username)
+	}
+	if username, _ := tf.CompleteLogin(token, testCode(secret, clk.Now().Add(totp.Period))); username != "" {
+		t.Errorf("TwoFactor.CompleteLogin() accepted a used token")
+	}
+
+	token, _ = tf.StartLogin("u1")
+	if username, _ := tf.CompleteLogin(token, "000000"); username != "" {
+		t.Errorf("TwoFactor.CompleteLogin() accepted a wrong code")
+	}
+	if username, _ := tf.CompleteLogin(token, testCode(secret, clk.Now().Add(totp.Period))); username != "" {
+		t.Errorf("TwoFactor.CompleteLogin() accepted a token after a wrong code")
+	}
+
+	token, _ = tf.StartLogin("u1")
+	if username, _ := tf.CompleteLogin(token, " "+recoveryCodes[0]+" "); username != "u1" {
+		t.Errorf("TwoFactor.CompleteLogin() rejected a recovery code")
+	}
+	token, _ = tf.StartLogin("u1")
+	if username, _ := tf.CompleteLogin(token, recoveryCodes[0]); username != "" {
+		t.Errorf("TwoFactor.CompleteLogin() accepted a used recovery code")
+	}
+
+	token, _ = tf.StartLogin("u1")
+	clk.Advance(time.Minute)
+	if username, _ := tf.CompleteLogin(token, testCode(secret, clk.Now())); username != "" {
+		t.Errorf("TwoFactor.CompleteLogin() accepted an expired token")
+	}
+}
+
+func TestTwoFactor_Disable(t *testing.T) {
+	tf, _, clk := newTestTwoFactor()
+	if ok, _ := tf.Disable("u1", "000000"); ok {
+		t.Errorf("TwoFactor.Disable() of a user without two-factor authentication = true")
+	}
+	_, recoveryCodes := enableTestTwoFactor(t, tf, clk)
+
+	if ok, _ := tf.Disable("u1", "000000"); ok {
+		t.Errorf("TwoFactor.Disable() accepted a wrong code")
+	}
+	if ok, _ := tf.Disable("u1", recoveryCodes[1]); !ok {
+		t.Errorf("TwoFactor.Disable() rejected a recovery code")
+	}
+	if enabled, _ := tf.IsEnabled("u1"); enabled {
+		t.Errorf("TwoFactor.IsEnabled() = true after disabling")
+	}
+}
+
+func TestTwoFactor_RegenerateRecoveryCodes(t *testing.T) {
+	tf, _, clk := newTestTwoFactor()
+	if codes, _ := tf.RegenerateRecoveryCodes("u1"); codes != nil {
+		t.Errorf("TwoFactor.RegenerateRecoveryCodes() of a user without two-factor authentication = %v", codes)
+	}
+	_, old := enableTestTwoFactor(t, tf, clk)
+
+	codes, err := tf.RegenerateRecoveryCodes("u1")
+	if err != nil || len(codes) != recoveryCodesCount {
+		t.Fatalf("TwoFactor.RegenerateRecoveryCodes() = %v, %v", codes, err)
+	}
+	token, _ := tf.StartLogin("u1")
+	if username, _ := tf.CompleteLogin(token, old[0]); username != "" {
+		t.Errorf("TwoFactor.CompleteLogin() accepted a replaced recovery code")
+	}
+	token, _ = tf.StartLogin("u1")
+	if username, _ := tf.CompleteLogin(token, codes[0]); username != "u1" {
+		t.Errorf("TwoFactor.CompleteLogin() rejected a new recovery code")
+	}
+}
+
+func TestTwoFactor_DBError(t *testing.T) {
+	tf, db, _ := newTestTwoFactor()
+	db.err = fmt.Errorf("db")
+	if _, err := tf.Enroll("u1"); err == nil {
+		t.Errorf("TwoFactor.Enroll() expected error")
+	}
+	if _, err := tf.VerifyFresh("u1", "000000"); err == nil {
+		t.Errorf("TwoFactor.VerifyFresh() expected error")
+	}
+}
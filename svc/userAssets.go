@@ -25,8 +25,8 @@ func (u UserAssets) GetByUsername(username string) ([]model.UserAsset, error) {
 		return nil, err
 	}
 
-	_, assets, err = u.v.valAssets(assets)
-	return assets, err
+	_, assets = u.v.valAssets(assets)
+	return assets, nil
 }
 
 // get specific user asset by id with valuation
@@ -36,7 +36,8 @@ func (u UserAssets) GetByUsernameAndId(username, id string) (*model.UserAsset, e
 		return asset, err
 	}
 
-	return u.v.valAsset(*asset)
+	valAsset := u.v.valAsset(*asset)
+	return &valAsset, nil
 }
 
 // create new user asset
@@ -2,7 +2,10 @@ package svc
 
 import (
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/MonikaPalova/currency-master/clock"
 	"github.com/MonikaPalova/currency-master/model"
 )
 
@@ -11,8 +14,10 @@ const startUserUSD = 100
 
 // users service to handle users, user assets and their valuation
 type Users struct {
-	UDB usersDB
-	v   valuator
+	UDB    usersDB
+	Hasher passwordHasher
+	Clock  clock.Clock
+	v      valuator
 }
 
 type usersDB interface {
@@ -21,13 +26,41 @@ type usersDB interface {
 	GetByUsername(username string) (*model.User, error)
 	GetByUsernameWithAssets(username string) (*model.User, error)
 	UpdateUSD(username string, money float64) error
-	Exists(username, password string) (bool, error)
+	GetPassword(username string) (*string, error)
+	UpdatePassword(username, password string) error
+	GetRole(username string) (string, error)
+	UpdateRole(username, role string) error
+	UpdateEmail(username, email string) error
+	Delete(username string, deleted time.Time) (bool, error)
 }
 
-// create a user
+// Error returned when deleting a user who still holds assets, which should be sold first
+type UserHasAssetsError struct {
+	Username string
+	Assets   int
+}
+
+func (e UserHasAssetsError) Error() string {
+	return fmt.Sprintf("user %s still holds %d assets, they should be sold before deleting the account", e.Username, e.Assets)
+}
+
+type passwordHasher interface {
+	Hash(password string) (string, error)
+	// ok if password matches the stored one, rehash if the stored password should be replaced by a new hash
+	Verify(stored, password string) (ok, rehash bool)
+}
+
+// create a user with hashed password and the user role
 func (u Users) Create(user model.User) (*model.User, error) {
 	user.USD = startUserUSD
 	user.Valuation = 0
+	user.Role = model.RoleUser
+
+	hash, err := u.Hasher.Hash(user.Password)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash password, %v", err)
+	}
+	user.Password = hash
 
 	return u.UDB.Create(user)
 }
@@ -38,7 +71,7 @@ func (u Users) GetAll() ([]model.User, error) {
 	if err != nil {
 		return nil, err
 	}
-	return u.v.valUsers(users)
+	return u.v.valUsers(users), nil
 }
 
 // get user with or without valuation calculated
@@ -53,7 +86,82 @@ func (u Users) GetByUsername(username string, valuation bool) (user *model.User,
 		return user, err
 	}
 
-	return u.v.valUser(*user)
+	valUser := u.v.valUser(*user)
+	return &valUser, nil
+}
+
+// whether user verified their email, false if the user doesn't exist
+func (u Users) IsEmailVerified(username string) (bool, error) {
+	user, err := u.UDB.GetByUsername(username)
+	if err != nil || user == nil {
+		return false, err
+	}
+	return user.EmailVerified, nil
+}
+
+// get the role of user, empty if the user doesn't exist
+func (u Users) GetRole(username string) (string, error) {
+	return u.UDB.GetRole(username)
+}
+
+// set the role of user and get the user without valuation, nil if the user doesn't exist
+func (u Users) UpdateRole(username, role string) (*model.User, error) {
+	if !model.ValidRole(role) {
+		return nil, fmt.Errorf("unknown role %s", role)
+	}
+	user, err := u.UDB.GetByUsername(username)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if err := u.UDB.UpdateRole(username, role); err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+// change the email of user, which is unverified until the user verifies it, and get the user without valuation, nil if the user doesn't exist
+func (u Users) UpdateEmail(username, email string) (*model.User, error) {
+	user, err := u.UDB.GetByUsername(username)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if err := u.UDB.UpdateEmail(username, email); err != nil {
+		return nil, err
+	}
+	user.Email = email
+	user.EmailVerified = false
+	return user, nil
+}
+
+// replaces the password of user if current is their password, false if it isn't
+func (u Users) ChangePassword(username, current, newPassword string) (bool, error) {
+	ok, err := u.ValidateUser(username, current)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	hash, err := u.Hasher.Hash(newPassword)
+	if err != nil {
+		return false, fmt.Errorf("could not hash password, %v", err)
+	}
+	if err := u.UDB.UpdatePassword(username, hash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// deletes user and archives their acquisitions, false if the user doesn't exist.
+// Returns UserHasAssetsError if the user holds assets
+func (u Users) Delete(username string) (bool, error) {
+	user, err := u.UDB.GetByUsernameWithAssets(username)
+	if err != nil || user == nil {
+		return false, err
+	}
+	if len(user.Assets) > 0 {
+		return false, UserHasAssetsError{Username: username, Assets: len(user.Assets)}
+	}
+	return u.UDB.Delete(username, u.Clock.Now())
 }
 
 // add usd to user balance
@@ -96,6 +204,35 @@ func (u Users) DeductUSD(username string, usd float64) (float64, error) {
 	return money, nil
 }
 
+// checks the password of user, a plaintext password stored before passwords were hashed is hashed on success
 func (u Users) ValidateUser(username, password string) (bool, error) {
-	return u.UDB.Exists(username, password)
+	stored, err := u.UDB.GetPassword(username)
+	if err != nil {
+		return false, err
+	}
+	if stored == nil {
+		// still verified, so unknown usernames are not told apart by response time
+		u.Hasher.Verify("", password)
+		return false, nil
+	}
+
+	ok, rehash := u.Hasher.Verify(*stored, password)
+	if ok && rehash {
+		u.rehash(username, password)
+	}
+	return ok, nil
+}
+
+// the login succeeds even if the new hash is not saved, it is saved on the next one
+func (u Users) rehash(username, password string) {
+	hash, err := u.Hasher.Hash(password)
+	if err != nil {
+		log.Printf("Could not rehash password of user %s, %v", username, err)
+		return
+	}
+	if err := u.UDB.UpdatePassword(username, hash); err != nil {
+		log.Printf("Could not save rehashed password of user %s, %v", username, err)
+		return
+	}
+	log.Printf("Rehashed password of user %s", username)
 }
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/MonikaPalova/currency-master/clock"
 	"github.com/MonikaPalova/currency-master/coinapi"
 	"github.com/MonikaPalova/currency-master/model"
 )
@@ -14,9 +16,18 @@ type stubUDB struct {
 	user   *model.User
 	assets []model.UserAsset
 	err    error
+	// stored password, nil if the user doesn't exist
+	password *string
+	// stored role
+	role *string
+	// time of the deletion of the user
+	deleted *time.Time
 }
 
 func (s stubUDB) Create(user model.User) (*model.User, error) {
+	if s.password != nil {
+		*s.password = user.Password
+	}
 	user.Password = ""
 	user.Assets = []model.UserAsset{}
 
@@ -44,8 +55,56 @@ func (s stubUDB) UpdateUSD(username string, money float64) error {
 	return s.err
 }
 
-func (s stubUDB) Exists(username, password string) (bool, error) {
-	return false, nil
+func (s stubUDB) GetPassword(username string) (*string, error) {
+	if s.err != nil || s.password == nil {
+		return nil, s.err
+	}
+	password := *s.password
+	return &password, nil
+}
+
+func (s stubUDB) UpdatePassword(username, password string) error {
+	*s.password = password
+	return s.err
+}
+
+func (s stubUDB) GetRole(username string) (string, error) {
+	if s.err != nil || s.role == nil {
+		return "", s.err
+	}
+	return *s.role, nil
+}
+
+func (s stubUDB) UpdateRole(username, role string) error {
+	*s.role = role
+	return s.err
+}
+
+func (s stubUDB) UpdateEmail(username, email string) error {
+	s.user.Email = email
+	return s.err
+}
+
+func (s stubUDB) Delete(username string, deleted time.Time) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	*s.deleted = deleted
+	return true, nil
+}
+
+// hashes by prefixing, verifies plaintext passwords as stored before hashing
+type stubHasher struct{}
+
+func (stubHasher) Hash(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (stubHasher) Verify(stored, password string) (ok, rehash bool) {
+	if stored == "hashed:"+password {
+		return true, false
+	}
+	return stored != "" && stored == password, true
 }
 
 func TestUsers_Create(t *testing.T) {
@@ -62,12 +121,13 @@ func TestUsers_Create(t *testing.T) {
 		want    *model.User
 		wantErr bool
 	}{
-		{"valid", fields{stubUDB{}}, args{model.User{Username: "u1", Password: "P1", Email: "e1"}}, &model.User{Username: "u1", Password: "", Email: "e1", USD: startUserUSD, Assets: []model.UserAsset{}, Valuation: 0}, false},
+		{"valid", fields{stubUDB{}}, args{model.User{Username: "u1", Password: "P1", Email: "e1"}}, &model.User{Username: "u1", Password: "", Email: "e1", Role: model.RoleUser, USD: startUserUSD, Assets: []model.UserAsset{}, Valuation: 0}, false},
+		{"role can't be chosen", fields{stubUDB{}}, args{model.User{Username: "u1", Password: "P1", Email: "e1", Role: model.RoleAdmin}}, &model.User{Username: "u1", Password: "", Email: "e1", Role: model.RoleUser, USD: startUserUSD, Assets: []model.UserAsset{}, Valuation: 0}, false},
 		{"error saving in db", fields{stubUDB{err: fmt.Errorf("")}}, args{model.User{Username: "u1", Password: "P1", Email: "e1"}}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u := Users{UDB: tt.fields.UDB}
+			u := Users{UDB: tt.fields.UDB, Hasher: stubHasher{}}
 			got, err := u.Create(tt.args.user)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Users.Create() error = %v, wantErr %v", err, tt.wantErr)
@@ -80,6 +140,208 @@ func TestUsers_Create(t *testing.T) {
 	}
 }
 
+func TestUsers_CreateHashesPassword(t *testing.T) {
+	stored := ""
+	u := Users{UDB: stubUDB{password: &stored}, Hasher: stubHasher{}}
+	if _, err := u.Create(model.User{Username: "u1", Password: "P1", Email: "e1"}); err != nil {
+		t.Fatalf("Users.Create() error = %v", err)
+	}
+	if stored != "hashed:P1" {
+		t.Errorf("Users.Create() stored password %q, want %q", stored, "hashed:P1")
+	}
+}
+
+func TestUsers_UpdateRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *model.User
+		err      error
+		role     string
+		want     *model.User
+		wantErr  bool
+		wantRole string
+	}{
+		{"ok", &model.User{Username: "u1", Role: model.RoleUser}, nil, model.RoleSupport, &model.User{Username: "u1", Role: model.RoleSupport}, false, model.RoleSupport},
+		{"unknown role", &model.User{Username: "u1", Role: model.RoleUser}, nil, "root", nil, true, model.RoleUser},
+		{"no such user", nil, nil, model.RoleAdmin, nil, false, model.RoleUser},
+		{"db error", nil, fmt.Errorf(""), model.RoleAdmin, nil, true, model.RoleUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := model.RoleUser
+			u := Users{UDB: stubUDB{user: tt.user, err: tt.err, role: &role}}
+			got, err := u.UpdateRole("u1", tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Users.UpdateRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Users.UpdateRole() = %v, want %v", got, tt.want)
+			}
+			if role != tt.wantRole {
+				t.Errorf("Users.UpdateRole() stored role %s, want %s", role, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestUsers_UpdateEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *model.User
+		err     error
+		want    *model.User
+		wantErr bool
+	}{
+		{"ok", &model.User{Username: "u1", Email: "e1"}, nil, &model.User{Username: "u1", Email: "e2"}, false},
+		{"new email is unverified", &model.User{Username: "u1", Email: "e1", EmailVerified: true}, nil, &model.User{Username: "u1", Email: "e2"}, false},
+		{"no such user", nil, nil, nil, false},
+		{"db error", nil, fmt.Errorf(""), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{UDB: stubUDB{user: tt.user, err: tt.err}}
+			got, err := u.UpdateEmail("u1", "e2")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Users.UpdateEmail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Users.UpdateEmail() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsers_IsEmailVerified(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *model.User
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{"verified", &model.User{Username: "u1", EmailVerified: true}, nil, true, false},
+		{"unverified", &model.User{Username: "u1"}, nil, false, false},
+		{"no such user", nil, nil, false, false},
+		{"db error", nil, fmt.Errorf(""), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{UDB: stubUDB{user: tt.user, err: tt.err}}
+			got, err := u.IsEmailVerified("u1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Users.IsEmailVerified() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Users.IsEmailVerified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsers_ChangePassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     *string
+		err        error
+		current    string
+		want       bool
+		wantErr    bool
+		wantStored string
+	}{
+		{"ok", strPtr("hashed:P1"), nil, "P1", true, false, "hashed:P2"},
+		{"plaintext password", strPtr("P1"), nil, "P1", true, false, "hashed:P2"},
+		{"wrong current password", strPtr("hashed:P1"), nil, "P3", false, false, "hashed:P1"},
+		{"unknown user", nil, nil, "P1", false, false, ""},
+		{"db error", nil, fmt.Errorf(""), "P1", false, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{UDB: stubUDB{password: tt.stored, err: tt.err}, Hasher: stubHasher{}}
+			got, err := u.ChangePassword("u1", tt.current, "P2")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Users.ChangePassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Users.ChangePassword() = %v, want %v", got, tt.want)
+			}
+			if tt.stored != nil && *tt.stored != tt.wantStored {
+				t.Errorf("Users.ChangePassword() stored password %q, want %q", *tt.stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestUsers_Delete(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        *model.User
+		assets      []model.UserAsset
+		err         error
+		want        bool
+		wantErr     error
+		wantDeleted time.Time
+	}{
+		{"ok", &model.User{Username: "u1"}, []model.UserAsset{}, nil, true, nil, testNow},
+		{"holds assets", &model.User{Username: "u1"}, []model.UserAsset{{Username: "u1", AssetId: "BTC", Quantity: 1}}, nil, false, UserHasAssetsError{Username: "u1", Assets: 1}, time.Time{}},
+		{"no such user", nil, nil, nil, false, nil, time.Time{}},
+		{"db error", nil, nil, fmt.Errorf("db"), false, fmt.Errorf("db"), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted time.Time
+			u := Users{UDB: stubUDB{user: tt.user, assets: tt.assets, err: tt.err, deleted: &deleted}, Clock: clock.NewFake(testNow)}
+			got, err := u.Delete("u1")
+			if !reflect.DeepEqual(err, tt.wantErr) && (err == nil || tt.wantErr == nil || err.Error() != tt.wantErr.Error()) {
+				t.Fatalf("Users.Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Users.Delete() = %v, want %v", got, tt.want)
+			}
+			if !deleted.Equal(tt.wantDeleted) {
+				t.Errorf("Users.Delete() deleted at %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestUsers_ValidateUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     *string
+		err        error
+		password   string
+		want       bool
+		wantErr    bool
+		wantStored string
+	}{
+		{"hashed password", strPtr("hashed:P1"), nil, "P1", true, false, "hashed:P1"},
+		{"wrong password", strPtr("hashed:P1"), nil, "P2", false, false, "hashed:P1"},
+		{"plaintext password is rehashed", strPtr("P1"), nil, "P1", true, false, "hashed:P1"},
+		{"wrong plaintext password is kept", strPtr("P1"), nil, "P2", false, false, "P1"},
+		{"unknown user", nil, nil, "P1", false, false, ""},
+		{"db error", nil, fmt.Errorf(""), "P1", false, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := Users{UDB: stubUDB{password: tt.stored, err: tt.err}, Hasher: stubHasher{}}
+			got, err := u.ValidateUser("u1", tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Users.ValidateUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Users.ValidateUser() = %v, want %v", got, tt.want)
+			}
+			if tt.stored != nil && *tt.stored != tt.wantStored {
+				t.Errorf("Users.ValidateUser() stored password %q, want %q", *tt.stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
 func TestUsers_GetAll(t *testing.T) {
 	type fields struct {
 		UDB    usersDB
@@ -99,7 +361,8 @@ func TestUsers_GetAll(t *testing.T) {
 		wantErr bool
 	}{
 		{"valid", fields{stubUDB{users: []model.User{dbU1, dbU2}}, stubClient{[]coinapi.Asset{a1, a2}, nil}}, []model.User{u1, u2}, false},
-		{"asset does not exist - no price data", fields{stubUDB{users: []model.User{dbU1}}, stubClient{[]coinapi.Asset{}, fmt.Errorf((""))}}, nil, true},
+		{"no price data - stale assets", fields{stubUDB{users: []model.User{dbU1}}, stubClient{[]coinapi.Asset{}, fmt.Errorf((""))}}, []model.User{{Username: "u1", Assets: []model.UserAsset{{AssetId: "id1", Quantity: 3, PriceStatus: model.PriceStale}}}}, false},
+		{"delisted asset does not fail others", fields{stubUDB{users: []model.User{dbU1, dbU2}}, stubClient{[]coinapi.Asset{a1}, nil}}, []model.User{u1, {Username: "u2", Assets: []model.UserAsset{{AssetId: "id1", Quantity: 4, Valuation: 4 * a1.PriceUSD}, {AssetId: "id2", Quantity: 2, PriceStatus: model.PriceDelisted}}, Valuation: 4 * a1.PriceUSD}}, false},
 		{"could not fetch users from db", fields{stubUDB{err: fmt.Errorf("")}, stubClient{[]coinapi.Asset{}, nil}}, nil, true},
 	}
 	for _, tt := range tests {
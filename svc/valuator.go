@@ -4,54 +4,46 @@ import (
 	"github.com/MonikaPalova/currency-master/model"
 )
 
+// values users and their assets, assets without current price never fail the valuation.
+// The prices are refreshed once per valuation rather than for every asset
 type valuator struct {
 	svc *Assets
 }
 
-func (v valuator) valUsers(users []model.User) ([]model.User, error) {
+func (v valuator) valUsers(users []model.User) []model.User {
+	refreshErr := v.svc.updateCacheIfNeeded()
 	valUsers := []model.User{}
 	for _, user := range users {
-		valUser, err := v.valUser(user)
-		if err != nil {
-			return nil, err
-		}
-		valUsers = append(valUsers, *valUser)
+		valUsers = append(valUsers, v.valUserWith(user, refreshErr))
 	}
-	return valUsers, nil
+	return valUsers
 }
 
-func (v valuator) valUser(user model.User) (*model.User, error) {
-	valuation, valAssets, err := v.valAssets(user.Assets)
-	if err != nil {
-		return nil, err
-	}
+func (v valuator) valUser(user model.User) model.User {
+	return v.valUserWith(user, v.svc.updateCacheIfNeeded())
+}
+
+func (v valuator) valUserWith(user model.User, refreshErr error) model.User {
+	user.Valuation, user.Assets = v.valAssetsWith(user.Assets, refreshErr)
+	return user
+}
 
-	user.Assets = valAssets
-	user.Valuation = valuation
-	return &user, nil
+func (v valuator) valAssets(assets []model.UserAsset) (float64, []model.UserAsset) {
+	return v.valAssetsWith(assets, v.svc.updateCacheIfNeeded())
 }
 
-func (v valuator) valAssets(assets []model.UserAsset) (float64, []model.UserAsset, error) {
+func (v valuator) valAssetsWith(assets []model.UserAsset, refreshErr error) (float64, []model.UserAsset) {
 	valuation := 0.0
 	valAssets := []model.UserAsset{}
 	for _, asset := range assets {
-		valAsset, err := v.valAsset(asset)
-		if err != nil {
-			return -1, nil, err
-		}
-
+		valAsset := v.svc.valuate(asset, refreshErr)
 		valuation += valAsset.Valuation
-		valAssets = append(valAssets, *valAsset)
+		valAssets = append(valAssets, valAsset)
 	}
 
-	return valuation, valAssets, nil
+	return valuation, valAssets
 }
 
-func (v valuator) valAsset(asset model.UserAsset) (*model.UserAsset, error) {
-	val, err := v.svc.Valuate(asset)
-	if err != nil {
-		return nil, err
-	}
-	asset.Valuation = val
-	return &asset, nil
+func (v valuator) valAsset(asset model.UserAsset) model.UserAsset {
+	return v.svc.Valuate(asset)
 }
@@ -0,0 +1,145 @@
+package svc
+
+import (
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/model"
+	"github.com/google/uuid"
+)
+
+// period over which the change of watchlist asset prices is measured
+const watchlistChangePeriod = 24 * time.Hour
+
+// Watchlists service which manages named lists of tracked assets and prices them from the assets cache
+type Watchlists struct {
+	WDB  watchlistsDB
+	ASvc *Assets
+	// past prices for the 24h change, nil if no history is kept
+	History priceHistory
+	Clock   clock.Clock
+}
+
+type watchlistsDB interface {
+	GetByUsername(username string) ([]model.Watchlist, error)
+	GetByID(username, id string) (*model.Watchlist, error)
+	Create(watchlist model.Watchlist) (*model.Watchlist, error)
+	Update(watchlist model.Watchlist) (bool, error)
+	Delete(username, id string) (bool, error)
+}
+
+type priceHistory interface {
+	// usd price of asset at time at, false if there is no price for that time
+	PriceAt(id string, at time.Time) (float64, bool)
+}
+
+// get priced watchlists of user, only the public ones if publicOnly is set
+func (w Watchlists) GetByUsername(username string, publicOnly bool) ([]model.Watchlist, error) {
+	watchlists, err := w.WDB.GetByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	priced := []model.Watchlist{}
+	for _, watchlist := range watchlists {
+		if publicOnly && !watchlist.Public {
+			continue
+		}
+		priced = append(priced, w.price(watchlist))
+	}
+	return priced, nil
+}
+
+// get priced watchlist of user by id, nil if it doesn't exist
+func (w Watchlists) GetByID(username, id string) (*model.Watchlist, error) {
+	watchlist, err := w.WDB.GetByID(username, id)
+	if err != nil || watchlist == nil {
+		return watchlist, err
+	}
+
+	priced := w.price(*watchlist)
+	return &priced, nil
+}
+
+// create a watchlist.
+// Returns AssetNotFoundError if one of its assets is not in the cache
+func (w Watchlists) Create(watchlist model.Watchlist) (*model.Watchlist, error) {
+	if err := w.checkAssets(watchlist.AssetIds); err != nil {
+		return nil, err
+	}
+
+	watchlist.ID = uuid.New().String()
+	watchlist.Created = w.Clock.Now().UTC()
+	created, err := w.WDB.Create(watchlist)
+	if err != nil {
+		return nil, err
+	}
+
+	priced := w.price(*created)
+	return &priced, nil
+}
+
+// replace the name, visibility and assets of a watchlist, nil if it doesn't exist.
+// Returns AssetNotFoundError if one of its assets is not in the cache
+func (w Watchlists) Update(watchlist model.Watchlist) (*model.Watchlist, error) {
+	if err := w.checkAssets(watchlist.AssetIds); err != nil {
+		return nil, err
+	}
+
+	updated, err := w.WDB.Update(watchlist)
+	if err != nil || !updated {
+		return nil, err
+	}
+	return w.GetByID(watchlist.Username, watchlist.ID)
+}
+
+// delete watchlist of user, false if it doesn't exist
+func (w Watchlists) Delete(username, id string) (bool, error) {
+	return w.WDB.Delete(username, id)
+}
+
+// only listed assets can be added to a watchlist
+func (w Watchlists) checkAssets(ids []string) error {
+	for _, id := range ids {
+		asset, err := w.ASvc.GetAssetById(id)
+		if err != nil {
+			return err
+		}
+		if asset == nil {
+			return AssetNotFoundError{ID: id}
+		}
+	}
+	return nil
+}
+
+// prices the assets of watchlist, assets without current price get their last known price like in valuation
+func (w Watchlists) price(watchlist model.Watchlist) model.Watchlist {
+	since := w.Clock.Now().Add(-watchlistChangePeriod)
+	watchlist.Assets = []model.WatchlistAsset{}
+	for _, id := range watchlist.AssetIds {
+		wa := model.WatchlistAsset{AssetId: id}
+		asset, err := w.ASvc.GetAssetById(id)
+		if err == nil && asset != nil {
+			wa.Name = asset.Name
+			wa.PriceUSD = asset.PriceUSD
+		} else {
+			wa.PriceStatus = model.PriceDelisted
+			if err != nil {
+				wa.PriceStatus = model.PriceStale
+			}
+			if last := w.ASvc.LastPrice(id); last != nil {
+				wa.Name = last.Name
+				wa.PriceUSD = last.PriceUSD
+			}
+		}
+
+		if w.History != nil && wa.PriceUSD > 0 {
+			if past, ok := w.History.PriceAt(id, since); ok && past > 0 {
+				change := (wa.PriceUSD - past) / past * 100
+				wa.Change24h = &change
+			}
+		}
+		watchlist.Assets = append(watchlist.Assets, wa)
+	}
+	return watchlist
+}
@@ -0,0 +1,154 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MonikaPalova/currency-master/clock"
+	"github.com/MonikaPalova/currency-master/coinapi"
+	"github.com/MonikaPalova/currency-master/model"
+)
+
+type stubWDB struct {
+	watchlists []model.Watchlist
+	exists     bool
+	err        error
+}
+
+func (s stubWDB) GetByUsername(username string) ([]model.Watchlist, error) {
+	return s.watchlists, s.err
+}
+func (s stubWDB) GetByID(username, id string) (*model.Watchlist, error) {
+	if s.err != nil || len(s.watchlists) == 0 {
+		return nil, s.err
+	}
+	return &s.watchlists[0], nil
+}
+func (s stubWDB) Create(watchlist model.Watchlist) (*model.Watchlist, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &watchlist, nil
+}
+func (s stubWDB) Update(watchlist model.Watchlist) (bool, error) {
+	return s.exists, s.err
+}
+func (s stubWDB) Delete(username, id string) (bool, error) {
+	return s.exists, s.err
+}
+
+type stubHistory map[string]float64
+
+func (s stubHistory) PriceAt(id string, at time.Time) (float64, bool) {
+	price, ok := s[id]
+	return price, ok
+}
+
+func TestWatchlists_GetByUsername(t *testing.T) {
+	assets := []coinapi.Asset{{ID: "BTC", Name: "Bitcoin", PriceUSD: 44000}, {ID: "ETH", Name: "Ethereum", PriceUSD: 3000}}
+	private := model.Watchlist{ID: "1", Name: "private", AssetIds: []string{"ETH"}}
+	public := model.Watchlist{ID: "2", Name: "public", Public: true, AssetIds: []string{"BTC", "LUNA"}}
+	change := 10.0
+	lastUpdated := testNow.Add(-time.Hour)
+	pricedPublic := public
+	pricedPublic.Assets = []model.WatchlistAsset{
+		{AssetId: "BTC", Name: "Bitcoin", PriceUSD: 44000, Change24h: &change},
+		{AssetId: "LUNA", Name: "Terra", PriceUSD: 0.5, PriceStatus: model.PriceDelisted},
+	}
+	pricedPrivate := private
+	pricedPrivate.Assets = []model.WatchlistAsset{{AssetId: "ETH", Name: "Ethereum", PriceUSD: 3000}}
+	tests := []struct {
+		name       string
+		wdb        stubWDB
+		publicOnly bool
+		want       []model.Watchlist
+		wantErr    bool
+	}{
+		{"owner", stubWDB{watchlists: []model.Watchlist{private, public}}, false, []model.Watchlist{pricedPrivate, pricedPublic}, false},
+		{"public only", stubWDB{watchlists: []model.Watchlist{private, public}}, true, []model.Watchlist{pricedPublic}, false},
+		{"db error", stubWDB{err: fmt.Errorf("")}, false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAssets(stubClient{assets, nil})
+			a.KeepLastPrices(stubLpDB{prices: map[string]model.LastPrice{"LUNA": {AssetId: "LUNA", Name: "Terra", PriceUSD: 0.5, Updated: lastUpdated}}})
+			w := Watchlists{WDB: tt.wdb, ASvc: a, History: stubHistory{"BTC": 40000}, Clock: clock.NewFake(testNow)}
+			got, err := w.GetByUsername("user", tt.publicOnly)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Watchlists.GetByUsername() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Watchlists.GetByUsername() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchlists_Create(t *testing.T) {
+	assets := []coinapi.Asset{{ID: "BTC", Name: "Bitcoin", PriceUSD: 44000}}
+	tests := []struct {
+		name         string
+		client       coinAPIClient
+		wdb          stubWDB
+		assetIds     []string
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{"ok", stubClient{assets, nil}, stubWDB{}, []string{"BTC"}, false, false},
+		{"empty", stubClient{assets, nil}, stubWDB{}, []string{}, false, false},
+		{"missing asset", stubClient{assets, nil}, stubWDB{}, []string{"BTC", "XYZ"}, true, true},
+		{"cache update error", stubClient{nil, fmt.Errorf("")}, stubWDB{}, []string{"BTC"}, false, true},
+		{"db error", stubClient{assets, nil}, stubWDB{err: fmt.Errorf("")}, []string{"BTC"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Watchlists{WDB: tt.wdb, ASvc: NewAssets(tt.client), Clock: clock.NewFake(testNow)}
+			got, err := w.Create(model.Watchlist{Username: "user", Name: "coins", AssetIds: tt.assetIds})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Watchlists.Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if notFound := errors.As(err, &AssetNotFoundError{}); notFound != tt.wantNotFound {
+				t.Fatalf("Watchlists.Create() not found error = %v, want %v", notFound, tt.wantNotFound)
+			}
+			if err != nil {
+				return
+			}
+			if got.ID == "" || !got.Created.Equal(testNow) || len(got.Assets) != len(tt.assetIds) {
+				t.Errorf("Watchlists.Create() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestWatchlists_Update(t *testing.T) {
+	assets := []coinapi.Asset{{ID: "BTC", Name: "Bitcoin", PriceUSD: 44000}}
+	stored := model.Watchlist{ID: "1", Username: "user", Name: "coins", AssetIds: []string{"BTC"}}
+	tests := []struct {
+		name    string
+		wdb     stubWDB
+		want    bool
+		wantErr bool
+	}{
+		{"ok", stubWDB{watchlists: []model.Watchlist{stored}, exists: true}, true, false},
+		{"not found", stubWDB{}, false, false},
+		{"db error", stubWDB{err: fmt.Errorf("")}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Watchlists{WDB: tt.wdb, ASvc: NewAssets(stubClient{assets, nil}), Clock: clock.NewFake(testNow)}
+			got, err := w.Update(stored)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Watchlists.Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got != nil) != tt.want {
+				t.Fatalf("Watchlists.Update() = %+v, want updated %v", got, tt.want)
+			}
+			if got != nil && (len(got.Assets) != 1 || got.Assets[0].PriceUSD != 44000) {
+				t.Errorf("Watchlists.Update() assets = %+v", got.Assets)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// secret of the RFC 6238 test vectors for SHA1
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// the RFC vectors have 8 digits, 6 digit codes are their last 6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code() at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCode_InvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Errorf("Code() expected error for invalid secret")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	tests := []struct {
+		name     string
+		codeStep int64
+		want     bool
+	}{
+		{"current step", step, true},
+		{"previous step", step - 1, true},
+		{"next step", step + 1, true},
+		{"too old", step - 2, false},
+		{"too new", step + 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := Code(rfcSecret, tt.codeStep)
+			got, ok := Match(rfcSecret, code, now)
+			if ok != tt.want || (ok && got != tt.codeStep) {
+				t.Errorf("Match() = %d, %v, want %d, %v", got, ok, tt.codeStep, tt.want)
+			}
+		})
+	}
+	if _, ok := Match(rfcSecret, "12345", now); ok {
+		t.Errorf("Match() accepted a short code")
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+	other, _ := NewSecret()
+	if len(secret) != 32 || secret == other {
+		t.Errorf("NewSecret() = %s and %s, want different 32 character secrets", secret, other)
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("Code() of new secret error = %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Currency Master", "monika", "ABC")
+	want := "otpauth://totp/Currency%20Master:monika?algorithm=SHA1&digits=6&issuer=Currency+Master&period=30&secret=ABC"
+	if got != want {
+		t.Errorf("URI() = %s, want %s", got, want)
+	}
+}